@@ -0,0 +1,324 @@
+/**
+ * Node.js client for the WhatsApp Chat Mockup API.
+ *
+ *   const { createClient } = require('./client');
+ *   const client = createClient('http://localhost:3000', process.env.WA_MOCK_API_KEY);
+ *   const { image, metadata } = await client.screenshot({ messages, options: { format: 'png' } });
+ *
+ * Requests that fail with a retryable error (the server's error.retryable,
+ * network errors and timeouts) are retried with exponential backoff, honoring
+ * Retry-After. Retries reuse the request ID, so server logs show one request.
+ * Requests with side effects (queuing a job, delivering an output) are only
+ * retried when the server refused them (429, 503) or the connection was never
+ * made, so a timeout can't queue or deliver twice.
+ * Requires Node.js 18+ (global fetch).
+ */
+const crypto = require('crypto');
+
+const RETRYABLE_STATUSES = new Set([408, 429, 502, 503, 504]);
+
+// Statuses of requests the server turned away before doing any work
+const REFUSED_STATUSES = new Set([429, 503]);
+
+// Connection failures before the request was sent
+const CONNECT_ERRORS = new Set(['ECONNREFUSED', 'ENOTFOUND', 'EAI_AGAIN']);
+
+/**
+ * Error returned by the API, or a network failure (statusCode 0)
+ */
+class ClientError extends Error {
+  /**
+   * @param {string} message - Error message
+   * @param {Object} fields - { statusCode, code, stage, retryable, requestId, details }
+   */
+  constructor(message, { statusCode = 0, code = null, stage = null, retryable = false, requestId = null, details } = {}) {
+    super(message);
+    this.name = 'ClientError';
+    this.statusCode = statusCode;
+    this.code = code;
+    this.stage = stage;
+    this.retryable = retryable;
+    this.requestId = requestId;
+    if (details) {
+      this.details = details;
+    }
+  }
+}
+
+/**
+ * Decode a data URL returned in data.image
+ * @param {string} dataUrl - Data URL
+ * @returns {Buffer} Decoded bytes
+ */
+function decodeImage(dataUrl) {
+  const match = /^data:[^;,]+;base64,(.*)$/s.exec(dataUrl || '');
+  if (!match) {
+    throw new TypeError('Not a base64 data URL');
+  }
+  return Buffer.from(match[1], 'base64');
+}
+
+/**
+ * Sleep, aborting early with the signal
+ * @param {number} ms - Milliseconds
+ * @param {AbortSignal} [signal] - Abort signal
+ * @returns {Promise<void>}
+ */
+function sleep(ms, signal) {
+  return new Promise((resolve, reject) => {
+    const timer = setTimeout(resolve, ms);
+    if (signal) {
+      signal.addEventListener('abort', () => {
+        clearTimeout(timer);
+        reject(signal.reason);
+      }, { once: true });
+    }
+  });
+}
+
+class WaMockClient {
+  /**
+   * @param {string} baseUrl - Server URL, e.g. http://localhost:3000
+   * @param {string} [apiKey] - API key, sent as X-API-Key
+   * @param {Object} [options] - Client options
+   * @param {number} [options.retries=3] - Retries after the first attempt
+   * @param {number} [options.backoffMs=500] - First retry delay, doubled per retry
+   * @param {number} [options.maxBackoffMs=10000] - Upper bound for retry delays
+   * @param {number} [options.timeoutMs=120000] - Timeout per attempt
+   */
+  constructor(baseUrl, apiKey, { retries = 3, backoffMs = 500, maxBackoffMs = 10000, timeoutMs = 120000 } = {}) {
+    this.baseUrl = baseUrl.replace(/\/$/, '');
+    this.apiKey = apiKey || null;
+    this.retries = retries;
+    this.backoffMs = backoffMs;
+    this.maxBackoffMs = maxBackoffMs;
+    this.timeoutMs = timeoutMs;
+  }
+
+  /**
+   * Send a request, retrying retryable failures
+   * @param {string} method - HTTP method
+   * @param {string} path - Path below the base URL
+   * @param {Object} [body] - JSON body
+   * @param {Object} [options] - { signal, requestId, raw, idempotent }; raw returns { buffer, headers } instead
+   *   of parsed JSON. idempotent (GET by default) allows retries after the request may have reached the server
+   * @returns {Promise<Object>} Response body's data, or { buffer, headers } with raw
+   */
+  async request(method, path, body, { signal, requestId = crypto.randomUUID(), raw = false, idempotent = method === 'GET' } = {}) {
+    const headers = { 'X-Request-Id': requestId };
+    if (body !== undefined) {
+      headers['Content-Type'] = 'application/json';
+    }
+    if (this.apiKey) {
+      headers['X-API-Key'] = this.apiKey;
+    }
+
+    for (let attempt = 0; ; attempt += 1) {
+      let error;
+      let retryAfterMs = null;
+      try {
+        const timeout = AbortSignal.timeout(this.timeoutMs);
+        const response = await fetch(`${this.baseUrl}${path}`, {
+          method,
+          headers,
+          body: body === undefined ? undefined : JSON.stringify(body),
+          signal: signal && AbortSignal.any ? AbortSignal.any([signal, timeout]) : signal || timeout
+        });
+
+        if (response.ok) {
+          if (raw) {
+            return { buffer: Buffer.from(await response.arrayBuffer()), headers: response.headers };
+          }
+          return (await response.json()).data;
+        }
+
+        const payload = await response.json().catch(() => null);
+        const fields = (payload && payload.error) || {};
+        const retryable = typeof fields.retryable === 'boolean' ? fields.retryable : RETRYABLE_STATUSES.has(response.status);
+        error = new ClientError(fields.message || `HTTP ${response.status}`, {
+          ...fields,
+          statusCode: response.status,
+          retryable: retryable && (idempotent || REFUSED_STATUSES.has(response.status)),
+          requestId: fields.requestId || requestId
+        });
+        const retryAfter = parseInt(response.headers.get('retry-after'), 10);
+        retryAfterMs = Number.isNaN(retryAfter) ? null : retryAfter * 1000;
+      } catch (cause) {
+        if (signal && signal.aborted) {
+          throw cause;
+        }
+        error = new ClientError(cause.name === 'TimeoutError' ? 'Request timed out' : cause.message, {
+          code: cause.name === 'TimeoutError' ? 'client_timeout' : 'network_error',
+          retryable: idempotent || Boolean(cause.cause && CONNECT_ERRORS.has(cause.cause.code)),
+          requestId
+        });
+        error.cause = cause;
+      }
+
+      if (!error.retryable || attempt >= this.retries) {
+        throw error;
+      }
+      const backoff = Math.min(this.maxBackoffMs, this.backoffMs * (2 ** attempt));
+      await sleep(retryAfterMs !== null ? retryAfterMs : backoff / 2 + Math.random() * (backoff / 2), signal);
+    }
+  }
+
+  /**
+   * Render a chat screenshot
+   * @param {Object} request - { messages | sources, merge, options }, see POST /api/whatsapp-screenshot
+   * @param {Object} [options] - { signal, requestId }
+   * @returns {Promise<Object>} { image, metadata, ... }
+   */
+  screenshot(request, options) {
+    return this.request('POST', '/api/whatsapp-screenshot', request, { idempotent: !request.delivery, ...options });
+  }
+
+  /**
+   * Queue a chat screenshot as a background job
+   * @param {Object} request - Same as screenshot()
+   * @param {Object} [options] - { signal, requestId }
+   * @returns {Promise<Object>} { id, status, status_url, ... }
+   */
+  screenshotAsync(request, options) {
+    return this.request('POST', '/api/whatsapp-screenshot/async', request, options);
+  }
+
+  /**
+   * Get the status of an async job
+   * @param {string} id - Job ID
+   * @param {Object} [options] - { signal, requestId }
+   * @returns {Promise<Object>} { id, status, result, error, ... }
+   */
+  getJob(id, options) {
+    return this.request('GET', `/api/jobs/${encodeURIComponent(id)}`, undefined, options);
+  }
+
+  /**
+   * Render a finished async job again, e.g. once its result expired
+   * @param {string} id - Job ID
+   * @param {Object} [options] - { signal, requestId }
+   * @returns {Promise<Object>} { id, status, status_url, ... }
+   */
+  rerunJob(id, options) {
+    return this.request('POST', `/api/jobs/${encodeURIComponent(id)}/rerun`, undefined, options);
+  }
+
+  /**
+   * Poll an async job until it finished
+   * @param {string} id - Job ID
+   * @param {Object} [options] - { signal, requestId, intervalMs }
+   * @returns {Promise<Object>} The job's result, like screenshot()
+   */
+  async waitForJob(id, { intervalMs = 1000, ...options } = {}) {
+    for (;;) {
+      const job = await this.getJob(id, options);
+      if (job.status === 'succeeded') {
+        return job.result;
+      }
+      if (job.status === 'failed') {
+        throw new ClientError(job.error.message, { code: job.error.code, retryable: job.error.retryable, requestId: options.requestId });
+      }
+      await sleep(intervalMs, options.signal);
+    }
+  }
+
+  /**
+   * Render up to 20 chats into a ZIP or TAR archive
+   * @param {Object} request - { items, package }
+   * @param {Object} [options] - { signal, requestId }
+   * @returns {Promise<Object>} { buffer, succeeded, failed }
+   */
+  async batch(request, options) {
+    const { buffer, headers } = await this.request('POST', '/api/whatsapp-screenshot/batch', request, { ...options, raw: true });
+    return {
+      buffer,
+      succeeded: parseInt(headers.get('x-batch-succeeded'), 10) || 0,
+      failed: parseInt(headers.get('x-batch-failed'), 10) || 0
+    };
+  }
+
+  /**
+   * Render 2-4 chats side by side
+   * @param {Object} request - { panels, options }
+   * @param {Object} [options] - { signal, requestId }
+   * @returns {Promise<Object>} { image, metadata }
+   */
+  compose(request, options) {
+    return this.request('POST', '/api/whatsapp-screenshot/compose', request, { idempotent: true, ...options });
+  }
+
+  /**
+   * Capture a remote page (requires an API key)
+   * @param {string} url - Page URL
+   * @param {Object} [renderOptions] - See POST /api/render/url
+   * @param {Object} [options] - { signal, requestId }
+   * @returns {Promise<Object>} { image, metadata, ... }
+   */
+  renderUrl(url, renderOptions = {}, options) {
+    return this.request('POST', '/api/render/url', { url, options: renderOptions }, { idempotent: true, ...options });
+  }
+
+  /**
+   * Compute conversation statistics
+   * @param {Object} request - { messages | sources, options }
+   * @param {Object} [options] - { signal, requestId }
+   * @returns {Promise<Object>} Statistics
+   */
+  analyze(request, options) {
+    return this.request('POST', '/api/analyze', request, { idempotent: true, ...options });
+  }
+
+  /**
+   * Merge conversations without rendering
+   * @param {Object} request - { sources, duplicates }
+   * @param {Object} [options] - { signal, requestId }
+   * @returns {Promise<Object>} { messages, report }
+   */
+  merge(request, options) {
+    return this.request('POST', '/api/merge', request, { idempotent: true, ...options });
+  }
+
+  /**
+   * Verify the provenance record of a PNG
+   * @param {Buffer|string} image - PNG bytes or data URL
+   * @param {Object} [options] - { signal, requestId }
+   * @returns {Promise<Object>} { valid, signed, problems, provenance }
+   */
+  verifyProvenance(image, options) {
+    const encoded = Buffer.isBuffer(image) ? image.toString('base64') : image;
+    return this.request('POST', '/api/provenance/verify', { image: encoded }, { idempotent: true, ...options });
+  }
+
+  /**
+   * Download a stored output (OUTPUT_DIR) and check it against its hash
+   * @param {string} sha256 - metadata.sha256 of a render
+   * @param {Object} [options] - { signal, requestId }
+   * @returns {Promise<Object>} { buffer, contentType }
+   */
+  async getFile(sha256, options) {
+    const { buffer, headers } = await this.request('GET', `/api/files/by-hash/${sha256}`, undefined, { ...options, raw: true });
+    const actual = crypto.createHash('sha256').update(buffer).digest('hex');
+    if (actual !== sha256.toLowerCase()) {
+      throw new ClientError(`Downloaded file hashes to ${actual}, expected ${sha256}`, { code: 'integrity_mismatch', retryable: false });
+    }
+    return { buffer, contentType: headers.get('content-type') };
+  }
+}
+
+/**
+ * Create a client
+ * @param {string} baseUrl - Server URL
+ * @param {string} [apiKey] - API key
+ * @param {Object} [options] - See WaMockClient
+ * @returns {WaMockClient} Client
+ */
+function createClient(baseUrl, apiKey, options) {
+  return new WaMockClient(baseUrl, apiKey, options);
+}
+
+module.exports = {
+  createClient,
+  WaMockClient,
+  ClientError,
+  decodeImage
+};
@@ -0,0 +1,91 @@
+#!/usr/bin/env node
+/**
+ * Micro-benchmarks for the formatting and HTML generation pipeline.
+ * --pipeline also runs processChatData and generateChatHTML of the
+ * screenshot service, which needs puppeteer installed (Chrome is not started).
+ *
+ * Usage: node scripts/bench.js [--messages 1000] [--iterations 20] [--pipeline]
+ */
+const { formatContentHTML } = require('../src/utils/content-format');
+const { convertWhatsAppToHTML } = require('../src/utils/whatsapp-html');
+const formatPool = require('../src/utils/format-pool');
+const sampleMessages = require('../sample-messages.json');
+
+const args = process.argv.slice(2);
+const argValue = (name, fallback) => {
+  const index = args.indexOf(`--${name}`);
+  return index >= 0 ? parseInt(args[index + 1], 10) : fallback;
+};
+
+const MESSAGE_COUNT = argValue('messages', 1000);
+const ITERATIONS = argValue('iterations', 20);
+const PIPELINE = args.includes('--pipeline');
+
+/**
+ * Build a payload of the requested size by cycling through the sample messages
+ * @param {number} count - Number of messages
+ * @returns {Array} Messages
+ */
+function buildMessages(count) {
+  return Array.from({ length: count }, (_, index) => ({
+    ...sampleMessages[index % sampleMessages.length],
+    content: `${sampleMessages[index % sampleMessages.length].content} *bold* _italic_ ~strike~ \`\`\`mono\`\`\``
+  }));
+}
+
+/**
+ * Run a function repeatedly and report timing statistics
+ * @param {string} name - Benchmark name
+ * @param {number} iterations - Number of runs
+ * @param {Function} fn - Function under test (may be async)
+ */
+async function bench(name, iterations, fn) {
+  // Warm up
+  await fn();
+
+  const samples = [];
+  for (let i = 0; i < iterations; i += 1) {
+    const start = process.hrtime.bigint();
+    await fn();
+    samples.push(Number(process.hrtime.bigint() - start) / 1e6);
+  }
+
+  samples.sort((a, b) => a - b);
+  const mean = samples.reduce((sum, value) => sum + value, 0) / samples.length;
+  const p95 = samples[Math.min(samples.length - 1, Math.floor(samples.length * 0.95))];
+  console.log(`${name.padEnd(40)} ${iterations} runs  mean ${mean.toFixed(3)}ms  p95 ${p95.toFixed(3)}ms`);
+}
+
+async function main() {
+  const messages = buildMessages(MESSAGE_COUNT);
+  console.log(`Benchmarking with ${MESSAGE_COUNT} messages, ${ITERATIONS} iterations\n`);
+
+  await bench('convertWhatsAppToHTML (all messages)', ITERATIONS, () => {
+    messages.forEach(msg => convertWhatsAppToHTML(msg.content));
+  });
+  await bench('convertWhatsAppToHTML (plain text)', ITERATIONS, () => {
+    messages.forEach(() => convertWhatsAppToHTML('Baik, terima kasih atas konfirmasinya.\nSampai jumpa'));
+  });
+  await bench('formatContentHTML (all messages)', ITERATIONS, () => {
+    messages.forEach(msg => formatContentHTML(msg.content));
+  });
+  await bench('formatAll (all messages)', ITERATIONS, () => formatPool.formatAll(messages, {}));
+
+  if (PIPELINE) {
+    // Loaded on demand: the service pulls in puppeteer
+    const screenshotService = require('../src/services/screenshot.service');
+    const options = { headerDisplay: 'name', width: 400 };
+    const chatData = await screenshotService.prepareChatData(messages, options);
+
+    await bench('processChatData', ITERATIONS, () => screenshotService.processChatData(messages, options));
+    await bench('generateChatHTML', ITERATIONS, () => screenshotService.generateChatHTML(chatData));
+    await screenshotService.closeBrowser();
+  }
+}
+
+main()
+  .catch(error => {
+    console.error('Benchmark failed:', error);
+    process.exitCode = 1;
+  })
+  .finally(() => formatPool.destroy());
@@ -0,0 +1,111 @@
+#!/usr/bin/env node
+/**
+ * Load-test harness: replays a corpus of payloads against a running server.
+ *
+ * Usage:
+ *   node scripts/loadtest.js --url http://localhost:3000/api/whatsapp-screenshot \
+ *     --corpus ./payloads --concurrency 4 --requests 100
+ *
+ * The corpus is a JSON file or a directory of JSON files. A file may contain a
+ * full request body ({ messages, options }) or a bare message array.
+ */
+const fs = require('fs');
+const path = require('path');
+
+const args = process.argv.slice(2);
+const argValue = (name, fallback) => {
+  const index = args.indexOf(`--${name}`);
+  return index >= 0 ? args[index + 1] : fallback;
+};
+
+const URL_TARGET = argValue('url', 'http://localhost:3000/api/whatsapp-screenshot');
+const CORPUS = argValue('corpus', path.join(__dirname, '../sample-messages.json'));
+const CONCURRENCY = parseInt(argValue('concurrency', '4'), 10);
+const TOTAL_REQUESTS = parseInt(argValue('requests', '50'), 10);
+const TIMEOUT_MS = parseInt(argValue('timeout', '60000'), 10);
+
+/**
+ * Load request bodies from a file or directory
+ * @param {string} corpusPath - File or directory path
+ * @returns {Array<Object>} Request bodies
+ */
+function loadCorpus(corpusPath) {
+  const stat = fs.statSync(corpusPath);
+  const files = stat.isDirectory()
+    ? fs.readdirSync(corpusPath).filter(file => file.endsWith('.json')).map(file => path.join(corpusPath, file))
+    : [corpusPath];
+
+  return files.map(file => {
+    const payload = JSON.parse(fs.readFileSync(file, 'utf-8'));
+    return Array.isArray(payload) ? { messages: payload } : payload;
+  });
+}
+
+/**
+ * Percentile of a sorted array
+ * @param {Array<number>} sorted - Sorted samples
+ * @param {number} p - Percentile (0-100)
+ * @returns {number} Value
+ */
+function percentile(sorted, p) {
+  if (sorted.length === 0) {
+    return 0;
+  }
+  return sorted[Math.min(sorted.length - 1, Math.ceil((p / 100) * sorted.length) - 1)];
+}
+
+async function main() {
+  const corpus = loadCorpus(CORPUS);
+  if (corpus.length === 0) {
+    throw new Error(`No payloads found in ${CORPUS}`);
+  }
+
+  console.log(`Replaying ${corpus.length} payload(s) against ${URL_TARGET}`);
+  console.log(`Concurrency ${CONCURRENCY}, ${TOTAL_REQUESTS} requests\n`);
+
+  const latencies = [];
+  const statuses = {};
+  let next = 0;
+  const startedAt = Date.now();
+
+  const worker = async () => {
+    while (next < TOTAL_REQUESTS) {
+      const body = corpus[next % corpus.length];
+      next += 1;
+
+      const start = process.hrtime.bigint();
+      let status;
+      try {
+        const response = await fetch(URL_TARGET, {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify(body),
+          signal: AbortSignal.timeout(TIMEOUT_MS)
+        });
+        await response.arrayBuffer();
+        status = response.status;
+      } catch (error) {
+        status = error.name === 'TimeoutError' ? 'timeout' : 'error';
+      }
+      latencies.push(Number(process.hrtime.bigint() - start) / 1e6);
+      statuses[status] = (statuses[status] || 0) + 1;
+    }
+  };
+
+  await Promise.all(Array.from({ length: CONCURRENCY }, worker));
+
+  const elapsedSeconds = (Date.now() - startedAt) / 1000;
+  const sorted = [...latencies].sort((a, b) => a - b);
+
+  console.log(`Completed ${latencies.length} requests in ${elapsedSeconds.toFixed(2)}s ` +
+    `(${(latencies.length / elapsedSeconds).toFixed(2)} req/s)`);
+  console.log('Status codes:', statuses);
+  console.log('Latency (ms):');
+  [50, 90, 95, 99].forEach(p => console.log(`  p${p}: ${percentile(sorted, p).toFixed(1)}`));
+  console.log(`  max: ${(sorted[sorted.length - 1] || 0).toFixed(1)}`);
+}
+
+main().catch(error => {
+  console.error('Load test failed:', error);
+  process.exitCode = 1;
+});
@@ -0,0 +1,125 @@
+#!/usr/bin/env node
+/**
+ * Replay recorded fixtures (RECORD_FIXTURES_DIR) against a running server and
+ * compare status codes and durations with the recording.
+ *
+ * Usage:
+ *   node scripts/replay.js --url http://localhost:3000 --fixtures ./fixtures \
+ *     [--api-key KEY] [--slower 2] [--out ./replay-output]
+ *
+ * --fixtures is a fixture file or a directory of them. A replay fails when the
+ * status code differs from the recording; it is flagged as slower when it
+ * takes more than --slower times the recorded duration. With --out, response
+ * bodies are saved there for inspection. Exits with 1 when any replay failed.
+ */
+const fs = require('fs');
+const path = require('path');
+
+const args = process.argv.slice(2);
+const argValue = (name, fallback) => {
+  const index = args.indexOf(`--${name}`);
+  return index >= 0 ? args[index + 1] : fallback;
+};
+
+const BASE_URL = argValue('url', 'http://localhost:3000').replace(/\/$/, '');
+const FIXTURES = argValue('fixtures', './fixtures');
+const API_KEY = argValue('api-key', null);
+const SLOWER_FACTOR = parseFloat(argValue('slower', '2'));
+const OUT_DIR = argValue('out', null);
+const TIMEOUT_MS = parseInt(argValue('timeout', '120000'), 10);
+const SUPPORTED_VERSION = 1;
+
+/**
+ * Load fixtures from a file or directory, oldest recording first
+ * @param {string} fixturesPath - File or directory path
+ * @returns {Array<Object>} Fixtures with their file name
+ */
+function loadFixtures(fixturesPath) {
+  const stat = fs.statSync(fixturesPath);
+  const files = stat.isDirectory()
+    ? fs.readdirSync(fixturesPath).filter(file => file.endsWith('.json')).sort().map(file => path.join(fixturesPath, file))
+    : [fixturesPath];
+
+  return files
+    .map(file => ({ file: path.basename(file), ...JSON.parse(fs.readFileSync(file, 'utf-8')) }))
+    .filter(fixture => {
+      if (fixture.fixture_version !== SUPPORTED_VERSION) {
+        console.warn(`Skipping ${fixture.file}: unsupported fixture_version ${fixture.fixture_version}`);
+        return false;
+      }
+      return true;
+    });
+}
+
+/**
+ * Replay one fixture
+ * @param {Object} fixture - Recorded fixture
+ * @returns {Promise<Object>} { status, durationMs, body }
+ */
+async function replay(fixture) {
+  const headers = { 'Content-Type': 'application/json', 'X-Request-Id': `replay-${fixture.request_id || Date.now()}` };
+  if (API_KEY) {
+    headers['X-API-Key'] = API_KEY;
+  }
+
+  const start = process.hrtime.bigint();
+  try {
+    const response = await fetch(`${BASE_URL}${fixture.path}`, {
+      method: fixture.method,
+      headers,
+      body: JSON.stringify(fixture.body),
+      signal: AbortSignal.timeout(TIMEOUT_MS)
+    });
+    const body = Buffer.from(await response.arrayBuffer());
+    return { status: response.status, durationMs: Number(process.hrtime.bigint() - start) / 1e6, body };
+  } catch (error) {
+    return {
+      status: error.name === 'TimeoutError' ? 'timeout' : 'error',
+      durationMs: Number(process.hrtime.bigint() - start) / 1e6,
+      body: Buffer.from(error.message)
+    };
+  }
+}
+
+async function main() {
+  const fixtures = loadFixtures(FIXTURES);
+  if (fixtures.length === 0) {
+    throw new Error(`No fixtures found in ${FIXTURES}`);
+  }
+  if (OUT_DIR) {
+    fs.mkdirSync(OUT_DIR, { recursive: true });
+  }
+
+  console.log(`Replaying ${fixtures.length} fixture(s) against ${BASE_URL}\n`);
+
+  let failed = 0;
+  let slower = 0;
+  for (const fixture of fixtures) {
+    const result = await replay(fixture);
+    const statusChanged = result.status !== fixture.status;
+    const isSlower = fixture.duration_ms > 0 && result.durationMs > fixture.duration_ms * SLOWER_FACTOR;
+    failed += statusChanged ? 1 : 0;
+    slower += isSlower ? 1 : 0;
+
+    const verdict = statusChanged ? 'FAIL' : isSlower ? 'SLOW' : 'ok';
+    console.log(`${verdict.padEnd(5)} ${fixture.file.padEnd(60)} ${fixture.method} ${fixture.path}  ` +
+      `status ${fixture.status} -> ${result.status}  ` +
+      `${fixture.duration_ms}ms -> ${result.durationMs.toFixed(0)}ms`);
+    if (statusChanged && result.body.length > 0 && result.body.length < 4096) {
+      console.log(`      ${result.body.toString('utf-8')}`);
+    }
+    if (OUT_DIR) {
+      fs.writeFileSync(path.join(OUT_DIR, `${path.basename(fixture.file, '.json')}.response`), result.body);
+    }
+  }
+
+  console.log(`\n${fixtures.length - failed} passed, ${failed} failed, ${slower} slower than ${SLOWER_FACTOR}x`);
+  if (failed > 0) {
+    process.exitCode = 1;
+  }
+}
+
+main().catch(error => {
+  console.error('Replay failed:', error);
+  process.exitCode = 1;
+});
@@ -1,33 +1,62 @@
 require('dotenv').config();
-const express = require('express');
-const helmet = require('helmet');
-const cors = require('cors');
-const { errorHandler } = require('./src/middleware/error.middleware');
-const screenshotRoutes = require('./src/routes/screenshot.routes');
+const { createApp } = require('./src/app');
+const { loadTemplateFunctionsFromConfig } = require('./src/utils/template-engine');
+const screenshotService = require('./src/services/screenshot.service');
+const storageService = require('./src/services/storage.service');
+const configService = require('./src/services/config.service');
+const { reloadConfig } = require('./src/utils/config-reload');
+const { logger } = require('./src/utils/logger');
+const { reportError } = require('./src/utils/error-reporter');
 
-const app = express();
+// Register custom template helpers before any template is rendered
+loadTemplateFunctionsFromConfig();
+
+const app = createApp();
 const PORT = process.env.PORT || 3000;
 
-// Middleware
-app.use(helmet());
-app.use(cors());
-app.use(express.json({ limit: '10mb' }));
-app.use(express.urlencoded({ extended: true, limit: '10mb' }));
+// Start server
+const server = app.listen(PORT, () => {
+  console.log(`Server is running on port ${PORT}`);
+  // Startup banner: the effective configuration, so misconfigured
+  // deployments show up in the first log lines
+  configService.getSummary()
+    .then(summary => logger.info('Configuration', summary))
+    .catch(error => logger.warn('Failed to summarize the configuration', { error }));
+});
 
-// Routes
-app.use('/api', screenshotRoutes);
+// Apply the output retention policy when persistence is enabled (OUTPUT_DIR)
+storageService.startGarbageCollector();
 
-// Health check endpoint
-app.get('/health', (req, res) => {
-  res.status(200).json({ status: 'ok', timestamp: new Date().toISOString() });
+// Graceful shutdown: fail readiness, let in-flight renders finish, then exit
+const shutdown = async (signal) => {
+  logger.info('Shutdown signal received, draining', { signal });
+  storageService.stopGarbageCollector();
+  await screenshotService.drain(parseInt(process.env.SHUTDOWN_GRACE_MS, 10) || 30000);
+  server.close(() => process.exit(0));
+};
+process.once('SIGTERM', () => shutdown('SIGTERM'));
+// Reload the safe-to-change configuration from .env, keeping the warm browser
+process.on('SIGHUP', () => {
+  try {
+    reloadConfig();
+  } catch (error) {
+    logger.error('Failed to reload the configuration', { error });
+  }
 });
+process.once('SIGINT', () => shutdown('SIGINT'));
 
-// Error handling middleware
-app.use(errorHandler);
-
-// Start server
-app.listen(PORT, () => {
-  console.log(`Server is running on port ${PORT}`);
+// Crashes outside a request: log, report, and for uncaught exceptions exit
+// (the process state is unknown) after closing the browser
+process.on('unhandledRejection', (reason) => {
+  const error = reason instanceof Error ? reason : new Error(String(reason));
+  logger.error('Unhandled promise rejection', { error });
+  reportError(error, { source: 'unhandledRejection' });
+});
+process.on('uncaughtException', async (error) => {
+  logger.error('Uncaught exception', { error });
+  await reportError(error, { source: 'uncaughtException' });
+  await screenshotService.closeBrowser().catch(() => {});
+  process.exit(1);
 });
 
 module.exports = app;
@@ -0,0 +1,41 @@
+const express = require('express');
+const helmet = require('helmet');
+const cors = require('cors');
+const { errorHandler } = require('./middleware/error.middleware');
+const { createRequestId } = require('./middleware/request-id.middleware');
+const { slowRequestLogger } = require('./middleware/slow-request.middleware');
+const { fixtureRecorder } = require('./middleware/fixture-recorder.middleware');
+const { createRouter } = require('./routes');
+
+/**
+ * Build the Express application: middleware, routes and error handling,
+ * without listening. server.js serves it; tests can serve their own copy.
+ * @param {Object} [services] - Service overrides passed to createRouter; idGenerator
+ *   ({ next() }) also names the requests
+ * @returns {Object} Express application
+ */
+const createApp = (services = {}) => {
+  const app = express();
+
+  // Middleware
+  app.use(createRequestId(services));
+  app.use(slowRequestLogger);
+  app.use(helmet());
+  app.use(cors());
+  app.use(express.json({ limit: '10mb' }));
+  app.use(express.urlencoded({ extended: true, limit: '10mb' }));
+  // Debug mode: record requests as replayable fixtures (RECORD_FIXTURES_DIR)
+  app.use(fixtureRecorder);
+
+  // Routes (see src/routes/index.js for the route groups)
+  app.use(createRouter(services));
+
+  // Error handling middleware
+  app.use(errorHandler);
+
+  return app;
+};
+
+module.exports = {
+  createApp
+};
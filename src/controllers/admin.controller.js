@@ -0,0 +1,99 @@
+const defaultScreenshotService = require('../services/screenshot.service');
+const defaultConfigService = require('../services/config.service');
+const { reloadConfig } = require('../utils/config-reload');
+
+/**
+ * Create the admin handlers. Services default to the shared singletons;
+ * callers such as tests can pass their own implementations.
+ * @param {Object} [services] - { screenshotService, configService }
+ * @returns {Object} Route handlers
+ */
+const createAdminController = ({
+  screenshotService = defaultScreenshotService,
+  configService = defaultConfigService
+} = {}) => {
+  /**
+   * Get the browser state
+   * @route GET /api/admin/browser
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const getBrowserStatus = (req, res, next) => {
+    try {
+      res.status(200).json({
+        success: true,
+        data: screenshotService.getBrowserStatus()
+      });
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  /**
+   * Restart the browser. In-flight renders finish on the old instance.
+   * @route POST /api/admin/browser/recycle
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const recycleBrowser = async (req, res, next) => {
+    try {
+      await screenshotService.recycleBrowser(req.log);
+
+      res.status(200).json({
+        success: true,
+        data: screenshotService.getBrowserStatus()
+      });
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  /**
+   * Get the effective configuration, with secrets redacted
+   * @route GET /api/admin/config
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const getConfig = async (req, res, next) => {
+    try {
+      res.status(200).json({
+        success: true,
+        data: await configService.getSummary()
+      });
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  /**
+   * Re-read the .env file and apply the variables that are safe to change
+   * @route POST /api/admin/reload
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const reload = (req, res, next) => {
+    try {
+      res.status(200).json({
+        success: true,
+        data: reloadConfig()
+      });
+    } catch (error) {
+      next(error);
+    }
+  };
+  return {
+    getBrowserStatus,
+    recycleBrowser,
+    getConfig,
+    reload
+  };
+};
+
+module.exports = {
+  ...createAdminController(),
+  createAdminController
+};
@@ -0,0 +1,74 @@
+const defaultScreenshotService = require('../services/screenshot.service');
+const { computeChatStats } = require('../utils/chat-stats');
+const { mergeConversations, resolveRequestMessages } = require('../utils/chat-merge');
+const { systemClock } = require('../utils/clock');
+
+/**
+ * Create the analysis handlers. Services default to the shared singletons;
+ * callers such as tests can pass their own implementations.
+ * @param {Object} [services] - { screenshotService, clock }
+ * @returns {Object} Route handlers
+ */
+const createAnalysisController = ({
+  screenshotService = defaultScreenshotService,
+  clock = systemClock
+} = {}) => {
+  /**
+   * Compute conversation statistics without rendering
+   * @route POST /api/analyze
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const analyzeChat = async (req, res, next) => {
+    try {
+      const { options = {} } = req.body;
+      const { messages } = resolveRequestMessages(req.body);
+      const context = { log: req.log, timings: req.timings, clock };
+
+      // Same processing as a render, so anonymization and masking apply
+      const chatData = await screenshotService.prepareChatData(messages, options, context);
+
+      res.status(200).json({
+        success: true,
+        data: computeChatStats(chatData)
+      });
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  /**
+   * Merge several message arrays into one chronologically sorted conversation
+   * @route POST /api/merge
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const mergeChats = (req, res, next) => {
+    try {
+      const { sources, merge } = req.body;
+      const { messages, report } = mergeConversations(sources, merge);
+
+      res.status(200).json({
+        success: true,
+        data: {
+          messages,
+          report
+        }
+      });
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  return {
+    analyzeChat,
+    mergeChats
+  };
+};
+
+module.exports = {
+  ...createAnalysisController(),
+  createAnalysisController
+};
@@ -0,0 +1,57 @@
+const defaultStorageService = require('../services/storage.service');
+const { ApiError } = require('../middleware/error.middleware');
+
+/**
+ * Create the stored output handlers
+ * @param {Object} [services] - { storageService }, the shared singleton by default
+ * @returns {Object} Route handlers
+ */
+const createFilesController = ({ storageService = defaultStorageService } = {}) => {
+  /**
+   * Download a stored output by its SHA-256 content hash. The hash is returned
+   * as ETag and Digest so clients can verify the bytes they received.
+   * @route GET /api/files/by-hash/:sha256
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const getFileByHash = async (req, res, next) => {
+    try {
+      if (!storageService.isEnabled()) {
+        throw new ApiError(404, 'Output persistence is not enabled').annotate({ stage: 'deliver', code: 'storage_disabled' });
+      }
+
+      const artifact = await storageService.findByHash(req.params.sha256);
+      if (!artifact) {
+        throw new ApiError(404, `No stored file with hash ${req.params.sha256}`).annotate({ stage: 'deliver', code: 'file_not_found' });
+      }
+
+      const etag = `"${artifact.sha256}"`;
+      res.set({
+        ETag: etag,
+        'Cache-Control': 'public, max-age=31536000, immutable'
+      });
+      if (req.get('If-None-Match') === etag) {
+        res.status(304).end();
+        return;
+      }
+
+      const buffer = await storageService.read(artifact);
+      res.set({
+        'Content-Type': artifact.contentType,
+        'Content-Disposition': `inline; filename="${artifact.sha256}.${artifact.extension}"`,
+        Digest: `sha-256=${Buffer.from(artifact.sha256, 'hex').toString('base64')}`
+      });
+      res.status(200).send(buffer);
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  return { getFileByHash };
+};
+
+module.exports = {
+  ...createFilesController(),
+  createFilesController
+};
@@ -0,0 +1,83 @@
+const defaultScreenshotService = require('../services/screenshot.service');
+const { ApiError } = require('../middleware/error.middleware');
+const { buildFileName } = require('../utils/file-name');
+const { decodeDataUrl } = require('../utils/packaging');
+const { resolveRequestMessages } = require('../utils/chat-merge');
+const { ANIMATION_FORMATS } = require('../utils/animation');
+const { systemClock } = require('../utils/clock');
+const defaultDeliveryService = require('../services/delivery.service');
+
+/**
+ * Create the integration handlers. Services default to the shared singletons;
+ * callers such as tests can pass their own implementations.
+ * @param {Object} [services] - { screenshotService, deliveryService, clock }
+ * @returns {Object} Route handlers
+ */
+const createIntegrationController = ({
+  screenshotService = defaultScreenshotService,
+  deliveryService = defaultDeliveryService,
+  clock = systemClock
+} = {}) => {
+  /**
+   * Render a chat screenshot and post it to a Slack channel
+   * @route POST /api/integrations/slack
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const shareToSlack = async (req, res, next) => {
+    try {
+      const { options = {}, slack } = req.body;
+      const { messages } = resolveRequestMessages(req.body);
+
+      if (options.debugData === 'only') {
+        throw new ApiError(400, 'debugData "only" renders no image to share').annotate({ stage: 'validate', code: 'image_required' });
+      }
+
+      const slackTarget = await deliveryService.resolveTarget('slack', slack, req.apiKey);
+      const context = { log: req.log, warnings: [], apiKey: req.apiKey, timings: req.timings, clock };
+      const chatData = await screenshotService.prepareChatData(messages, options, context);
+      const imageData = await screenshotService.captureChatScreenshot(chatData, options, context);
+      const extension = options.animation
+        ? ANIMATION_FORMATS[options.animation.format].extension
+        : options.format || 'png';
+      const fileName = buildFileName(options.outputFileName, {
+        chatName: chatData.chatName,
+        format: extension,
+        messages,
+        options,
+        requestId: req.id,
+        now: clock.now()
+      });
+
+      const delivery = await deliveryService.deliver({
+        buffer: decodeDataUrl(imageData),
+        contentType: /^data:([^;,]+)/.exec(imageData)[1],
+        fileName
+      }, slackTarget, { requestId: req.id, log: req.log });
+      req.log.info('Shared screenshot to Slack', { channel: delivery.channel, fileId: delivery.file_id });
+
+      res.status(200).json({
+        success: true,
+        data: {
+          slack: delivery,
+          metadata: {
+            file_name: fileName,
+            message_count: chatData.messages.length,
+            generated_at: clock.now().toISOString()
+          }
+        }
+      });
+    } catch (error) {
+      next(error);
+    }
+  };
+  return {
+    shareToSlack
+  };
+};
+
+module.exports = {
+  ...createIntegrationController(),
+  createIntegrationController
+};
@@ -0,0 +1,148 @@
+const defaultJobService = require('../services/job.service');
+const { ApiError } = require('../middleware/error.middleware');
+const { decodeDataUrl } = require('../utils/packaging');
+
+/**
+ * Create the async job handlers
+ * @param {Object} [services] - { jobService }, the shared singleton by default
+ * @returns {Object} Route handlers
+ */
+const createJobController = ({ jobService = defaultJobService } = {}) => {
+  /**
+   * Get the status of an async render job. Once it succeeded the response
+   * holds the same data as a synchronous render.
+   * @route GET /api/jobs/:id
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const getJob = (req, res, next) => {
+    try {
+      const job = jobService.get(req.params.id, req.apiKey);
+      res.status(200).json({
+        success: true,
+        data: {
+          ...jobService.describe(job),
+          result_url: `/api/jobs/${job.id}/result`,
+          ...(job.status === 'succeeded' && { result: job.result })
+        }
+      });
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  /**
+   * Download the image of a finished job
+   * @route GET /api/jobs/:id/result
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const getJobResult = (req, res, next) => {
+    try {
+      const job = jobService.get(req.params.id, req.apiKey);
+      if (job.status === 'queued' || job.status === 'running') {
+        const error = new ApiError(409, `Job is ${job.status}`).annotate({ stage: 'deliver', code: 'job_not_finished', retryable: true });
+        error.retryAfter = 2;
+        throw error;
+      }
+      if (job.status === 'failed') {
+        throw new ApiError(409, `Job failed: ${job.error.message}`).annotate({ stage: 'deliver', code: 'job_failed' });
+      }
+      if (!job.result.image) {
+        throw new ApiError(404, 'Job rendered no image (debugData "only")').annotate({ stage: 'deliver', code: 'job_result_empty' });
+      }
+
+      res.set({
+        'Content-Type': /^data:([^;,]+)/.exec(job.result.image)[1],
+        'Content-Disposition': `inline; filename="${job.result.metadata.file_name}"`
+      });
+      res.status(200).send(decodeDataUrl(job.result.image));
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  /**
+   * Follow a job as server-sent events: a "status" event right away, a
+   * "progress" event for every stage the render enters, and a final "status"
+   * event once the job succeeded or failed. The stream then closes; the result
+   * itself is fetched from the job or result URL.
+   * @route GET /api/jobs/:id/events
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const getJobEvents = (req, res, next) => {
+    try {
+      const job = jobService.get(req.params.id, req.apiKey);
+      const send = (event, data) => res.write(`event: ${event}\ndata: ${JSON.stringify(data)}\n\n`);
+
+      res.status(200).set({
+        'Content-Type': 'text/event-stream',
+        'Cache-Control': 'no-store',
+        'X-Accel-Buffering': 'no'
+      });
+      res.flushHeaders();
+      send('status', jobService.describe(job));
+      if (job.status === 'succeeded' || job.status === 'failed') {
+        res.end();
+        return;
+      }
+
+      const onProgress = (updated) => {
+        if (updated === job) {
+          send('progress', { id: job.id, ...job.progress });
+        }
+      };
+      const onFinished = (finished) => {
+        if (finished === job) {
+          send('status', { ...jobService.describe(job), result_url: `/api/jobs/${job.id}/result` });
+          res.end();
+        }
+      };
+      const unsubscribe = () => {
+        jobService.removeListener('progress', onProgress);
+        jobService.removeListener('finished', onFinished);
+      };
+      jobService.on('progress', onProgress);
+      jobService.on('finished', onFinished);
+      res.on('close', unsubscribe);
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  /**
+   * Render a finished job's stored payload again under the same ID, e.g. once
+   * its result expired. Delivery targets are not sent to again.
+   * @route POST /api/jobs/:id/rerun
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const rerunJob = (req, res, next) => {
+    try {
+      const job = jobService.rerun(req.params.id, req.apiKey);
+      const statusUrl = `/api/jobs/${job.id}`;
+
+      res.set('Location', statusUrl);
+      res.status(202).json({ success: true, data: { ...job, status_url: statusUrl } });
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  return {
+    getJob,
+    getJobResult,
+    getJobEvents,
+    rerunJob
+  };
+};
+
+module.exports = {
+  ...createJobController(),
+  createJobController
+};
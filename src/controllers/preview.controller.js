@@ -0,0 +1,95 @@
+const defaultScreenshotService = require('../services/screenshot.service');
+const defaultPreviewService = require('../services/preview.service');
+const { resolveRequestMessages } = require('../utils/chat-merge');
+const { resolveWatermarks, watermarksHTML } = require('../utils/watermark');
+const { messageAnchorId } = require('../utils/message-anchor');
+const { systemClock, randomIdGenerator } = require('../utils/clock');
+
+// Previews are chat documents from request data; they may show styles and
+// data: images but never run scripts or load anything
+const PREVIEW_CSP = "default-src 'none'; style-src 'unsafe-inline'; img-src data:; font-src data:; sandbox";
+
+/**
+ * Create the preview handlers. Services default to the shared singletons;
+ * callers such as tests can pass their own implementations.
+ * @param {Object} [services] - { screenshotService, previewService, clock, idGenerator };
+ *   idGenerator ({ next() }) names the previews, random UUIDs by default
+ * @returns {Object} Route handlers
+ */
+const createPreviewController = ({
+  screenshotService = defaultScreenshotService,
+  previewService = defaultPreviewService,
+  clock = systemClock,
+  idGenerator = randomIdGenerator
+} = {}) => {
+  /**
+   * Render a chat to HTML and store it as a preview that can be opened in a
+   * browser, with #msg-<id> deep links to messages
+   * @route POST /api/previews
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const createPreview = async (req, res, next) => {
+    try {
+      const { options = {} } = req.body;
+      const { messages } = resolveRequestMessages(req.body);
+      const context = { log: req.log, warnings: [], apiKey: req.apiKey, timings: req.timings, clock };
+
+      const chatData = await screenshotService.prepareChatData(messages, options, context);
+      const html = await screenshotService.generateChatHTML(chatData, context);
+      // Enforced watermarks apply to previews like to any other output
+      const watermarks = watermarksHTML(resolveWatermarks(options.watermark, req.apiKey));
+      const { id, expiresAt } = previewService.create(html.includes('</body>')
+        ? html.replace('</body>', `${watermarks}</body>`)
+        : `${html}${watermarks}`, { id: idGenerator.next(), now: clock.now() });
+
+      const url = `/api/previews/${id}`;
+      res.status(201).json({
+        success: true,
+        data: {
+          id,
+          url,
+          expires_at: expiresAt.toISOString(),
+          anchors: Object.fromEntries(chatData.messages
+            .filter(msg => msg.id !== undefined)
+            .map(msg => [msg.id, `${url}#${messageAnchorId(msg.id)}`]))
+        }
+      });
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  /**
+   * Serve a stored preview
+   * @route GET /api/previews/:id
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const getPreview = (req, res, next) => {
+    try {
+      const html = previewService.get(req.params.id);
+      res.set({
+        'Content-Type': 'text/html; charset=utf-8',
+        'Content-Security-Policy': PREVIEW_CSP,
+        'Cache-Control': 'private, no-store',
+        'Referrer-Policy': 'no-referrer',
+        'X-Robots-Tag': 'noindex'
+      });
+      res.status(200).send(html);
+    } catch (error) {
+      next(error);
+    }
+  };
+  return {
+    createPreview,
+    getPreview
+  };
+};
+
+module.exports = {
+  ...createPreviewController(),
+  createPreviewController
+};
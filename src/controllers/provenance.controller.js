@@ -0,0 +1,27 @@
+const { verifyProvenance } = require('../utils/provenance');
+const { decodeDataUrl } = require('../utils/packaging');
+
+/**
+ * Check an image against its embedded provenance record
+ * @route POST /api/provenance/verify
+ * @param {Object} req - Express request object
+ * @param {Object} res - Express response object
+ * @param {Function} next - Next middleware function
+ */
+const verifyImage = (req, res, next) => {
+  try {
+    const { image } = req.body;
+    const png = image.startsWith('data:') ? decodeDataUrl(image) : Buffer.from(image, 'base64');
+
+    res.status(200).json({
+      success: true,
+      data: verifyProvenance(png)
+    });
+  } catch (error) {
+    next(error);
+  }
+};
+
+module.exports = {
+  verifyImage
+};
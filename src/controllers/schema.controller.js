@@ -0,0 +1,86 @@
+const { ApiError } = require('../middleware/error.middleware');
+const validation = require('../middleware/validation.middleware');
+const { buildSchemaDocument, toTypeScript } = require('../utils/json-schema');
+
+// Published request models and the schemas the endpoints validate them with
+const REQUEST_MODELS = {
+  ScreenshotRequest: validation.screenshotRequestSchema,
+  AnalyzeRequest: validation.requestSchema,
+  RequestMessage: validation.messageSchema,
+  ScreenshotOptions: validation.optionsSchema,
+  BatchRequest: validation.batchSchema,
+  CompositionRequest: validation.compositionSchema,
+  MergeRequest: validation.mergeSchema,
+  UrlRenderRequest: validation.urlRenderSchema,
+  ProvenanceVerifyRequest: validation.provenanceVerifySchema,
+  SimpleRequest: validation.simpleSchema,
+  SlackShareRequest: validation.slackShareSchema,
+  TemplateUploadRequest: validation.templateUploadSchema
+};
+
+// The schemas don't change at runtime, so the documents are built once
+let cachedDocument = null;
+let cachedTypeScript = null;
+
+const getSchemaDocument = () => {
+  if (!cachedDocument) {
+    cachedDocument = buildSchemaDocument(REQUEST_MODELS);
+  }
+  return cachedDocument;
+};
+
+/**
+ * JSON Schema (or TypeScript declarations with ?format=typescript) for every
+ * request model
+ * @route GET /api/schema
+ * @param {Object} req - Express request object
+ * @param {Object} res - Express response object
+ * @param {Function} next - Next middleware function
+ */
+const getSchema = (req, res, next) => {
+  try {
+    const { format = 'json' } = req.query;
+    if (format === 'typescript') {
+      if (!cachedTypeScript) {
+        cachedTypeScript = toTypeScript(getSchemaDocument());
+      }
+      res.set('Content-Type', 'application/typescript; charset=utf-8');
+      res.status(200).send(cachedTypeScript);
+      return;
+    }
+    if (format !== 'json') {
+      throw new ApiError(400, 'format must be json or typescript').annotate({ stage: 'validate', code: 'invalid_format' });
+    }
+    res.set('Content-Type', 'application/schema+json');
+    res.status(200).send(JSON.stringify(getSchemaDocument(), null, 2));
+  } catch (error) {
+    next(error);
+  }
+};
+
+/**
+ * JSON Schema for one request model
+ * @route GET /api/schema/:model
+ * @param {Object} req - Express request object
+ * @param {Object} res - Express response object
+ * @param {Function} next - Next middleware function
+ */
+const getModelSchema = (req, res, next) => {
+  try {
+    const document = getSchemaDocument();
+    const model = document.$defs[req.params.model];
+    if (!model) {
+      throw new ApiError(404, `Unknown model: ${req.params.model}. Available: ${Object.keys(document.$defs).join(', ')}`)
+        .annotate({ stage: 'validate', code: 'model_not_found' });
+    }
+    res.set('Content-Type', 'application/schema+json');
+    res.status(200).send(JSON.stringify({ $schema: document.$schema, ...model }, null, 2));
+  } catch (error) {
+    next(error);
+  }
+};
+
+module.exports = {
+  getSchema,
+  getModelSchema
+};
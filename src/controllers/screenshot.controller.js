@@ -1,51 +1,546 @@
-const screenshotService = require('../services/screenshot.service');
+const defaultScreenshotService = require('../services/screenshot.service');
+const defaultStorageService = require('../services/storage.service');
 const { ApiError } = require('../middleware/error.middleware');
+const { resolveAnonymizeSettings } = require('../utils/anonymize');
+const { buildFileName } = require('../utils/file-name');
+const { buildPackage, createPackageStream, decodeDataUrl } = require('../utils/packaging');
+const { resolveRequestMessages } = require('../utils/chat-merge');
+const { ANIMATION_FORMATS } = require('../utils/animation');
+const { hashPayload, embedProvenance } = require('../utils/provenance');
+const { parseSimpleChat } = require('../utils/simple-chat');
+const { measureBubbles } = require('../utils/bubble-metrics');
+const { expandMailMerge } = require('../utils/mail-merge');
+const { systemClock } = require('../utils/clock');
+const defaultDeliveryService = require('../services/delivery.service');
+const defaultJobService = require('../services/job.service');
+const { optionsSchema, requestSchema } = require('../middleware/validation.middleware');
 
 /**
- * Generate a WhatsApp chat screenshot
- * @route POST /api/whatsapp-screenshot
- * @param {Object} req - Express request object
- * @param {Object} res - Express response object
- * @param {Function} next - Next middleware function
+ * File extension of the output a render produces
+ * @param {Object} options - Screenshot options
+ * @returns {string} Extension
  */
-const generateScreenshot = async (req, res, next) => {
-  try {
-    const { messages, options = {} } = req.body;
+const getOutputExtension = (options) => (options.animation
+  ? ANIMATION_FORMATS[options.animation.format].extension
+  : options.format || 'png');
+
+/**
+ * Embed a provenance record into a PNG data URL. Other outputs are returned
+ * unchanged with a null record.
+ * @param {string} imageData - Data URL
+ * @param {Object} payload - { messages, options } the output was rendered from
+ * @param {string} requestId - Request ID
+ * @param {Date} renderedAt - Render time recorded in the provenance
+ * @returns {Object} { image, provenance }
+ */
+const withProvenance = (imageData, { messages, options }, requestId, renderedAt) => {
+  if (!imageData || !imageData.startsWith('data:image/png;')) {
+    return { image: imageData, provenance: null };
+  }
+  const { png, record } = embedProvenance(decodeDataUrl(imageData), {
+    payloadHash: hashPayload(messages, options),
+    requestId,
+    renderedAt: renderedAt.toISOString()
+  });
+  return { image: `data:image/png;base64,${png.toString('base64')}`, provenance: record };
+};
+
+/**
+ * Create the screenshot handlers. Services default to the shared singletons;
+ * callers such as tests can pass their own implementations.
+ * @param {Object} [services] - { screenshotService, storageService, deliveryService, jobService, clock };
+ *   clock ({ now() }) is the time renders, file names and metadata use
+ * @returns {Object} Route handlers
+ */
+const createScreenshotController = ({
+  screenshotService = defaultScreenshotService,
+  storageService = defaultStorageService,
+  deliveryService = defaultDeliveryService,
+  jobService = defaultJobService,
+  clock = systemClock
+} = {}) => {
+  /**
+   * Store an output under its content hash when persistence is enabled
+   * @param {string} imageData - Data URL
+   * @param {string} extension - File extension of the output
+   * @param {string} [apiKey] - Caller's API key; outputs count against its retention quota
+   * @returns {Promise<Object>} Metadata fields { sha256, file_url }, empty when not stored
+   */
+  const persistOutput = async (imageData, extension, apiKey) => {
+    if (!imageData || !storageService.isEnabled()) {
+      return {};
+    }
+    const { sha256 } = await storageService.store(decodeDataUrl(imageData), extension, apiKey);
+    return { sha256, file_url: `/api/files/by-hash/${sha256}` };
+  };
+
+  /**
+   * Check a screenshot request before rendering. Delivery targets are
+   * resolved here, so a misconfigured target fails the request without a
+   * wasted render.
+   * @param {Object} body - Validated request body
+   * @param {string} [apiKey] - Caller's API key
+   * @returns {Promise<Object>} { messages, mergeReport, targets }
+   */
+  const checkScreenshotRequest = async (body, apiKey) => {
+    const { options = {}, delivery } = body;
+    const { messages, mergeReport } = resolveRequestMessages(body);
 
     if (!messages || !Array.isArray(messages) || messages.length === 0) {
-      throw new ApiError(400, 'At least one message is required');
+      throw new ApiError(400, 'At least one message is required').annotate({ stage: 'validate', code: 'messages_required' });
     }
+    if (delivery && options.debugData === 'only') {
+      throw new ApiError(400, 'debugData "only" renders no image to deliver').annotate({ stage: 'validate', code: 'image_required' });
+    }
+    const targets = options.debugData === 'only' ? [] : await deliveryService.resolveTargets(delivery, apiKey);
+    return { messages, mergeReport, targets };
+  };
+
+  /**
+   * Render a checked screenshot request
+   * @param {Object} body - Validated request body
+   * @param {Object} checked - { messages, mergeReport, targets } from checkScreenshotRequest
+   * @param {Object} request - { id, log, apiKey, timings, reportProgress } of the request the render
+   *   belongs to; async jobs pass reportProgress to publish the stage they are in
+   * @returns {Promise<Object>} Response data
+   */
+  const renderScreenshot = async (body, { messages, mergeReport, targets }, request) => {
+    const { options = {} } = body;
+    const { debugData = 'off', consoleWarnings = false } = options;
+    const warnings = [];
+    const context = { log: request.log, warnings, apiKey: request.apiKey, timings: request.timings, clock };
+    // Stages this render goes through, for progress reports
+    const stages = [
+      'prepare',
+      ...(debugData !== 'only' ? ['render'] : []),
+      ...(debugData !== 'only' && storageService.isEnabled() ? ['store'] : []),
+      ...(targets.length > 0 ? ['deliver'] : [])
+    ];
+    const progress = (stage) => request.reportProgress
+      && request.reportProgress({ stage, completed: stages.indexOf(stage), total: stages.length });
 
-    // Generate the screenshot
-    const imageData = await screenshotService.generateWhatsAppScreenshot(messages, options);
+    // Process the chat data; with debugData the processed data is echoed back
+    // alongside ("include") or instead of ("only") the image
+    progress('prepare');
+    const chatData = await screenshotService.prepareChatData(messages, options, context);
+    if (debugData !== 'only') {
+      progress('render');
+    }
+    let imageData = debugData === 'only'
+      ? null
+      : await screenshotService.captureChatScreenshot(chatData, options, context);
+    let provenance;
+    if (options.provenance && imageData) {
+      ({ image: imageData, provenance } = withProvenance(imageData, { messages, options }, request.id, clock.now()));
+      if (!provenance) {
+        warnings.push({ type: 'provenance', message: 'provenance is only embedded into PNG output' });
+      }
+    }
+    const appIcon = options.appIcon && debugData !== 'only'
+      ? await screenshotService.captureAppIcon(options.appIcon, context)
+      : undefined;
+    if (stages.includes('store')) {
+      progress('store');
+    }
+    const stored = await persistOutput(imageData, getOutputExtension(options), request.apiKey);
+    const fileName = buildFileName(options.outputFileName, {
+      chatName: chatData.chatName,
+      format: getOutputExtension(options),
+      messages,
+      options,
+      requestId: request.id,
+      now: clock.now()
+    });
+    if (targets.length > 0) {
+      progress('deliver');
+    }
+    const delivered = targets.length > 0
+      ? await deliveryService.deliverAll({
+        buffer: decodeDataUrl(imageData),
+        contentType: /^data:([^;,]+)/.exec(imageData)[1],
+        fileName
+      }, targets, { requestId: request.id, log: request.log })
+      : undefined;
     
-    // Get the first message for metadata
-    const firstMessage = messages[0];
-    const lastMessage = messages[messages.length - 1];
-
-    // Prepare response
-    const response = {
-      success: true,
-      data: {
-        image: imageData,
-        metadata: {
-          width: options.width || 400,
-          format: options.format || 'png',
-          quality: options.quality || 'high',
-          message_count: messages.length,
-          first_message_timestamp: firstMessage.timestamp,
-          last_message_timestamp: lastMessage.timestamp,
-          generated_at: new Date().toISOString()
+    // Get the first and last rendered message for metadata; with
+    // options.window these differ from the request's messages
+    const firstMessage = chatData.messages[0];
+    const lastMessage = chatData.messages[chatData.messages.length - 1];
+
+    return {
+      image: imageData,
+      metadata: {
+        width: options.width || 400,
+        format: options.animation ? options.animation.format : options.format || 'png',
+        quality: options.quality || 'high',
+        ...(context.frameCount && { frame_count: context.frameCount }),
+        message_count: chatData.messages.length,
+        ...(options.window && { total_message_count: chatData.totalMessageCount }),
+        ...(provenance !== undefined && { provenance }),
+        ...(options.searchTerm && { search_matches: chatData.searchMatchCount }),
+        file_name: fileName,
+        ...stored,
+        ...(context.watermarks && context.watermarks.length > 0 && { watermarks: context.watermarks }),
+        anonymized: Boolean(resolveAnonymizeSettings(options.anonymize)),
+        first_message_timestamp: firstMessage.timestamp,
+        last_message_timestamp: lastMessage.timestamp,
+        ...(context.queuedMs !== undefined && { queued_ms: context.queuedMs }),
+        generated_at: clock.now().toISOString()
+      },
+      ...(appIcon && { app_icon: appIcon }),
+      // Limit warnings are returned even without consoleWarnings
+      ...((consoleWarnings || warnings.some(warning => ['whatsapp_limit', 'media'].includes(warning.type))) && { warnings }),
+      ...(context.resources && { resources: context.resources }),
+      ...(mergeReport && { merge: mergeReport }),
+      ...(delivered && { delivery: delivered }),
+      ...(debugData !== 'off' && { chat_data: chatData }),
+      // Dry runs also report how the bubble text wraps at the requested width
+      ...(debugData !== 'off' && {
+        layout: measureBubbles(chatData.messages, { width: options.width, viewportHeight: options.viewportHeight })
+      })
+    };
+  };
+
+  /**
+   * Generate a WhatsApp chat screenshot
+   * @route POST /api/whatsapp-screenshot
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const generateScreenshot = async (req, res, next) => {
+    try {
+      const checked = await checkScreenshotRequest(req.body, req.apiKey);
+      const data = await renderScreenshot(req.body, checked, req);
+      res.status(200).json({ success: true, data });
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  /**
+   * Queue a screenshot as a background job and return its ID right away.
+   * The request is checked first, so invalid requests still fail here.
+   * @route POST /api/whatsapp-screenshot/async
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const generateScreenshotAsync = async (req, res, next) => {
+    try {
+      const checked = await checkScreenshotRequest(req.body, req.apiKey);
+      // The job outlives the request, so it gets its own timings
+      const request = { id: req.id, log: req.log.child({ async: true }), apiKey: req.apiKey, timings: {} };
+      // Reruns render the stored payload again without redelivering it
+      const rerun = () => reportProgress => renderScreenshot(req.body, { ...checked, targets: [] }, {
+        ...request,
+        log: request.log.child({ rerun: true }),
+        timings: {},
+        reportProgress
+      });
+      const job = jobService.enqueue(
+        reportProgress => renderScreenshot(req.body, checked, { ...request, reportProgress }),
+        { apiKey: req.apiKey, rerun }
+      );
+      const statusUrl = `/api/jobs/${job.id}`;
+
+      res.set('Location', statusUrl);
+      res.status(202).json({ success: true, data: { ...job, status_url: statusUrl } });
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  /**
+   * Render a chat from the flat payload used by low-code tools (Zapier, n8n)
+   * and return the image as plain base64
+   * @route POST /api/simple
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const generateSimple = async (req, res, next) => {
+    try {
+      const { theme, width, format, quality, chat_phone: chatPhone } = req.body;
+      const messages = parseSimpleChat(req.body, clock);
+      const { value: options, error } = optionsSchema.validate({
+        width,
+        format,
+        quality,
+        headerDisplay: chatPhone ? 'phone' : 'name',
+        ...(theme && { template: theme })
+      });
+      if (error) {
+        throw new ApiError(400, `Validation error: ${error.message}`).annotate({ stage: 'validate', code: 'validation_failed' });
+      }
+
+      const context = { log: req.log, warnings: [], apiKey: req.apiKey, timings: req.timings, clock };
+      const chatData = await screenshotService.prepareChatData(messages, options, context);
+      const imageData = await screenshotService.captureChatScreenshot(chatData, options, context);
+      const stored = await persistOutput(imageData, format, req.apiKey);
+
+      res.status(200).json({
+        success: true,
+        image_base64: decodeDataUrl(imageData).toString('base64'),
+        mime_type: `image/${format}`,
+        file_name: buildFileName(undefined, { chatName: chatData.chatName, format, messages, options, requestId: req.id, now: clock.now() }),
+        width,
+        message_count: messages.length,
+        ...stored
+      });
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  /**
+   * Capture a screenshot of a remote page
+   * @route POST /api/render/url
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const renderUrl = async (req, res, next) => {
+    try {
+      const { url, options = {} } = req.body;
+      const warnings = [];
+      const context = { log: req.log, warnings, apiKey: req.apiKey, timings: req.timings, clock };
+      const imageData = await screenshotService.captureUrl(url, options, context);
+      const stored = await persistOutput(imageData, options.format || 'png', req.apiKey);
+
+      res.status(200).json({
+        success: true,
+        data: {
+          image: imageData,
+          metadata: {
+            url,
+            format: options.format || 'png',
+            quality: options.quality || 'high',
+            selector: options.selector || null,
+            ...stored,
+            ...(context.watermarks && context.watermarks.length > 0 && { watermarks: context.watermarks }),
+            ...(context.queuedMs !== undefined && { queued_ms: context.queuedMs }),
+            generated_at: clock.now().toISOString()
+          },
+          ...(options.consoleWarnings && { warnings }),
+          ...(context.resources && { resources: context.resources })
+        }
+      });
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  /**
+   * Render 2-4 chats side by side in one image
+   * @route POST /api/whatsapp-screenshot/compose
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const generateComposition = async (req, res, next) => {
+    try {
+      const { panels, options } = req.body;
+      const context = { log: req.log, warnings: [], apiKey: req.apiKey, timings: req.timings, clock };
+      const resolvedPanels = panels.map(panel => ({
+        title: panel.title,
+        messages: resolveRequestMessages(panel).messages,
+        options: panel.options || {}
+      }));
+
+      const composition = await screenshotService.generateComposition(resolvedPanels, options, context);
+      const { width, height, panels: panelMetadata } = composition;
+      const { image, provenance } = options.provenance
+        ? withProvenance(composition.image, { messages: resolvedPanels.map(panel => panel.messages), options: req.body }, req.id, clock.now())
+        : { image: composition.image };
+      const stored = await persistOutput(image, 'png', req.apiKey);
+
+      res.status(200).json({
+        success: true,
+        data: {
+          image,
+          metadata: {
+            format: 'png',
+            width,
+            height,
+            panels: panelMetadata,
+            ...stored,
+            ...(provenance && { provenance }),
+            ...(context.queuedMs !== undefined && { queued_ms: context.queuedMs }),
+            generated_at: clock.now().toISOString()
+          }
         }
+      });
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  /**
+   * Batch items of a batch request: its items, or one item per variable set
+   * of a mail merge
+   * @param {Object} body - Validated batch request body
+   * @returns {Array<Object>} { request, variables, error }
+   */
+  const getBatchItems = ({ items, mailMerge, variables }) => (mailMerge
+    ? expandMailMerge(mailMerge, variables)
+    : items.map(request => ({ request })));
+
+  /**
+   * Render one batch item into a package item. Render errors are kept on the
+   * item instead of being thrown.
+   * @param {Object} batchItem - { request, variables, error } from getBatchItems; request is shaped
+   *   like a screenshot request body
+   * @param {number} index - Position of the item in the batch
+   * @param {Object} req - Express request object
+   * @returns {Promise<Object>} { name, data, format, warnings, error, meta } for the packaging layer
+   */
+  const renderBatchItem = async ({ request: item = {}, variables, error: mergeError }, index, req) => {
+    const { options = {} } = item;
+    const warnings = [];
+    const context = { log: req.log.child({ item: index }), warnings, apiKey: req.apiKey, timings: {}, clock };
+    const format = getOutputExtension(options);
+    let messages = item.messages || [];
+
+    try {
+      if (mergeError) {
+        throw mergeError;
       }
-    };
+      if (variables) {
+        // Filled-in texts may no longer pass the request's limits
+        const { error } = requestSchema.validate(item, { abortEarly: false });
+        if (error) {
+          throw new ApiError(400, `Validation error: ${error.details.map(detail => detail.message).join(', ')}`)
+            .annotate({ stage: 'validate', code: 'validation_failed' });
+        }
+      }
+      ({ messages } = resolveRequestMessages(item));
+      const chatData = await screenshotService.prepareChatData(messages, options, context);
+      let imageData = await screenshotService.captureChatScreenshot(chatData, options, context);
+      let provenance;
+      if (options.provenance) {
+        ({ image: imageData, provenance } = withProvenance(imageData, { messages, options }, req.id, clock.now()));
+      }
+      return {
+        name: buildFileName(options.outputFileName, {
+          chatName: chatData.chatName,
+          format,
+          messages,
+          options,
+          requestId: req.id,
+          now: clock.now()
+        }),
+        data: decodeDataUrl(imageData),
+        format,
+        warnings,
+        meta: { message_count: messages.length, ...(provenance && { provenance }), ...(variables && { variables }) }
+      };
+    } catch (error) {
+      context.log.warn('Batch item failed', { error });
+      return { format, warnings, error, meta: { message_count: messages.length, ...(variables && { variables }) } };
+    }
+  };
 
-    res.status(200).json(response);
-  } catch (error) {
-    next(error);
-  }
+  /**
+   * Stream a batch: the archive is sent chunked and every item is written as
+   * soon as it has rendered, so only one output is held in memory. The
+   * success counts only exist at the end and are sent as trailers.
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   */
+  const streamBatch = async (req, res) => {
+    const { package: packageType = 'zip' } = req.body;
+    const items = getBatchItems(req.body);
+    const archive = createPackageStream(res, { type: packageType, requestId: req.id, now: clock.now() });
+    let closed = false;
+    res.on('close', () => {
+      closed = true;
+    });
+
+    res.set({
+      'Content-Type': archive.contentType,
+      'Content-Disposition': `attachment; filename="batch-${clock.now().toISOString().slice(0, 10)}.${archive.extension}"`,
+      Trailer: 'X-Batch-Succeeded, X-Batch-Failed'
+    });
+    res.status(200);
+    res.flushHeaders();
+
+    try {
+      for (const [index, item] of items.entries()) {
+        // Stop rendering for a client that went away
+        if (closed) {
+          req.log.warn('Batch stream closed by the client', { rendered: index, total: items.length });
+          return;
+        }
+        await archive.add(await renderBatchItem(item, index, req));
+      }
+      const manifest = await archive.finish();
+      res.addTrailers({
+        'X-Batch-Succeeded': String(manifest.succeeded),
+        'X-Batch-Failed': String(manifest.failed)
+      });
+      res.end();
+    } catch (error) {
+      // The status line is gone; cutting the response short is the only way
+      // left to tell the client the archive is incomplete
+      req.log.error('Batch stream failed', { error });
+      res.destroy(error);
+    }
+  };
+
+  /**
+   * Render several chats and return them as a ZIP or TAR archive with a
+   * manifest. Items are rendered one after another; a failing item is recorded
+   * in the manifest instead of failing the whole batch. With mailMerge the
+   * items are one request filled in with each variable set. With stream the
+   * archive is sent while it is produced (see streamBatch).
+   * @route POST /api/whatsapp-screenshot/batch
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const generateBatch = async (req, res, next) => {
+    try {
+      const { package: packageType = 'zip', stream = false } = req.body;
+      if (stream) {
+        await streamBatch(req, res);
+        return;
+      }
+
+      const rendered = [];
+      for (const [index, item] of getBatchItems(req.body).entries()) {
+        rendered.push(await renderBatchItem(item, index, req));
+      }
+
+      const { buffer, contentType, extension, manifest } = buildPackage(rendered, {
+        type: packageType,
+        requestId: req.id,
+        now: clock.now()
+      });
+
+      res.set({
+        'Content-Type': contentType,
+        'Content-Disposition': `attachment; filename="batch-${manifest.generated_at.slice(0, 10)}.${extension}"`,
+        'X-Batch-Succeeded': String(manifest.succeeded),
+        'X-Batch-Failed': String(manifest.failed)
+      });
+      res.status(200).send(buffer);
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  return {
+    generateScreenshot,
+    generateScreenshotAsync,
+    generateSimple,
+    generateBatch,
+    generateComposition,
+    renderUrl
+  };
 };
 
 module.exports = {
-  generateScreenshot
+  ...createScreenshotController(),
+  createScreenshotController
 };
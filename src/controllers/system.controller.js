@@ -0,0 +1,167 @@
+const os = require('os');
+const defaultScreenshotService = require('../services/screenshot.service');
+const defaultJobService = require('../services/job.service');
+const { ApiError } = require('../middleware/error.middleware');
+const { recordProbe, getProbeHistory, getHistorySize } = require('../utils/health-history');
+const { getImageDimensions } = require('../utils/image-info');
+const { decodeDataUrl } = require('../utils/packaging');
+const { systemClock } = require('../utils/clock');
+
+const PROBES = ['health', 'ready', 'selftest'];
+
+// Built-in conversation rendered by the self-test
+const SELFTEST_MESSAGES = [
+  { timestamp: '2025-01-01T09:00:00Z', sender: 'Customer', recipient_name: 'Self-test', recipient_phone: '81200000000', content: 'Hello, is this *working*?' },
+  { timestamp: '2025-01-01T09:00:05Z', sender: 'Bot', content: 'Yes, the pipeline renders end-to-end.' }
+];
+
+/**
+ * Create the system handlers. Services default to the shared singletons;
+ * callers such as tests can pass their own implementations.
+ * @param {Object} [services] - { screenshotService, jobService, clock }
+ * @returns {Object} Route handlers
+ */
+const createSystemController = ({
+  screenshotService = defaultScreenshotService,
+  jobService = defaultJobService,
+  clock = systemClock
+} = {}) => {
+  /**
+   * Liveness probe
+   * @route GET /health
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   */
+  const getHealth = (req, res) => {
+    recordProbe('health', { ok: true });
+    res.status(200).json({ status: 'ok', timestamp: clock.now().toISOString() });
+  };
+
+  /**
+   * Readiness probe: 503 while the browser is starting, recycling or the
+   * server is draining, so load balancers stop routing new renders here
+   * @route GET /ready
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   */
+  const getReady = (req, res) => {
+    const readiness = screenshotService.getReadiness();
+    recordProbe('ready', { ok: readiness.ready, state: readiness.state, queued: readiness.queued });
+    res.status(readiness.ready ? 200 : 503).json({ ...readiness, timestamp: clock.now().toISOString() });
+  };
+
+  /**
+   * Load and headroom of this replica, for autoscalers (KEDA, HPA external
+   * metrics) to scale on queue depth instead of CPU. Nothing is recorded, so
+   * it can be polled often.
+   * @route GET /capacity
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   */
+  const getCapacity = (req, res) => {
+    const readiness = screenshotService.getReadiness();
+    const jobs = jobService.getLoad();
+    // Renders waiting for a browser restart count as queued work too
+    const queueDepth = jobs.queued + readiness.queued;
+    const freeWorkers = Math.max(0, jobs.concurrency - jobs.running);
+
+    res.set('Cache-Control', 'no-store');
+    res.status(200).json({
+      replica: os.hostname(),
+      accepting: readiness.ready,
+      state: readiness.state,
+      queueDepth,
+      // Work in progress and waiting per regular worker; above 1 the replica
+      // is saturated
+      utilization: Math.round(((jobs.running + queueDepth) / jobs.concurrency) * 100) / 100,
+      headroom: {
+        workers: freeWorkers,
+        queue: Math.max(0, jobs.queueLimit - jobs.queued)
+      },
+      jobs,
+      renders: {
+        inFlight: readiness.openPages,
+        waitingForBrowser: readiness.queued,
+        waitLimit: readiness.queueLimit
+      },
+      timestamp: clock.now().toISOString()
+    });
+  };
+
+  /**
+   * Latest probe results, oldest first
+   * @route GET /healthz/history
+   * @param {Object} req - Express request object; query { probe, limit }
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const getHealthHistory = (req, res, next) => {
+    try {
+      const { probe } = req.query;
+      const limit = parseInt(req.query.limit, 10) || undefined;
+      if (probe && !PROBES.includes(probe)) {
+        throw new ApiError(400, `probe must be one of ${PROBES.join(', ')}`).annotate({ stage: 'validate' });
+      }
+
+      res.status(200).json({
+        success: true,
+        data: {
+          size: getHistorySize(),
+          results: getProbeHistory({ probe, limit })
+        }
+      });
+    } catch (error) {
+      next(error);
+    }
+  };
+
+  /**
+   * Render a built-in conversation end-to-end (processing, HTML, Chrome
+   * capture) and report the timing of each stage
+   * @route POST /selftest
+   * @param {Object} req - Express request object
+   * @param {Object} res - Express response object
+   * @param {Function} next - Next middleware function
+   */
+  const runSelfTest = async (req, res, next) => {
+    const startedAt = Date.now();
+    const context = { log: req.log, warnings: [], apiKey: req.apiKey, timings: {}, clock };
+    try {
+      const options = { width: 400, format: 'png' };
+      const chatData = await screenshotService.prepareChatData(SELFTEST_MESSAGES, options, context);
+      const imageData = await screenshotService.captureChatScreenshot(chatData, options, context);
+      const image = decodeDataUrl(imageData);
+      const dimensions = image && getImageDimensions(image);
+      if (!dimensions) {
+        throw new ApiError(500, 'Self-test produced an unreadable image').annotate({ stage: 'encode' });
+      }
+
+      const result = {
+        ok: true,
+        durationMs: Date.now() - startedAt,
+        timings: context.timings,
+        image: { ...dimensions, bytes: image.length },
+        warnings: context.warnings
+      };
+      recordProbe('selftest', { ok: true, durationMs: result.durationMs });
+      res.status(200).json({ success: true, data: result });
+    } catch (error) {
+      recordProbe('selftest', { ok: false, durationMs: Date.now() - startedAt, stage: error.stage || null, error: error.message });
+      next(new ApiError(503, `Self-test failed: ${error.message}`)
+        .annotate({ stage: error.stage, code: 'selftest_failed', retryable: true })
+        .causedBy(error));
+    }
+  };
+  return {
+    getHealth,
+    getReady,
+    getCapacity,
+    getHealthHistory,
+    runSelfTest
+  };
+};
+
+module.exports = {
+  ...createSystemController(),
+  createSystemController
+};
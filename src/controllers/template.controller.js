@@ -0,0 +1,71 @@
+const templateService = require('../services/template.service');
+
+/**
+ * Upload a custom template for the caller's API key. Uploaded templates run
+ * in the template sandbox.
+ * @route POST /api/templates
+ * @param {Object} req - Express request object
+ * @param {Object} res - Express response object
+ * @param {Function} next - Next middleware function
+ */
+const uploadTemplate = async (req, res, next) => {
+  try {
+    const { name, source } = req.body;
+    const template = await templateService.uploadTemplate(name, source, req.apiKey);
+
+    res.status(201).json({
+      success: true,
+      data: {
+        name: template.name,
+        sandboxed: template.sandboxed,
+        created_at: template.createdAt
+      }
+    });
+  } catch (error) {
+    next(error);
+  }
+};
+
+/**
+ * List available templates
+ * @route GET /api/templates
+ * @param {Object} req - Express request object
+ * @param {Object} res - Express response object
+ * @param {Function} next - Next middleware function
+ */
+const listTemplates = async (req, res, next) => {
+  try {
+    res.status(200).json({
+      success: true,
+      data: await templateService.listTemplates(req.apiKey)
+    });
+  } catch (error) {
+    next(error);
+  }
+};
+
+/**
+ * Describe which request fields and template functions a template uses
+ * @route GET /api/templates/:name/schema
+ * @param {Object} req - Express request object
+ * @param {Object} res - Express response object
+ * @param {Function} next - Next middleware function
+ */
+const getTemplateSchema = async (req, res, next) => {
+  try {
+    const schema = await templateService.getTemplateSchema(req.params.name, req.apiKey);
+
+    res.status(200).json({
+      success: true,
+      data: schema
+    });
+  } catch (error) {
+    next(error);
+  }
+};
+
+module.exports = {
+  uploadTemplate,
+  listTemplates,
+  getTemplateSchema
+};
@@ -0,0 +1,98 @@
+const Joi = require('joi');
+const { ApiError } = require('../middleware/error.middleware');
+
+// One client per region/endpoint; the SDK keeps connections alive
+const clients = new Map();
+
+/**
+ * Load the AWS SDK. It is not a dependency of the service, so deployments
+ * without S3 delivery don't install it (npm install @aws-sdk/client-s3).
+ * @returns {Object} @aws-sdk/client-s3 exports
+ */
+function loadSdk() {
+  try {
+    return require('@aws-sdk/client-s3');
+  } catch (error) {
+    throw new ApiError(501, 'S3 delivery requires the @aws-sdk/client-s3 package to be installed')
+      .annotate({ stage: 'deliver', code: 's3_unavailable' })
+      .causedBy(error);
+  }
+}
+
+/**
+ * S3 client for a region and optional endpoint (MinIO and other S3
+ * compatible stores use path-style URLs). Credentials come from the SDK's
+ * default provider chain (AWS_ACCESS_KEY_ID, profiles, instance roles).
+ * @param {string} region - Bucket region
+ * @param {string} [endpoint] - Custom endpoint
+ * @returns {Object} S3Client
+ */
+function getClient(region, endpoint) {
+  const key = `${region}|${endpoint || ''}`;
+  if (!clients.has(key)) {
+    const { S3Client } = loadSdk();
+    clients.set(key, new S3Client({ region, ...(endpoint && { endpoint, forcePathStyle: true }) }));
+  }
+  return clients.get(key);
+}
+
+module.exports = {
+  name: 's3',
+
+  schema: Joi.object({
+    key_prefix: Joi.string().pattern(/^[\w\-./]{1,200}$/).optional()
+  }),
+
+  /**
+   * Resolve the bucket: the policy's bucket or DELIVERY_S3_BUCKET. Requests
+   * can only add a key prefix below the configured one.
+   * @param {Object} options - Request options { key_prefix }
+   * @param {Object} policy - Tenant settings { bucket, prefix, region, endpoint }
+   * @returns {Object} Target
+   */
+  resolve(options, policy) {
+    const bucket = policy.bucket || process.env.DELIVERY_S3_BUCKET;
+    if (!bucket) {
+      throw new ApiError(501, 'S3 delivery requires DELIVERY_S3_BUCKET').annotate({ stage: 'deliver', code: 's3_not_configured' });
+    }
+    loadSdk();
+    if (options.key_prefix && options.key_prefix.split('/').includes('..')) {
+      throw new ApiError(400, 'delivery.s3.key_prefix must not contain ".."').annotate({ stage: 'validate', code: 'validation_failed' });
+    }
+    return {
+      credentials: policy.bucket ? 'tenant' : 'server',
+      bucket,
+      prefix: `${policy.bucket ? policy.prefix || '' : process.env.DELIVERY_S3_PREFIX || ''}${options.key_prefix || ''}`,
+      region: policy.region || process.env.DELIVERY_S3_REGION || process.env.AWS_REGION || 'us-east-1',
+      endpoint: policy.endpoint || process.env.DELIVERY_S3_ENDPOINT || null
+    };
+  },
+
+  /**
+   * Upload the output as <prefix><file name>
+   * @param {Object} output - { buffer, contentType, fileName, sha256 }
+   * @param {Object} target - Resolved target
+   * @param {Object} context - { requestId }
+   * @returns {Promise<Object>} { bucket, key, etag }
+   */
+  async deliver({ buffer, contentType, fileName, sha256 }, { bucket, prefix, region, endpoint }, { requestId }) {
+    const key = `${prefix}${fileName}`;
+    const { PutObjectCommand } = loadSdk();
+    try {
+      const result = await getClient(region, endpoint).send(new PutObjectCommand({
+        Bucket: bucket,
+        Key: key,
+        Body: buffer,
+        ContentType: contentType,
+        ChecksumSHA256: Buffer.from(sha256, 'hex').toString('base64'),
+        Metadata: { 'request-id': String(requestId) }
+      }));
+      return { bucket, key, etag: result.ETag || null };
+    } catch (error) {
+      const status = (error.$metadata && error.$metadata.httpStatusCode) || 0;
+      throw new ApiError(502, `S3 upload failed: ${error.name || error.message}`)
+        .annotate({ stage: 'deliver', code: 's3_delivery_failed', retryable: Boolean(error.$retryable) || status === 0 || status >= 500 })
+        .causedBy(error);
+    }
+  }
+};
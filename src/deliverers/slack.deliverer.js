@@ -0,0 +1,139 @@
+const Joi = require('joi');
+const { ApiError } = require('../middleware/error.middleware');
+const { parseListEnv } = require('../utils/env');
+
+const SLACK_API_URL = 'https://slack.com/api';
+const SLACK_TIMEOUT_MS = 30000;
+
+/**
+ * Error for a failed Slack call. Rate limits and Slack outages are retryable.
+ * @param {string} step - API method that failed
+ * @param {Object} failure - { status, slackError, retryAfter }
+ * @returns {ApiError} Error
+ */
+function slackError(step, { status, slackError: reason, retryAfter }) {
+  const error = new ApiError(502, `Slack ${step} failed: ${reason || `HTTP ${status}`}`)
+    .annotate({ stage: 'deliver', code: 'slack_delivery_failed', retryable: status === 429 || status >= 500 });
+  error.details = { slack_error: reason || null, status };
+  if (retryAfter) {
+    error.retryAfter = retryAfter;
+  }
+  return error;
+}
+
+/**
+ * Call a Slack Web API method
+ * @param {string} method - API method, e.g. files.getUploadURLExternal
+ * @param {string} token - Bot token
+ * @param {Object} body - Form fields (URL-encoded) or JSON body
+ * @param {boolean} json - Send a JSON body
+ * @returns {Promise<Object>} Response payload
+ */
+async function callSlack(method, token, body, json = false) {
+  let response;
+  try {
+    response = await fetch(`${SLACK_API_URL}/${method}`, {
+      method: 'POST',
+      headers: {
+        Authorization: `Bearer ${token}`,
+        'Content-Type': json ? 'application/json; charset=utf-8' : 'application/x-www-form-urlencoded'
+      },
+      body: json ? JSON.stringify(body) : new URLSearchParams(body).toString(),
+      signal: AbortSignal.timeout(SLACK_TIMEOUT_MS)
+    });
+  } catch (error) {
+    throw slackError(method, { status: 503, slackError: error.message }).causedBy(error);
+  }
+
+  const payload = await response.json().catch(() => ({}));
+  if (!response.ok || !payload.ok) {
+    throw slackError(method, {
+      status: response.status,
+      slackError: payload.error,
+      retryAfter: parseInt(response.headers.get('retry-after'), 10) || null
+    });
+  }
+  return payload;
+}
+
+module.exports = {
+  name: 'slack',
+
+  schema: Joi.object({
+    channel: Joi.string().max(80).optional(),
+    title: Joi.string().max(255).optional(),
+    comment: Joi.string().max(4000).optional()
+  }),
+
+  /**
+   * Resolve the bot token and channel. The token is the tenant's (policy
+   * token) or SLACK_BOT_TOKEN; the channel must be the default
+   * (SLACK_DEFAULT_CHANNEL or the policy's channel) or allowlisted
+   * (SLACK_ALLOWED_CHANNELS or the policy's allowed_channels).
+   * @param {Object} options - Request options { channel, title, comment }
+   * @param {Object} policy - Tenant settings { token, channel, allowed_channels }
+   * @returns {Object} Target
+   */
+  resolve(options, policy) {
+    const token = policy.token || process.env.SLACK_BOT_TOKEN;
+    if (!token) {
+      throw new ApiError(501, 'Slack delivery requires SLACK_BOT_TOKEN').annotate({ stage: 'deliver', code: 'slack_not_configured' });
+    }
+    const defaultChannel = policy.channel || process.env.SLACK_DEFAULT_CHANNEL;
+    const channel = options.channel || defaultChannel;
+    if (!channel) {
+      throw new ApiError(400, 'slack.channel is required when SLACK_DEFAULT_CHANNEL is not set')
+        .annotate({ stage: 'validate', code: 'slack_channel_required' });
+    }
+    const allowedChannels = [
+      defaultChannel,
+      ...parseListEnv('SLACK_ALLOWED_CHANNELS'),
+      ...[].concat(policy.allowed_channels || [])
+    ];
+    if (!allowedChannels.includes(channel)) {
+      throw new ApiError(403, `Posting to Slack channel ${channel} is not allowed`)
+        .annotate({ stage: 'validate', code: 'slack_channel_not_allowed' });
+    }
+    return {
+      credentials: policy.token ? 'tenant' : 'server',
+      token,
+      channel,
+      title: options.title,
+      comment: options.comment
+    };
+  },
+
+  /**
+   * Upload the output with Slack's external upload flow: get an upload URL,
+   * send the file as multipart/form-data, then share it in the channel
+   * @param {Object} output - { buffer, contentType, fileName }
+   * @param {Object} target - Resolved target
+   * @returns {Promise<Object>} { channel, file_id, permalink }
+   */
+  async deliver({ buffer, contentType, fileName }, { token, channel, title, comment }) {
+    const { upload_url: uploadUrl, file_id: fileId } = await callSlack('files.getUploadURLExternal', token, {
+      filename: fileName,
+      length: String(buffer.length)
+    });
+
+    const form = new FormData();
+    form.append('file', new Blob([buffer], { type: contentType }), fileName);
+    let uploadResponse;
+    try {
+      uploadResponse = await fetch(uploadUrl, { method: 'POST', body: form, signal: AbortSignal.timeout(SLACK_TIMEOUT_MS) });
+    } catch (error) {
+      throw slackError('upload', { status: 503, slackError: error.message }).causedBy(error);
+    }
+    if (!uploadResponse.ok) {
+      throw slackError('upload', { status: uploadResponse.status });
+    }
+
+    const completed = await callSlack('files.completeUploadExternal', token, {
+      files: [{ id: fileId, title: title || fileName }],
+      channel_id: channel,
+      ...(comment && { initial_comment: comment })
+    }, true);
+    const file = (completed.files || [])[0] || {};
+    return { channel, file_id: fileId, permalink: file.permalink || null };
+  }
+};
@@ -0,0 +1,132 @@
+const Joi = require('joi');
+const { ApiError } = require('../middleware/error.middleware');
+const { parseListEnv } = require('../utils/env');
+
+const TELEGRAM_API_URL = 'https://api.telegram.org';
+const TELEGRAM_TIMEOUT_MS = 30000;
+
+// Bot API method and multipart field per kind of output
+const SEND_METHODS = {
+  photo: { method: 'sendPhoto', field: 'photo' },
+  animation: { method: 'sendAnimation', field: 'animation' },
+  video: { method: 'sendVideo', field: 'video' },
+  document: { method: 'sendDocument', field: 'document' }
+};
+
+/**
+ * Pick how an output is sent: images as photos (unless sent as documents),
+ * GIFs as animations, MP4 as video and anything else as a document
+ * @param {string} contentType - Output content type
+ * @param {string} sendAs - "photo" or "document"
+ * @returns {Object} { method, field }
+ */
+function resolveSendMethod(contentType, sendAs) {
+  if (contentType === 'image/gif') {
+    return SEND_METHODS.animation;
+  }
+  if (contentType === 'video/mp4') {
+    return SEND_METHODS.video;
+  }
+  if (sendAs === 'photo' && /^image\/(png|jpeg|webp)$/.test(contentType)) {
+    return SEND_METHODS.photo;
+  }
+  return SEND_METHODS.document;
+}
+
+module.exports = {
+  name: 'telegram',
+
+  schema: Joi.object({
+    chat_id: Joi.alternatives().try(
+      Joi.number().integer(),
+      Joi.string().pattern(/^(-?\d+|@\w{5,32})$/)
+    ).optional(),
+    bot_token: Joi.string().pattern(/^\d+:[\w-]{30,}$/).optional(),
+    caption: Joi.string().max(1024).optional(),
+    send_as: Joi.string().valid('photo', 'document').default('photo')
+  }),
+
+  /**
+   * Resolve the bot token and chat. A request's own bot_token may post
+   * anywhere; the tenant's (policy bot_token) and the server's bot
+   * (TELEGRAM_BOT_TOKEN) only to the default chat (TELEGRAM_DEFAULT_CHAT_ID
+   * or the policy's chat_id) and allowlisted chats (TELEGRAM_ALLOWED_CHATS or
+   * the policy's allowed_chats).
+   * @param {Object} options - Request options { chat_id, bot_token, caption, send_as }
+   * @param {Object} policy - Tenant settings { bot_token, chat_id, allowed_chats }
+   * @returns {Object} Target
+   */
+  resolve(options, policy) {
+    const token = options.bot_token || policy.bot_token || process.env.TELEGRAM_BOT_TOKEN;
+    if (!token) {
+      throw new ApiError(501, 'Telegram delivery requires TELEGRAM_BOT_TOKEN or delivery.telegram.bot_token')
+        .annotate({ stage: 'deliver', code: 'telegram_not_configured' });
+    }
+    const defaultChatId = policy.chat_id || process.env.TELEGRAM_DEFAULT_CHAT_ID;
+    const chatId = options.chat_id || defaultChatId;
+    if (!chatId) {
+      throw new ApiError(400, 'delivery.telegram.chat_id is required when TELEGRAM_DEFAULT_CHAT_ID is not set')
+        .annotate({ stage: 'validate', code: 'telegram_chat_required' });
+    }
+    const allowedChats = [
+      defaultChatId,
+      ...parseListEnv('TELEGRAM_ALLOWED_CHATS'),
+      ...[].concat(policy.allowed_chats || [])
+    ].filter(Boolean).map(String);
+    if (!options.bot_token && !allowedChats.includes(String(chatId))) {
+      throw new ApiError(403, `Posting to Telegram chat ${chatId} is not allowed`)
+        .annotate({ stage: 'validate', code: 'telegram_chat_not_allowed' });
+    }
+
+    let credentials = 'server';
+    if (options.bot_token) {
+      credentials = 'request';
+    } else if (policy.bot_token) {
+      credentials = 'tenant';
+    }
+    return { credentials, token, chatId, caption: options.caption, sendAs: options.send_as };
+  },
+
+  /**
+   * Send the output with the Bot API
+   * @param {Object} output - { buffer, contentType, fileName }
+   * @param {Object} target - Resolved target
+   * @returns {Promise<Object>} { chat_id, message_id, method }
+   */
+  async deliver({ buffer, contentType, fileName }, { token, chatId, caption, sendAs }) {
+    const { method, field } = resolveSendMethod(contentType, sendAs);
+    const form = new FormData();
+    form.append('chat_id', String(chatId));
+    if (caption) {
+      form.append('caption', caption);
+    }
+    form.append(field, new Blob([buffer], { type: contentType }), fileName);
+
+    let response;
+    try {
+      response = await fetch(`${TELEGRAM_API_URL}/bot${token}/${method}`, {
+        method: 'POST',
+        body: form,
+        signal: AbortSignal.timeout(TELEGRAM_TIMEOUT_MS)
+      });
+    } catch (error) {
+      // The request URL contains the token, so only the error name is reported
+      throw new ApiError(502, `Telegram ${method} failed: ${error.name}`)
+        .annotate({ stage: 'deliver', code: 'telegram_delivery_failed', retryable: true });
+    }
+
+    const payload = await response.json().catch(() => ({}));
+    if (!response.ok || !payload.ok) {
+      const retryAfter = (payload.parameters && payload.parameters.retry_after) || null;
+      const error = new ApiError(502, `Telegram ${method} failed: ${payload.description || `HTTP ${response.status}`}`)
+        .annotate({ stage: 'deliver', code: 'telegram_delivery_failed', retryable: response.status === 429 || response.status >= 500 });
+      error.details = { telegram_error: payload.error_code || response.status };
+      if (retryAfter) {
+        error.retryAfter = retryAfter;
+      }
+      throw error;
+    }
+
+    return { chat_id: chatId, message_id: payload.result ? payload.result.message_id : null, method };
+  }
+};
@@ -0,0 +1,91 @@
+const crypto = require('crypto');
+const Joi = require('joi');
+const { ApiError } = require('../middleware/error.middleware');
+const { assertUrlAllowed } = require('../utils/url-guard');
+const { parseListEnv } = require('../utils/env');
+
+const WEBHOOK_TIMEOUT_MS = 30000;
+
+module.exports = {
+  name: 'webhook',
+
+  schema: Joi.object({
+    url: Joi.string().uri({ scheme: ['http', 'https'] }).optional()
+  }),
+
+  /**
+   * Resolve the webhook URL: the request's url, the policy's url or
+   * DELIVERY_WEBHOOK_URL. URLs from requests must be on
+   * DELIVERY_WEBHOOK_ALLOWLIST (or the policy's allowed_hosts) and not resolve
+   * to internal addresses. Payloads are signed with the policy's secret or
+   * DELIVERY_WEBHOOK_SECRET.
+   * @param {Object} options - Request options { url }
+   * @param {Object} policy - Tenant settings { url, secret, allowed_hosts }
+   * @returns {Promise<Object>} Target
+   */
+  async resolve(options, policy) {
+    const url = options.url || policy.url || process.env.DELIVERY_WEBHOOK_URL;
+    if (!url) {
+      throw new ApiError(400, 'delivery.webhook.url is required when DELIVERY_WEBHOOK_URL is not set')
+        .annotate({ stage: 'validate', code: 'webhook_url_required' });
+    }
+    if (options.url) {
+      await assertUrlAllowed(options.url, {
+        allowlist: [
+          ...parseListEnv('DELIVERY_WEBHOOK_ALLOWLIST'),
+          ...[].concat(policy.allowed_hosts || [])
+        ].map(entry => String(entry).trim().toLowerCase()).filter(Boolean),
+        allowlistName: 'DELIVERY_WEBHOOK_ALLOWLIST'
+      });
+    }
+
+    let credentials = 'server';
+    if (options.url) {
+      credentials = 'request';
+    } else if (policy.url) {
+      credentials = 'tenant';
+    }
+    return { credentials, url, secret: policy.secret || process.env.DELIVERY_WEBHOOK_SECRET || null };
+  },
+
+  /**
+   * POST the output as JSON (base64 data). With a secret, the body's
+   * HMAC-SHA256 is sent as X-Signature-256: sha256=<hex>.
+   * @param {Object} output - { buffer, contentType, fileName, sha256 }
+   * @param {Object} target - Resolved target
+   * @param {Object} context - { requestId }
+   * @returns {Promise<Object>} { url, status }
+   */
+  async deliver({ buffer, contentType, fileName, sha256 }, { url, secret }, { requestId }) {
+    const body = JSON.stringify({
+      event: 'render.delivered',
+      request_id: requestId,
+      file_name: fileName,
+      content_type: contentType,
+      size: buffer.length,
+      sha256,
+      data: buffer.toString('base64')
+    });
+    const headers = { 'Content-Type': 'application/json', 'X-Request-Id': requestId };
+    if (secret) {
+      headers['X-Signature-256'] = `sha256=${crypto.createHmac('sha256', secret).update(body).digest('hex')}`;
+    }
+
+    let response;
+    try {
+      // Redirects could lead to hosts that were never checked
+      response = await fetch(url, { method: 'POST', headers, body, redirect: 'error', signal: AbortSignal.timeout(WEBHOOK_TIMEOUT_MS) });
+    } catch (error) {
+      throw new ApiError(502, `Webhook delivery failed: ${error.message}`)
+        .annotate({ stage: 'deliver', code: 'webhook_delivery_failed', retryable: true })
+        .causedBy(error);
+    }
+    if (!response.ok) {
+      const error = new ApiError(502, `Webhook delivery failed: HTTP ${response.status}`)
+        .annotate({ stage: 'deliver', code: 'webhook_delivery_failed', retryable: response.status === 429 || response.status >= 500 });
+      error.retryAfter = parseInt(response.headers.get('retry-after'), 10) || undefined;
+      throw error;
+    }
+    return { url: new URL(url).origin, status: response.status };
+  }
+};
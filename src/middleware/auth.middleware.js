@@ -0,0 +1,114 @@
+const crypto = require('crypto');
+const { ApiError } = require('./error.middleware');
+const { parseListEnv } = require('../utils/env');
+
+/**
+ * API keys accepted by protected routes, configured through API_KEYS
+ * (comma separated)
+ * @returns {Array<string>} Configured keys
+ */
+const getApiKeys = () => parseListEnv('API_KEYS');
+
+/**
+ * Extract the API key from the X-API-Key header or a Bearer token
+ * @param {Object} req - Express request object
+ * @returns {string|null} API key
+ */
+const extractApiKey = (req) => {
+  const headerKey = req.get('x-api-key');
+  if (headerKey) {
+    return headerKey.trim();
+  }
+
+  const authorization = req.get('authorization') || '';
+  const match = authorization.match(/^Bearer\s+(.+)$/i);
+  return match ? match[1].trim() : null;
+};
+
+/**
+ * Constant-time comparison of two keys
+ * @param {string} a - First key
+ * @param {string} b - Second key
+ * @returns {boolean} Whether the keys match
+ */
+const safeEqual = (a, b) => {
+  const bufferA = Buffer.from(a);
+  const bufferB = Buffer.from(b);
+  return bufferA.length === bufferB.length && crypto.timingSafeEqual(bufferA, bufferB);
+};
+
+/**
+ * Build middleware requiring one of the keys returned by getKeys
+ * @param {Function} getKeys - Returns the accepted keys
+ * @param {string} envName - Environment variable holding the keys, for the error message
+ * @returns {Function} Express middleware
+ */
+const createKeyAuth = (getKeys, envName) => (req, res, next) => {
+  const keys = getKeys();
+  const provided = extractApiKey(req);
+
+  if (keys.length === 0) {
+    return next(new ApiError(401, `This endpoint requires ${envName} to be configured`)
+      .annotate({ stage: 'validate', code: 'api_keys_not_configured' }));
+  }
+  if (!provided || !keys.some(key => safeEqual(key, provided))) {
+    return next(new ApiError(401, 'Invalid or missing API key').annotate({ stage: 'validate', code: 'invalid_api_key' }));
+  }
+
+  req.apiKey = provided;
+  next();
+};
+
+/**
+ * Admin keys, configured through ADMIN_API_KEYS (comma separated)
+ * @returns {Array<string>} Configured admin keys
+ */
+const getAdminApiKeys = () => parseListEnv('ADMIN_API_KEYS');
+
+/**
+ * Priority keys, configured through PRIORITY_API_KEYS (comma separated).
+ * They must also be listed in API_KEYS.
+ * @returns {Array<string>} Configured priority keys
+ */
+const getPriorityApiKeys = () => parseListEnv('PRIORITY_API_KEYS');
+
+/**
+ * Whether a caller's renders skip ahead of the regular queues
+ * @param {string} [apiKey] - Caller's API key
+ * @returns {boolean} Whether the key is a priority key
+ */
+const isPriorityKey = (apiKey) => Boolean(apiKey) && getPriorityApiKeys().some(key => safeEqual(key, apiKey));
+
+/**
+ * Identify the caller on routes that don't require a key: a valid API key
+ * sets req.apiKey (for per-tenant policies), anything else is ignored and
+ * the request is treated as anonymous
+ * @param {Object} req - Express request object
+ * @param {Object} res - Express response object
+ * @param {Function} next - Next middleware function
+ */
+const identifyApiKey = (req, res, next) => {
+  const provided = extractApiKey(req);
+  if (provided && getApiKeys().some(key => safeEqual(key, provided))) {
+    req.apiKey = provided;
+  }
+  next();
+};
+
+// Require a valid API key. Routes using this middleware are unavailable
+// until API_KEYS is configured.
+const requireApiKey = createKeyAuth(getApiKeys, 'API_KEYS');
+
+// Require an admin key, separate from the regular API keys
+const requireAdminKey = createKeyAuth(getAdminApiKeys, 'ADMIN_API_KEYS');
+
+module.exports = {
+  requireApiKey,
+  requireAdminKey,
+  identifyApiKey,
+  extractApiKey,
+  getApiKeys,
+  getAdminApiKeys,
+  getPriorityApiKeys,
+  isPriorityKey
+};
@@ -1,26 +1,154 @@
+const { reportError } = require('../utils/error-reporter');
+const { logger } = require('../utils/logger');
+
+// Pipeline stages an error can originate from
+const ERROR_STAGES = ['decode', 'validate', 'template', 'render', 'encode', 'deliver'];
+
+// Machine-readable codes used when an error does not set its own
+const DEFAULT_ERROR_CODES = {
+  400: 'invalid_request',
+  401: 'unauthorized',
+  403: 'forbidden',
+  404: 'not_found',
+  408: 'request_timeout',
+  409: 'conflict',
+  413: 'payload_too_large',
+  422: 'unprocessable',
+  429: 'rate_limited',
+  500: 'internal_error',
+  502: 'upstream_error',
+  503: 'unavailable',
+  504: 'timeout'
+};
+
+// Statuses that are worth retrying with backoff
+const RETRYABLE_STATUSES = new Set([408, 429, 502, 503, 504]);
+
 /**
- * Error handling middleware
+ * Classify an error for the response: status, code, stage and retryability.
+ * Body parser errors (malformed JSON, oversized body) belong to the decode stage.
+ * Render failures are retryable because Chrome errors are usually transient.
  * @param {Error} err - Error object
+ * @returns {Object} { statusCode, code, stage, retryable }
+ */
+const classifyError = (err) => {
+  let { stage = null, code } = err;
+  if (err.type === 'entity.parse.failed') {
+    stage = 'decode';
+    code = code || 'invalid_json';
+  } else if (err.type === 'entity.too.large') {
+    stage = 'decode';
+  }
+
+  const statusCode = err.statusCode || err.status || 500;
+  const retryable = typeof err.retryable === 'boolean'
+    ? err.retryable
+    : RETRYABLE_STATUSES.has(statusCode) || (statusCode >= 500 && stage === 'render');
+
+  return {
+    statusCode,
+    code: code || DEFAULT_ERROR_CODES[statusCode] || (statusCode >= 500 ? 'internal_error' : 'invalid_request'),
+    stage,
+    retryable
+  };
+};
+
+/**
+ * Request context attached to crash logs and error reports
  * @param {Object} req - Express request object
- * @param {Object} res - Express response object
- * @param {Function} next - Next middleware function
+ * @returns {Object} { requestId, route, method, payloadBytes }
+ */
+const getRequestContext = (req) => ({
+  requestId: req.id || null,
+  route: req.route ? `${req.baseUrl || ''}${req.route.path}` : req.originalUrl || null,
+  method: req.method || null,
+  payloadBytes: parseInt(req.get ? req.get('content-length') : '', 10) || null
+});
+
+/**
+ * Default error response body
+ * @param {Object} error - { message, statusCode, code, stage, retryable, requestId, details }
+ * @param {Error} err - Original error
+ * @returns {Object} Response body
+ */
+const defaultErrorBody = (error, err) => ({
+  success: false,
+  error: {
+    ...error,
+    ...(process.env.NODE_ENV === 'development' && { stack: err.stack })
+  }
+});
+
+/**
+ * Create the error handling middleware
+ * @param {Object} [options] - Handler options
+ * @param {Function} [options.buildBody] - (error, err, req) => response body;
+ *   error is { message, statusCode, code, stage, retryable, requestId, details }
+ * @param {Function} [options.onError] - (err, req, error) => void, called for
+ *   unexpected errors after they are logged and reported, e.g. to page someone
+ * @param {boolean} [options.debug] - Show the message of crashes (errors
+ *   that aren't operational ApiErrors) instead of "Internal Server Error".
+ *   Defaults to ERROR_DEBUG, read per error so a config reload applies it;
+ *   never applies when NODE_ENV is production.
+ * @returns {Function} Express error middleware
  */
-const errorHandler = (err, req, res, next) => {
-  console.error(`[${new Date().toISOString()}] Error:`, err);
-  
-  const statusCode = err.statusCode || 500;
-  const message = err.message || 'Internal Server Error';
-  
-  res.status(statusCode).json({
-    success: false,
-    error: {
-      message,
+const createErrorHandler = ({
+  buildBody = defaultErrorBody,
+  onError,
+  debug
+} = {}) => (err, req, res, next) => {
+  const { statusCode, code, stage, retryable } = classifyError(err);
+
+  // Server-side failures other than "busy, retry" are unexpected: log them
+  // with the request context and report them
+  const unexpected = statusCode >= 500 && statusCode !== 503;
+  const log = req.log || logger;
+  if (unexpected) {
+    const context = getRequestContext(req);
+    log.error('Unhandled error while processing request', {
+      ...context,
       statusCode,
-      ...(process.env.NODE_ENV === 'development' && { stack: err.stack })
-    }
-  });
+      code,
+      stage,
+      error: err,
+      ...(err.cause && { cause: err.cause })
+    });
+    reportError(err, { ...context, statusCode, stage, code });
+  } else {
+    log.warn(err.message || 'Request failed', { statusCode, code, stage });
+  }
+
+  // Crash messages can leak internals; only deliberate errors explain themselves
+  const crashed = statusCode >= 500 && !(err instanceof ApiError && err.isOperational);
+  const debugMode = debug === undefined ? process.env.ERROR_DEBUG === 'true' : debug;
+  const showMessage = !crashed || (debugMode && process.env.NODE_ENV !== 'production');
+  const error = {
+    message: (showMessage && err.message) || 'Internal Server Error',
+    statusCode,
+    code,
+    stage,
+    retryable,
+    requestId: req.id || null,
+    ...(err.details && { details: err.details })
+  };
+
+  // The hook may be async; its failures must not change the response
+  if (unexpected && onError) {
+    Promise.resolve()
+      .then(() => onError(err, req, error))
+      .catch(hookError => log.error('Error hook failed', { error: hookError }));
+  }
+
+  if (err.retryAfter) {
+    res.set('Retry-After', String(err.retryAfter));
+  }
+
+  res.status(statusCode).json(buildBody(error, err, req));
 };
 
+// Error handling middleware with the default body
+const errorHandler = createErrorHandler();
+
 class ApiError extends Error {
   constructor(statusCode, message, isOperational = true, stack = '') {
     super(message);
@@ -32,9 +160,44 @@ class ApiError extends Error {
       Error.captureStackTrace(this, this.constructor);
     }
   }
+
+  /**
+   * Set the stage, code and/or retryability, keeping any value already set
+   * closer to where the error was raised
+   * @param {Object} fields - { stage, code, retryable }
+   * @returns {ApiError} This error
+   */
+  annotate({ stage, code, retryable } = {}) {
+    if (stage && !this.stage) {
+      this.stage = stage;
+    }
+    if (code && !this.code) {
+      this.code = code;
+    }
+    if (typeof retryable === 'boolean' && typeof this.retryable !== 'boolean') {
+      this.retryable = retryable;
+    }
+    return this;
+  }
+
+  /**
+   * Keep the underlying error when wrapping it, for logs and error reports
+   * @param {Error} error - Original error
+   * @returns {ApiError} This error
+   */
+  causedBy(error) {
+    if (error && error !== this) {
+      this.cause = error;
+    }
+    return this;
+  }
 }
 
 module.exports = {
   errorHandler,
-  ApiError
+  createErrorHandler,
+  getRequestContext,
+  classifyError,
+  ApiError,
+  ERROR_STAGES
 };
@@ -0,0 +1,158 @@
+const fs = require('fs/promises');
+const path = require('path');
+const { logger } = require('../utils/logger');
+
+// Bumped when the fixture layout changes, so scripts/replay.js can tell
+const FIXTURE_VERSION = 1;
+
+// Fields whose string values only shape the render (enums, IDs, dates, sizes)
+// and are recorded verbatim; every other string is masked, so fields added
+// later are redacted by default
+const STRUCTURAL_FIELDS = new Set([
+  'id', 'messageId', 'fromId', 'toId', 'aroundId',
+  'timestamp', 'start_time', 'from', 'to', 'time', 'aboutDate', 'createdAt',
+  'type', 'status', 'lastMessageStatus', 'direction', 'side', 'disappearingMessages',
+  'format', 'quality', 'pageSize', 'top', 'right', 'bottom', 'left',
+  'view', 'platform', 'theme', 'style', 'size', 'device', 'background', 'titleColor',
+  'headerDisplay', 'chatType', 'spoilers', 'contentFormat', 'locale', 'presence', 'timeFormat',
+  'template', 'debugData', 'position', 'align', 'scrollTo', 'tail', 'tailPlacement',
+  'limits', 'mediaErrors', 'duplicates', 'package', 'presets', 'waitUntil'
+]);
+
+// Message senders; "sender" also names the quoted author of a composer reply
+const SENDERS = new Set(['Bot', 'Customer']);
+
+// Fields holding URLs, recorded without credentials so replays can still fetch them
+const URL_FIELDS = new Set(['mediaUrl', 'avatarUrl', 'authorAvatars', 'wallpaper', 'image', 'url', 'proxy']);
+
+// Maps keyed by author names, whose keys are masked too
+const NAME_KEYED_FIELDS = new Set(['pseudonyms', 'authorAliases', 'authorAvatars']);
+
+/**
+ * Fixture recording settings. RECORD_FIXTURES_DIR enables recording;
+ * RECORD_FIXTURES_REDACT is "mask" (default) or "none", and
+ * RECORD_FIXTURES_SAMPLE_RATE the share of requests recorded (0-1).
+ * @returns {Object} Settings
+ */
+function getRecorderSettings() {
+  const sampleRate = parseFloat(process.env.RECORD_FIXTURES_SAMPLE_RATE);
+  return {
+    dir: process.env.RECORD_FIXTURES_DIR ? path.resolve(process.env.RECORD_FIXTURES_DIR) : null,
+    redact: (process.env.RECORD_FIXTURES_REDACT || 'mask').toLowerCase() === 'none' ? 'none' : 'mask',
+    sampleRate: Number.isNaN(sampleRate) ? 1 : Math.min(Math.max(sampleRate, 0), 1)
+  };
+}
+
+/**
+ * Mask text while keeping its shape: letters become x/X and digits 0, while
+ * whitespace, punctuation, formatting markers and emoji are kept, so the
+ * fixture still wraps, formats and matches like the original. The mapping
+ * is deterministic, so searchTerm keeps matching the masked content.
+ * @param {string} text - Text to mask
+ * @returns {string} Masked text
+ */
+function maskText(text) {
+  return text.replace(/[\p{L}\p{N}]/gu, char => {
+    if (/\p{N}/u.test(char)) {
+      return '0';
+    }
+    return /\p{Lu}/u.test(char) ? 'X' : 'x';
+  });
+}
+
+/**
+ * Redact a string by the field it belongs to
+ * @param {string} value - String value
+ * @param {string} key - Field name
+ * @returns {string} Redacted string
+ */
+function redactString(value, key) {
+  if (key === 'sender') {
+    return SENDERS.has(value) ? value : maskText(value);
+  }
+  if (STRUCTURAL_FIELDS.has(key)) {
+    return value;
+  }
+  if (URL_FIELDS.has(key) && /^([a-z][a-z0-9+.-]*:\/\/|data:)/i.test(value)) {
+    return value.replace(/^([a-z][a-z0-9+.-]*:\/\/)[^/?#]*@/i, '$1');
+  }
+  return maskText(value);
+}
+
+/**
+ * Redact a request body for a fixture. Redaction is deny-by-default: every
+ * string is masked except structural fields (see STRUCTURAL_FIELDS) and
+ * URLs, which only lose their credentials
+ * @param {*} value - Request body or part of it
+ * @param {string} [key] - Field the value belongs to
+ * @returns {*} Redacted copy
+ */
+function redactPayload(value, key) {
+  if (typeof value === 'string') {
+    return redactString(value, key);
+  }
+  if (Array.isArray(value)) {
+    return value.map(item => redactPayload(item, key));
+  }
+  if (!value || typeof value !== 'object') {
+    return value;
+  }
+  const nameKeyed = NAME_KEYED_FIELDS.has(key);
+  return Object.fromEntries(Object.entries(value).map(([field, item]) => (nameKeyed
+    ? [maskText(field), redactPayload(item, key)]
+    : [field, redactPayload(item, field)])));
+}
+
+/**
+ * Record POST requests as replayable fixtures (see scripts/replay.js): the
+ * redacted body, the response status and the pipeline stage timings, one
+ * JSON file per request in RECORD_FIXTURES_DIR. Admin requests and request
+ * headers are never recorded.
+ * @param {Object} req - Express request object
+ * @param {Object} res - Express response object
+ * @param {Function} next - Next middleware function
+ */
+const fixtureRecorder = (req, res, next) => {
+  const settings = getRecorderSettings();
+  if (!settings.dir || req.method !== 'POST' || req.path.startsWith('/api/admin') || Math.random() >= settings.sampleRate) {
+    return next();
+  }
+
+  const startedAt = process.hrtime.bigint();
+  // Copy before validation fills in defaults, so replays exercise today's defaults
+  const body = settings.redact === 'none'
+    ? JSON.parse(JSON.stringify(req.body || {}))
+    : redactPayload(req.body || {});
+  // Delivery targets and their credentials are never recorded, so replays don't redeliver
+  delete body.delivery;
+
+  res.on('finish', () => {
+    const recordedAt = new Date();
+    const fixture = {
+      fixture_version: FIXTURE_VERSION,
+      recorded_at: recordedAt.toISOString(),
+      request_id: req.id || null,
+      method: req.method,
+      path: req.originalUrl,
+      redaction: settings.redact,
+      status: res.statusCode,
+      duration_ms: Math.round(Number(process.hrtime.bigint() - startedAt) / 1e6),
+      timings: req.timings || {},
+      body
+    };
+    const fileName = `${recordedAt.toISOString().replace(/[:.]/g, '-')}-${req.id || process.hrtime.bigint()}.json`;
+
+    fs.mkdir(settings.dir, { recursive: true })
+      .then(() => fs.writeFile(path.join(settings.dir, fileName), JSON.stringify(fixture, null, 2)))
+      .catch(error => logger.warn('Failed to record fixture', { reason: error.message }));
+  });
+
+  next();
+};
+
+module.exports = {
+  fixtureRecorder,
+  redactPayload,
+  maskText,
+  FIXTURE_VERSION
+};
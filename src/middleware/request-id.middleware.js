@@ -0,0 +1,27 @@
+const { logger } = require('../utils/logger');
+const { randomIdGenerator } = require('../utils/clock');
+
+// Incoming IDs are accepted only when they look like an opaque token
+const REQUEST_ID_PATTERN = /^[\w.:-]{1,128}$/;
+
+/**
+ * Create the request ID middleware. It assigns a request ID (reusing a valid
+ * incoming X-Request-Id header), echoes it in the response and attaches a
+ * request-scoped logger as req.log.
+ * @param {Object} [services] - { idGenerator }; new IDs come from idGenerator.next(), random UUIDs by default
+ * @returns {Function} Express middleware
+ */
+const createRequestId = ({ idGenerator = randomIdGenerator } = {}) => (req, res, next) => {
+  const incoming = req.get('X-Request-Id');
+  req.id = incoming && REQUEST_ID_PATTERN.test(incoming) ? incoming : idGenerator.next();
+  req.log = logger.child({ requestId: req.id });
+  res.set('X-Request-Id', req.id);
+  next();
+};
+
+const requestId = createRequestId();
+
+module.exports = {
+  requestId,
+  createRequestId
+};
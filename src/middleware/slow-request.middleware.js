@@ -0,0 +1,103 @@
+const { logger } = require('../utils/logger');
+const { registry } = require('../utils/metrics');
+
+const slowRequests = registry.counter(
+  'http_slow_requests_total',
+  'Number of requests slower than SLOW_REQUEST_THRESHOLD_MS, by route'
+);
+
+// In-process alert hooks, called with the slow request report
+const slowRequestHooks = [];
+let lastWebhookAt = 0;
+
+/**
+ * Register a hook called for every slow request (e.g. to page someone)
+ * @param {Function} hook - Receives the slow request report
+ */
+function onSlowRequest(hook) {
+  slowRequestHooks.push(hook);
+}
+
+/**
+ * Slow request settings, configured through SLOW_REQUEST_THRESHOLD_MS,
+ * SLOW_REQUEST_WEBHOOK_URL and SLOW_REQUEST_WEBHOOK_COOLDOWN_MS
+ * @returns {Object} Settings
+ */
+function getSlowRequestSettings() {
+  return {
+    thresholdMs: parseInt(process.env.SLOW_REQUEST_THRESHOLD_MS, 10) || 5000,
+    webhookUrl: process.env.SLOW_REQUEST_WEBHOOK_URL || null,
+    webhookCooldownMs: parseInt(process.env.SLOW_REQUEST_WEBHOOK_COOLDOWN_MS, 10) || 60000
+  };
+}
+
+/**
+ * Post a slow request report to the webhook, at most once per cooldown so a
+ * degraded Chrome does not flood the receiver
+ * @param {Object} report - Slow request report
+ * @param {Object} settings - Slow request settings
+ */
+function notifyWebhook(report, settings) {
+  const now = Date.now();
+  if (!settings.webhookUrl || now - lastWebhookAt < settings.webhookCooldownMs) {
+    return;
+  }
+  lastWebhookAt = now;
+
+  fetch(settings.webhookUrl, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ event: 'slow_request', ...report }),
+    signal: AbortSignal.timeout(5000)
+  }).catch(error => logger.warn('Slow request webhook failed', { reason: error.message }));
+}
+
+/**
+ * Time every request and flag the ones over the threshold with their
+ * pipeline stage timings. Handlers record stages into req.timings.
+ * @param {Object} req - Express request object
+ * @param {Object} res - Express response object
+ * @param {Function} next - Next middleware function
+ */
+const slowRequestLogger = (req, res, next) => {
+  const startedAt = process.hrtime.bigint();
+  req.timings = {};
+
+  res.on('finish', () => {
+    const durationMs = Number(process.hrtime.bigint() - startedAt) / 1e6;
+    const settings = getSlowRequestSettings();
+    if (durationMs < settings.thresholdMs) {
+      return;
+    }
+
+    const route = req.route ? `${req.baseUrl || ''}${req.route.path}` : req.path;
+    const report = {
+      requestId: req.id || null,
+      method: req.method,
+      route,
+      statusCode: res.statusCode,
+      durationMs: Math.round(durationMs),
+      thresholdMs: settings.thresholdMs,
+      timings: req.timings,
+      timestamp: new Date().toISOString()
+    };
+
+    slowRequests.inc({ route });
+    (req.log || logger).warn('Slow request', report);
+    slowRequestHooks.forEach(hook => {
+      try {
+        hook(report);
+      } catch (error) {
+        logger.warn('Slow request hook failed', { error });
+      }
+    });
+    notifyWebhook(report, settings);
+  });
+
+  next();
+};
+
+module.exports = {
+  slowRequestLogger,
+  onSlowRequest
+};
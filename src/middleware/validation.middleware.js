@@ -1,25 +1,419 @@
 const Joi = require('joi');
 const { ApiError } = require('./error.middleware');
+const { CONTENT_FORMATS } = require('../utils/content-format');
+const { DIRECTIONS } = require('../utils/text-direction');
+const { WATERMARK_POSITIONS } = require('../utils/watermark');
+const { THEMES } = require('../utils/theme');
+const { MEDIA_TYPES } = require('../utils/media');
+const { MESSAGE_STATUSES } = require('../utils/message-formatter');
+const { LIMIT_MODES, WHATSAPP_LIMITS } = require('../utils/whatsapp-limits');
+const { TIME_FORMATS } = require('../utils/i18n');
+const { DEVICE_FRAME_NAMES } = require('../utils/device-frame');
+const { WALLPAPER_NAMES } = require('../utils/wallpaper');
+const deliveryService = require('../services/delivery.service');
+
+// Longest message content accepted: twice WhatsApp's own limit, so
+// limits "warn" and "off" can still show over-long messages
+const MAX_CONTENT_LENGTH = 2 * WHATSAPP_LIMITS.message;
 
 // Define validation schemas
 const messageSchema = Joi.object({
+  id: Joi.string().max(128).optional(),
   timestamp: Joi.string().isoDate().required(),
   sender: Joi.string().valid('Bot', 'Customer').required(),
-  content: Joi.string().required(),
+  type: Joi.string().valid('text', ...MEDIA_TYPES).default('text'),
+  // Media messages may have an empty caption
+  content: Joi.string().max(MAX_CONTENT_LENGTH).when('type', { is: 'text', then: Joi.required(), otherwise: Joi.allow('').default('') }),
+  mediaUrl: Joi.string().max(10 * 1024 * 1024).pattern(/^(https?:\/\/|data:)/).when('type', {
+    is: 'text',
+    then: Joi.forbidden()
+  }),
+  fileName: Joi.string().max(255).optional(),
+  fileSize: Joi.number().integer().min(0).optional(),
+  duration: Joi.number().integer().min(0).max(86400).optional(),
+  // Shared location; an image mediaUrl replaces the map thumbnail
+  location: Joi.object({
+    lat: Joi.number().min(-90).max(90).required(),
+    lng: Joi.number().min(-180).max(180).required(),
+    label: Joi.string().max(200).optional(),
+    address: Joi.string().max(300).optional()
+  }).when('type', { is: 'location', then: Joi.required(), otherwise: Joi.forbidden() }),
   recipient_name: Joi.string().optional(),
-  recipient_phone: Joi.string().optional()
+  // Group member who wrote a received message
+  author: Joi.string().max(100).optional(),
+  // Receipt of a sent message; received messages ignore it
+  status: Joi.string().valid(...MESSAGE_STATUSES).optional(),
+  // WhatsApp profile name of an author who is a bare phone number
+  pushName: Joi.string().max(100).optional(),
+  // Bubble direction, instead of detecting it from the content
+  direction: Joi.string().valid(...DIRECTIONS).optional(),
+  // Business API template message; its body is held to the template limit
+  templateMessage: Joi.boolean().default(false),
+  recipient_phone: Joi.string().optional(),
+  blurred: Joi.boolean().default(false),
+  redacted: Joi.boolean().default(false)
+});
+
+// Capture modes that only make sense for the chat view (options.view)
+const chatViewOnly = name => ({
+  is: Joi.not('chat'),
+  then: Joi.forbidden().messages({ 'any.unknown': `"${name}" is only available with view "chat"` })
+});
+
+// CSS length accepted by page.pdf margins, e.g. "15mm"
+const pdfMarginSchema = Joi.string().pattern(/^\d+(\.\d+)?(mm|cm|in|px)$/);
+
+const watermarkSchema = Joi.object({
+  text: Joi.string().max(200).required(),
+  position: Joi.string().valid(...WATERMARK_POSITIONS).default('bottom-right'),
+  opacity: Joi.number().min(0.05).max(1).default(0.6)
+});
+
+// Profile photo: http(s) URL (see MEDIA_URL_ALLOWLIST) or data: URL
+const avatarUrlSchema = Joi.string().max(2 * 1024 * 1024).pattern(/^(https?:\/\/|data:image\/)/);
+const wallpaperImageSchema = Joi.string().max(10 * 1024 * 1024).pattern(/^(https?:\/\/|data:image\/)/);
+
+// Per-conversation flags of the chat-list view
+const chatListFlags = {
+  muted: Joi.boolean().default(false),
+  pinned: Joi.boolean().default(false),
+  archived: Joi.boolean().default(false),
+  typing: Joi.boolean().default(false),
+  unreadCount: Joi.number().integer().min(0).max(9999).default(0),
+  // Defaults to the status of the last message, else "read"
+  lastMessageStatus: Joi.string().valid(...MESSAGE_STATUSES).optional()
+};
+
+const chatListChatSchema = Joi.object({
+  name: Joi.string().max(100).required(),
+  lastMessage: Joi.string().max(500).allow('').default(''),
+  time: Joi.string().max(32).optional(),
+  lastMessageFromMe: Joi.boolean().default(false),
+  ...chatListFlags
 });
 
 const optionsSchema = Joi.object({
   width: Joi.number().min(300).max(1200).default(400),
   headerDisplay: Joi.string().valid('name', 'phone').default('phone'),
   quality: Joi.string().valid('low', 'medium', 'high').default('high'),
-  format: Joi.string().valid('png', 'jpeg', 'webp').default('png')
+  format: Joi.string().valid('png', 'jpeg', 'webp', 'pdf').default('png'),
+  pdf: Joi.object({
+    pageSize: Joi.string().valid('A3', 'A4', 'A5', 'Letter', 'Legal').default('A4'),
+    landscape: Joi.boolean().default(false),
+    margin: Joi.object({
+      top: pdfMarginSchema,
+      right: pdfMarginSchema,
+      bottom: pdfMarginSchema,
+      left: pdfMarginSchema
+    }).default(),
+    header: Joi.boolean().default(true),
+    footer: Joi.boolean().default(true)
+  }).optional(),
+  anonymize: Joi.alternatives().try(
+    Joi.boolean(),
+    Joi.object({
+      enabled: Joi.boolean().default(true),
+      names: Joi.boolean().default(true),
+      phones: Joi.boolean().default(true),
+      emails: Joi.boolean().default(true),
+      avatar: Joi.boolean().default(true),
+      pseudonyms: Joi.object().pattern(Joi.string(), Joi.string()).optional(),
+      extraNames: Joi.array().items(Joi.string()).optional()
+    })
+  ).default(false),
+  authorAliases: Joi.object().pattern(Joi.string().max(128), Joi.string().max(100)).max(500).optional(),
+  avatarUrl: avatarUrlSchema.optional(),
+  authorAvatars: Joi.object().pattern(Joi.string().max(100), avatarUrlSchema).max(256).optional(),
+  formatAuthorPhones: Joi.boolean().default(true),
+  chatType: Joi.string().valid('standard', 'community').default('standard'),
+  contentFilter: Joi.object({
+    enabled: Joi.boolean().default(true),
+    words: Joi.array().items(Joi.string()).optional(),
+    presets: Joi.array().items(Joi.string().valid('otp', 'card')).optional(),
+    style: Joi.string().valid('asterisks', 'blur').default('asterisks')
+  }).optional(),
+  spoilers: Joi.string().valid('hidden', 'revealed').default('hidden'),
+  contentFormat: Joi.string().valid(...CONTENT_FORMATS).default('whatsapp'),
+  autoLink: Joi.alternatives().try(
+    Joi.boolean(),
+    Joi.object({
+      phones: Joi.boolean().default(true),
+      emails: Joi.boolean().default(true),
+      anchors: Joi.boolean().default(false)
+    })
+  ).default(false),
+  // "auto" mirrors the chat when most messages are right-to-left
+  direction: Joi.string().valid(...DIRECTIONS, 'auto').default('auto'),
+  // Without a locale, system strings are English and times keep the legacy
+  // 12-hour format
+  locale: Joi.string().pattern(/^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$/).optional(),
+  presence: Joi.string().valid('lastSeen', 'online', 'typing').default('lastSeen'),
+  // Custom status line shown as given, instead of the presence preset
+  headerStatus: Joi.string().max(100).allow('').optional(),
+  typingIndicator: Joi.alternatives().try(
+    Joi.boolean(),
+    Joi.object({
+      side: Joi.string().valid('received', 'sent').default('received')
+    })
+  ).default(false).when('view', chatViewOnly('typingIndicator')),
+  // 12- or 24-hour clock; the locale's own without one
+  timeFormat: Joi.string().valid(...Object.keys(TIME_FORMATS)).optional(),
+  autoDirection: Joi.boolean().default(true),
+  template: Joi.string().max(64).optional(),
+  debugData: Joi.string().valid('off', 'include', 'only').default('off'),
+  outputFileName: Joi.string().max(255).optional(),
+  watermark: watermarkSchema.optional(),
+  stripMetadata: Joi.boolean().default(false),
+  provenance: Joi.boolean().default(false).when('stripMetadata', {
+    is: true,
+    then: Joi.valid(false).messages({ 'any.only': '"provenance" cannot be combined with "stripMetadata"' })
+  }),
+  searchTerm: Joi.string().max(200).optional(),
+  cropToMatch: Joi.boolean().default(false).when('view', {
+    is: Joi.not('chat'),
+    then: Joi.valid(false).messages({ 'any.only': '"cropToMatch" is only available with view "chat"' })
+  }),
+  cropHeight: Joi.number().integer().min(100).max(4000).default(600),
+  scrollTo: Joi.alternatives().try(
+    Joi.string().valid('top', 'bottom'),
+    Joi.object({
+      messageId: Joi.string().max(128).required(),
+      align: Joi.string().valid('top', 'center', 'bottom').default('center')
+    })
+  ).optional().when('view', chatViewOnly('scrollTo')),
+  cropToMessage: Joi.object({
+    messageId: Joi.string().max(128),
+    fromId: Joi.string().max(128),
+    toId: Joi.string().max(128),
+    padding: Joi.number().integer().min(0).max(100).default(12)
+  }).xor('messageId', 'fromId').and('fromId', 'toId').when('format', {
+    is: 'pdf',
+    then: Joi.forbidden().messages({ 'any.unknown': '"cropToMessage" cannot be combined with format "pdf"' })
+  }).when('view', chatViewOnly('cropToMessage')),
+  view: Joi.string().valid('chat', 'notification', 'contact-info', 'chat-list').default('chat'),
+  notification: Joi.object({
+    style: Joi.string().valid('ios', 'android').optional(),
+    count: Joi.number().integer().min(1).max(5).default(1),
+    background: Joi.alternatives().try(
+      Joi.string().valid('transparent'),
+      Joi.string().pattern(/^#[0-9a-fA-F]{6}$/)
+    ).default('transparent')
+  }).optional(),
+  chatList: Joi.object({
+    conversation: Joi.object(chatListFlags).optional(),
+    chats: Joi.array().items(chatListChatSchema).max(50).default([])
+  }).optional(),
+  contact: Joi.object({
+    name: Joi.string().max(100).optional(),
+    phone: Joi.string().max(32).optional(),
+    about: Joi.string().max(139).default('Hey there! I am using WhatsApp.'),
+    aboutDate: Joi.date().iso().optional(),
+    mediaCount: Joi.number().integer().min(0).max(99999).default(0),
+    muted: Joi.boolean().default(false),
+    disappearingMessages: Joi.string().valid('off', '24h', '7d', '90d').default('off'),
+    group: Joi.object({
+      description: Joi.string().max(512).optional(),
+      createdBy: Joi.string().max(100).optional(),
+      createdAt: Joi.date().iso().optional(),
+      participants: Joi.array().items(Joi.object({
+        name: Joi.string().max(100).required(),
+        phone: Joi.string().max(32).optional(),
+        admin: Joi.boolean().default(false)
+      })).min(1).max(50).required()
+    }).optional()
+  }).optional(),
+  platform: Joi.string().valid('ios', 'android').optional(),
+  theme: Joi.string().valid(...THEMES).default('light'),
+  wallpaper: Joi.alternatives(
+    Joi.string().valid(...WALLPAPER_NAMES),
+    Joi.string().pattern(/^#[0-9a-fA-F]{6}$/),
+    wallpaperImageSchema,
+    Joi.object({
+      image: wallpaperImageSchema.required(),
+      size: Joi.string().valid('cover', 'tile').default('cover'),
+      dim: Joi.number().min(0).max(0.9).default(0)
+    })
+  ).optional().when('view', chatViewOnly('wallpaper')),
+  appIcon: Joi.object({
+    badge: Joi.number().integer().min(0).max(9999).required(),
+    size: Joi.number().integer().min(48).max(512).default(180)
+  }).optional(),
+  keyboard: Joi.object({
+    style: Joi.string().valid('ios', 'android').optional(),
+    theme: Joi.string().valid('light', 'dark').optional(),
+    draft: Joi.string().max(500).allow('').optional()
+  }).optional().when('composer.attachmentTray', {
+    is: true,
+    then: Joi.forbidden().messages({ 'any.unknown': '"keyboard" cannot be shown with the attachment tray open' })
+  }),
+  composer: Joi.object({
+    draft: Joi.string().max(500).allow('').optional(),
+    replyTo: Joi.alternatives().try(
+      Joi.object({ messageId: Joi.string().max(128).required() }),
+      Joi.object({ sender: Joi.string().max(100).required(), content: Joi.string().max(5000).required() })
+    ).optional(),
+    attachmentTray: Joi.boolean().default(false)
+  }).optional(),
+  bubbles: Joi.object({
+    tail: Joi.string().valid('all', 'first', 'last', 'none').default('all'),
+    tailPlacement: Joi.string().valid('bottom', 'top').default('bottom'),
+    radius: Joi.number().min(0).max(24).default(7.5),
+    groupSpacing: Joi.number().min(0).max(40).default(2),
+    senderSpacing: Joi.number().min(0).max(40).default(2)
+  }).optional(),
+  viewportHeight: Joi.number().integer().min(300).max(3000).default(800),
+  animation: Joi.object({
+    type: Joi.string().valid('scroll', 'typing').default('scroll'),
+    format: Joi.string().valid('gif', 'mp4', 'frames').default('gif'),
+    typingDuration: Joi.number().min(0.5).max(10).default(2),
+    fps: Joi.number().integer().min(1).max(30).default(10),
+    duration: Joi.number().min(1).max(20).default(4),
+    hold: Joi.number().min(0).max(5).default(1),
+    scale: Joi.number().valid(1, 2).default(1)
+  }).optional().when('view', chatViewOnly('animation')),
+  window: Joi.object({
+    from: Joi.string().isoDate().optional(),
+    to: Joi.string().isoDate().optional(),
+    last: Joi.number().integer().min(1).optional(),
+    aroundId: Joi.string().max(128).optional(),
+    context: Joi.number().integer().min(0).max(500).default(5)
+  }).oxor('last', 'aroundId').optional(),
+  // Phone mockup around the capture
+  deviceFrame: Joi.alternatives().try(
+    Joi.string().valid(...DEVICE_FRAME_NAMES),
+    Joi.object({
+      device: Joi.string().valid(...DEVICE_FRAME_NAMES).required(),
+      time: Joi.string().pattern(/^\d{1,2}:\d{2}$/).default('9:41'),
+      battery: Joi.number().integer().min(0).max(100).default(100)
+    })
+  ).optional().when('format', {
+    is: 'pdf',
+    then: Joi.forbidden().messages({ 'any.unknown': '"deviceFrame" cannot be combined with format "pdf"' })
+  }),
+  consoleWarnings: Joi.boolean().default(false),
+  resourceReport: Joi.boolean().default(false),
+  // Messages beyond WhatsApp's limits: response warnings, a 400, or nothing
+  limits: Joi.string().valid(...LIMIT_MODES).default('warn'),
+  // Media downloads that fail: a download placeholder with a warning, or a 502
+  mediaErrors: Joi.string().valid('placeholder', 'error').default('placeholder')
+}).oxor('cropToMessage', 'scrollTo').oxor('cropToMessage', 'animation');
+
+const mergeSourceSchema = Joi.object({
+  name: Joi.string().max(64).optional(),
+  sender: Joi.string().valid('Bot', 'Customer').optional(),
+  messages: Joi.array().items(messageSchema).min(1).required()
 });
 
+const mergeOptionsSchema = Joi.object({
+  duplicates: Joi.string().valid('drop', 'keep', 'error').default('drop')
+});
+
+// A conversation is given either as messages or as sources to merge
 const requestSchema = Joi.object({
-  messages: Joi.array().items(messageSchema).min(1).required(),
+  messages: Joi.array().items(messageSchema).min(1),
+  sources: Joi.array().items(mergeSourceSchema).min(2).max(10),
+  merge: mergeOptionsSchema.optional(),
   options: optionsSchema.optional()
+}).xor('messages', 'sources');
+
+// Where to send the output besides the response, one key per registered deliverer
+const deliverySchema = Joi.object(deliveryService.getSchemas());
+
+const screenshotRequestSchema = requestSchema.keys({
+  delivery: deliverySchema.optional()
+});
+
+const mergeSchema = Joi.object({
+  sources: Joi.array().items(mergeSourceSchema).min(2).max(10).required(),
+  merge: mergeOptionsSchema.default()
+});
+
+const compositionSchema = Joi.object({
+  panels: Joi.array().items(requestSchema.keys({
+    title: Joi.string().max(100).allow('').optional()
+  })).min(2).max(4).required(),
+  options: Joi.object({
+    gap: Joi.number().integer().min(0).max(100).default(16),
+    background: Joi.string().pattern(/^#[0-9a-fA-F]{6}$/).default('#d1d7db'),
+    titleColor: Joi.string().pattern(/^#[0-9a-fA-F]{6}$/).default('#111b21'),
+    provenance: Joi.boolean().default(false)
+  }).default()
+});
+
+// Flat payload for low-code tools (POST /api/simple)
+const simpleSchema = Joi.object({
+  text: Joi.string().max(100000).required(),
+  chat_name: Joi.string().max(100).optional(),
+  chat_phone: Joi.string().max(32).optional(),
+  me: Joi.string().max(64).optional(),
+  theme: Joi.string().max(64).optional(),
+  start_time: Joi.string().isoDate().optional(),
+  interval_seconds: Joi.number().integer().min(0).max(86400).default(60),
+  width: Joi.number().min(300).max(1200).default(400),
+  format: Joi.string().valid('png', 'jpeg', 'webp').default('png'),
+  quality: Joi.string().valid('low', 'medium', 'high').default('high')
+});
+
+// Render and share to Slack (POST /api/integrations/slack)
+const slackShareSchema = requestSchema.keys({
+  slack: deliveryService.getSchemas().slack.default()
+});
+
+const provenanceVerifySchema = Joi.object({
+  image: Joi.string().required()
+});
+
+// Streamed batches hold one output at a time, so they may be much larger.
+// A mail merge renders mailMerge once per entry of variables.
+const batchSchema = Joi.object({
+  items: Joi.array().items(requestSchema).min(1).max(20).when('stream', {
+    is: true,
+    then: Joi.array().max(500)
+  }),
+  mailMerge: requestSchema,
+  variables: Joi.array().items(
+    Joi.object().pattern(/^[A-Za-z_][A-Za-z0-9_]*$/, Joi.alternatives(Joi.string().allow('').max(5000), Joi.number(), Joi.boolean()))
+  ).min(1).max(20).when('stream', {
+    is: true,
+    then: Joi.array().max(500)
+  }),
+  package: Joi.string().valid('zip', 'tar').default('zip'),
+  stream: Joi.boolean().default(false)
+}).xor('items', 'mailMerge').and('mailMerge', 'variables');
+
+const urlRenderSchema = Joi.object({
+  url: Joi.string().uri({ scheme: ['http', 'https'] }).required(),
+  options: Joi.object({
+    viewport: Joi.object({
+      width: Joi.number().min(200).max(3840).default(1280),
+      height: Joi.number().min(200).max(4320).default(800),
+      deviceScaleFactor: Joi.number().min(1).max(3).default(1)
+    }).default(),
+    selector: Joi.string().optional(),
+    fullPage: Joi.boolean().default(true),
+    waitUntil: Joi.string().valid('load', 'domcontentloaded', 'networkidle0', 'networkidle2').default('networkidle2'),
+    waitForSelector: Joi.string().optional(),
+    delay: Joi.number().min(0).max(10000).default(0),
+    timeout: Joi.number().min(1000).max(60000).default(30000),
+    consoleWarnings: Joi.boolean().default(false),
+    resourceReport: Joi.boolean().default(false),
+    watermark: watermarkSchema.optional(),
+    stripMetadata: Joi.boolean().default(false),
+    proxy: Joi.alternatives().try(
+      Joi.string().uri({ scheme: ['http', 'https', 'socks5'] }),
+      Joi.object({
+        url: Joi.string().uri({ scheme: ['http', 'https', 'socks5'] }).required(),
+        bypass: Joi.array().items(Joi.string().max(255)).max(50).default([])
+      })
+    ).optional(),
+    quality: Joi.string().valid('low', 'medium', 'high').default('high'),
+    format: Joi.string().valid('png', 'jpeg', 'webp').default('png')
+  }).default()
+});
+
+const templateUploadSchema = Joi.object({
+  name: Joi.string().pattern(/^[a-z0-9][a-z0-9-]{0,63}$/).required(),
+  source: Joi.string().required()
 });
 
 /**
@@ -32,7 +426,8 @@ const validateRequest = (schema) => (req, res, next) => {
   
   if (error) {
     const errorMessage = error.details.map(detail => detail.message).join(', ');
-    return next(new ApiError(400, `Validation error: ${errorMessage}`));
+    return next(new ApiError(400, `Validation error: ${errorMessage}`)
+      .annotate({ stage: 'validate', code: 'validation_failed' }));
   }
   
   // Replace the request body with the validated value
@@ -42,8 +437,28 @@ const validateRequest = (schema) => (req, res, next) => {
 
 // Export validation middleware for different schemas
 module.exports = {
-  validateScreenshotRequest: validateRequest(requestSchema),
+  validateScreenshotRequest: validateRequest(screenshotRequestSchema),
+  validateAnalyzeRequest: validateRequest(requestSchema),
+  validatePreviewRequest: validateRequest(requestSchema),
+  validateTemplateUpload: validateRequest(templateUploadSchema),
+  validateUrlRenderRequest: validateRequest(urlRenderSchema),
+  validateBatchRequest: validateRequest(batchSchema),
+  validateMergeRequest: validateRequest(mergeSchema),
+  validateCompositionRequest: validateRequest(compositionSchema),
+  validateProvenanceVerifyRequest: validateRequest(provenanceVerifySchema),
+  validateSimpleRequest: validateRequest(simpleSchema),
+  validateSlackShareRequest: validateRequest(slackShareSchema),
   messageSchema,
   optionsSchema,
-  requestSchema
+  requestSchema,
+  screenshotRequestSchema,
+  deliverySchema,
+  batchSchema,
+  mergeSchema,
+  compositionSchema,
+  provenanceVerifySchema,
+  simpleSchema,
+  slackShareSchema,
+  templateUploadSchema,
+  urlRenderSchema
 };
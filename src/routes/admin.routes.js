@@ -0,0 +1,78 @@
+const { createAdminController } = require('../controllers/admin.controller');
+
+/**
+ * Register the admin routes
+ * @param {Object} groups - Route groups from createRouter
+ * @param {Object} [services] - Service overrides from createRouter
+ */
+module.exports = ({ admin }, services) => {
+  const { getBrowserStatus, recycleBrowser, getConfig, reload } = createAdminController(services);
+
+  /**
+   * @swagger
+   * /api/admin/browser:
+   *   get:
+   *     summary: Get the browser state
+   *     description: Requires an admin key from ADMIN_API_KEYS.
+   *     security:
+   *       - ApiKeyAuth: []
+   *     responses:
+   *       200:
+   *         description: Browser state (readiness, render count, retired instances)
+   *       401:
+   *         description: Missing or invalid admin key
+   */
+  admin.get('/browser', getBrowserStatus);
+
+  /**
+   * @swagger
+   * /api/admin/browser/recycle:
+   *   post:
+   *     summary: Restart the browser
+   *     description: Requires an admin key from ADMIN_API_KEYS. In-flight renders finish on the old instance;
+   *       new renders wait for the new one.
+   *     security:
+   *       - ApiKeyAuth: []
+   *     responses:
+   *       200:
+   *         description: Browser restarted
+   *       401:
+   *         description: Missing or invalid admin key
+   */
+  admin.post('/browser/recycle', recycleBrowser);
+
+  /**
+   * @swagger
+   * /api/admin/config:
+   *   get:
+   *     summary: Get the effective configuration
+   *     description: Requires an admin key from ADMIN_API_KEYS. Returns the configuration variables that are set
+   *       (credentials redacted, API keys in tenant maps masked), the templates, the Chrome version and the pool sizes.
+   *     security:
+   *       - ApiKeyAuth: []
+   *     responses:
+   *       200:
+   *         description: Configuration summary
+   *       401:
+   *         description: Missing or invalid admin key
+   */
+  admin.get('/config', getConfig);
+
+  /**
+   * @swagger
+   * /api/admin/reload:
+   *   post:
+   *     summary: Reload the configuration
+   *     description: Requires an admin key from ADMIN_API_KEYS. Re-reads the .env file and applies the variables
+   *       that are safe to change at runtime (API keys, log level, quotas, policies, limits) without restarting
+   *       the server or the browser. Same as sending SIGHUP.
+   *     security:
+   *       - ApiKeyAuth: []
+   *     responses:
+   *       200:
+   *         description: Names of the variables applied (changed) and of changed variables that need a restart (restartRequired)
+   *       401:
+   *         description: Missing or invalid admin key
+   */
+  admin.post('/reload', reload);
+};
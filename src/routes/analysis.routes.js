@@ -0,0 +1,92 @@
+const { validateAnalyzeRequest, validateMergeRequest } = require('../middleware/validation.middleware');
+const { createAnalysisController } = require('../controllers/analysis.controller');
+
+/**
+ * Register the analysis routes
+ * @param {Object} groups - Route groups from createRouter
+ * @param {Object} [services] - Service overrides from createRouter
+ */
+module.exports = ({ public: publicRoutes }, services) => {
+  const { analyzeChat, mergeChats } = createAnalysisController(services);
+
+  /**
+   * @swagger
+   * /api/analyze:
+   *   post:
+   *     summary: Get conversation statistics
+   *     description: Accepts the same body as /api/whatsapp-screenshot and returns statistics computed from the
+   *       processed chat data (after anonymization and content masking), without rendering an image.
+   *     requestBody:
+   *       required: true
+   *       content:
+   *         application/json:
+   *           schema:
+   *             type: object
+   *             required:
+   *               - messages
+   *             properties:
+   *               messages:
+   *                 type: array
+   *                 items:
+   *                   type: object
+   *               options:
+   *                 type: object
+   *     responses:
+   *       200:
+   *         description: Message and word counts per author, media counts, time span and busiest hour
+   *       400:
+   *         description: Invalid request
+   */
+  publicRoutes.post('/analyze', validateAnalyzeRequest, analyzeChat);
+
+  /**
+   * @swagger
+   * /api/merge:
+   *   post:
+   *     summary: Merge several message arrays into one conversation
+   *     description: Sorts the messages of 2-10 sources chronologically (ties keep the source order) and reports
+   *       messages found in more than one source.
+   *     requestBody:
+   *       required: true
+   *       content:
+   *         application/json:
+   *           schema:
+   *             type: object
+   *             required:
+   *               - sources
+   *             properties:
+   *               sources:
+   *                 type: array
+   *                 minItems: 2
+   *                 maxItems: 10
+   *                 items:
+   *                   type: object
+   *                   required:
+   *                     - messages
+   *                   properties:
+   *                     name:
+   *                       type: string
+   *                       example: "agent-log"
+   *                     sender:
+   *                       type: string
+   *                       enum: [Bot, Customer]
+   *                       description: "Override the sender of every message in this source"
+   *                     messages:
+   *                       type: array
+   *                       items:
+   *                         type: object
+   *               merge:
+   *                 type: object
+   *                 properties:
+   *                   duplicates:
+   *                     type: string
+   *                     enum: [drop, keep, error]
+   *                     default: drop
+   *     responses:
+   *       200:
+   *         description: Merged messages and a report of the sources and duplicates
+   *       409:
+   *         description: Duplicates found with duplicates set to "error"
+   */
+  publicRoutes.post('/merge', validateMergeRequest, mergeChats);
+};
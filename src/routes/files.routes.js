@@ -0,0 +1,37 @@
+const { createFilesController } = require('../controllers/files.controller');
+
+/**
+ * Register the stored output routes
+ * @param {Object} groups - Route groups from createRouter
+ * @param {Object} [services] - Service overrides from createRouter
+ */
+module.exports = ({ public: publicRoutes }, services) => {
+  const { getFileByHash } = createFilesController(services);
+
+  /**
+   * @swagger
+   * /api/files/by-hash/{sha256}:
+   *   get:
+   *     summary: Download a stored output by content hash
+   *     description: Available when persistence is enabled (OUTPUT_DIR). Outputs are stored under the SHA-256 of
+   *       their bytes, returned as metadata.sha256 by the render endpoints. The response carries the hash as ETag
+   *       and Digest headers
+   *     parameters:
+   *       - in: path
+   *         name: sha256
+   *         required: true
+   *         schema:
+   *           type: string
+   *           pattern: "^[a-fA-F0-9]{64}$"
+   *     responses:
+   *       200:
+   *         description: The stored file
+   *       304:
+   *         description: Not modified (If-None-Match matched)
+   *       400:
+   *         description: Malformed hash
+   *       404:
+   *         description: No file with this hash, or persistence is disabled
+   */
+  publicRoutes.get('/files/by-hash/:sha256', getFileByHash);
+};
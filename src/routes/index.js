@@ -0,0 +1,43 @@
+const express = require('express');
+const RouteGroup = require('./route-group');
+const { requireApiKey, requireAdminKey, identifyApiKey } = require('../middleware/auth.middleware');
+const systemRoutes = require('./system.routes');
+const screenshotRoutes = require('./screenshot.routes');
+const templateRoutes = require('./template.routes');
+const adminRoutes = require('./admin.routes');
+const analysisRoutes = require('./analysis.routes');
+const provenanceRoutes = require('./provenance.routes');
+const filesRoutes = require('./files.routes');
+const schemaRoutes = require('./schema.routes');
+const integrationRoutes = require('./integration.routes');
+const previewRoutes = require('./preview.routes');
+const jobRoutes = require('./job.routes');
+
+/**
+ * Build the application router. Routes are registered on groups, each with
+ * its own middleware chain:
+ * - root: unprefixed operational endpoints
+ * - public: /api, no authentication; a valid API key identifies the tenant
+ * - authenticated: /api, requires an API key (API_KEYS)
+ * - admin: /api/admin, requires an admin key (ADMIN_API_KEYS)
+ * @param {Object} [services] - Service overrides passed to the route modules,
+ *   e.g. { screenshotService } to render with a fake in tests
+ * @returns {Object} Express router
+ */
+const createRouter = (services = {}) => {
+  const root = new RouteGroup(express.Router());
+  const api = root.group({ prefix: '/api' });
+  const groups = {
+    root,
+    public: api.group({ middleware: [identifyApiKey] }),
+    authenticated: api.group({ middleware: [requireApiKey] }),
+    admin: api.group({ prefix: '/admin', middleware: [requireAdminKey] })
+  };
+
+  [systemRoutes, screenshotRoutes, analysisRoutes, provenanceRoutes, filesRoutes, previewRoutes, jobRoutes, schemaRoutes, integrationRoutes, templateRoutes, adminRoutes].forEach(register => register(groups, services));
+  return root.router;
+};
+
+module.exports = {
+  createRouter
+};
@@ -0,0 +1,66 @@
+const { createIntegrationController } = require('../controllers/integration.controller');
+const { validateSlackShareRequest } = require('../middleware/validation.middleware');
+
+/**
+ * Register the render-and-share integration routes
+ * @param {Object} groups - Route groups from createRouter
+ * @param {Object} [services] - Service overrides from createRouter
+ */
+module.exports = ({ authenticated }, services) => {
+  const { shareToSlack } = createIntegrationController(services);
+
+  /**
+   * @swagger
+   * /api/integrations/slack:
+   *   post:
+   *     summary: Render a chat screenshot and post it to Slack
+   *     description: Takes the same messages, sources, merge and options as /api/whatsapp-screenshot, renders the
+   *       image and uploads it to a Slack channel with the bot token in SLACK_BOT_TOKEN. The channel defaults to
+   *       SLACK_DEFAULT_CHANNEL; other channels must be listed in SLACK_ALLOWED_CHANNELS
+   *     security:
+   *       - ApiKeyAuth: []
+   *     requestBody:
+   *       required: true
+   *       content:
+   *         application/json:
+   *           schema:
+   *             type: object
+   *             properties:
+   *               messages:
+   *                 type: array
+   *                 items:
+   *                   type: object
+   *               sources:
+   *                 type: array
+   *                 items:
+   *                   type: object
+   *               options:
+   *                 type: object
+   *               slack:
+   *                 type: object
+   *                 properties:
+   *                   channel:
+   *                     type: string
+   *                     description: Channel ID, e.g. C0123456789
+   *                   title:
+   *                     type: string
+   *                     description: File title (defaults to the file name)
+   *                   comment:
+   *                     type: string
+   *                     description: Message posted with the file
+   *     responses:
+   *       200:
+   *         description: Posted; data.slack holds the channel, file_id and permalink
+   *       400:
+   *         description: Invalid input
+   *       401:
+   *         description: Missing or invalid API key
+   *       403:
+   *         description: Channel not allowed
+   *       501:
+   *         description: SLACK_BOT_TOKEN is not configured
+   *       502:
+   *         description: Slack rejected the upload (error.details.slack_error)
+   */
+  authenticated.post('/integrations/slack', validateSlackShareRequest, shareToSlack);
+};
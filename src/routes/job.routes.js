@@ -0,0 +1,106 @@
+const { createJobController } = require('../controllers/job.controller');
+
+/**
+ * Register the async render job routes
+ * @param {Object} groups - Route groups from createRouter
+ */
+module.exports = ({ public: publicRoutes }, services) => {
+  const { getJob, getJobResult, getJobEvents, rerunJob } = createJobController(services);
+
+  /**
+   * @swagger
+   * /api/jobs/{id}:
+   *   get:
+   *     summary: Poll an async render job
+   *     description: Status of a job created by POST /api/whatsapp-screenshot/async. status is queued, running,
+   *       succeeded or failed; queued jobs report their position. Succeeded jobs include result, the data of a
+   *       synchronous render; running jobs report their progress. Jobs created with an API key are only visible with the same key. Finished jobs are
+   *       kept for JOB_TTL_MS
+   *     parameters:
+   *       - in: path
+   *         name: id
+   *         required: true
+   *         schema:
+   *           type: string
+   *     responses:
+   *       200:
+   *         description: Job status
+   *       404:
+   *         description: Unknown or expired job
+   */
+  publicRoutes.get('/jobs/:id', getJob);
+
+  /**
+   * @swagger
+   * /api/jobs/{id}/result:
+   *   get:
+   *     summary: Download the image of a finished job
+   *     parameters:
+   *       - in: path
+   *         name: id
+   *         required: true
+   *         schema:
+   *           type: string
+   *     responses:
+   *       200:
+   *         description: The rendered image, GIF, MP4, ZIP or PDF
+   *       404:
+   *         description: Unknown or expired job, or no image rendered
+   *       409:
+   *         description: Job still queued or running (with Retry-After), or failed
+   */
+  publicRoutes.get('/jobs/:id/result', getJobResult);
+
+  /**
+   * @swagger
+   * /api/jobs/{id}/events:
+   *   get:
+   *     summary: Follow an async render job as server-sent events
+   *     description: Sends a status event with the job's current status, a progress event ({ id, stage, completed,
+   *       total }) whenever the render enters a stage (prepare, render, store, deliver), and a final status event
+   *       once the job succeeded or failed, then closes. Finished jobs get the status event only
+   *     parameters:
+   *       - in: path
+   *         name: id
+   *         required: true
+   *         schema:
+   *           type: string
+   *     responses:
+   *       200:
+   *         description: Event stream
+   *         content:
+   *           text/event-stream:
+   *             schema:
+   *               type: string
+   *       404:
+   *         description: Unknown or expired job
+   */
+  publicRoutes.get('/jobs/:id/events', getJobEvents);
+
+  /**
+   * @swagger
+   * /api/jobs/{id}/rerun:
+   *   post:
+   *     summary: Render a finished job again
+   *     description: Queues the stored payload of a succeeded or failed job again under the same ID, also after
+   *       its result expired. Payloads are kept for JOB_RERUN_TTL_MS after the job finished, for at most
+   *       JOB_RERUN_MAX_JOBS jobs. Delivery targets of the original request are not sent to again. Poll the
+   *       returned status URL as for a new job
+   *     parameters:
+   *       - in: path
+   *         name: id
+   *         required: true
+   *         schema:
+   *           type: string
+   *     responses:
+   *       202:
+   *         description: Job queued again; Location holds the status URL
+   *       404:
+   *         description: Unknown job, or its payload expired
+   *       409:
+   *         description: Job still queued or running
+   *       503:
+   *         description: Job queue full (with Retry-After)
+   */
+  publicRoutes.post('/jobs/:id/rerun', rerunJob);
+};
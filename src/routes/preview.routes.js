@@ -0,0 +1,67 @@
+const { createPreviewController } = require('../controllers/preview.controller');
+const { validatePreviewRequest } = require('../middleware/validation.middleware');
+
+/**
+ * Register the chat preview routes
+ * @param {Object} groups - Route groups from createRouter
+ * @param {Object} [services] - Service overrides from createRouter
+ */
+module.exports = ({ public: publicRoutes }, services) => {
+  const { createPreview, getPreview } = createPreviewController(services);
+
+  /**
+   * @swagger
+   * /api/previews:
+   *   post:
+   *     summary: Create a browser preview of a chat
+   *     description: Renders the chat to HTML (no screenshot) and stores it for PREVIEW_TTL_MS. Takes the same
+   *       messages, sources, merge and options as /api/whatsapp-screenshot. Every message with an id gets an
+   *       id="msg-{id}" anchor, so the preview URL can deep-link to it
+   *     requestBody:
+   *       required: true
+   *       content:
+   *         application/json:
+   *           schema:
+   *             type: object
+   *             properties:
+   *               messages:
+   *                 type: array
+   *                 items:
+   *                   type: object
+   *               sources:
+   *                 type: array
+   *                 items:
+   *                   type: object
+   *               options:
+   *                 type: object
+   *     responses:
+   *       201:
+   *         description: Created; data has id, url, expires_at and anchors (message id -> deep link)
+   *       400:
+   *         description: Invalid input
+   */
+  publicRoutes.post('/previews', validatePreviewRequest, createPreview);
+
+  /**
+   * @swagger
+   * /api/previews/{id}:
+   *   get:
+   *     summary: Open a chat preview
+   *     description: Returns the chat as an HTML page. Append "#msg-{id}" to jump to a message; the target bubble
+   *       is outlined. The page runs no scripts and loads no resources
+   *     parameters:
+   *       - in: path
+   *         name: id
+   *         required: true
+   *         schema:
+   *           type: string
+   *     responses:
+   *       200:
+   *         description: The preview page
+   *         content:
+   *           text/html: {}
+   *       404:
+   *         description: Unknown or expired preview
+   */
+  publicRoutes.get('/previews/:id', getPreview);
+};
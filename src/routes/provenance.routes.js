@@ -0,0 +1,35 @@
+const { validateProvenanceVerifyRequest } = require('../middleware/validation.middleware');
+const { verifyImage } = require('../controllers/provenance.controller');
+
+/**
+ * Register the provenance routes
+ * @param {Object} groups - Route groups from createRouter
+ */
+module.exports = ({ public: publicRoutes }) => {
+  /**
+   * @swagger
+   * /api/provenance/verify:
+   *   post:
+   *     summary: Verify the provenance record of a rendered PNG
+   *     description: Checks that the pixel data matches the embedded record and that the record's
+   *       signature is valid. Records are only reported valid when the server has PROVENANCE_SECRET.
+   *     requestBody:
+   *       required: true
+   *       content:
+   *         application/json:
+   *           schema:
+   *             type: object
+   *             required:
+   *               - image
+   *             properties:
+   *               image:
+   *                 type: string
+   *                 description: "PNG as a data URL or base64"
+   *     responses:
+   *       200:
+   *         description: Verification result (valid, signed, problems, provenance)
+   *       400:
+   *         description: Invalid request
+   */
+  publicRoutes.post('/provenance/verify', validateProvenanceVerifyRequest, verifyImage);
+};
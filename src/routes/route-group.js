@@ -0,0 +1,50 @@
+const express = require('express');
+
+const METHODS = ['get', 'post', 'put', 'patch', 'delete'];
+
+/**
+ * A set of routes sharing a path prefix and a middleware chain. Groups nest:
+ * a child group adds its prefix and middleware after the parent's. The
+ * middleware runs per route, so groups can share one router without a
+ * group's middleware (e.g. authentication) leaking onto other groups.
+ */
+class RouteGroup {
+  /**
+   * @param {Object} router - Express router the routes are registered on
+   * @param {Object} options - Group options
+   * @param {string} options.prefix - Path prefix
+   * @param {Array<Function>} options.middleware - Middleware run before every route handler
+   */
+  constructor(router = express.Router(), { prefix = '', middleware = [] } = {}) {
+    this.router = router;
+    this.prefix = prefix;
+    this.middleware = middleware;
+  }
+
+  /**
+   * Create a nested group
+   * @param {Object} options - { prefix, middleware } added to this group's
+   * @returns {RouteGroup} Child group
+   */
+  group({ prefix = '', middleware = [] } = {}) {
+    return new RouteGroup(this.router, {
+      prefix: `${this.prefix}${prefix}`,
+      middleware: [...this.middleware, ...middleware]
+    });
+  }
+}
+
+METHODS.forEach(method => {
+  /**
+   * Register a route on the group
+   * @param {string} path - Path relative to the group prefix
+   * @param {...Function} handlers - Route middleware and handler
+   * @returns {RouteGroup} This group
+   */
+  RouteGroup.prototype[method] = function registerRoute(path, ...handlers) {
+    this.router[method](`${this.prefix}${path}`, ...this.middleware, ...handlers);
+    return this;
+  };
+});
+
+module.exports = RouteGroup;
@@ -0,0 +1,51 @@
+const { getSchema, getModelSchema } = require('../controllers/schema.controller');
+
+/**
+ * Register the request schema routes
+ * @param {Object} groups - Route groups from createRouter
+ */
+module.exports = ({ public: publicRoutes }) => {
+  /**
+   * @swagger
+   * /api/schema:
+   *   get:
+   *     summary: JSON Schema for the request models
+   *     description: Generated from the validation schemas, so it always matches what the endpoints accept.
+   *       Models are under $defs (ScreenshotRequest, RequestMessage, ScreenshotOptions, BatchRequest,
+   *       CompositionRequest, MergeRequest, UrlRenderRequest, ProvenanceVerifyRequest, SimpleRequest, TemplateUploadRequest)
+   *     parameters:
+   *       - in: query
+   *         name: format
+   *         schema:
+   *           type: string
+   *           enum: [json, typescript]
+   *           default: json
+   *         description: "typescript returns TypeScript declarations instead"
+   *     responses:
+   *       200:
+   *         description: JSON Schema document (application/schema+json) or TypeScript declarations
+   *       400:
+   *         description: Unknown format
+   */
+  publicRoutes.get('/schema', getSchema);
+
+  /**
+   * @swagger
+   * /api/schema/{model}:
+   *   get:
+   *     summary: JSON Schema for one request model
+   *     parameters:
+   *       - in: path
+   *         name: model
+   *         required: true
+   *         schema:
+   *           type: string
+   *         example: ScreenshotRequest
+   *     responses:
+   *       200:
+   *         description: JSON Schema (application/schema+json)
+   *       404:
+   *         description: Unknown model
+   */
+  publicRoutes.get('/schema/:model', getModelSchema);
+};
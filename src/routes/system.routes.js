@@ -0,0 +1,82 @@
+const { registry: metricsRegistry } = require('../utils/metrics');
+const { requireAdminKey } = require('../middleware/auth.middleware');
+const { createSystemController } = require('../controllers/system.controller');
+
+/**
+ * Register the unprefixed operational routes (health, readiness, capacity,
+ * metrics, probe history and the self-test)
+ * @param {Object} groups - Route groups from createRouter
+ * @param {Object} [services] - Service overrides from createRouter
+ */
+module.exports = ({ root }, services) => {
+  const { getHealth, getReady, getCapacity, getHealthHistory, runSelfTest } = createSystemController(services);
+
+  // Health check endpoint
+  root.get('/health', getHealth);
+
+  // Readiness endpoint: 503 while the browser is starting, recycling or the
+  // server is draining, so load balancers stop routing new renders here
+  root.get('/ready', getReady);
+
+  /**
+   * @swagger
+   * /capacity:
+   *   get:
+   *     summary: Load and headroom of this replica
+   *     description: Current concurrency, queue depth and headroom in machine-readable form, for autoscalers such
+   *       as KEDA or an HPA on external metrics to scale replicas on queue depth. Always 200; see accepting for
+   *       readiness.
+   *     responses:
+   *       200:
+   *         description: queueDepth, utilization, headroom { workers, queue }, jobs and renders
+   */
+  root.get('/capacity', getCapacity);
+
+  /**
+   * @swagger
+   * /healthz/history:
+   *   get:
+   *     summary: Latest health probe results
+   *     description: Results of the latest /health, /ready and /selftest probes, oldest first.
+   *       HEALTH_HISTORY_SIZE results are kept.
+   *     parameters:
+   *       - in: query
+   *         name: probe
+   *         schema:
+   *           type: string
+   *           enum: [health, ready, selftest]
+   *       - in: query
+   *         name: limit
+   *         schema:
+   *           type: integer
+   *     responses:
+   *       200:
+   *         description: Probe results
+   */
+  root.get('/healthz/history', getHealthHistory);
+
+  /**
+   * @swagger
+   * /selftest:
+   *   post:
+   *     summary: Render a built-in conversation end-to-end
+   *     description: Requires an admin key from ADMIN_API_KEYS. Processes, renders and captures a two-message
+   *       chat, and returns the duration of each pipeline stage and the image size.
+   *     security:
+   *       - ApiKeyAuth: []
+   *     responses:
+   *       200:
+   *         description: Pipeline works; timings per stage
+   *       401:
+   *         description: Missing or invalid admin key
+   *       503:
+   *         description: The render failed (code selftest_failed, stage of the failure)
+   */
+  root.post('/selftest', requireAdminKey, runSelfTest);
+
+  // Metrics endpoint (Prometheus text format)
+  root.get('/metrics', (req, res) => {
+    res.set('Content-Type', 'text/plain; version=0.0.4');
+    res.status(200).send(metricsRegistry.render());
+  });
+};
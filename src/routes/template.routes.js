@@ -0,0 +1,74 @@
+const { validateTemplateUpload } = require('../middleware/validation.middleware');
+const { uploadTemplate, listTemplates, getTemplateSchema } = require('../controllers/template.controller');
+
+/**
+ * Register the template routes
+ * @param {Object} groups - Route groups from createRouter
+ */
+module.exports = ({ public: publicRoutes, authenticated }) => {
+  /**
+   * @swagger
+   * /api/templates:
+   *   get:
+   *     summary: List available chat templates
+   *     description: The registered templates (shipped and from TEMPLATE_DIR) and the templates uploaded with the caller's API key.
+   *     responses:
+   *       200:
+   *         description: Successful operation
+   *   post:
+   *     summary: Upload a custom chat template
+   *     description: Requires an API key. Uploaded templates are only visible to the uploading
+   *       key, are linted and always rendered in the template sandbox (whitelisted functions,
+   *       execution timeout and output size cap).
+   *     security:
+   *       - ApiKeyAuth: []
+   *     requestBody:
+   *       required: true
+   *       content:
+   *         application/json:
+   *           schema:
+   *             type: object
+   *             required:
+   *               - name
+   *               - source
+   *             properties:
+   *               name:
+   *                 type: string
+   *                 example: "acme-support"
+   *               source:
+   *                 type: string
+   *                 example: "<html><body>{{messages}}</body></html>"
+   *     responses:
+   *       201:
+   *         description: Template registered
+   *       400:
+   *         description: Invalid input or template rejected by the sandbox lint
+   *       401:
+   *         description: Missing or invalid API key
+   *       409:
+   *         description: Template name belongs to a built-in template, or the key's template limit is reached
+   */
+  publicRoutes.get('/templates', listTemplates);
+  authenticated.post('/templates', validateTemplateUpload, uploadTemplate);
+
+  /**
+   * @swagger
+   * /api/templates/{name}/schema:
+   *   get:
+   *     summary: Describe the data a template uses
+   *     description: Parses the template and reports which chat data fields, request fields
+   *       and template functions it references.
+   *     parameters:
+   *       - in: path
+   *         name: name
+   *         required: true
+   *         schema:
+   *           type: string
+   *     responses:
+   *       200:
+   *         description: Successful operation
+   *       404:
+   *         description: Template not found
+   */
+  publicRoutes.get('/templates/:name/schema', getTemplateSchema);
+};
@@ -0,0 +1,41 @@
+const screenshotService = require('./screenshot.service');
+const templateService = require('./template.service');
+const formatPool = require('../utils/format-pool');
+const { describeEnvironment } = require('../utils/config-summary');
+const { version } = require('../../package.json');
+
+class ConfigService {
+  /**
+   * Effective configuration for the startup banner and GET /api/admin/config:
+   * the configuration variables that are set (secrets redacted), the
+   * templates, the Chrome version and the pool sizes
+   * @returns {Promise<Object>} Configuration summary
+   */
+  async getSummary() {
+    const browser = screenshotService.getBrowserStatus();
+    return {
+      version,
+      node: process.version,
+      environment: describeEnvironment(),
+      templates: {
+        directory: process.env.TEMPLATE_DIR || null,
+        names: (await templateService.listTemplates()).map(template => template.name)
+      },
+      browser: {
+        version: await screenshotService.getBrowserVersion().catch(() => null),
+        executablePath: process.env.PUPPETEER_EXECUTABLE_PATH || '/usr/bin/chromium',
+        state: browser.state,
+        maxRenders: browser.maxRenders,
+        queueLimit: browser.queueLimit
+      },
+      pools: {
+        formatWorkers: formatPool.size,
+        parallelFormatThreshold: formatPool.PARALLEL_THRESHOLD
+      }
+    };
+  }
+}
+
+const configServiceInstance = new ConfigService();
+
+module.exports = configServiceInstance;
@@ -0,0 +1,162 @@
+const crypto = require('crypto');
+const { ApiError } = require('../middleware/error.middleware');
+const { deliveryMetrics, secondsSince } = require('../utils/metrics');
+const { parseJsonEnv } = require('../utils/env');
+
+/**
+ * A delivery target. Each deliverer is registered under its name, which is
+ * also the key of its options in a request's `delivery` object and of its
+ * settings in DELIVERY_POLICIES.
+ * @typedef {Object} Deliverer
+ * @property {string} name - Target name
+ * @property {Object} schema - Joi schema of the request options
+ * @property {Function} resolve - (options, policy) => target; checks configuration
+ *   and permissions before the render and throws an ApiError when the target can't
+ *   be used. The target's `credentials` is "request", "tenant" or "server".
+ * @property {Function} deliver - async (output, target, context) => result;
+ *   output is { buffer, contentType, fileName, sha256 }, context { requestId, log }
+ */
+
+/**
+ * Per-tenant delivery settings from DELIVERY_POLICIES, a JSON object mapping
+ * an API key to { <target>: settings }. "anonymous" applies to requests
+ * without an API key and "*" to every tenant without its own entry. Settings
+ * with `always: true` deliver every render of the tenant.
+ * @returns {Object} API key -> policy
+ */
+const getDeliveryPolicies = () => parseJsonEnv('DELIVERY_POLICIES');
+
+class DeliveryService {
+  constructor() {
+    this.deliverers = new Map();
+  }
+
+  /**
+   * Register a deliverer
+   * @param {Deliverer} deliverer - Deliverer
+   */
+  register(deliverer) {
+    this.deliverers.set(deliverer.name, deliverer);
+  }
+
+  /**
+   * Request option schemas of the registered deliverers
+   * @returns {Object} Target name -> Joi schema
+   */
+  getSchemas() {
+    return Object.fromEntries([...this.deliverers.values()].map(deliverer => [deliverer.name, deliverer.schema]));
+  }
+
+  /**
+   * Resolve one target
+   * @param {string} name - Target name
+   * @param {Object} options - Validated request options
+   * @param {string} [apiKey] - Caller's API key
+   * @returns {Promise<Object>} { deliverer, target }
+   */
+  async resolveTarget(name, options, apiKey) {
+    const deliverer = this.deliverers.get(name);
+    if (!deliverer) {
+      throw new ApiError(400, `Unknown delivery target: ${name}`).annotate({ stage: 'validate', code: 'delivery_target_unknown' });
+    }
+    const policies = getDeliveryPolicies();
+    const policy = ((apiKey ? policies[apiKey] : policies.anonymous) || policies['*'] || {})[name] || {};
+    const target = await deliverer.resolve(options, policy);
+    // Shared server credentials post to shared channels, so callers must be identified
+    if (target.credentials === 'server' && !apiKey) {
+      throw new ApiError(401, `Delivery to ${name} with the server's credentials requires an API key`)
+        .annotate({ stage: 'validate', code: 'delivery_requires_api_key' });
+    }
+    return { deliverer, target };
+  }
+
+  /**
+   * Resolve the targets of a render before it starts: the ones the request
+   * asked for and the tenant's `always` targets
+   * @param {Object} [requested] - Validated delivery options, target name -> options
+   * @param {string} [apiKey] - Caller's API key
+   * @returns {Promise<Array<Object>>} Resolved targets
+   */
+  async resolveTargets(requested = {}, apiKey) {
+    const policies = getDeliveryPolicies();
+    const policy = (apiKey ? policies[apiKey] : policies.anonymous) || policies['*'] || {};
+    const names = new Set(Object.keys(requested).filter(name => requested[name]));
+    Object.entries(policy).forEach(([name, settings]) => {
+      if (settings && settings.always && this.deliverers.has(name)) {
+        names.add(name);
+      }
+    });
+
+    return Promise.all([...names].map(name => {
+      const options = requested[name] || this.deliverers.get(name).schema.validate({}).value;
+      return this.resolveTarget(name, options, apiKey);
+    }));
+  }
+
+  /**
+   * Deliver an output to one resolved target
+   * @param {Object} output - { buffer, contentType, fileName }
+   * @param {Object} resolved - { deliverer, target } from resolveTarget
+   * @param {Object} context - { requestId, log }
+   * @returns {Promise<Object>} Deliverer result
+   */
+  async deliver(output, { deliverer, target }, context) {
+    const start = process.hrtime.bigint();
+    const sha256 = output.sha256 || crypto.createHash('sha256').update(output.buffer).digest('hex');
+    try {
+      const result = await deliverer.deliver({ ...output, sha256 }, target, context);
+      deliveryMetrics.deliveries.inc({ target: deliverer.name, result: 'ok' });
+      return result;
+    } catch (error) {
+      deliveryMetrics.deliveries.inc({ target: deliverer.name, result: 'failed' });
+      context.log.warn('Delivery failed', { target: deliverer.name, reason: error.message });
+      throw error;
+    } finally {
+      deliveryMetrics.duration.observe({ target: deliverer.name }, secondsSince(start));
+    }
+  }
+
+  /**
+   * Deliver an output to every resolved target in parallel. A failed target
+   * doesn't fail the others; its entry reports the error instead.
+   * @param {Object} output - { buffer, contentType, fileName }
+   * @param {Array<Object>} targets - From resolveTargets
+   * @param {Object} context - { requestId, log }
+   * @returns {Promise<Object>} Target name -> { ok: true, ...result } or { ok: false, error }
+   */
+  async deliverAll(output, targets, context) {
+    const sha256 = crypto.createHash('sha256').update(output.buffer).digest('hex');
+    const settled = await Promise.allSettled(targets.map(resolved => this.deliver({ ...output, sha256 }, resolved, context)));
+
+    return Object.fromEntries(settled.map((outcome, index) => {
+      const { name } = targets[index].deliverer;
+      if (outcome.status === 'fulfilled') {
+        return [name, { ok: true, ...outcome.value }];
+      }
+      const error = outcome.reason;
+      return [name, {
+        ok: false,
+        error: {
+          message: error.message,
+          code: error.code || null,
+          retryable: Boolean(error.retryable),
+          ...(error.retryAfter && { retry_after: error.retryAfter })
+        }
+      }];
+    }));
+  }
+}
+
+// Create a singleton instance
+const deliveryServiceInstance = new DeliveryService();
+
+[
+  require('../deliverers/webhook.deliverer'),
+  require('../deliverers/s3.deliverer'),
+  require('../deliverers/email.deliverer'),
+  require('../deliverers/slack.deliverer'),
+  require('../deliverers/telegram.deliverer')
+].forEach(deliverer => deliveryServiceInstance.register(deliverer));
+
+module.exports = deliveryServiceInstance;
+module.exports.getDeliveryPolicies = getDeliveryPolicies;
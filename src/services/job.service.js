@@ -0,0 +1,275 @@
+const crypto = require('crypto');
+const { EventEmitter } = require('events');
+const { ApiError } = require('../middleware/error.middleware');
+const { jobMetrics } = require('../utils/metrics');
+const { isPriorityKey } = require('../middleware/auth.middleware');
+
+/**
+ * Job queue settings: JOB_CONCURRENCY (jobs rendered at the same time),
+ * JOB_QUEUE_LIMIT (jobs waiting to start; more are rejected), JOB_TTL_MS
+ * (how long finished jobs and their results are kept),
+ * JOB_PRIORITY_SLOTS (extra workers only priority jobs may use), and
+ * JOB_RERUN_TTL_MS and JOB_RERUN_MAX_JOBS (how long and how many payloads
+ * of expired jobs are kept for reruns)
+ * @returns {Object} Settings
+ */
+const getJobSettings = () => ({
+  concurrency: parseInt(process.env.JOB_CONCURRENCY, 10) || 2,
+  queueLimit: parseInt(process.env.JOB_QUEUE_LIMIT, 10) || 100,
+  ttlMs: parseInt(process.env.JOB_TTL_MS, 10) || 3600000,
+  prioritySlots: parseInt(process.env.JOB_PRIORITY_SLOTS, 10) || 1,
+  rerunTtlMs: parseInt(process.env.JOB_RERUN_TTL_MS, 10) || 7 * 86400000,
+  rerunMaxJobs: parseInt(process.env.JOB_RERUN_MAX_JOBS, 10) || 1000
+});
+
+/**
+ * In-memory job queue. Emits "progress" when a running job reports
+ * progress and "finished" when a job succeeded or failed, both with the job.
+ */
+class JobService extends EventEmitter {
+  constructor() {
+    super();
+    // One listener per client following a job's events
+    this.setMaxListeners(0);
+    // id -> job; finished jobs stay until they expire, and rerunnable ones
+    // keep their payload (without the result) until JOB_RERUN_TTL_MS
+    this.jobs = new Map();
+    // Jobs waiting for a worker: priority jobs first, then oldest first
+    this.pending = [];
+    this.running = 0;
+  }
+
+  /**
+   * Queue a task. It runs in the background once a worker is free. Jobs of
+   * PRIORITY_API_KEYS skip ahead of the other queued jobs.
+   * @param {Function} task - async (reportProgress) => result; reportProgress(progress) publishes
+   *   the job's progress, e.g. { stage, completed, total }
+   * @param {Object} [owner] - { apiKey, rerun }; only the same key can read the job. rerun()
+   *   returns a new task rendering the job's payload again, for POST /api/jobs/:id/rerun
+   * @returns {Object} Job status
+   */
+  enqueue(task, { apiKey, rerun } = {}) {
+    // Job URLs are the only access control for anonymous jobs
+    const job = {
+      id: crypto.randomUUID(),
+      status: 'queued',
+      apiKey: apiKey || null,
+      priority: isPriorityKey(apiKey),
+      createdAt: new Date(),
+      startedAt: null,
+      finishedAt: null,
+      expiresAt: null,
+      progress: null,
+      result: null,
+      error: null,
+      task,
+      rerun: rerun || null
+    };
+    this.queue(job);
+    this.jobs.set(job.id, job);
+    return this.describe(job);
+  }
+
+  /**
+   * Add a job to the pending queue
+   * @param {Object} job - Job
+   * @param {Object} [reset] - Fields to set on the job once it has a place in the queue
+   * @private
+   */
+  queue(job, reset = {}) {
+    const { queueLimit } = getJobSettings();
+    this.removeExpired();
+    // Priority jobs only wait behind each other, so batch jobs filling the
+    // queue don't turn them away
+    const ahead = job.priority ? this.pending.filter(pending => pending.priority).length : this.pending.length;
+    if (ahead >= queueLimit) {
+      const error = new ApiError(503, 'The job queue is full, retry shortly')
+        .annotate({ stage: 'validate', code: 'job_queue_full', retryable: true });
+      error.retryAfter = 5;
+      throw error;
+    }
+
+    Object.assign(job, reset);
+    this.pending.splice(ahead, 0, job);
+    jobMetrics.queued.set({}, this.pending.length);
+    this.drain();
+  }
+
+  /**
+   * Render a finished job's payload again under the same ID, also after its
+   * result expired, e.g. for a client coming back days later
+   * @param {string} id - Job ID
+   * @param {string} [apiKey] - Caller's API key
+   * @returns {Object} Job status
+   */
+  rerun(id, apiKey) {
+    this.removeExpired();
+    const job = this.jobs.get(id);
+    if (!job || !job.rerun || (job.apiKey && job.apiKey !== apiKey)) {
+      throw new ApiError(404, 'Job not found or no longer rerunnable').annotate({ stage: 'validate', code: 'job_not_found' });
+    }
+    if (job.status === 'queued' || job.status === 'running') {
+      throw new ApiError(409, `Job is ${job.status}`).annotate({ stage: 'validate', code: 'job_not_finished' });
+    }
+
+    // Priority follows the key's current tier, not the one at first render
+    job.priority = isPriorityKey(job.apiKey);
+    this.queue(job, {
+      status: 'queued',
+      task: job.rerun(),
+      startedAt: null,
+      finishedAt: null,
+      expiresAt: null,
+      rerunExpiresAt: null,
+      progress: null,
+      result: null,
+      error: null
+    });
+    return this.describe(job);
+  }
+
+  /**
+   * Start queued jobs while workers are free. Priority jobs may also use
+   * the JOB_PRIORITY_SLOTS extra workers, so they start even when batch
+   * jobs hold every regular worker; running jobs are never interrupted.
+   * @private
+   */
+  drain() {
+    const { concurrency, prioritySlots } = getJobSettings();
+    while (this.pending.length > 0) {
+      const limit = this.pending[0].priority ? concurrency + prioritySlots : concurrency;
+      if (this.running >= limit) {
+        break;
+      }
+      this.run(this.pending.shift());
+    }
+    jobMetrics.queued.set({}, this.pending.length);
+  }
+
+  /**
+   * Run one job and keep its result or error
+   * @param {Object} job - Job
+   * @private
+   */
+  async run(job) {
+    this.running += 1;
+    job.status = 'running';
+    job.startedAt = new Date();
+    try {
+      job.result = await job.task(progress => this.setProgress(job, progress));
+      job.status = 'succeeded';
+    } catch (error) {
+      job.status = 'failed';
+      job.error = {
+        message: error.statusCode && error.statusCode < 500 ? error.message : 'Render failed',
+        code: error.code || null,
+        retryable: Boolean(error.retryable)
+      };
+    } finally {
+      job.task = null;
+      job.finishedAt = new Date();
+      job.expiresAt = new Date(job.finishedAt.getTime() + getJobSettings().ttlMs);
+      job.rerunExpiresAt = job.rerun ? new Date(job.finishedAt.getTime() + getJobSettings().rerunTtlMs) : null;
+      jobMetrics.jobs.inc({ result: job.status });
+      this.running -= 1;
+      this.emit('finished', job);
+      this.drain();
+    }
+  }
+
+  /**
+   * Record the progress of a running job
+   * @param {Object} job - Job
+   * @param {Object} progress - Progress reported by the task
+   * @private
+   */
+  setProgress(job, progress) {
+    if (job.status !== 'running') {
+      return;
+    }
+    job.progress = progress;
+    this.emit('progress', job);
+  }
+
+  /**
+   * Get a job
+   * @param {string} id - Job ID
+   * @param {string} [apiKey] - Caller's API key
+   * @returns {Object} Job
+   */
+  get(id, apiKey) {
+    const job = this.jobs.get(id);
+    // Another tenant's job is reported like a missing one
+    if (!job || (job.expiresAt && job.expiresAt.getTime() <= Date.now()) || (job.apiKey && job.apiKey !== apiKey)) {
+      throw new ApiError(404, 'Job not found or expired').annotate({ stage: 'validate', code: 'job_not_found' });
+    }
+    return job;
+  }
+
+  /**
+   * Public status of a job
+   * @param {Object} job - Job
+   * @returns {Object} { id, status, position, priority, progress, created_at, started_at, finished_at, expires_at, error }
+   */
+  describe(job) {
+    const position = this.pending.indexOf(job);
+    return {
+      id: job.id,
+      status: job.status,
+      ...(position >= 0 && { position: position + 1 }),
+      ...(job.priority && { priority: true }),
+      ...(job.status === 'running' && job.progress && { progress: job.progress }),
+      created_at: job.createdAt.toISOString(),
+      started_at: job.startedAt && job.startedAt.toISOString(),
+      finished_at: job.finishedAt && job.finishedAt.toISOString(),
+      expires_at: job.expiresAt && job.expiresAt.toISOString(),
+      ...(job.error && { error: job.error })
+    };
+  }
+
+  /**
+   * Current load of the queue against its settings
+   * @returns {Object} { concurrency, prioritySlots, running, queued, queuedPriority, queueLimit }
+   */
+  getLoad() {
+    const { concurrency, prioritySlots, queueLimit } = getJobSettings();
+    return {
+      concurrency,
+      prioritySlots,
+      running: this.running,
+      queued: this.pending.length,
+      queuedPriority: this.pending.filter(job => job.priority).length,
+      queueLimit
+    };
+  }
+
+  /**
+   * Drop expired jobs. Rerunnable jobs only lose their result until their
+   * payload expires too; beyond JOB_RERUN_MAX_JOBS, the oldest are dropped.
+   */
+  removeExpired() {
+    const now = Date.now();
+    const { rerunMaxJobs } = getJobSettings();
+    const rerunnable = [];
+    this.jobs.forEach((job, id) => {
+      if (!job.expiresAt || job.expiresAt.getTime() > now) {
+        return;
+      }
+      if (job.rerunExpiresAt && job.rerunExpiresAt.getTime() > now) {
+        job.result = null;
+        rerunnable.push(job);
+        return;
+      }
+      this.jobs.delete(id);
+    });
+    rerunnable
+      .sort((a, b) => a.finishedAt - b.finishedAt)
+      .slice(0, Math.max(rerunnable.length - rerunMaxJobs, 0))
+      .forEach(job => this.jobs.delete(job.id));
+  }
+}
+
+// Create a singleton instance
+const jobServiceInstance = new JobService();
+
+module.exports = jobServiceInstance;
@@ -0,0 +1,77 @@
+const crypto = require('crypto');
+const { ApiError } = require('../middleware/error.middleware');
+
+/**
+ * Preview settings: PREVIEW_TTL_MS (how long a preview can be opened) and
+ * PREVIEW_MAX_ENTRIES (previews kept in memory; the oldest are dropped)
+ * @returns {Object} Settings
+ */
+const getPreviewSettings = () => ({
+  ttlMs: parseInt(process.env.PREVIEW_TTL_MS, 10) || 3600000,
+  maxEntries: parseInt(process.env.PREVIEW_MAX_ENTRIES, 10) || 100
+});
+
+// Lets "#msg-<id>" links land below the sticky header and marks the target
+const PREVIEW_STYLE = `<style data-preview>
+  .message[id] { scroll-margin-top: 80px; }
+  .message:target .message-content { box-shadow: 0 0 0 3px rgba(37, 211, 102, 0.8); }
+</style>`;
+
+class PreviewService {
+  constructor() {
+    // id -> { html, expiresAt }; Map keeps insertion order for eviction
+    this.previews = new Map();
+  }
+
+  /**
+   * Store a rendered chat document for GET /api/previews/{id}
+   * @param {string} html - Chat HTML
+   * @param {Object} [naming] - { id, now }; a random ID and the current time by default
+   * @returns {Object} { id, expiresAt }
+   */
+  create(html, { id = crypto.randomUUID(), now = new Date() } = {}) {
+    const { ttlMs, maxEntries } = getPreviewSettings();
+    this.removeExpired();
+    while (this.previews.size >= maxEntries) {
+      this.previews.delete(this.previews.keys().next().value);
+    }
+
+    // Preview URLs are the only access control, so outside of tests IDs
+    // must be unguessable
+    const expiresAt = new Date(now.getTime() + ttlMs);
+    const styled = html.includes('</head>') ? html.replace('</head>', `${PREVIEW_STYLE}</head>`) : `${PREVIEW_STYLE}${html}`;
+    this.previews.set(id, { html: styled, expiresAt });
+    return { id, expiresAt };
+  }
+
+  /**
+   * Get a stored preview
+   * @param {string} id - Preview ID
+   * @returns {string} HTML
+   */
+  get(id) {
+    const preview = this.previews.get(id);
+    if (!preview || preview.expiresAt.getTime() <= Date.now()) {
+      this.previews.delete(id);
+      throw new ApiError(404, 'Preview not found or expired').annotate({ stage: 'validate', code: 'preview_not_found' });
+    }
+    return preview.html;
+  }
+
+  /**
+   * Drop expired previews
+   */
+  removeExpired() {
+    const now = Date.now();
+    this.previews.forEach((preview, id) => {
+      if (preview.expiresAt.getTime() <= now) {
+        this.previews.delete(id);
+      }
+    });
+  }
+}
+
+// Create a singleton instance
+const previewServiceInstance = new PreviewService();
+
+module.exports = previewServiceInstance;
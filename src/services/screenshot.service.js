@@ -1,14 +1,177 @@
 const puppeteer = require('puppeteer');
-const path = require('path');
-const fs = require('fs/promises');
 const { ApiError } = require('../middleware/error.middleware');
-const { convertWhatsAppToHTML } = require('../utils/whatsapp-html');
+const { isPriorityKey } = require('../middleware/auth.middleware');
+const templateService = require('./template.service');
+const { DEFAULT_TEMPLATE } = require('./template.service');
+const formatPool = require('../utils/format-pool');
+const { renderTemplate } = require('../utils/template-engine');
+const { pipelineMetrics, browserMetrics, secondsSince } = require('../utils/metrics');
+const { getProcessTreeMemory } = require('../utils/process-memory');
+const { assertUrlAllowed, isHostAllowed } = require('../utils/url-guard');
+const { resolveProxy } = require('../utils/proxy');
+const { logger } = require('../utils/logger');
+const { attachPageLogging, pipeBrowserOutput } = require('../utils/page-logs');
+const { trackResources } = require('../utils/resource-report');
+const { anonymizeMessages, resolveAnonymizeSettings } = require('../utils/anonymize');
+const { applyAuthorAliases } = require('../utils/author-aliases');
+const { resolveContentFilter } = require('../utils/content-filter');
+const { applyWindow } = require('../utils/chat-window');
+const { compileSearchPattern, highlightMatches } = require('../utils/search-highlight');
+const { escapeHTML } = require('../utils/html-escape');
+const { encodeAnimation, ANIMATION_FORMATS } = require('../utils/animation');
+const { decodePng } = require('../utils/png-decoder');
+const { encodePng } = require('../utils/png-encoder');
+const { stitchImages } = require('../utils/image-stitch');
+const { decodeDataUrl } = require('../utils/packaging');
+const { stripMetadata } = require('../utils/image-metadata');
+const { resolveWatermarks, applyWatermarks } = require('../utils/watermark');
+const { messageAnchorId } = require('../utils/message-anchor');
+const { buildBubbleStyle, markMessageGroups } = require('../utils/bubble-style');
+const { resolvePlatform } = require('../utils/platform-style');
+const { renderKeyboard } = require('../utils/keyboard');
+const { buildThemeStyle } = require('../utils/theme');
+const { DEFAULT_LOCALE } = require('../utils/number-format');
+const { resolveReply } = require('../utils/composer');
+const { buildNotificationData } = require('../utils/notification');
+const { buildContactInfoData } = require('../utils/contact-info');
+const { buildChatListData } = require('../utils/chat-list');
+const { buildAppIconHTML } = require('../utils/app-icon');
+const { inlineMedia, resolveMediaOrPlaceholder, renderMediaHTML } = require('../utils/media');
+const { attachAuthorAvatars, renderAuthorAvatar, authorColor } = require('../utils/avatar');
+const { renderAnnouncementIcon, renderAdminOnlyNote } = require('../utils/community');
+const { renderMockChat } = require('../utils/mock-render');
+const { detectDirection, detectChatDirection } = require('../utils/text-direction');
+const { translate, isRtlLocale, getTimeFormatter } = require('../utils/i18n');
+const { checkWhatsAppLimits } = require('../utils/whatsapp-limits');
+const { resolveTypingIndicator, renderTypingIndicator } = require('../utils/typing-indicator');
+const { resolveWallpaper, buildWallpaperStyle } = require('../utils/wallpaper');
+const { systemClock } = require('../utils/clock');
+const { resolveDeviceFrame, buildDeviceFrameData } = require('../utils/device-frame');
+
+// Upper bound on captured animation frames, whatever fps and duration ask for
+const MAX_ANIMATION_FRAMES = 300;
+
+/**
+ * Browser lifecycle limits. A browser is recycled after BROWSER_MAX_RENDERS
+ * pages or once its process tree exceeds BROWSER_MAX_MEMORY_MB; pages open
+ * longer than BROWSER_MAX_PAGE_AGE_MS are treated as leaked and closed.
+ * While the browser restarts, up to BROWSER_RESTART_QUEUE_LIMIT renders wait for it.
+ * @returns {Object} Limits
+ */
+function getBrowserLimits() {
+  return {
+    maxRenders: parseInt(process.env.BROWSER_MAX_RENDERS, 10) || 500,
+    maxMemoryBytes: (parseInt(process.env.BROWSER_MAX_MEMORY_MB, 10) || 1024) * 1024 * 1024,
+    maxPageAgeMs: parseInt(process.env.BROWSER_MAX_PAGE_AGE_MS, 10) || 120000,
+    checkIntervalMs: parseInt(process.env.BROWSER_HEALTH_CHECK_INTERVAL_MS, 10) || 30000,
+    queueLimit: parseInt(process.env.BROWSER_RESTART_QUEUE_LIMIT, 10) || 50
+  };
+}
+
+/**
+ * Rendering backend from RENDERER: "chrome" (default) or "mock", which
+ * draws placeholder images without a browser for offline development
+ * @returns {string} Renderer name
+ */
+const getRenderer = () => (process.env.RENDERER === 'mock' ? 'mock' : 'chrome');
+
+/**
+ * Record a pipeline stage duration in the metrics and, when the request
+ * context collects them, in the per-request timings (milliseconds)
+ * @param {Object} context - Request context
+ * @param {string} stage - Stage name
+ * @param {bigint} startedAt - process.hrtime.bigint() start mark
+ * @returns {number} Elapsed seconds
+ */
+function observeStage(context, stage, startedAt) {
+  const seconds = secondsSince(startedAt);
+  pipelineMetrics.stageDuration.observe({ stage }, seconds);
+  if (context && context.timings) {
+    context.timings[stage] = Math.round(seconds * 10000) / 10;
+  }
+  return seconds;
+}
+
+/**
+ * Attach a page's network resource report to an error's details
+ * @param {ApiError} error - Error to throw
+ * @param {Object|null} resourceTracker - Tracker from trackResources
+ * @returns {ApiError} The same error
+ */
+function withResourceReport(error, resourceTracker) {
+  if (resourceTracker) {
+    error.details = { ...error.details, resources: resourceTracker.report() };
+  }
+  return error;
+}
+
+/**
+ * Format a recipient phone number for display, e.g. "+62 8123-4567-8901"
+ * @param {string} phone - Phone number from the request
+ * @returns {string} Formatted phone number
+ */
+function formatRecipientPhone(phone) {
+  let recipientPhone = phone;
+  // Format recipient phone number to add +62 prefix if it's not already there
+  if (!recipientPhone.startsWith('+62')) {
+    if (!recipientPhone.startsWith('62')) {
+      recipientPhone = `+62 ${recipientPhone}`;
+    } else {
+      recipientPhone = `+62 ${recipientPhone.slice(2)}`;
+    }
+  } else {
+    // Add space after +62 if space is not already there
+    if (!recipientPhone.includes(' ')) {
+      recipientPhone = recipientPhone.replace('+62', '+62 ');
+    }
+  }
+  // Format to add dash after every 4 digits
+  return recipientPhone.replace(/(?=\d{4}(?:\d{4})*$)/g, '-');
+}
+
+/**
+ * Chat-list entry of the request's conversation
+ * @param {string} name - Chat name
+ * @param {Object} [lastMessage] - Last processed message
+ * @param {Object} flags - options.chatList.conversation and blurAvatar
+ * @returns {Object} Chat entry, see buildChatListData
+ */
+const chatListEntry = (name, lastMessage, flags) => ({
+  name,
+  previewHTML: lastMessage ? lastMessage.previewHTML || lastMessage.contentHTML : '',
+  previewClass: lastMessage ? lastMessage.contentClass : '',
+  time: lastMessage ? lastMessage.time : '',
+  lastMessageFromMe: Boolean(lastMessage && lastMessage.isSent),
+  ...(lastMessage && lastMessage.status && { lastMessageStatus: lastMessage.status }),
+  ...flags
+});
+
+// Template data builders of the views other than the chat (options.view)
+const VIEW_DATA = {
+  notification: chatData => buildNotificationData(chatData, chatData.notification),
+  'contact-info': chatData => buildContactInfoData(chatData.contact, { locale: chatData.uiLocale }),
+  'chat-list': chatData => buildChatListData(chatData.chatList.chats, { ...chatData.chatList, locale: chatData.uiLocale })
+};
 
 class ScreenshotService {
   constructor() {
-    this.templatePath = path.join(__dirname, '../templates/whatsapp-chat.html');
     this.browser = null;
-    this.chatTemplate = null; // Initialize chatTemplate property
+    this.renderCount = 0;
+    this.recycleReason = null;
+    this.recyclePromise = null;
+    // Open pages -> { browser, incognito, openedAt }, used to find leaked tabs and to
+    // know when a retired browser can be closed
+    this.pages = new Map();
+    this.retiredBrowsers = new Set();
+    this.healthTimer = null;
+    this.launchPromise = null;
+    // Renders waiting for the browser to (re)start, and shutdown state
+    this.waiting = 0;
+    this.draining = false;
+    if (getRenderer() === 'mock') {
+      logger.info('RENDERER=mock: Chrome is not started, renders are placeholders');
+      return;
+    }
     this.initializeBrowser().catch(err => {
       console.error("Failed to initialize ScreenshotService on startup:", err);
       // Depending on the application's needs, this might be a fatal error.
@@ -16,20 +179,25 @@ class ScreenshotService {
     });
   }
 
+  /**
+   * Start the browser. Concurrent callers share a single launch.
+   * @returns {Promise<void>}
+   */
   async initializeBrowser() {
-    // Load HTML template if not already loaded
-    if (!this.chatTemplate) {
-      try {
-        console.log('Loading HTML template...');
-        this.chatTemplate = await fs.readFile(this.templatePath, 'utf-8');
-        console.log('HTML template loaded successfully.');
-      } catch (error) {
-        console.error('Failed to load HTML template:', error);
-        // This is a critical error for the service's operation.
-        // Rethrow to be caught by the constructor's catch or calling context.
-        throw error;
-      }
+    if (!this.launchPromise) {
+      this.launchPromise = this.launchBrowser().finally(() => {
+        this.launchPromise = null;
+      });
     }
+    return this.launchPromise;
+  }
+
+  async launchBrowser() {
+    // Preload the default HTML template. A failure here is critical for the
+    // service's operation, so it is rethrown to the constructor's catch or calling context.
+    console.log('Loading HTML template...');
+    await templateService.getTemplate();
+    console.log('HTML template loaded successfully.');
 
     if (this.browser && this.browser.isConnected()) {
       console.log('Browser already initialized.');
@@ -55,6 +223,9 @@ class ScreenshotService {
           '--disable-gpu'
         ]
       });
+      pipeBrowserOutput(this.browser, logger);
+      this.watchBrowser(this.browser);
+      this.startHealthMonitor();
       console.log('Browser initialized successfully.');
     } catch (error) {
       console.error('Error initializing browser:', error);
@@ -65,41 +236,543 @@ class ScreenshotService {
     }
   }
 
+  /**
+   * Handle a browser that dies on its own (crash, OOM kill): forget its pages
+   * so the next render starts a fresh instance
+   * @param {Object} browser - Puppeteer browser
+   */
+  watchBrowser(browser) {
+    browser.on('disconnected', () => {
+      this.pages.forEach((entry, page) => {
+        if (entry.browser === browser) {
+          this.pages.delete(page);
+        }
+      });
+      browserMetrics.openPages.set({}, this.pages.size);
+
+      if (this.browser === browser) {
+        logger.warn('Browser disconnected unexpectedly', { renders: this.renderCount });
+        browserMetrics.recycles.inc({ reason: 'disconnected' });
+        this.browser = null;
+        this.renderCount = 0;
+        // Relaunch right away; readiness stays down until it is back
+        if (!this.draining) {
+          this.initializeBrowser().catch(error => logger.error('Failed to relaunch browser', { error }));
+        }
+      }
+    });
+  }
+
+  /**
+   * Start the periodic browser health check (memory and leaked pages)
+   */
+  startHealthMonitor() {
+    if (this.healthTimer) {
+      return;
+    }
+    this.healthTimer = setInterval(() => {
+      this.checkBrowserHealth().catch(error => logger.error('Browser health check failed', { error }));
+    }, getBrowserLimits().checkIntervalMs);
+    // Never keep the process alive just for the monitor
+    this.healthTimer.unref();
+  }
+
+  /**
+   * Close leaked pages and schedule a recycle when the browser uses too much memory.
+   * An idle browser is recycled immediately; a busy one on the next render.
+   */
+  async checkBrowserHealth() {
+    const limits = getBrowserLimits();
+    const now = Date.now();
+
+    for (const [page, { openedAt }] of this.pages) {
+      if (now - openedAt > limits.maxPageAgeMs) {
+        logger.warn('Closing zombie page', { ageMs: now - openedAt });
+        browserMetrics.zombiePagesClosed.inc();
+        await this.closePage(page);
+      }
+    }
+
+    if (!this.browser || !this.browser.isConnected()) {
+      return;
+    }
+
+    const chromeProcess = this.browser.process();
+    const memory = getProcessTreeMemory(chromeProcess && chromeProcess.pid);
+    if (memory) {
+      browserMetrics.memoryBytes.set({}, memory.rss);
+      if (memory.rss > limits.maxMemoryBytes && !this.recycleReason) {
+        logger.warn('Browser memory limit exceeded, scheduling recycle', {
+          rssBytes: memory.rss,
+          limitBytes: limits.maxMemoryBytes,
+          processes: memory.processes
+        });
+        this.recycleReason = 'memory';
+      }
+    }
+
+    if (this.recycleReason && this.pages.size === 0) {
+      await this.recycleBrowser();
+    }
+  }
+
+  /**
+   * Replace the current browser with a fresh instance. The old browser is
+   * closed as soon as its in-flight pages are done.
+   * @param {Object} log - Logger
+   * @returns {Promise<void>}
+   */
+  async recycleBrowser(log = logger) {
+    if (!this.recyclePromise) {
+      const reason = this.recycleReason || 'manual';
+      this.recyclePromise = (async () => {
+        const retired = this.browser;
+        log.info('Recycling browser', { reason, renders: this.renderCount });
+        browserMetrics.recycles.inc({ reason });
+
+        this.browser = null;
+        this.renderCount = 0;
+        this.recycleReason = null;
+        if (retired) {
+          this.retiredBrowsers.add(retired);
+          await this.closeRetiredBrowserIfIdle(retired);
+        }
+        await this.initializeBrowser();
+      })().finally(() => {
+        this.recyclePromise = null;
+      });
+    }
+    return this.recyclePromise;
+  }
+
+  /**
+   * Close a retired browser once none of its pages are open
+   * @param {Object} browser - Puppeteer browser
+   */
+  async closeRetiredBrowserIfIdle(browser) {
+    const inUse = [...this.pages.values()].some(entry => entry.browser === browser);
+    if (inUse) {
+      return;
+    }
+    this.retiredBrowsers.delete(browser);
+    if (browser.isConnected()) {
+      await browser.close().catch(error => logger.warn('Failed to close retired browser', { error }));
+    }
+  }
+
+  /**
+   * Wait for the browser to finish (re)starting. Renders queue up to
+   * BROWSER_RESTART_QUEUE_LIMIT; beyond that they are rejected with a 503 so
+   * clients retry elsewhere. Renders of PRIORITY_API_KEYS are never rejected
+   * for a full queue.
+   * @param {Object} context - Request context; receives queuedMs
+   * @returns {Promise<void>}
+   */
+  async waitForBrowser(context = {}) {
+    const { log = logger } = context;
+    const { queueLimit } = getBrowserLimits();
+
+    if (this.waiting >= queueLimit && !isPriorityKey(context.apiKey)) {
+      const error = new ApiError(503, 'Browser is restarting and the render queue is full, retry shortly')
+        .annotate({ stage: 'render', code: 'render_queue_full' });
+      error.retryAfter = 5;
+      throw error;
+    }
+
+    this.waiting += 1;
+    const startedAt = Date.now();
+    log.info('Render queued while the browser restarts', { position: this.waiting, queueLimit });
+    try {
+      if (this.recycleReason || this.recyclePromise) {
+        await this.recycleBrowser(log);
+      }
+      if (!this.browser || !this.browser.isConnected()) {
+        await this.initializeBrowser();
+      }
+    } finally {
+      this.waiting -= 1;
+      context.queuedMs = Date.now() - startedAt;
+      if (context.timings) {
+        context.timings.queued = context.queuedMs;
+      }
+      log.info('Render resumed after browser restart', { queuedMs: context.queuedMs });
+    }
+  }
+
+  /**
+   * Open a page for a render, recycling the browser first when it has
+   * reached its render or memory limit
+   * @param {Object} context - Request context (log, and queuedMs is set when the render had to wait)
+   * @param {Object} pageOptions - Page options
+   * @param {Object} pageOptions.proxy - Proxy from resolveProxy; the page gets its own
+   *   incognito context so the proxy does not affect other renders
+   * @returns {Promise<Object>} Puppeteer page; release it with closePage
+   */
+  async openPage(context = {}, { proxy = null } = {}) {
+    if (this.draining) {
+      throw new ApiError(503, 'Server is shutting down').annotate({ stage: 'render', code: 'shutting_down' });
+    }
+    if (getRenderer() === 'mock') {
+      throw new ApiError(501, 'This output needs Chrome and is not available with RENDERER=mock')
+        .annotate({ stage: 'render', code: 'renderer_unsupported' });
+    }
+    if (this.browser && !this.recycleReason && this.renderCount >= getBrowserLimits().maxRenders) {
+      this.recycleReason = 'renders';
+    }
+    if (this.recycleReason || this.recyclePromise || !this.browser || !this.browser.isConnected()) {
+      await this.waitForBrowser(context);
+    }
+
+    const browser = this.browser;
+    let incognito = null;
+    let page;
+    if (proxy) {
+      incognito = await browser.createIncognitoBrowserContext({
+        proxyServer: proxy.server,
+        proxyBypassList: proxy.bypass
+      });
+      page = await incognito.newPage();
+      if (proxy.username) {
+        await page.authenticate({ username: proxy.username, password: proxy.password });
+      }
+    } else {
+      page = await browser.newPage();
+    }
+    this.renderCount += 1;
+    this.pages.set(page, { browser, incognito, openedAt: Date.now() });
+    browserMetrics.openPages.set({}, this.pages.size);
+    return page;
+  }
+
+  /**
+   * Close a page opened with openPage
+   * @param {Object} page - Puppeteer page
+   */
+  async closePage(page) {
+    const entry = this.pages.get(page);
+    this.pages.delete(page);
+    browserMetrics.openPages.set({}, this.pages.size);
+    await page.close().catch(() => {});
+    if (entry && entry.incognito) {
+      await entry.incognito.close().catch(() => {});
+    }
+
+    if (entry && this.retiredBrowsers.has(entry.browser)) {
+      await this.closeRetiredBrowserIfIdle(entry.browser);
+    }
+  }
+
   /**
    * Generate a WhatsApp-style chat screenshot from messages
    * @param {Array} messages - Array of message objects
    * @param {Object} options - Screenshot options
+   * @param {Object} context - Request context, see captureChatScreenshot
    * @returns {Promise<string>} Base64 encoded image
    */
-  async generateWhatsAppScreenshot(messages, options = {}) {
+  async generateWhatsAppScreenshot(messages, options = {}, context = {}) {
+    const chatData = await this.prepareChatData(messages, options, context);
+    return this.captureChatScreenshot(chatData, options, context);
+  }
+
+  /**
+   * Render 2-4 conversations side by side in one PNG: each panel is captured
+   * on its own, a title row is rendered from the composition template, and
+   * the captures are stitched together
+   * @param {Array<Object>} panels - { title, messages, options }
+   * @param {Object} options - Composition options
+   * @param {number} options.gap - Space around and between panels in CSS pixels
+   * @param {string} options.background - Canvas color (#rrggbb)
+   * @param {string} options.titleColor - Title text color (#rrggbb)
+   * @param {Object} context - Request context, see captureChatScreenshot
+   * @returns {Promise<Object>} { image, width, height, panels }
+   */
+  async generateComposition(panels, { gap = 16, background = '#d1d7db', titleColor = '#111b21' } = {}, context = {}) {
+    // Panels are captured at deviceScaleFactor 2, so layout is done in CSS
+    // pixels and doubled for the canvas
+    const scale = 2;
+    const captures = [];
+    for (const panel of panels) {
+      const panelOptions = { ...panel.options, format: 'png' };
+      delete panelOptions.animation;
+      const chatData = await this.prepareChatData(panel.messages, panelOptions, context);
+      const image = decodePng(decodeDataUrl(await this.captureChatScreenshot(chatData, panelOptions, context)));
+      captures.push({ panel, chatData, image, cssWidth: Math.round(image.width / scale) });
+    }
+
+    const cssWidth = captures.reduce((sum, capture) => sum + capture.cssWidth, 0) + gap * (captures.length + 1);
+    const hasTitles = panels.some(panel => panel.title);
+    let titleRow = null;
+    if (hasTitles) {
+      const titles = captures
+        .map(({ panel, cssWidth: width }) => `<div class="panel-title" style="width:${width}px">${escapeHTML(panel.title || '')}</div>`)
+        .join('');
+      titleRow = await this.captureComposition(await templateService.getCompositionTemplate(), {
+        width: cssWidth,
+        gap,
+        background,
+        titleColor,
+        titles
+      }, context);
+    }
+
+    const top = titleRow ? titleRow.height : gap * scale;
+    let left = gap * scale;
+    const layers = titleRow ? [{ image: titleRow, x: 0, y: 0 }] : [];
+    captures.forEach(({ image }) => {
+      layers.push({ image, x: left, y: top });
+      left += image.width + gap * scale;
+    });
+
+    const width = cssWidth * scale;
+    const height = top + Math.max(...captures.map(({ image }) => image.height)) + gap * scale;
+    const stitchStartedAt = process.hrtime.bigint();
+    const composed = encodePng(stitchImages({ width, height, background }, layers));
+    observeStage(context, 'stitch', stitchStartedAt);
+
+    return {
+      image: `data:image/png;base64,${composed.toString('base64')}`,
+      width,
+      height,
+      panels: captures.map(({ panel, chatData, image }) => ({
+        title: panel.title || null,
+        message_count: chatData.messages.length,
+        width: image.width,
+        height: image.height
+      }))
+    };
+  }
+
+  /**
+   * Render a composition template and capture it as decoded pixels
+   * @param {string} source - Template source
+   * @param {Object} data - Template data; data.width is the page width in CSS pixels
+   * @param {Object} context - Request context
+   * @returns {Promise<Object>} { width, height, data: RGBA Buffer }
+   */
+  async captureComposition(source, data, context = {}) {
+    let page;
     try {
-      const { width = 400, format = 'png', quality = 'high', headerDisplay = 'phone' } = options;
+      page = await this.openPage(context);
+      await page.setViewport({ width: data.width, height: 100, deviceScaleFactor: 2 });
+      await page.setContent(renderTemplate(source, data), { waitUntil: 'domcontentloaded' });
+
+      // Size the viewport to the rendered content, as for chat captures
+      const bodyHandle = await page.$('body');
+      const boundingBox = bodyHandle ? await bodyHandle.boundingBox() : null;
+      if (bodyHandle) {
+        await bodyHandle.dispose();
+      }
+      await page.setViewport({
+        width: data.width,
+        height: Math.max(1, Math.ceil(boundingBox ? boundingBox.height : 100)),
+        deviceScaleFactor: 2
+      });
+      return decodePng(await page.screenshot({ type: 'png' }));
+    } catch (error) {
+      throw (error instanceof ApiError ? error : new ApiError(500, 'Failed to render composition titles').causedBy(error))
+        .annotate({ stage: 'render' });
+    } finally {
+      if (page) {
+        await this.closePage(page);
+      }
+    }
+  }
+
+  /**
+   * Capture the app icon with an unread badge as a small transparent PNG,
+   * separate from the chat
+   * @param {Object} appIcon - options.appIcon { badge, size }
+   * @param {Object} context - Request context
+   * @returns {Promise<string>} PNG data URL
+   */
+  async captureAppIcon(appIcon, context = {}) {
+    let page;
+    try {
+      page = await this.openPage(context);
+      const size = appIcon.size || 180;
+      await page.setViewport({ width: size, height: size, deviceScaleFactor: 1 });
+      await page.setContent(buildAppIconHTML(appIcon), { waitUntil: 'domcontentloaded' });
+      const png = await page.screenshot({ type: 'png', omitBackground: true });
+      return `data:image/png;base64,${Buffer.from(png).toString('base64')}`;
+    } catch (error) {
+      throw (error instanceof ApiError ? error : new ApiError(500, 'Failed to render app icon').causedBy(error))
+        .annotate({ stage: 'render' });
+    } finally {
+      if (page) {
+        await this.closePage(page);
+      }
+    }
+  }
+
+  /**
+   * Apply the request-level content transformations (anonymization and
+   * sensitive-content masking) and process the messages into chat data
+   * @param {Array} messages - Array of message objects
+   * @param {Object} options - Screenshot options
+   * @param {Object} context - Request context; context.timings receives stage durations,
+   *   context.warnings the messages beyond WhatsApp's limits
+   * @returns {Promise<Object>} Processed chat data
+   */
+  async prepareChatData(messages, options = {}, context = {}) {
+    const {
+      anonymize = false,
+      contentFilter,
+      authorAliases,
+      authorAvatars,
+      avatarUrl,
+      wallpaper,
+      limits = 'warn',
+      mediaErrors = 'placeholder'
+    } = options;
+
+    // Conversations the real app can't show are rejected or flagged
+    const limitProblems = limits === 'off' ? [] : checkWhatsAppLimits(messages);
+    if (limitProblems.length > 0 && limits === 'error') {
+      const error = new ApiError(400, `Beyond WhatsApp limits: ${limitProblems.map(problem => problem.message).join(', ')}`)
+        .annotate({ stage: 'validate', code: 'whatsapp_limit_exceeded' });
+      error.details = { limits: limitProblems };
+      throw error;
+    }
+    if (context.warnings) {
+      context.warnings.push(...limitProblems);
+    }
+
+    // Anonymize names, phone numbers and emails before anything is rendered;
+    // aliases are applied first so display names are anonymized too, and
+    // author photos are looked up by the aliased name
+    const anonymizeSettings = resolveAnonymizeSettings(anonymize);
+    const aliasedMessages = attachAuthorAvatars(applyAuthorAliases(messages, authorAliases), authorAvatars);
+    // Attachments and photos are inlined so the page never fetches remote media.
+    // Downloads go through the tenant proxy or RENDER_PROXY, like URL renders;
+    // those that fail render as placeholders and are reported as warnings.
+    const media = {
+      proxy: resolveProxy(null, context.apiKey),
+      onUnavailable: mediaErrors === 'placeholder'
+        ? (error, field) => context.warnings && context.warnings.push({
+          type: 'media',
+          code: error.code,
+          message: `${error.message}; a placeholder is shown instead`,
+          field
+        })
+        : undefined
+    };
+    const renderMessages = await inlineMedia(anonymizeMessages(aliasedMessages, anonymizeSettings), media);
+    const avatarSrc = avatarUrl ? await resolveMediaOrPlaceholder(avatarUrl, 'image', 'options.avatarUrl', media) : null;
+    const resolvedWallpaper = resolveWallpaper(wallpaper);
+    const wallpaperSrc = resolvedWallpaper && resolvedWallpaper.type === 'image'
+      ? await resolveMediaOrPlaceholder(resolvedWallpaper.url, 'image', 'options.wallpaper', media)
+      : null;
+    const blurAvatar = Boolean(anonymizeSettings && anonymizeSettings.avatar);
+
+    // Sensitive-content masking (per-request rules plus server-enforced rules)
+    const resolvedFilter = resolveContentFilter(contentFilter);
+
+    return this.processChatData(renderMessages, {
+      ...options,
+      // A wallpaper image that couldn't be fetched leaves the default background
+      ...(resolvedWallpaper && resolvedWallpaper.type === 'image' && !wallpaperSrc && { wallpaper: 'default' }),
+      avatarSrc,
+      wallpaperSrc,
+      blurAvatar,
+      contentFilter: resolvedFilter
+    }, context);
+  }
+
+  /**
+   * Render processed chat data to HTML and capture it
+   * @param {Object} chatData - Processed chat data from prepareChatData
+   * @param {Object} options - Screenshot options
+   * @param {Object} context - Request context
+   * @param {Object} context.log - Request-scoped logger for browser output
+   * @param {Array} context.warnings - Receives page console errors and failed requests
+   * @returns {Promise<string>} Base64 encoded image. With options.resourceReport
+   *   the network report is stored as context.resources, and attached to the
+   *   error details when the capture fails
+   */
+  async captureChatScreenshot(chatData, options = {}, context = {}) {
+    const { log = logger, warnings } = context;
+    let page;
+    let resourceTracker = null;
+    // Pipeline stage reported when the capture fails
+    let stage = 'template';
+    try {
+      const {
+        width = 400,
+        format = 'png',
+        quality = 'high',
+        resourceReport = false,
+        cropToMatch = false,
+        cropHeight = 600,
+        scrollTo,
+        cropToMessage,
+        viewportHeight = 800,
+        animation,
+        deviceFrame
+      } = options;
+
+      if (scrollTo && scrollTo.messageId !== undefined && !chatData.messages.some(msg => msg.id === scrollTo.messageId)) {
+        throw new ApiError(400, `scrollTo: message "${scrollTo.messageId}" is not in the rendered messages`)
+          .annotate({ stage: 'validate', code: 'scroll_message_not_found' });
+      }
+      // A framed capture is one phone screen
+      const frame = resolveDeviceFrame(deviceFrame);
+      if (frame && (animation || cropToMessage)) {
+        throw new ApiError(400, `deviceFrame cannot be combined with ${animation ? 'animation' : 'cropToMessage'}`)
+          .annotate({ stage: 'validate', code: 'device_frame_unsupported' });
+      }
+      if (cropToMessage) {
+        const { messageId, fromId, toId } = cropToMessage;
+        const missing = [messageId, fromId, toId]
+          .filter(id => id !== undefined && !chatData.messages.some(msg => msg.id === id));
+        if (missing.length > 0) {
+          throw new ApiError(400, `cropToMessage: message "${missing[0]}" is not in the rendered messages`)
+            .annotate({ stage: 'validate', code: 'crop_message_not_found' });
+        }
+      }
 
       // Generate HTML content
-      const htmlContent = await this.generateChatHTML(messages, { width, headerDisplay });
+      const htmlContent = await this.generateChatHTML(chatData, context);
+      stage = 'render';
 
-      // Ensure browser is initialized
-      if (!this.browser || !this.browser.isConnected()) {
-        await this.initializeBrowser();
+      if (getRenderer() === 'mock' && format !== 'pdf' && !animation) {
+        return await this.captureMockScreenshot(chatData, options, context);
       }
 
-      const page = await this.browser.newPage();
+      // Open a page (initializing or recycling the browser as needed)
+      const captureStartedAt = process.hrtime.bigint();
+      page = await this.openPage(context);
+      const pageProblems = attachPageLogging(page, log);
+      if (resourceReport) {
+        resourceTracker = trackResources(page);
+      }
+
+      // Uploaded templates get no JavaScript and no network access
+      const { sandboxed } = await templateService.getTemplate(chatData.template, context.apiKey);
+      if (sandboxed) {
+        await page.setJavaScriptEnabled(false);
+        await page.setRequestInterception(true);
+        page.on('request', request => (request.url().startsWith('data:') ? request.continue() : request.abort()));
+      }
 
       // Set content first. For local content, 'domcontentloaded' is usually sufficient.
       // A minimal default viewport is active before this, which is fine for rendering.
       await page.setContent(htmlContent, { waitUntil: 'domcontentloaded' });
 
+      // Watermarks (tenant-enforced and requested) are drawn before measuring,
+      // so a footer is part of the content height
+      const watermarks = resolveWatermarks(options.watermark, context.apiKey);
+      await applyWatermarks(page, watermarks);
+      context.watermarks = watermarks;
+
       // Calculate the height of the content
       const bodyHandle = await page.$('body');
       if (!bodyHandle) {
-        await page.close(); // Close the page to free up resources
         throw new ApiError(500, 'Failed to get body handle for height calculation');
       }
       const boundingBox = await bodyHandle.boundingBox();
       await bodyHandle.dispose();
 
       if (!boundingBox) {
-        await page.close(); // Close the page to free up resources
         throw new ApiError(500, 'Failed to get bounding box for height calculation');
       }
       const contentHeight = Math.ceil(boundingBox.height);
@@ -123,124 +796,886 @@ class ScreenshotService {
         screenshotOptions.quality = quality === 'high' ? 90 : quality === 'medium' ? 70 : 50;
       }
 
+      // Print-friendly PDF instead of an image
+      if (format === 'pdf') {
+        stage = 'encode';
+        const pdf = await this.printChatPdf(page, chatData, options.pdf, context.clock);
+        if (warnings) {
+          warnings.push(...pageProblems);
+        }
+        if (resourceTracker) {
+          context.resources = resourceTracker.report();
+        }
+        observeStage(context, 'capture', captureStartedAt);
+        const output = options.stripMetadata ? stripMetadata(pdf, 'pdf') : pdf;
+        return `data:application/pdf;base64,${output.toString('base64')}`;
+      }
+
+      // Animated output: frames captured while scrolling, or while the last
+      // message is being "typed", encoded to GIF/MP4
+      if (animation) {
+        const captureFrames = animation.type === 'typing' ? this.captureTypingFrames : this.captureScrollFrames;
+        const frames = await captureFrames.call(this, page, {
+          width: parseInt(width, 10),
+          viewportHeight,
+          ...animation
+        });
+        stage = 'encode';
+        const encoded = await encodeAnimation(frames, { ...animation, stripMetadata: options.stripMetadata });
+        if (warnings) {
+          warnings.push(...pageProblems);
+        }
+        if (resourceTracker) {
+          context.resources = resourceTracker.report();
+        }
+        context.frameCount = frames.length;
+        observeStage(context, 'capture', captureStartedAt);
+        return `data:${ANIMATION_FORMATS[animation.format].mimeType};base64,${encoded.toString('base64')}`;
+      }
+
+      // Capture what a phone shows at a scroll position: a viewport-sized
+      // capture with the sticky header, instead of the whole chat
+      let searchWarning = null;
+      if (frame) {
+        // What the phone's screen shows, inside the device mockup
+        await this.loadDeviceFrame(page, frame, { scrollTo, theme: chatData.theme });
+        screenshotOptions.fullPage = false;
+      } else if (scrollTo) {
+        await page.setViewport({
+          width: parseInt(width, 10),
+          height: viewportHeight,
+          deviceScaleFactor: 2
+        });
+        await this.scrollChat(page, scrollTo);
+        screenshotOptions.fullPage = false;
+      } else if (cropToMessage) {
+        // Only the bubble (or the range of messages), with a margin of chat
+        // background around it
+        const clip = await this.getMessageClip(page, cropToMessage, { width: parseInt(width, 10), contentHeight });
+        screenshotOptions.fullPage = false;
+        screenshotOptions.clip = clip;
+        await applyWatermarks(page, watermarks, clip);
+      } else if (cropToMatch) {
+        // Crop to a window centred on the first message matching searchTerm
+        const clip = await this.getMatchClip(page, { width: parseInt(width, 10), contentHeight, cropHeight });
+        if (clip) {
+          screenshotOptions.fullPage = false;
+          screenshotOptions.clip = clip;
+          await applyWatermarks(page, watermarks, clip);
+        } else {
+          searchWarning = { type: 'search', message: 'cropToMatch: no message matches searchTerm, captured the full chat' };
+        }
+      }
+
+      stage = 'encode';
       const screenshot = await page.screenshot(screenshotOptions);
+      if (warnings) {
+        warnings.push(...pageProblems);
+        if (searchWarning) {
+          warnings.push(searchWarning);
+        }
+      }
+      if (resourceTracker) {
+        context.resources = resourceTracker.report();
+      }
+      observeStage(context, 'capture', captureStartedAt);
 
       // Do not close the browser here; it's reused.
       // await browser.close(); 
 
       // Convert to base64
-      const base64Image = screenshot.toString('base64');
+      const output = options.stripMetadata ? stripMetadata(screenshot, format) : screenshot;
+      const base64Image = output.toString('base64');
       return `data:image/${format};base64,${base64Image}`;
     } catch (error) {
       console.error('Error generating screenshot:', error);
-      throw new ApiError(500, 'Failed to generate screenshot');
+      // Client errors, "busy, retry" responses and errors already classified
+      // by an earlier stage pass through unchanged
+      const apiError = error instanceof ApiError && (error.statusCode < 500 || error.statusCode === 503 || error.stage)
+        ? error
+        : new ApiError(500, 'Failed to generate screenshot').causedBy(error);
+      throw withResourceReport(apiError.annotate({ stage }), resourceTracker);
+    } finally {
+      if (page) {
+        await this.closePage(page);
+      }
     }
   }
 
   /**
-   * Generate HTML content for the chat
-   * @private
+   * Placeholder capture for RENDERER=mock: an image sized like the real
+   * screenshot, in the requested format. The placeholder can't carry
+   * watermarks, so renders a tenant policy watermarks are refused.
+   * @param {Object} chatData - Data from processChatData
+   * @param {Object} options - Screenshot options
+   * @param {Object} context - Request context
+   * @returns {Promise<string>} Image data URL
    */
-  async generateChatHTML(messages, options = {}) {
-    try {
-      const { width, headerDisplay } = options;
+  async captureMockScreenshot(chatData, options, context) {
+    const { format = 'png', quality = 'high' } = options;
+    if (resolveWatermarks(options.watermark, context.apiKey).some(watermark => watermark.enforced)) {
+      throw new ApiError(501, 'Enforced watermarks need Chrome and are not available with RENDERER=mock')
+        .annotate({ stage: 'render', code: 'renderer_unsupported' });
+    }
 
-      if (!this.chatTemplate) {
-        // This case should ideally not be reached if initializeBrowser was successful.
-        // However, as a fallback, or if generateChatHTML could be called before full initialization.
-        console.error('Chat template not loaded. Attempting to load now...');
-        try {
-          this.chatTemplate = await fs.readFile(this.templatePath, 'utf-8');
-          console.log('HTML template loaded on demand.');
-        } catch (error) {
-          console.error('Failed to load HTML template on demand:', error);
-          throw new ApiError(500, 'Failed to load chat template');
-        }
+    const captureStartedAt = process.hrtime.bigint();
+    let image = renderMockChat(chatData, { width: options.width });
+    if (format === 'jpeg' || format === 'webp') {
+      // Loaded on demand: only the mock renderer encodes images itself
+      const sharp = require('sharp');
+      image = await sharp(image)[format]({ quality: quality === 'high' ? 90 : quality === 'medium' ? 70 : 50 }).toBuffer();
+    }
+    context.watermarks = [];
+    observeStage(context, 'capture', captureStartedAt);
+    return `data:image/${format};base64,${image.toString('base64')}`;
+  }
+
+  /**
+   * Scroll the chat for a scrollTo capture. Message positions account for the
+   * sticky header so the message is not hidden behind it.
+   * @param {Object} page - Puppeteer page with the chat loaded
+   * @param {string|Object} scrollTo - "top", "bottom" or { messageId, align }
+   * @returns {Promise<void>}
+   */
+  async scrollChat(page, scrollTo) {
+    const target = typeof scrollTo === 'string' ? { position: scrollTo } : { align: 'center', ...scrollTo };
+    await page.evaluate(({ position, messageId, align }) => {
+      const root = document.scrollingElement || document.documentElement;
+      if (position) {
+        window.scrollTo(0, position === 'bottom' ? root.scrollHeight : 0);
+        return;
+      }
+
+      const element = [...document.querySelectorAll('[data-message-id]')]
+        .find(node => node.getAttribute('data-message-id') === messageId);
+      if (!element) {
+        return;
+      }
+      const header = document.querySelector('.chat-header');
+      const headerHeight = header ? header.offsetHeight : 0;
+      const rect = element.getBoundingClientRect();
+      const top = rect.top + window.scrollY;
+      const margin = 8;
+      const offsets = {
+        top: top - headerHeight - margin,
+        center: top + rect.height / 2 - (window.innerHeight + headerHeight) / 2,
+        bottom: top + rect.height - window.innerHeight + margin
+      };
+      window.scrollTo(0, Math.max(0, offsets[align]));
+    }, target);
+  }
+
+  /**
+   * Replace the loaded chat with its device mockup: the chat is captured at
+   * the phone's screen size, scrolled like scrollTo (to the bottom by
+   * default, like a phone showing the latest messages), and drawn below a
+   * status bar in the color of the chat header
+   * @param {Object} page - Puppeteer page with the chat loaded
+   * @param {Object} frame - Device from resolveDeviceFrame
+   * @param {Object} settings - { scrollTo, theme }
+   */
+  async loadDeviceFrame(page, frame, { scrollTo = 'bottom', theme = 'light' }) {
+    await page.setViewport({
+      width: frame.screen.width,
+      height: frame.screen.height - frame.statusBarHeight,
+      deviceScaleFactor: 2
+    });
+    await this.scrollChat(page, scrollTo);
+    const headerColor = await page.evaluate(() => {
+      const header = document.querySelector('.chat-header');
+      return header ? window.getComputedStyle(header).backgroundColor : null;
+    });
+    const screen = await page.screenshot({ type: 'png' });
+
+    const data = buildDeviceFrameData(frame, {
+      src: `data:image/png;base64,${Buffer.from(screen).toString('base64')}`,
+      background: headerColor || (theme === 'dark' ? '#111b21' : '#ffffff')
+    });
+    await page.setViewport({ width: data.deviceWidth, height: data.deviceHeight, deviceScaleFactor: 2 });
+    await page.setContent(renderTemplate(await templateService.getDeviceFrameTemplate(), data), { waitUntil: 'load' });
+  }
+
+  /**
+   * Print the loaded chat to PDF with a header (chat name, export date), a
+   * "Page X of Y" footer and no page breaks inside message bubbles
+   * @param {Object} page - Puppeteer page with the chat loaded
+   * @param {Object} chatData - Processed chat data
+   * @param {Object} pdfOptions - { pageSize, landscape, margin, header, footer }
+   * @param {Object} [clock] - Clock of the export date, the system clock by default
+   * @returns {Promise<Buffer>} PDF file
+   */
+  async printChatPdf(page, chatData, pdfOptions = {}, clock = systemClock) {
+    const {
+      pageSize = 'A4',
+      landscape = false,
+      margin = {},
+      header = true,
+      footer = true
+    } = pdfOptions;
+
+    await page.addStyleTag({
+      content: '.message { break-inside: avoid; page-break-inside: avoid; } .chat-header { position: static; }'
+    });
+
+    // Chrome fills .pageNumber and .totalPages in header/footer templates;
+    // the templates do not inherit page styles, so they are styled inline
+    const style = 'font-size:9px;color:#667781;width:100%;padding:0 12mm;display:flex;justify-content:space-between;'
+      + 'font-family:-apple-system,BlinkMacSystemFont,Segoe UI,Roboto,Helvetica,Arial,sans-serif;';
+    const exportDate = clock.now().toISOString().slice(0, 10);
+    const headerTemplate = header
+      ? `<div style="${style}"><span>${escapeHTML(chatData.chatName || '')}</span><span>Exported ${exportDate}</span></div>`
+      : '<div></div>';
+    const footerTemplate = footer
+      ? `<div style="${style}justify-content:center;">Page&nbsp;<span class="pageNumber"></span>&nbsp;of&nbsp;<span class="totalPages"></span></div>`
+      : '<div></div>';
+
+    return page.pdf({
+      format: pageSize,
+      landscape,
+      printBackground: true,
+      displayHeaderFooter: header || footer,
+      headerTemplate,
+      footerTemplate,
+      margin: {
+        top: margin.top || (header ? '20mm' : '10mm'),
+        right: margin.right || '10mm',
+        bottom: margin.bottom || (footer ? '20mm' : '10mm'),
+        left: margin.left || '10mm'
       }
-      let template = this.chatTemplate;
+    });
+  }
+
+  /**
+   * Capture PNG frames while scrolling the chat from top to bottom at a
+   * constant speed. The first and last frames are held for `hold` seconds.
+   * @param {Object} page - Puppeteer page with the chat loaded
+   * @param {Object} settings - { width, viewportHeight, fps, duration, hold, scale }
+   * @returns {Promise<Array<Object>>} Frames as { png, delayMs }
+   */
+  async captureScrollFrames(page, { width, viewportHeight, fps = 10, duration = 4, hold = 1, scale = 1 }) {
+    await page.setViewport({ width, height: viewportHeight, deviceScaleFactor: scale });
+    const maxScroll = await page.evaluate(() => {
+      const root = document.scrollingElement || document.documentElement;
+      return Math.max(0, root.scrollHeight - window.innerHeight);
+    });
+
+    const frameCount = Math.min(Math.max(2, Math.round(fps * duration)), MAX_ANIMATION_FRAMES);
+    const frameMs = 1000 / fps;
+    const frames = [];
+    for (let i = 0; i < frameCount; i += 1) {
+      const y = Math.round((i / (frameCount - 1)) * maxScroll);
+      await page.evaluate(scrollY => window.scrollTo(0, scrollY), y);
+      const png = await page.screenshot({ type: 'png' });
+      const held = i === 0 || i === frameCount - 1 ? hold * 1000 : 0;
+      frames.push({ png, delayMs: frameMs + held });
+    }
+    return frames;
+  }
+
+  /**
+   * Capture a typing sequence: the chat without its last message, a typing
+   * indicator on the last message's side with the dots cycling, then the
+   * last message. The view stays scrolled to the bottom, like a phone.
+   * The indicator is styled inline so it works with any template.
+   * @param {Object} page - Puppeteer page with the chat loaded
+   * @param {Object} settings - { width, viewportHeight, hold, typingDuration, scale }
+   * @returns {Promise<Array<Object>>} Frames as { png, delayMs }
+   */
+  async captureTypingFrames(page, { width, viewportHeight, hold = 1, typingDuration = 2, scale = 1 }) {
+    await page.setViewport({ width, height: viewportHeight, deviceScaleFactor: scale });
+
+    // phase: "before" hides the last message, "typing" also shows the
+    // indicator with `dot` highlighted, "after" restores the chat
+    const showPhase = (phase, dot = 0) => page.evaluate(({ phase: current, dot: activeDot }) => {
+      // options.typingIndicator's bubble stays below the animated one
+      const messages = document.querySelectorAll('.message:not(.typing-indicator)');
+      const last = messages[messages.length - 1];
+      let indicator = document.getElementById('typing-indicator');
+
+      if (last) {
+        last.style.display = current === 'after' ? '' : 'none';
+      }
+      if (current === 'typing' && !indicator && last) {
+        indicator = document.createElement('div');
+        indicator.id = 'typing-indicator';
+        indicator.className = last.classList.contains('sent') ? 'message sent' : 'message received';
+        indicator.innerHTML = '<div class="message-content" style="display:inline-flex;gap:4px;padding:10px 12px">'
+          + [0, 1, 2].map(() => '<span style="width:8px;height:8px;border-radius:50%;display:inline-block"></span>').join('')
+          + '</div>';
+        last.parentNode.insertBefore(indicator, last.nextSibling);
+      }
+      if (indicator) {
+        indicator.style.display = current === 'typing' ? '' : 'none';
+        indicator.querySelectorAll('span').forEach((node, index) => {
+          node.style.backgroundColor = index === activeDot ? '#667781' : '#b3bcc2';
+        });
+      }
+
+      const root = document.scrollingElement || document.documentElement;
+      window.scrollTo(0, root.scrollHeight);
+    }, { phase, dot });
+
+    const dotMs = 300;
+    const frames = [];
+    await showPhase('before');
+    frames.push({ png: await page.screenshot({ type: 'png' }), delayMs: Math.max(dotMs, hold * 1000) });
+
+    const typingFrames = Math.max(1, Math.round((typingDuration * 1000) / dotMs));
+    for (let i = 0; i < typingFrames; i += 1) {
+      await showPhase('typing', i % 3);
+      frames.push({ png: await page.screenshot({ type: 'png' }), delayMs: dotMs });
+    }
+
+    await showPhase('after');
+    frames.push({ png: await page.screenshot({ type: 'png' }), delayMs: Math.max(dotMs, hold * 2000) });
+    return frames;
+  }
+
+  /**
+   * Bounding box of a message's bubble, found by its anchor ID
+   * @param {Object} page - Puppeteer page with the chat loaded
+   * @param {string} messageId - Request message ID
+   * @returns {Promise<Object>} { x, y, width, height }
+   */
+  async getBubbleBox(page, messageId) {
+    const bubble = await page.$(`#${messageAnchorId(messageId)}`);
+    const box = bubble ? await bubble.boundingBox() : null;
+    if (bubble) {
+      await bubble.dispose();
+    }
+    if (!box) {
+      throw new ApiError(500, `cropToMessage: message "${messageId}" has no bubble in the template`)
+        .annotate({ code: 'crop_message_not_rendered' });
+    }
+    return box;
+  }
+
+  /**
+   * Find the clip rectangle for cropToMessage, kept inside the content: one
+   * bubble plus padding on every side, or the full chat width from the top of
+   * fromId's bubble to the bottom of toId's (in either order) plus padding
+   * @param {Object} page - Puppeteer page with the chat loaded
+   * @param {Object} cropToMessage - { messageId } or { fromId, toId }, and padding
+   * @param {Object} dimensions - { width, contentHeight }
+   * @returns {Promise<Object>} Clip for page.screenshot
+   */
+  async getMessageClip(page, { messageId, fromId, toId, padding = 12 }, { width, contentHeight }) {
+    let box;
+    if (messageId !== undefined) {
+      box = await this.getBubbleBox(page, messageId);
+    } else {
+      const [from, to] = await Promise.all([this.getBubbleBox(page, fromId), this.getBubbleBox(page, toId)]);
+      const top = Math.min(from.y, to.y);
+      box = { x: 0, y: top, width, height: Math.max(from.y + from.height, to.y + to.height) - top };
+    }
+
+    const x = Math.max(0, Math.floor(box.x - padding));
+    const y = Math.max(0, Math.floor(box.y - padding));
+    const right = Math.min(width, Math.ceil(box.x + box.width + padding));
+    const bottom = Math.min(contentHeight, Math.ceil(box.y + box.height + padding));
+    return { x, y, width: right - x, height: bottom - y };
+  }
+
+  /**
+   * Find the clip rectangle for cropToMatch: cropHeight pixels centred on the
+   * first message highlighted by searchTerm, kept inside the content
+   * @param {Object} page - Puppeteer page with the chat loaded
+   * @param {Object} dimensions - { width, contentHeight, cropHeight }
+   * @returns {Promise<Object|null>} Clip for page.screenshot, or null without a match
+   */
+  async getMatchClip(page, { width, contentHeight, cropHeight }) {
+    const match = await page.$('.message.search-hit');
+    if (!match) {
+      return null;
+    }
+    const box = await match.boundingBox();
+    await match.dispose();
+    if (!box) {
+      return null;
+    }
+
+    const height = Math.min(cropHeight, contentHeight);
+    const centre = box.y + box.height / 2;
+    const y = Math.max(0, Math.min(centre - height / 2, contentHeight - height));
+    return { x: 0, y: Math.round(y), width, height };
+  }
+
+  /**
+   * Process messages into the data handed to the template: header fields
+   * plus formatted content, classes and times for every message
+   * @param {Array} messages - Array of message objects
+   * @param {Object} options - Processing options
+   * @param {Object} context - Request context; context.timings receives stage durations,
+   *   context.clock ({ now() }) is the time "last seen" shows
+   * @returns {Promise<Object>} Chat data
+   */
+  async processChatData(messages, options = {}, context = {}) {
+    const startedAt = process.hrtime.bigint();
+    try {
+      const {
+        width,
+        headerDisplay,
+        avatarSrc = null,
+        wallpaper,
+        wallpaperSrc = null,
+        blurAvatar = false,
+        contentFilter = null,
+        spoilers = 'hidden',
+        contentFormat = 'whatsapp',
+        autoLink = false,
+        direction: requestedDirection = 'auto',
+        autoDirection = true,
+        formatAuthorPhones = true,
+        chatType = 'standard',
+        locale: uiLocale,
+        timeFormat,
+        presence = 'lastSeen',
+        headerStatus: customStatus,
+        typingIndicator,
+        template = DEFAULT_TEMPLATE,
+        window = null,
+        searchTerm,
+        bubbles,
+        platform,
+        theme = 'light',
+        keyboard,
+        composer = {},
+        view = 'chat',
+        notification = {},
+        contact = {},
+        chatList = {}
+      } = options;
 
       // Extract recipient info from the first message
       const firstMessage = messages[0] || {};
       const recipientName = firstMessage.recipient_name || 'Customer';
-      let recipientPhone = firstMessage.recipient_phone || 'Unknown';
-      let headerLineText;
+      // "auto" follows the messages, and an RTL locale mirrors the app
+      // chrome even when they are LTR
+      const direction = requestedDirection !== 'auto'
+        ? requestedDirection
+        : isRtlLocale(uiLocale) ? 'rtl' : detectChatDirection(messages);
+      const headerLineText = headerDisplay === 'name'
+        ? recipientName
+        : formatRecipientPhone(firstMessage.recipient_phone || 'Unknown');
 
-      if (headerDisplay === 'name') {
-        headerLineText = recipientName;
-      } else {
-        // Format recipient phone number to add +62 prefix if it's not already there
-        if (!recipientPhone.startsWith('+62')) {
-          if (!recipientPhone.startsWith('62')) {
-            recipientPhone = `+62 ${recipientPhone}`;
-          } else {
-            recipientPhone = `+62 ${recipientPhone.slice(2)}`;
+      const lastSeen = getTimeFormatter(uiLocale, 'Asia/Jakarta', timeFormat).format((context.clock || systemClock).now());
+      // A custom status line replaces the presence preset as given
+      let headerStatus = presence === 'lastSeen'
+        ? translate(uiLocale, 'lastSeenToday', { time: lastSeen })
+        : translate(uiLocale, presence);
+      if (customStatus !== undefined) {
+        headerStatus = customStatus;
+      }
+      const typing = resolveTypingIndicator(typingIndicator);
+      const resolvedWallpaper = resolveWallpaper(wallpaper);
+
+      // Render only the requested slice; the header still comes from the
+      // first message of the full conversation
+      const windowMessages = applyWindow(messages, window);
+
+      // Format every message (in parallel for large chats), timing the
+      // formatting (regex) work separately
+      const formatStartedAt = process.hrtime.bigint();
+      const chatMessages = await formatPool.formatAll(windowMessages, {
+        contentFilter,
+        contentFormat,
+        spoilers,
+        autoLink,
+        direction,
+        autoDirection,
+        formatAuthorPhones,
+        chatType,
+        locale: uiLocale,
+        timeFormat
+      });
+
+      // Highlight the search term like WhatsApp search; redacted bubbles
+      // contain no real text
+      let searchMatchCount = 0;
+      const searchPattern = compileSearchPattern(searchTerm);
+      if (searchPattern) {
+        chatMessages.forEach(msg => {
+          if (msg.contentClass === 'redacted') {
+            return;
           }
-        } else {
-          // Add space after +62 if space is not already there
-          if (!recipientPhone.includes(' ')) {
-            recipientPhone = recipientPhone.replace('+62', '+62 ');
+          const { html, count } = highlightMatches(msg.contentHTML, searchPattern);
+          if (count > 0) {
+            msg.contentHTML = html;
+            msg.bubbleClass += ' search-hit';
+            searchMatchCount += count;
           }
-        }
-        // Format to add dash after every 4 digits
-        recipientPhone = recipientPhone.replace(/(?=\d{4}(?:\d{4})*$)/g, '-');
-        headerLineText = recipientPhone;
+        });
       }
 
-      const lastSeen = new Date().toLocaleTimeString('id-ID', {
-        timeZone: "Asia/Jakarta",
-        hour: '2-digit',
-        minute: '2-digit',
-        hour12: true
+      markMessageGroups(chatMessages);
+      // keyboard.draft is a shorthand for composer.draft
+      const draft = composer.draft || (keyboard && keyboard.draft);
+      const reply = resolveReply(composer.replyTo, messages, { contentFilter, contentFormat, spoilers, direction, autoDirection, chatType, locale: uiLocale, timeFormat }, context.clock || systemClock);
+      const platformLook = resolvePlatform(platform, {
+        keyboard,
+        composer: { draft, reply, attachmentTray: composer.attachmentTray },
+        locale: uiLocale
       });
+      const keyboardLook = renderKeyboard(keyboard, { platform, theme, draft });
+      const community = chatType === 'community';
 
-      // Generate messages HTML
-      const messagesHTML = messages.map(msg => {
-        const isBot = msg.sender === 'Bot';
-        const time = new Date(msg.timestamp).toLocaleTimeString('id-ID', {
-          // msg.timestamp is already in Asia/Jakarta
-          hour: '2-digit',
-          minute: '2-digit',
-          hour12: true
-        });
+      observeStage(context, 'format', formatStartedAt);
+      observeStage(context, 'process', startedAt);
+      pipelineMetrics.messagesProcessed.inc({}, chatMessages.length);
 
-        // Format WhatsApp message formatting into html 
-        const content = convertWhatsAppToHTML(msg.content);
+      return {
+        template,
+        width: width || '400px',
+        direction,
+        // The header name follows its own script, like a bubble
+        headerDirection: detectDirection(headerLineText) || direction,
+        // Template helpers format numbers for id-ID unless a locale is set
+        locale: uiLocale || DEFAULT_LOCALE,
+        uiLocale,
+        timeFormat,
+        recipientName: recipientName.charAt(0).toUpperCase(),
+        chatName: recipientName,
+        // Announcement groups show the megaphone icon unless a photo is given
+        profilePicClass: `profile-pic${avatarSrc || community ? ' has-photo' : ''}${community ? ' announcement' : ''}${blurAvatar ? ' blurred' : ''}`,
+        profilePhoto: avatarSrc ? `<img src="${escapeHTML(avatarSrc)}" alt="">` : community ? renderAnnouncementIcon() : '',
+        blurAvatar,
+        headerLineText,
+        lastSeen,
+        headerStatus: escapeHTML(headerStatus),
+        typingIndicator: typing ? renderTypingIndicator(typing) : '',
+        totalMessageCount: messages.length,
+        searchMatchCount,
+        platformClass: platformLook.platformClass,
+        platformStyle: platformLook.platformStyle,
+        // Members can't post in announcement groups; a composer or keyboard
+        // state shows the input bar an admin sees
+        inputBar: platformLook.inputBar || (community ? renderAdminOnlyNote(uiLocale) : ''),
+        chatType,
+        theme,
+        themeStyle: buildThemeStyle(theme),
+        wallpaper: resolvedWallpaper ? resolvedWallpaper.name || resolvedWallpaper.type : 'default',
+        wallpaperStyle: buildWallpaperStyle(resolvedWallpaper, wallpaperSrc),
+        keyboardStyle: keyboardLook.keyboardStyle,
+        keyboard: keyboardLook.keyboard,
+        view,
+        ...(view === 'notification' && { notification: { ...notification, style: notification.style || platform || 'ios' } }),
+        // The contact defaults to the chat's recipient
+        ...(view === 'contact-info' && {
+          contact: {
+            ...contact,
+            name: contact.name || recipientName,
+            phone: contact.phone || formatRecipientPhone(firstMessage.recipient_phone || 'Unknown'),
+            style: platform || 'android',
+            photo: avatarSrc,
+            blurAvatar
+          }
+        }),
+        // The request's conversation is listed first, with its last message
+        ...(view === 'chat-list' && {
+          chatList: {
+            style: platform || 'android',
+            chats: [
+              chatListEntry(recipientName, chatMessages[chatMessages.length - 1], { ...chatList.conversation, blurAvatar }),
+              ...(chatList.chats || []).map(chat => ({ ...chat, previewHTML: escapeHTML(chat.lastMessage || '') }))
+            ]
+          }
+        }),
+        // options.bubbles replaces the platform's bubble preset
+        bubbleStyle: buildBubbleStyle(bubbles || platformLook.bubbles),
+        messages: chatMessages
+      };
+    } catch (error) {
+      console.error('Error processing chat data:', error);
+      if (error instanceof ApiError && error.statusCode < 500) {
+        throw error.annotate({ stage: 'validate' });
+      }
+      throw new ApiError(500, 'Failed to process chat data').annotate({ stage: 'template' }).causedBy(error);
+    }
+  }
 
-        return `
-          <div class="message ${isBot ? 'sent' : 'received'}">
-            <div class="message-content">
-              <p>${content}</p>
+  /**
+   * Render the message bubbles for processed chat messages. Received group
+   * messages show the author's avatar and name on the first bubble of a run.
+   * @param {Array} chatMessages - Messages from processChatData
+   * @param {Object} [settings] - { blurAvatar } from processChatData
+   * @returns {string} Messages HTML
+   */
+  renderMessagesHTML(chatMessages, { blurAvatar = false } = {}) {
+    return chatMessages.map(msg => {
+      const firstOfRun = msg.author && /\bgroup-first\b/.test(msg.bubbleClass);
+      return `
+          <div class="message ${msg.bubbleClass}${msg.author ? ' has-author' : ''}"${msg.id !== undefined ? ` id="${messageAnchorId(msg.id)}" data-message-id="${escapeHTML(msg.id)}"` : ''}>
+            ${firstOfRun ? renderAuthorAvatar(msg.author, blurAvatar) : ''}
+            <div class="message-content${msg.media ? ` has-media media-${msg.media.type}-bubble` : ''}">
+              ${firstOfRun ? `<span class="message-author" style="color: ${authorColor(msg.author.name)}">${escapeHTML(msg.author.label)}${msg.author.pushName ? `<span class="message-author-pushname">~${escapeHTML(msg.author.pushName)}</span>` : ''}</span>` : ''}
+              ${msg.media ? renderMediaHTML(msg.media, msg.contentClass) : ''}
+              ${msg.media && !msg.contentHTML ? '' : `<p class="${msg.contentClass}" dir="${msg.dir}">${msg.contentHTML}</p>`}
               <span class="message-time">
-                ${time}
-                ${isBot ? '<span class="message-status"></span>' : ''}
+                ${msg.time}
+                ${msg.isSent ? `<span class="message-status status-${msg.status}" title="${msg.statusLabel}"></span>` : ''}
               </span>
             </div>
           </div>
         `;
-      }).join('');
-
-      // Replace placeholders in the template
-      return template
-        .replace('{{recipientName}}', recipientName.charAt(0).toUpperCase())
-        .replace('{{headerLineText}}', headerLineText)
-        .replace('{{lastSeen}}', lastSeen)
-        .replace('{{messages}}', messagesHTML)
-        .replace('{{width}}', width || '400px');
+    }).join('');
+  }
+
+  /**
+   * Generate HTML content for the chat
+   * @param {Object} chatData - Processed chat data
+   * @param {Object} context - Request context; context.timings receives the stage duration
+   * @returns {Promise<string>} Full HTML document
+   * @private
+   */
+  async generateChatHTML(chatData, context = {}) {
+    const startedAt = process.hrtime.bigint();
+    try {
+      // Views other than the chat have their own built-in templates
+      if (VIEW_DATA[chatData.view]) {
+        const html = renderTemplate(await templateService.getViewTemplate(chatData.view), {
+          ...chatData,
+          ...VIEW_DATA[chatData.view](chatData)
+        });
+        observeStage(context, 'html', startedAt);
+        pipelineMetrics.htmlBytes.observe({}, Buffer.byteLength(html));
+        return html;
+      }
+
+      // Resolve the template from the caller's uploads or the registry of
+      // built-in templates; uploaded templates are rendered in the sandbox
+      const template = await templateService.getTemplate(chatData.template, context.apiKey);
+
+      // Render the template with the chat data
+      const html = renderTemplate(template.source, {
+        ...chatData,
+        // The typing bubble, if any, comes after the last message
+        messages: this.renderMessagesHTML(chatData.messages, chatData) + (chatData.typingIndicator || '')
+      }, { sandbox: template.sandboxed });
+
+      observeStage(context, 'html', startedAt);
+      pipelineMetrics.htmlBytes.observe({}, Buffer.byteLength(html));
+      return html;
     } catch (error) {
       console.error('Error generating chat HTML:', error);
-      throw new ApiError(500, 'Failed to generate chat HTML');
+      if (error instanceof ApiError && error.statusCode < 500) {
+        throw error.annotate({ stage: 'template' });
+      }
+      throw new ApiError(500, 'Failed to generate chat HTML').annotate({ stage: 'template' }).causedBy(error);
+    }
+  }
+
+  /**
+   * Capture a remote page. The URL must pass the SSRF guard, and every
+   * request the page makes is restricted to allowlisted hosts.
+   * @param {string} url - Page URL
+   * @param {Object} options - Capture options
+   * @param {Object} context - Request context, see captureChatScreenshot
+   * @returns {Promise<string>} Base64 encoded image
+   */
+  async captureUrl(url, options = {}, context = {}) {
+    const { log = logger, warnings, apiKey } = context;
+    const {
+      proxy: requestedProxy,
+      resourceReport = false,
+      viewport = {},
+      selector,
+      fullPage = true,
+      waitUntil = 'networkidle2',
+      waitForSelector,
+      delay = 0,
+      timeout = 30000,
+      format = 'png',
+      quality = 'high'
+    } = options;
+
+    const proxy = resolveProxy(requestedProxy, apiKey);
+    await assertUrlAllowed(url, { resolve: !proxy });
+    if (proxy) {
+      log.info('Rendering URL through proxy', { proxy: proxy.server });
+    }
+
+    let page;
+    let resourceTracker = null;
+    let stage = 'render';
+    const captureStartedAt = process.hrtime.bigint();
+    try {
+      page = await this.openPage(context, { proxy });
+      const pageProblems = attachPageLogging(page, log);
+      if (resourceReport) {
+        resourceTracker = trackResources(page);
+      }
+      await page.setViewport({
+        width: viewport.width || 1280,
+        height: viewport.height || 800,
+        deviceScaleFactor: viewport.deviceScaleFactor || 1
+      });
+
+      // Redirects and subresources must stay on allowlisted hosts too
+      await page.setRequestInterception(true);
+      page.on('request', request => {
+        const requestUrl = request.url();
+        if (requestUrl.startsWith('data:')) {
+          return request.continue();
+        }
+        try {
+          const { protocol, hostname } = new URL(requestUrl);
+          if ((protocol === 'http:' || protocol === 'https:') && isHostAllowed(hostname)) {
+            return request.continue();
+          }
+        } catch (error) {
+          // Fall through to abort
+        }
+        return request.abort('blockedbyclient');
+      });
+
+      await page.goto(url, { waitUntil, timeout });
+      if (waitForSelector) {
+        await page.waitForSelector(waitForSelector, { timeout });
+      }
+      if (delay > 0) {
+        await new Promise(resolve => setTimeout(resolve, delay));
+      }
+
+      const screenshotOptions = { type: format };
+      if (format === 'jpeg' || format === 'webp') {
+        screenshotOptions.quality = quality === 'high' ? 90 : quality === 'medium' ? 70 : 50;
+      }
+
+      // Drawn last so the page's own layout can't push them out of the capture
+      const watermarks = resolveWatermarks(options.watermark, apiKey);
+      await applyWatermarks(page, watermarks, selector ? { selector } : (fullPage ? 'document' : null));
+      context.watermarks = watermarks;
+
+      let screenshot;
+      if (selector) {
+        const element = await page.$(selector);
+        if (!element) {
+          throw new ApiError(422, `Selector not found: ${selector}`).annotate({ code: 'selector_not_found' });
+        }
+        stage = 'encode';
+        screenshot = await element.screenshot(screenshotOptions);
+        await element.dispose();
+      } else {
+        stage = 'encode';
+        screenshot = await page.screenshot({ ...screenshotOptions, fullPage });
+      }
+      if (warnings) {
+        warnings.push(...pageProblems);
+      }
+      if (resourceTracker) {
+        context.resources = resourceTracker.report();
+      }
+
+      observeStage(context, 'capture', captureStartedAt);
+      const output = options.stripMetadata ? stripMetadata(screenshot, format) : screenshot;
+      return `data:image/${format};base64,${output.toString('base64')}`;
+    } catch (error) {
+      console.error('Error capturing URL:', error);
+      let apiError;
+      if (error instanceof ApiError && (error.statusCode < 500 || error.statusCode === 503)) {
+        apiError = error;
+      } else if (error.name === 'TimeoutError') {
+        apiError = new ApiError(504, `Timed out rendering ${url}`).annotate({ code: 'render_timeout' }).causedBy(error);
+      } else {
+        apiError = new ApiError(502, `Failed to render ${url}`).annotate({ code: 'render_failed' }).causedBy(error);
+      }
+      throw withResourceReport(apiError.annotate({ stage }), resourceTracker);
+    } finally {
+      if (page) {
+        await this.closePage(page);
+      }
     }
   }
 
+  /**
+   * Readiness for load balancers: not ready while starting, recycling or
+   * draining. An absent browser is launched so readiness can recover without traffic.
+   * @returns {Object} { ready, state, queued, queueLimit, openPages }
+   */
+  getReadiness() {
+    let state = 'ready';
+    if (this.draining) {
+      state = 'draining';
+    } else if (getRenderer() === 'mock') {
+      state = 'ready';
+    } else if (this.recyclePromise || this.recycleReason) {
+      state = 'recycling';
+    } else if (this.launchPromise || !this.browser || !this.browser.isConnected()) {
+      state = 'starting';
+      this.initializeBrowser().catch(error => logger.error('Failed to launch browser', { error }));
+    }
+
+    return {
+      ready: state === 'ready',
+      state,
+      queued: this.waiting,
+      queueLimit: getBrowserLimits().queueLimit,
+      openPages: this.pages.size
+    };
+  }
+
+  /**
+   * Chrome version, waiting for a launch in progress
+   * @returns {Promise<string|null>} e.g. "HeadlessChrome/124.0.6367.91", or null without a browser
+   */
+  async getBrowserVersion() {
+    if (this.launchPromise) {
+      await this.launchPromise.catch(() => {});
+    }
+    return this.browser && this.browser.isConnected() ? this.browser.version() : null;
+  }
+
+  /**
+   * Detailed browser state for operators
+   * @returns {Object} Readiness plus render count and retired browsers
+   */
+  getBrowserStatus() {
+    const chromeProcess = this.browser && this.browser.process();
+    return {
+      ...this.getReadiness(),
+      pid: chromeProcess ? chromeProcess.pid : null,
+      renders: this.renderCount,
+      maxRenders: getBrowserLimits().maxRenders,
+      retiredBrowsers: this.retiredBrowsers.size,
+      pendingRecycle: this.recycleReason
+    };
+  }
+
+  /**
+   * Stop accepting renders, wait for in-flight ones and close the browser
+   * @param {number} timeoutMs - Maximum time to wait for in-flight renders
+   * @returns {Promise<void>}
+   */
+  async drain(timeoutMs = 30000) {
+    this.draining = true;
+    const deadline = Date.now() + timeoutMs;
+    while ((this.pages.size > 0 || this.waiting > 0) && Date.now() < deadline) {
+      await new Promise(resolve => setTimeout(resolve, 100));
+    }
+    if (this.pages.size > 0) {
+      logger.warn('Shutdown grace period expired with renders in flight', { openPages: this.pages.size });
+    }
+    await this.closeBrowser();
+  }
+
   /**
    * Closes the Puppeteer browser instance.
    * This should be called on application shutdown.
    */
   async closeBrowser() {
+    if (this.healthTimer) {
+      clearInterval(this.healthTimer);
+      this.healthTimer = null;
+    }
+    await Promise.all([...this.retiredBrowsers].map(browser => browser.close().catch(() => {})));
+    this.retiredBrowsers.clear();
+
     if (this.browser && this.browser.isConnected()) {
       console.log('Closing browser...');
-      await this.browser.close();
+      // Detach first so the disconnect is not reported as a crash
+      const browser = this.browser;
       this.browser = null;
+      await browser.close();
       console.log('Browser closed.');
     } else {
       console.log('Browser not open or already closed.');
@@ -250,35 +1685,4 @@ class ScreenshotService {
 
 const screenshotServiceInstance = new ScreenshotService();
 
-// To ensure the browser is closed gracefully on application shutdown,
-// you would typically call screenshotServiceInstance.closeBrowser() in your main server file (e.g., server.js or app.js)
-// Example for server.js:
-//
-// const screenshotService = require('./services/screenshot.service'); // Adjust path as needed
-//
-// process.on('SIGINT', async () => {
-//   console.log('SIGINT signal received. Closing browser...');
-//   await screenshotService.closeBrowser();
-//   process.exit(0);
-// });
-//
-// process.on('SIGTERM', async () => {
-//   console.log('SIGTERM signal received. Closing browser...');
-//   await screenshotService.closeBrowser();
-//   process.exit(0);
-// });
-//
-// // Handle unhandled rejections and uncaught exceptions to also close browser
-// process.on('unhandledRejection', async (reason, promise) => {
-//   console.error('Unhandled Rejection at:', promise, 'reason:', reason);
-//   await screenshotService.closeBrowser();
-//   process.exit(1);
-// });
-//
-// process.on('uncaughtException', async (error) => {
-//   console.error('Uncaught Exception:', error);
-//   await screenshotService.closeBrowser();
-//   process.exit(1);
-// });
-
 module.exports = screenshotServiceInstance;
@@ -0,0 +1,402 @@
+const path = require('path');
+const crypto = require('crypto');
+const fs = require('fs/promises');
+const { ApiError } = require('../middleware/error.middleware');
+const { storageMetrics, secondsSince } = require('../utils/metrics');
+const { logger } = require('../utils/logger');
+const { parseJsonEnv } = require('../utils/env');
+
+// Content types of the outputs the service can store, by file extension
+const CONTENT_TYPES = {
+  png: 'image/png',
+  jpeg: 'image/jpeg',
+  webp: 'image/webp',
+  pdf: 'application/pdf',
+  gif: 'image/gif',
+  mp4: 'video/mp4',
+  zip: 'application/zip'
+};
+
+const HASH_PATTERN = /^[a-f0-9]{64}$/;
+// Tenant of outputs stored without an API key
+const ANONYMOUS_TENANT = 'anonymous';
+// Temporary files older than this were left behind by a crashed write
+const STALE_TEMP_MS = 60 * 60 * 1000;
+
+/**
+ * Retention policy. Outputs not stored again for RETENTION_MAX_AGE_HOURS are
+ * deleted; each tenant keeps at most RETENTION_TENANT_MAX_BYTES (overridden per
+ * API key by RETENTION_TENANT_QUOTAS, a JSON object of API key -> bytes) and the
+ * store as a whole at most RETENTION_MAX_BYTES, oldest outputs going first.
+ * 0 disables a limit.
+ * @returns {Object} Policy
+ */
+function getRetentionPolicy() {
+  return {
+    maxAgeMs: (parseFloat(process.env.RETENTION_MAX_AGE_HOURS) || 0) * 60 * 60 * 1000,
+    maxBytes: parseInt(process.env.RETENTION_MAX_BYTES, 10) || 0,
+    tenantMaxBytes: parseInt(process.env.RETENTION_TENANT_MAX_BYTES, 10) || 0,
+    tenantQuotas: parseJsonEnv('RETENTION_TENANT_QUOTAS'),
+    gcIntervalMs: parseInt(process.env.RETENTION_GC_INTERVAL_MS, 10) || 10 * 60 * 1000
+  };
+}
+
+/**
+ * Identifier of a tenant as written to disk. API keys are hashed so they never
+ * appear in file names.
+ * @param {string} [apiKey] - Caller's API key
+ * @returns {string} Tenant ID
+ */
+function getTenantId(apiKey) {
+  return apiKey
+    ? crypto.createHash('sha256').update(apiKey).digest('hex').slice(0, 16)
+    : ANONYMOUS_TENANT;
+}
+
+/**
+ * Delete a file, ignoring files that are already gone
+ * @param {string} filePath - File to delete
+ */
+async function removeFile(filePath) {
+  await fs.unlink(filePath).catch(error => {
+    if (error.code !== 'ENOENT') {
+      throw error;
+    }
+  });
+}
+
+/**
+ * Content-addressable store for rendered outputs. Artifacts are named by the
+ * SHA-256 of their bytes (<OUTPUT_DIR>/<first two hex chars>/<sha256>.<ext>),
+ * so identical renders are stored once and the name doubles as an integrity
+ * check. Persistence is enabled by setting OUTPUT_DIR.
+ *
+ * Each tenant that stored an artifact gets an empty <sha256>.<tenant>.ref
+ * file next to it; its modification time is when the tenant last stored the
+ * artifact. Garbage collection uses the refs for per-tenant quotas and
+ * deletes an artifact once no tenant references it.
+ */
+class StorageService {
+  constructor() {
+    this.gcTimer = null;
+    this.gcRunning = null;
+    this.lastGc = null;
+  }
+
+  /**
+   * Directory artifacts are stored in, or null when persistence is disabled
+   * @returns {string|null} Output directory
+   */
+  getOutputDir() {
+    return process.env.OUTPUT_DIR ? path.resolve(process.env.OUTPUT_DIR) : null;
+  }
+
+  /**
+   * Whether rendered outputs are persisted
+   * @returns {boolean} True when OUTPUT_DIR is set
+   */
+  isEnabled() {
+    return Boolean(this.getOutputDir());
+  }
+
+  /**
+   * Path of an artifact in the store
+   * @param {string} sha256 - Hex content hash
+   * @param {string} extension - File extension
+   * @returns {string} Absolute path
+   */
+  getArtifactPath(sha256, extension) {
+    return path.join(this.getOutputDir(), sha256.slice(0, 2), `${sha256}.${extension}`);
+  }
+
+  /**
+   * Store an artifact under its content hash. Storing bytes that are already
+   * present only refreshes their age.
+   * @param {Buffer} buffer - Artifact bytes
+   * @param {string} extension - File extension (png, jpeg, webp, pdf, gif, mp4, zip)
+   * @param {string} [apiKey] - API key of the tenant storing the artifact
+   * @returns {Promise<Object>} { sha256, bytes, deduplicated }
+   */
+  async store(buffer, extension, apiKey) {
+    const sha256 = crypto.createHash('sha256').update(buffer).digest('hex');
+    const filePath = this.getArtifactPath(sha256, extension);
+    const refPath = path.join(path.dirname(filePath), `${sha256}.${getTenantId(apiKey)}.ref`);
+    const now = new Date();
+
+    try {
+      let deduplicated = true;
+      try {
+        await fs.utimes(filePath, now, now);
+      } catch (error) {
+        deduplicated = false;
+        await fs.mkdir(path.dirname(filePath), { recursive: true });
+        // Write under a temporary name and rename, so concurrent readers never
+        // see a partially written artifact
+        const tempPath = `${filePath}.${process.pid}.${crypto.randomBytes(4).toString('hex')}.tmp`;
+        await fs.writeFile(tempPath, buffer);
+        await fs.rename(tempPath, filePath);
+      }
+      await fs.writeFile(refPath, '');
+
+      storageMetrics.writes.inc({ result: deduplicated ? 'deduplicated' : 'stored' });
+      return { sha256, bytes: buffer.length, deduplicated };
+    } catch (error) {
+      throw new ApiError(500, 'Failed to store rendered output')
+        .annotate({ stage: 'deliver', code: 'storage_failed', retryable: true })
+        .causedBy(error);
+    }
+  }
+
+  /**
+   * Look up an artifact by its content hash
+   * @param {string} sha256 - Hex content hash
+   * @returns {Promise<Object|null>} { sha256, path, extension, contentType, bytes } or null when not stored
+   */
+  async findByHash(sha256) {
+    const hash = String(sha256).toLowerCase();
+    if (!HASH_PATTERN.test(hash)) {
+      throw new ApiError(400, 'sha256 must be 64 hexadecimal characters').annotate({ stage: 'validate', code: 'invalid_hash' });
+    }
+
+    let entries;
+    try {
+      entries = await fs.readdir(path.join(this.getOutputDir(), hash.slice(0, 2)));
+    } catch (error) {
+      return null;
+    }
+
+    const fileName = entries.find(entry => entry.startsWith(`${hash}.`) && !/\.(tmp|ref)$/.test(entry));
+    if (!fileName) {
+      return null;
+    }
+    const extension = path.extname(fileName).slice(1);
+    const filePath = this.getArtifactPath(hash, extension);
+    const { size } = await fs.stat(filePath);
+    return {
+      sha256: hash,
+      path: filePath,
+      extension,
+      contentType: CONTENT_TYPES[extension] || 'application/octet-stream',
+      bytes: size
+    };
+  }
+
+  /**
+   * Read the bytes of an artifact found by findByHash
+   * @param {Object} artifact - Artifact
+   * @returns {Promise<Buffer>} Artifact bytes
+   */
+  async read(artifact) {
+    return fs.readFile(artifact.path);
+  }
+
+  /**
+   * Scan the store
+   * @returns {Promise<Object>} { artifacts: Map sha256 -> { path, bytes, mtimeMs, refs: Map tenant -> { path, mtimeMs } }, tempFiles }
+   */
+  async scan() {
+    const outputDir = this.getOutputDir();
+    const artifacts = new Map();
+    const refs = [];
+    const tempFiles = [];
+    const getArtifact = sha256 => {
+      if (!artifacts.has(sha256)) {
+        artifacts.set(sha256, { sha256, path: null, bytes: 0, mtimeMs: 0, refs: new Map() });
+      }
+      return artifacts.get(sha256);
+    };
+
+    const shards = await fs.readdir(outputDir, { withFileTypes: true }).catch(() => []);
+    for (const shard of shards.filter(entry => entry.isDirectory() && /^[a-f0-9]{2}$/.test(entry.name))) {
+      const shardDir = path.join(outputDir, shard.name);
+      for (const name of await fs.readdir(shardDir).catch(() => [])) {
+        const filePath = path.join(shardDir, name);
+        const stats = await fs.stat(filePath).catch(() => null);
+        const [sha256, qualifier] = name.split('.');
+        if (!stats || !HASH_PATTERN.test(sha256)) {
+          continue;
+        }
+        if (name.endsWith('.tmp')) {
+          tempFiles.push({ path: filePath, mtimeMs: stats.mtimeMs });
+        } else if (name.endsWith('.ref')) {
+          refs.push({ sha256, tenant: qualifier, path: filePath, mtimeMs: stats.mtimeMs });
+        } else {
+          Object.assign(getArtifact(sha256), { path: filePath, bytes: stats.size, mtimeMs: stats.mtimeMs });
+        }
+      }
+    }
+    refs.forEach(ref => getArtifact(ref.sha256).refs.set(ref.tenant, ref));
+    return { artifacts, tempFiles };
+  }
+
+  /**
+   * Apply the retention policy: delete expired outputs, then the oldest
+   * outputs of tenants over quota, then the oldest outputs until the store
+   * fits RETENTION_MAX_BYTES. Outputs are deleted once no tenant references
+   * them; refs left without an output are removed.
+   * @returns {Promise<Object>} Report { files, bytes, deleted: { age, tenant_quota, total_bytes }, freed_bytes, duration_ms }
+   */
+  async collectGarbage() {
+    if (!this.isEnabled()) {
+      return null;
+    }
+    // Never run two collections at once
+    if (!this.gcRunning) {
+      this.gcRunning = this.runGarbageCollection().finally(() => {
+        this.gcRunning = null;
+      });
+    }
+    return this.gcRunning;
+  }
+
+  async runGarbageCollection() {
+    const start = process.hrtime.bigint();
+    const policy = getRetentionPolicy();
+    const now = Date.now();
+    const deleted = { age: 0, tenant_quota: 0, total_bytes: 0 };
+    let freedBytes = 0;
+
+    try {
+      const { artifacts, tempFiles } = await this.scan();
+      await Promise.all(tempFiles
+        .filter(file => now - file.mtimeMs > STALE_TEMP_MS)
+        .map(file => removeFile(file.path)));
+
+      const deleteArtifact = async (artifact, reason) => {
+        await Promise.all([...artifact.refs.values()].map(ref => removeFile(ref.path)));
+        await removeFile(artifact.path);
+        artifacts.delete(artifact.sha256);
+        deleted[reason] += 1;
+        freedBytes += artifact.bytes;
+        storageMetrics.gcDeletedFiles.inc({ reason });
+      };
+      const dropRef = async (artifact, tenant, reason) => {
+        await removeFile(artifact.refs.get(tenant).path);
+        artifact.refs.delete(tenant);
+        if (artifact.refs.size === 0) {
+          await deleteArtifact(artifact, reason);
+        }
+      };
+
+      // Refs whose output is gone (deleted by hand or by another instance)
+      for (const artifact of [...artifacts.values()].filter(entry => !entry.path)) {
+        await Promise.all([...artifact.refs.values()].map(ref => removeFile(ref.path)));
+        artifacts.delete(artifact.sha256);
+      }
+
+      if (policy.maxAgeMs > 0) {
+        const cutoff = now - policy.maxAgeMs;
+        for (const artifact of [...artifacts.values()]) {
+          if (artifact.mtimeMs < cutoff) {
+            await deleteArtifact(artifact, 'age');
+            continue;
+          }
+          for (const [tenant, ref] of [...artifact.refs]) {
+            if (ref.mtimeMs < cutoff) {
+              await dropRef(artifact, tenant, 'age');
+            }
+          }
+        }
+      }
+
+      const quotas = new Map(Object.entries(policy.tenantQuotas).map(([apiKey, bytes]) => [
+        apiKey === ANONYMOUS_TENANT ? ANONYMOUS_TENANT : getTenantId(apiKey),
+        parseInt(bytes, 10) || 0
+      ]));
+      if (policy.tenantMaxBytes > 0 || quotas.size > 0) {
+        const tenants = new Map();
+        artifacts.forEach(artifact => artifact.refs.forEach((ref, tenant) => {
+          if (!tenants.has(tenant)) {
+            tenants.set(tenant, []);
+          }
+          tenants.get(tenant).push({ artifact, mtimeMs: ref.mtimeMs });
+        }));
+
+        for (const [tenant, entries] of tenants) {
+          const quota = quotas.has(tenant) ? quotas.get(tenant) : policy.tenantMaxBytes;
+          let usage = entries.reduce((sum, entry) => sum + entry.artifact.bytes, 0);
+          entries.sort((a, b) => a.mtimeMs - b.mtimeMs);
+          for (const { artifact } of entries) {
+            if (quota <= 0 || usage <= quota) {
+              break;
+            }
+            usage -= artifact.bytes;
+            await dropRef(artifact, tenant, 'tenant_quota');
+          }
+        }
+      }
+
+      if (policy.maxBytes > 0) {
+        let total = [...artifacts.values()].reduce((sum, artifact) => sum + artifact.bytes, 0);
+        const oldestFirst = [...artifacts.values()].sort((a, b) => a.mtimeMs - b.mtimeMs);
+        for (const artifact of oldestFirst) {
+          if (total <= policy.maxBytes) {
+            break;
+          }
+          total -= artifact.bytes;
+          await deleteArtifact(artifact, 'total_bytes');
+        }
+      }
+
+      const remaining = [...artifacts.values()];
+      const report = {
+        files: remaining.length,
+        bytes: remaining.reduce((sum, artifact) => sum + artifact.bytes, 0),
+        deleted,
+        freed_bytes: freedBytes,
+        duration_ms: Math.round(secondsSince(start) * 1000),
+        finished_at: new Date().toISOString()
+      };
+      storageMetrics.files.set({}, report.files);
+      storageMetrics.bytes.set({}, report.bytes);
+      storageMetrics.gcFreedBytes.inc({}, freedBytes);
+      storageMetrics.gcRuns.inc({ result: 'ok' });
+      this.lastGc = report;
+      return report;
+    } catch (error) {
+      storageMetrics.gcRuns.inc({ result: 'failed' });
+      throw error;
+    } finally {
+      storageMetrics.gcDuration.observe({}, secondsSince(start));
+    }
+  }
+
+  /**
+   * Start the periodic garbage collection (RETENTION_GC_INTERVAL_MS). Does
+   * nothing when persistence is disabled.
+   */
+  startGarbageCollector() {
+    if (this.gcTimer || !this.isEnabled()) {
+      return;
+    }
+    const run = () => this.collectGarbage()
+      .then(report => {
+        const deletedCount = Object.values(report.deleted).reduce((sum, count) => sum + count, 0);
+        if (deletedCount > 0) {
+          logger.info('Storage garbage collection deleted outputs', report);
+        }
+      })
+      .catch(error => logger.error('Storage garbage collection failed', { error }));
+    run();
+    this.gcTimer = setInterval(run, getRetentionPolicy().gcIntervalMs);
+    // Never keep the process alive just for the collector
+    this.gcTimer.unref();
+  }
+
+  /**
+   * Stop the periodic garbage collection
+   */
+  stopGarbageCollector() {
+    if (this.gcTimer) {
+      clearInterval(this.gcTimer);
+      this.gcTimer = null;
+    }
+  }
+}
+
+// Create a singleton instance
+const storageServiceInstance = new StorageService();
+
+module.exports = storageServiceInstance;
+module.exports.CONTENT_TYPES = CONTENT_TYPES;
+module.exports.getRetentionPolicy = getRetentionPolicy;
@@ -0,0 +1,416 @@
+const path = require('path');
+const fs = require('fs/promises');
+const { ApiError } = require('../middleware/error.middleware');
+const { lintTemplate, describeTemplate } = require('../utils/template-engine');
+
+// Name of the template used when a request doesn't select one
+const DEFAULT_TEMPLATE = 'whatsapp-chat';
+// Template for the title row of side-by-side compositions
+const COMPOSITION_TEMPLATE = 'composition';
+// Phone mockup around framed captures (options.deviceFrame)
+const DEVICE_FRAME_TEMPLATE = 'device-frame';
+// Uploaded templates each API key may keep (TEMPLATE_UPLOAD_LIMIT)
+const DEFAULT_UPLOAD_LIMIT = 20;
+// Templates of the views other than the chat (options.view)
+const VIEW_TEMPLATES = {
+  notification: 'notification',
+  'contact-info': 'contact-info',
+  'chat-list': 'chat-list'
+};
+// Built-in templates that render parts of other outputs, not chats; they are
+// not listed or selectable by requests
+const INTERNAL_TEMPLATES = new Set([COMPOSITION_TEMPLATE, DEVICE_FRAME_TEMPLATE, ...Object.values(VIEW_TEMPLATES)]);
+
+// Data available to templates and the request fields that feed each key
+const CHAT_DATA_FIELDS = {
+  recipientName: {
+    description: 'Upper-cased initial of the recipient name',
+    requestFields: ['messages[0].recipient_name', 'options.anonymize']
+  },
+  chatName: {
+    description: 'Full recipient name (anonymized when requested)',
+    requestFields: ['messages[0].recipient_name', 'options.anonymize']
+  },
+  headerLineText: {
+    description: 'Recipient name or formatted phone shown in the header',
+    requestFields: ['options.headerDisplay', 'messages[0].recipient_name', 'messages[0].recipient_phone', 'options.anonymize']
+  },
+  lastSeen: {
+    description: 'Render time, in the locale\'s clock format',
+    requestFields: ['options.locale']
+  },
+  headerStatus: {
+    description: 'Header status line: localized last seen, online or typing, or the custom options.headerStatus',
+    requestFields: ['options.presence', 'options.headerStatus', 'options.locale']
+  },
+  typingIndicator: {
+    description: 'Typing bubble appended to messages, empty without options.typingIndicator',
+    requestFields: ['options.typingIndicator']
+  },
+  messages: {
+    description: 'Rendered message bubbles',
+    requestFields: [
+      'messages[].timestamp',
+      'messages[].sender',
+      'messages[].content',
+      'messages[].blurred',
+      'messages[].redacted',
+      'options.contentFormat',
+      'options.contentFilter',
+      'options.spoilers',
+      'options.autoLink',
+      'options.direction',
+      'options.autoDirection',
+      'messages[].direction',
+      'options.anonymize',
+      'options.window',
+      'options.searchTerm'
+    ]
+  },
+  totalMessageCount: {
+    description: 'Number of messages in the conversation before options.window was applied',
+    requestFields: ['messages', 'options.window']
+  },
+  searchMatchCount: {
+    description: 'Number of options.searchTerm matches highlighted in the messages',
+    requestFields: ['options.searchTerm']
+  },
+  width: {
+    description: 'Output width in pixels',
+    requestFields: ['options.width']
+  },
+  direction: {
+    description: 'Chat-level text direction ("ltr" or "rtl"), detected from the messages with options.direction "auto"',
+    requestFields: ['options.direction', 'messages[].content', 'messages[].direction']
+  },
+  headerDirection: {
+    description: 'Direction of the header name, from its script',
+    requestFields: ['options.headerDisplay', 'messages[0].recipient_name', 'options.direction']
+  },
+  locale: {
+    description: 'BCP 47 locale for the formatNumber and formatCurrency helpers (id-ID when the request sets none)',
+    requestFields: ['options.locale']
+  },
+  timeFormat: {
+    description: '"12h" or "24h" clock of the times, empty for the locale\'s own; pass it to formatTimestamp',
+    requestFields: ['options.timeFormat']
+  },
+  uiLocale: {
+    description: 'Locale of the system strings and times, empty when the request sets none',
+    requestFields: ['options.locale']
+  },
+  profilePicClass: {
+    description: 'CSS class of the header avatar',
+    requestFields: ['options.anonymize', 'options.avatarUrl']
+  },
+  profilePhoto: {
+    description: 'Header contact photo <img> (empty without options.avatarUrl, the megaphone icon in communities)',
+    requestFields: ['options.avatarUrl', 'options.chatType']
+  },
+  bubbleStyle: {
+    description: 'CSS rules for bubble tails, corner radii and grouped-message spacing (empty for the defaults)',
+    requestFields: ['options.bubbles', 'options.platform']
+  },
+  platformClass: {
+    description: 'Body class of the platform look, "platform-ios" or "platform-android" (empty without a platform)',
+    requestFields: ['options.platform']
+  },
+  platformStyle: {
+    description: 'CSS rules for the platform header, fonts, ticks and input bar (empty without a platform, keyboard or composer state)',
+    requestFields: ['options.platform', 'options.keyboard', 'options.composer']
+  },
+  inputBar: {
+    description: 'Message input bar with the draft, reply strip and attachment tray (empty without a platform, keyboard or composer state); the admin-only note in communities',
+    requestFields: ['options.platform', 'options.keyboard', 'options.composer', 'options.chatType', 'messages[].id']
+  },
+  chatType: {
+    description: 'Chat type, "standard" or "community"',
+    requestFields: ['options.chatType']
+  },
+  theme: {
+    description: 'Color theme, "light" or "dark"',
+    requestFields: ['options.theme']
+  },
+  themeStyle: {
+    description: 'CSS rules for the dark palette (empty for the light theme)',
+    requestFields: ['options.theme']
+  },
+  wallpaper: {
+    description: 'Wallpaper: "default" (the template\'s own background), a stock name, "color" or "image"',
+    requestFields: ['options.wallpaper']
+  },
+  wallpaperStyle: {
+    description: 'CSS rules for the wallpaper behind .chat-messages (empty for the default)',
+    requestFields: ['options.wallpaper']
+  },
+  keyboardStyle: {
+    description: 'CSS rules for the keyboard (empty without options.keyboard)',
+    requestFields: ['options.keyboard', 'options.platform', 'options.theme']
+  },
+  keyboard: {
+    description: 'Phone keyboard shown below the input bar (empty without options.keyboard)',
+    requestFields: ['options.keyboard', 'options.platform', 'options.theme']
+  }
+};
+
+class TemplateService {
+  constructor() {
+    // Built-in templates ship with the code, so they load whatever the working directory is
+    this.templatesDir = path.join(__dirname, '../templates');
+    // Built-in templates: name -> { name, source, sandboxed, builtIn, createdAt }
+    this.templates = new Map();
+    // Resolves once every chat template file is registered
+    this.registryPromise = null;
+    // Uploaded templates, scoped to the uploading API key: apiKey -> name -> entry
+    this.uploads = new Map();
+    this.compositionSource = null;
+    this.deviceFrameSource = null;
+    // view -> template source
+    this.viewSources = new Map();
+  }
+
+  /**
+   * Read a built-in template file. A file with the same name in TEMPLATE_DIR
+   * replaces the shipped one; overrides are trusted like the shipped templates.
+   * @param {string} name - Template name (file name without .html)
+   * @returns {Promise<string>} Template source
+   * @private
+   */
+  async readBuiltInSource(name) {
+    const overrideDir = process.env.TEMPLATE_DIR;
+    if (overrideDir) {
+      try {
+        return await fs.readFile(path.resolve(overrideDir, `${name}.html`), 'utf-8');
+      } catch (error) {
+        if (error.code !== 'ENOENT') {
+          throw error;
+        }
+      }
+    }
+    return fs.readFile(path.join(this.templatesDir, `${name}.html`), 'utf-8');
+  }
+
+  /**
+   * Load a built-in template from the templates directory
+   * @param {string} name - Template name (file name without .html)
+   * @returns {Promise<Object>} Template entry
+   */
+  async loadBuiltInTemplate(name) {
+    const source = await this.readBuiltInSource(name);
+    const entry = { name, source, sandboxed: false, builtIn: true, createdAt: new Date().toISOString() };
+    this.templates.set(name, entry);
+    return entry;
+  }
+
+  /**
+   * Names of the templates (*.html) in a directory
+   * @param {string} dir - Directory
+   * @returns {Promise<Array<string>>} File names without .html; none when the directory doesn't exist
+   * @private
+   */
+  async readTemplateNames(dir) {
+    try {
+      const files = await fs.readdir(dir);
+      return files.filter(file => file.endsWith('.html')).map(file => path.basename(file, '.html'));
+    } catch (error) {
+      if (error.code === 'ENOENT') {
+        return [];
+      }
+      throw error;
+    }
+  }
+
+  /**
+   * Register every chat template by file name: the shipped ones and those in
+   * TEMPLATE_DIR, which replace shipped templates of the same name. Runs once;
+   * a failure is retried on the next call.
+   * @returns {Promise<void>}
+   */
+  loadRegistry() {
+    if (!this.registryPromise) {
+      this.registryPromise = (async () => {
+        const names = new Set(await this.readTemplateNames(this.templatesDir));
+        if (process.env.TEMPLATE_DIR) {
+          (await this.readTemplateNames(path.resolve(process.env.TEMPLATE_DIR))).forEach(name => names.add(name));
+        }
+        // Loaded in order, so listings are sorted by name
+        for (const name of [...names].filter(name => !INTERNAL_TEMPLATES.has(name)).sort()) {
+          await this.loadBuiltInTemplate(name);
+        }
+      })().catch(error => {
+        this.registryPromise = null;
+        throw error;
+      });
+    }
+    return this.registryPromise;
+  }
+
+  /**
+   * Get the composition template used for side-by-side panel titles. It is
+   * not a chat template, so it is not listed or selectable by requests.
+   * @returns {Promise<string>} Template source
+   */
+  async getCompositionTemplate() {
+    if (!this.compositionSource) {
+      try {
+        this.compositionSource = await this.readBuiltInSource(COMPOSITION_TEMPLATE);
+      } catch (error) {
+        throw new ApiError(500, 'Failed to load composition template').annotate({ stage: 'template' }).causedBy(error);
+      }
+    }
+    return this.compositionSource;
+  }
+
+  /**
+   * Get the device frame template drawn around framed captures. Like the
+   * composition template, it is not listed or selectable by requests.
+   * @returns {Promise<string>} Template source
+   */
+  async getDeviceFrameTemplate() {
+    if (!this.deviceFrameSource) {
+      try {
+        this.deviceFrameSource = await this.readBuiltInSource(DEVICE_FRAME_TEMPLATE);
+      } catch (error) {
+        throw new ApiError(500, 'Failed to load device frame template').annotate({ stage: 'template' }).causedBy(error);
+      }
+    }
+    return this.deviceFrameSource;
+  }
+
+  /**
+   * Get the template of a view other than the chat. Like the composition
+   * template, view templates are not listed or selectable by requests.
+   * @param {string} view - View name (options.view)
+   * @returns {Promise<string>} Template source
+   */
+  async getViewTemplate(view) {
+    if (!this.viewSources.has(view)) {
+      try {
+        this.viewSources.set(view, await this.readBuiltInSource(VIEW_TEMPLATES[view]));
+      } catch (error) {
+        throw new ApiError(500, `Failed to load ${view} template`).annotate({ stage: 'template' }).causedBy(error);
+      }
+    }
+    return this.viewSources.get(view);
+  }
+
+  /**
+   * Maximum number of uploaded templates per API key
+   * @returns {number} Limit
+   */
+  getUploadLimit() {
+    const limit = parseInt(process.env.TEMPLATE_UPLOAD_LIMIT, 10);
+    return Number.isNaN(limit) || limit < 0 ? DEFAULT_UPLOAD_LIMIT : limit;
+  }
+
+  /**
+   * Load the template registry, reporting a failure as a template error
+   * @returns {Promise<void>}
+   * @private
+   */
+  async ensureRegistry() {
+    try {
+      await this.loadRegistry();
+    } catch (error) {
+      console.error('Failed to load HTML templates:', error);
+      throw new ApiError(500, 'Failed to load chat templates').annotate({ stage: 'template' }).causedBy(error);
+    }
+  }
+
+  /**
+   * Get a template by name from the caller's uploads or the registered
+   * built-in templates. Uploaded templates are only visible to the API key
+   * that uploaded them.
+   * @param {string} name - Template name
+   * @param {string} [apiKey] - Caller's API key
+   * @returns {Promise<Object>} Template entry
+   */
+  async getTemplate(name = DEFAULT_TEMPLATE, apiKey) {
+    await this.ensureRegistry();
+    const uploads = apiKey && this.uploads.get(apiKey);
+    if (uploads && uploads.has(name)) {
+      return uploads.get(name);
+    }
+    if (this.templates.has(name)) {
+      return this.templates.get(name);
+    }
+
+    throw new ApiError(404, `Template not found: ${name}`).annotate({ stage: 'template', code: 'template_not_found' });
+  }
+
+  /**
+   * Register an uploaded template for an API key. Uploaded templates always
+   * run sandboxed and must pass the sandbox lint; re-uploading a name
+   * replaces the key's own template.
+   * @param {string} name - Template name
+   * @param {string} source - Template source
+   * @param {string} apiKey - Uploader's API key
+   * @returns {Promise<Object>} Template entry
+   */
+  async uploadTemplate(name, source, apiKey) {
+    await this.ensureRegistry();
+    if (this.templates.has(name)) {
+      throw new ApiError(409, `Template ${name} is built in and cannot be replaced`)
+        .annotate({ stage: 'validate', code: 'template_builtin' });
+    }
+
+    const problems = lintTemplate(source);
+    if (problems.length > 0) {
+      throw new ApiError(400, `Template rejected: ${problems.join(', ')}`)
+        .annotate({ stage: 'validate', code: 'template_rejected' });
+    }
+
+    const uploads = this.uploads.get(apiKey) || new Map();
+    const limit = this.getUploadLimit();
+    if (!uploads.has(name) && uploads.size >= limit) {
+      throw new ApiError(409, `Template limit reached: an API key can keep ${limit} uploaded templates`)
+        .annotate({ stage: 'validate', code: 'template_limit_reached' });
+    }
+
+    const entry = { name, source, sandboxed: true, builtIn: false, createdAt: new Date().toISOString() };
+    uploads.set(name, entry);
+    this.uploads.set(apiKey, uploads);
+    return entry;
+  }
+
+  /**
+   * Report which chat data fields and template functions a template uses
+   * @param {string} name - Template name
+   * @param {string} [apiKey] - Caller's API key
+   * @returns {Promise<Object>} Template schema
+   */
+  async getTemplateSchema(name, apiKey) {
+    const template = await this.getTemplate(name, apiKey);
+    const { fields, functions } = describeTemplate(template.source);
+    const rootKey = field => field.split('.')[0];
+
+    return {
+      name: template.name,
+      sandboxed: template.sandboxed,
+      fields: fields
+        .filter(field => CHAT_DATA_FIELDS[rootKey(field)])
+        .map(field => ({ name: field, ...CHAT_DATA_FIELDS[rootKey(field)] })),
+      unknownFields: fields.filter(field => !CHAT_DATA_FIELDS[rootKey(field)]),
+      functions,
+      requestFields: [...new Set(fields.flatMap(field =>
+        (CHAT_DATA_FIELDS[rootKey(field)] || { requestFields: [] }).requestFields))].sort()
+    };
+  }
+
+  /**
+   * List the built-in templates and the caller's uploads without their sources
+   * @param {string} [apiKey] - Caller's API key
+   * @returns {Promise<Array<Object>>} Template summaries
+   */
+  async listTemplates(apiKey) {
+    await this.ensureRegistry();
+    const uploads = (apiKey && this.uploads.get(apiKey)) || new Map();
+    return [...this.templates.values(), ...uploads.values()].map(({ source, ...summary }) => summary);
+  }
+}
+
+const templateServiceInstance = new TemplateService();
+
+module.exports = templateServiceInstance;
+module.exports.DEFAULT_TEMPLATE = DEFAULT_TEMPLATE;
+module.exports.CHAT_DATA_FIELDS = CHAT_DATA_FIELDS;
@@ -0,0 +1,55 @@
+const crypto = require('crypto');
+const { ApiError } = require('../middleware/error.middleware');
+const { CONTENT_TYPES } = require('../services/storage.service');
+
+/**
+ * In-memory output store with the storage service's interface, so tests can
+ * exercise persistence and /api/files/by-hash without OUTPUT_DIR
+ * @returns {Object} { isEnabled, store, findByHash, read, clear }
+ */
+function createMemoryStorage() {
+  // sha256 -> { buffer, extension, tenants }
+  const artifacts = new Map();
+
+  return {
+    isEnabled: () => true,
+
+    async store(buffer, extension, apiKey) {
+      const sha256 = crypto.createHash('sha256').update(buffer).digest('hex');
+      const existing = artifacts.get(sha256);
+      const tenants = existing ? existing.tenants : new Set();
+      tenants.add(apiKey || 'anonymous');
+      artifacts.set(sha256, { buffer, extension, tenants });
+      return { sha256, bytes: buffer.length, deduplicated: Boolean(existing) };
+    },
+
+    async findByHash(sha256) {
+      const hash = String(sha256).toLowerCase();
+      if (!/^[a-f0-9]{64}$/.test(hash)) {
+        throw new ApiError(400, 'sha256 must be 64 hexadecimal characters').annotate({ stage: 'validate', code: 'invalid_hash' });
+      }
+      const artifact = artifacts.get(hash);
+      return artifact
+        ? {
+          sha256: hash,
+          extension: artifact.extension,
+          contentType: CONTENT_TYPES[artifact.extension] || 'application/octet-stream',
+          bytes: artifact.buffer.length
+        }
+        : null;
+    },
+
+    async read({ sha256 }) {
+      return artifacts.get(sha256).buffer;
+    },
+
+    // Drop every stored artifact, e.g. between tests
+    clear() {
+      artifacts.clear();
+    }
+  };
+}
+
+module.exports = {
+  createMemoryStorage
+};
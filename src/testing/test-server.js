@@ -0,0 +1,70 @@
+// In-process instance of the API for integration tests of services that call
+// it: the full router with the mock renderer and in-memory stores, on a free
+// local port
+
+const API_KEY = 'test-api-key';
+const ADMIN_API_KEY = 'test-admin-key';
+
+/**
+ * Start a test server. The configuration is process-wide (process.env), so
+ * run one test server per process, and require this module before the app's
+ * own modules so Chrome is never started.
+ * @param {Object} [options] - Server options
+ * @param {Object} [options.env] - Extra environment variables, e.g. { PRIORITY_API_KEYS: '...' }
+ * @param {Object} [options.services] - Service overrides passed to createRouter
+ * @returns {Promise<Object>} { url, app, server, apiKey, adminKey, storage, close }
+ */
+async function createTestServer({ env = {}, services = {} } = {}) {
+  const settings = {
+    RENDERER: 'mock',
+    API_KEYS: API_KEY,
+    ADMIN_API_KEYS: ADMIN_API_KEY,
+    LOG_LEVEL: 'error',
+    ...env
+  };
+  const previous = Object.fromEntries(Object.keys(settings).map(name => [name, process.env[name]]));
+  Object.assign(process.env, settings);
+
+  // Loaded after the environment is set, so the renderer is the mock
+  const { createApp } = require('../app');
+  const { createMemoryStorage } = require('./memory-storage');
+
+  const storage = services.storageService || createMemoryStorage();
+  const app = createApp({ ...services, storageService: storage });
+  const server = await new Promise((resolve, reject) => {
+    const listening = app.listen(0, '127.0.0.1', () => resolve(listening)).on('error', reject);
+  });
+
+  return {
+    url: `http://127.0.0.1:${server.address().port}`,
+    app,
+    server,
+    apiKey: settings.API_KEYS.split(',')[0].trim(),
+    adminKey: settings.ADMIN_API_KEYS.split(',')[0].trim(),
+    storage,
+    /**
+     * Stop the server and restore the environment
+     * @returns {Promise<void>}
+     */
+    close: () => new Promise(resolve => {
+      server.close(() => {
+        Object.entries(previous).forEach(([name, value]) => {
+          if (value === undefined) {
+            delete process.env[name];
+          } else {
+            process.env[name] = value;
+          }
+        });
+        resolve();
+      });
+      // Keep-alive connections of the test's HTTP client would hold it open
+      server.closeAllConnections();
+    })
+  };
+}
+
+module.exports = {
+  createTestServer,
+  API_KEY,
+  ADMIN_API_KEY
+};
@@ -0,0 +1,105 @@
+// Encoding of captured PNG frames into animated output. GIF is encoded in
+// process; MP4 needs an ffmpeg binary (FFMPEG_PATH, default "ffmpeg");
+// "frames" packages the PNG frames themselves.
+
+const { spawn } = require('child_process');
+const { ApiError } = require('../middleware/error.middleware');
+const { decodePng } = require('./png-decoder');
+const { encodeGif } = require('./gif-encoder');
+const { buildPackage } = require('./packaging');
+const { stripPng } = require('./image-metadata');
+
+const ANIMATION_FORMATS = {
+  gif: { mimeType: 'image/gif', extension: 'gif' },
+  mp4: { mimeType: 'video/mp4', extension: 'mp4' },
+  frames: { mimeType: 'application/zip', extension: 'zip' }
+};
+
+/**
+ * Encode PNG frames into an MP4 (H.264) with ffmpeg
+ * @param {Array<Buffer>} frames - PNG frames
+ * @param {number} fps - Frames per second
+ * @param {boolean} stripMetadata - Write no encoder tags or container metadata
+ * @returns {Promise<Buffer>} MP4 file
+ */
+function encodeMp4(frames, fps, stripMetadata = false) {
+  const ffmpegPath = process.env.FFMPEG_PATH || 'ffmpeg';
+  return new Promise((resolve, reject) => {
+    const ffmpeg = spawn(ffmpegPath, [
+      '-loglevel', 'error',
+      '-f', 'image2pipe',
+      '-framerate', String(fps),
+      '-i', 'pipe:0',
+      // H.264 with yuv420p needs even dimensions
+      '-vf', 'pad=ceil(iw/2)*2:ceil(ih/2)*2',
+      '-c:v', 'libx264',
+      '-pix_fmt', 'yuv420p',
+      '-movflags', 'frag_keyframe+empty_moov',
+      ...(stripMetadata ? ['-map_metadata', '-1', '-fflags', '+bitexact', '-flags:v', '+bitexact'] : []),
+      '-f', 'mp4',
+      'pipe:1'
+    ]);
+
+    const output = [];
+    let stderr = '';
+    ffmpeg.stdout.on('data', chunk => output.push(chunk));
+    ffmpeg.stderr.on('data', chunk => {
+      stderr += chunk;
+    });
+    ffmpeg.on('error', error => {
+      const apiError = error.code === 'ENOENT'
+        ? new ApiError(501, `MP4 output requires ffmpeg; "${ffmpegPath}" was not found (set FFMPEG_PATH)`)
+          .annotate({ stage: 'encode', code: 'mp4_unavailable', retryable: false })
+        : new ApiError(500, 'Failed to start ffmpeg').annotate({ stage: 'encode' });
+      reject(apiError.causedBy(error));
+    });
+    ffmpeg.on('close', code => {
+      if (code === 0) {
+        resolve(Buffer.concat(output));
+      } else {
+        reject(new ApiError(500, 'ffmpeg failed to encode the animation')
+          .annotate({ stage: 'encode', code: 'animation_encode_failed' })
+          .causedBy(new Error(stderr.trim() || `ffmpeg exited with code ${code}`)));
+      }
+    });
+
+    // A closed stdin (ffmpeg exited early) is reported through 'close'
+    ffmpeg.stdin.on('error', () => {});
+    frames.forEach(frame => ffmpeg.stdin.write(frame));
+    ffmpeg.stdin.end();
+  });
+}
+
+/**
+ * Encode captured PNG frames into an animation
+ * @param {Array<Object>} frames - { png: Buffer, delayMs }
+ * @param {Object} options - Encoding options
+ * @param {string} options.format - "gif", "mp4" or "frames" (ZIP of PNG frames with a manifest)
+ * @param {number} options.fps - Frame rate for MP4 (GIF uses each frame's delay)
+ * @param {boolean} options.stripMetadata - Remove metadata from frames and MP4 output
+ *   (GIFs never carry any)
+ * @returns {Promise<Buffer>} Encoded animation
+ */
+async function encodeAnimation(frames, { format = 'gif', fps = 10, stripMetadata = false } = {}) {
+  if (format === 'frames') {
+    const digits = String(frames.length).length;
+    return buildPackage(frames.map((frame, index) => ({
+      name: `frame-${String(index + 1).padStart(digits, '0')}.png`,
+      data: stripMetadata ? stripPng(frame.png) : frame.png,
+      format: 'png',
+      meta: { delay_ms: Math.round(frame.delayMs) }
+    }))).buffer;
+  }
+  if (format === 'mp4') {
+    // MP4 has a constant frame rate: repeat frames that are held longer
+    const frameMs = 1000 / fps;
+    const expanded = frames.flatMap(frame => new Array(Math.max(1, Math.round((frame.delayMs || frameMs) / frameMs))).fill(frame.png));
+    return encodeMp4(expanded, fps, stripMetadata);
+  }
+  return encodeGif(frames.map(frame => ({ ...decodePng(frame.png), delayMs: frame.delayMs })));
+}
+
+module.exports = {
+  encodeAnimation,
+  ANIMATION_FORMATS
+};
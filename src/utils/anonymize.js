@@ -0,0 +1,230 @@
+const { parseJsonEnv } = require('./env');
+const { logger } = require('./logger');
+
+// Character used for masked digits/letters. An asterisk would be picked up
+// by the WhatsApp bold formatter, so a bullet is used instead.
+const MASK_CHAR = '•';
+
+// Default detection rules. Each rule is applied to the message content in order.
+const DEFAULT_RULES = {
+  // Emails: keep the first character of the local part and the domain. A
+  // match only starts where the local part does, so runs of address
+  // characters are scanned once instead of from every position.
+  emails: {
+    pattern: /(?<![A-Za-z0-9._%+-])([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})/g,
+    replace: (match, first, domain) => `${first}${MASK_CHAR.repeat(3)}@${domain}`
+  },
+  // Phone numbers: international or local numbers with 8+ digits
+  phones: {
+    pattern: /(?<![\w])(\+?\d[\d\s-]{6,}\d)(?![\w])/g,
+    replace: (match) => maskPhone(match)
+  }
+};
+
+// NER-lite patterns used to pick up names people introduce themselves with.
+// The first capture group is treated as a name.
+const NAME_INTRODUCTION_PATTERNS = [
+  /\b(?:[Mm]y name is|I am|I'm)\s+([A-Z][a-z]+(?:\s+[A-Z][a-z]+)?)/g,
+  /\b(?:[Nn]ama saya|[Ss]aya)\s+([A-Z][a-z]+(?:\s+[A-Z][a-z]+)?)/g
+];
+
+/**
+ * Mask a phone number, keeping the country prefix and the last 3 digits
+ * @param {string} phone - Phone number to mask
+ * @returns {string} Masked phone number
+ */
+function maskPhone(phone) {
+  if (!phone || typeof phone !== 'string') {
+    return phone;
+  }
+
+  const totalDigits = (phone.match(/\d/g) || []).length;
+  const keepStart = phone.startsWith('+') ? 2 : 1;
+  const keepEnd = 3;
+  let seen = 0;
+
+  return phone.replace(/\d/g, digit => {
+    seen += 1;
+    if (seen <= keepStart || seen > totalDigits - keepEnd) {
+      return digit;
+    }
+    return MASK_CHAR;
+  });
+}
+
+/**
+ * Escape a string for use inside a RegExp
+ * @param {string} value - Raw string
+ * @returns {string} Escaped string
+ */
+function escapeRegExp(value) {
+  return value.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+}
+
+let cachedRules;
+
+/**
+ * Custom rules applied after the built-in ones, configured by the operator
+ * through ANONYMIZE_RULES (JSON array of { pattern, flags, replacement }).
+ * Requests can't supply regular expressions, so a public caller can't
+ * submit a pattern that backtracks catastrophically. Rules always replace
+ * every match. Compiled once per value of the variable, so a config reload
+ * takes effect; an invalid value or entry is warned about and ignored.
+ * @returns {Array} Compiled rules
+ */
+function getCustomRules() {
+  if (cachedRules !== undefined && cachedRules.raw === process.env.ANONYMIZE_RULES) {
+    return cachedRules.rules;
+  }
+
+  const entries = parseJsonEnv('ANONYMIZE_RULES', []);
+  const rules = (Array.isArray(entries) ? entries : []).flatMap(rule => {
+    try {
+      return [{
+        pattern: new RegExp(rule.pattern, `${(rule.flags || '').replace(/g/g, '')}g`),
+        replacement: rule.replacement !== undefined ? rule.replacement : MASK_CHAR.repeat(3)
+      }];
+    } catch (error) {
+      logger.warn('Ignoring invalid ANONYMIZE_RULES entry', { reason: error.message });
+      return [];
+    }
+  });
+
+  cachedRules = { raw: process.env.ANONYMIZE_RULES, rules };
+  return rules;
+}
+
+/**
+ * Normalize the `anonymize` option into a full settings object
+ * @param {boolean|Object} option - Value of options.anonymize
+ * @returns {Object|null} Settings, or null when anonymization is disabled
+ */
+function resolveAnonymizeSettings(option) {
+  if (!option) {
+    return null;
+  }
+
+  const settings = option === true ? {} : option;
+  if (settings.enabled === false) {
+    return null;
+  }
+
+  return {
+    names: settings.names !== false,
+    phones: settings.phones !== false,
+    emails: settings.emails !== false,
+    avatar: settings.avatar !== false,
+    pseudonyms: settings.pseudonyms || {},
+    extraNames: settings.extraNames || []
+  };
+}
+
+/**
+ * Build a map of real name -> pseudonym from the messages
+ * @param {Array} messages - Array of message objects
+ * @param {Object} settings - Resolved anonymize settings
+ * @returns {Map} Name to pseudonym mapping
+ */
+function buildPseudonymMap(messages, settings) {
+  const names = new Map();
+  let counter = 0;
+
+  const addName = (name) => {
+    const trimmed = (name || '').trim();
+    if (!trimmed || names.has(trimmed)) {
+      return;
+    }
+    counter += 1;
+    names.set(trimmed, settings.pseudonyms[trimmed] || `Customer ${counter}`);
+  };
+
+  messages.forEach(msg => {
+    addName(msg.recipient_name);
+    addName(msg.author);
+    addName(msg.pushName);
+  });
+  settings.extraNames.forEach(addName);
+  Object.keys(settings.pseudonyms).forEach(addName);
+
+  messages.forEach(msg => {
+    NAME_INTRODUCTION_PATTERNS.forEach(pattern => {
+      for (const match of (msg.content || '').matchAll(pattern)) {
+        addName(match[1]);
+      }
+    });
+  });
+
+  return names;
+}
+
+/**
+ * Replace known names in a piece of text
+ * @param {string} text - Text to process
+ * @param {Map} names - Name to pseudonym mapping
+ * @returns {string} Text with names replaced
+ */
+function replaceNames(text, names) {
+  if (!text || names.size === 0) {
+    return text;
+  }
+
+  // Replace longer names first so "Mila Palastri" wins over "Mila". Names only
+  // match whole words ("Ana" leaves "Banana" alone); the lookarounds cover
+  // every script, where \b only knows ASCII word characters.
+  const sorted = [...names.keys()].sort((a, b) => b.length - a.length);
+  const pattern = new RegExp(`(?<![\\p{L}\\p{N}_])(?:${sorted.map(escapeRegExp).join('|')})(?![\\p{L}\\p{N}_])`, 'giu');
+
+  return text.replace(pattern, match => {
+    const key = sorted.find(name => name.toLowerCase() === match.toLowerCase());
+    return names.get(key) || match;
+  });
+}
+
+/**
+ * Anonymize a message list before rendering.
+ * Names are replaced with pseudonyms, phone numbers and emails are masked,
+ * and the ANONYMIZE_RULES are applied to the content.
+ * @param {Array} messages - Array of message objects
+ * @param {Object} settings - Resolved anonymize settings
+ * @returns {Array} New array of anonymized messages
+ */
+function anonymizeMessages(messages, settings) {
+  if (!settings) {
+    return messages;
+  }
+
+  const names = settings.names ? buildPseudonymMap(messages, settings) : new Map();
+  const customRules = getCustomRules();
+
+  return messages.map(msg => {
+    let content = msg.content;
+
+    if (settings.names) {
+      content = replaceNames(content, names);
+    }
+    if (settings.emails) {
+      content = content.replace(DEFAULT_RULES.emails.pattern, DEFAULT_RULES.emails.replace);
+    }
+    if (settings.phones) {
+      content = content.replace(DEFAULT_RULES.phones.pattern, DEFAULT_RULES.phones.replace);
+    }
+    customRules.forEach(rule => {
+      content = content.replace(rule.pattern, rule.replacement);
+    });
+
+    return {
+      ...msg,
+      content,
+      ...(msg.recipient_name && settings.names && { recipient_name: replaceNames(msg.recipient_name, names) }),
+      ...(msg.author && settings.names && { author: replaceNames(msg.author, names) }),
+      ...(msg.pushName && settings.names && { pushName: replaceNames(msg.pushName, names) }),
+      ...(msg.recipient_phone && settings.phones && { recipient_phone: maskPhone(msg.recipient_phone) })
+    };
+  });
+}
+
+module.exports = {
+  anonymizeMessages,
+  resolveAnonymizeSettings,
+  maskPhone
+};
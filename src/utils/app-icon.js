@@ -0,0 +1,45 @@
+// WhatsApp glyph: white phone in a speech bubble
+const GLYPH = '<path d="M12 3a9 9 0 0 0-7.8 13.5L3 21l4.6-1.2A9 9 0 1 0 12 3z" fill="none" stroke="white" stroke-width="1.6" stroke-linejoin="round"/><path d="M9.2 8.2c.3-.3.7-.3.9 0l.9 1.5c.1.3 0 .6-.2.8l-.5.5c.4 1 1.3 1.9 2.3 2.3l.5-.5c.2-.2.5-.3.8-.2l1.5.9c.3.2.3.6 0 .9l-.6.6c-.6.6-1.6.7-2.4.2a9 9 0 0 1-3.6-3.6c-.5-.8-.4-1.8.2-2.4z" fill="white"/>';
+
+/**
+ * Badge text: counts above 999 are shown as "999+"
+ * @param {number} count - Unread count
+ * @returns {string} Badge text
+ */
+const badgeText = (count) => (count > 999 ? '999+' : String(count));
+
+/**
+ * HTML page with the WhatsApp app icon and a red numeric badge, captured as
+ * a separate transparent asset. The icon is inset so the badge fits on the
+ * canvas.
+ * @param {Object} appIcon - options.appIcon { badge, size }
+ * @returns {string} HTML document
+ */
+function buildAppIconHTML({ badge, size = 180 }) {
+  const icon = Math.round(size * 0.84);
+  const badgeSize = Math.round(size * 0.34);
+  return `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="UTF-8">
+  <style>
+    * { margin: 0; padding: 0; box-sizing: border-box; }
+    body { width: ${size}px; height: ${size}px; background: transparent; position: relative; }
+    .icon { position: absolute; left: 0; bottom: 0; width: ${icon}px; height: ${icon}px; border-radius: 22.5%;
+      background: linear-gradient(180deg, #5ff777 0%, #25d366 100%); display: flex; align-items: center; justify-content: center; }
+    .icon svg { width: 68%; height: 68%; }
+    .badge { position: absolute; top: 0; right: 0; min-width: ${badgeSize}px; height: ${badgeSize}px; padding: 0 ${Math.round(badgeSize * 0.28)}px;
+      border-radius: ${badgeSize}px; background-color: #ff3b30; color: white; display: flex; align-items: center; justify-content: center;
+      font: 600 ${Math.round(badgeSize * 0.6)}px -apple-system, 'SF Pro Text', 'Helvetica Neue', Helvetica, Arial, sans-serif; }
+  </style>
+</head>
+<body>
+  <div class="icon"><svg viewBox="0 0 24 24">${GLYPH}</svg></div>
+  ${badge > 0 ? `<div class="badge">${badgeText(badge)}</div>` : ''}
+</body>
+</html>`;
+}
+
+module.exports = {
+  buildAppIconHTML
+};
@@ -0,0 +1,251 @@
+// Minimal ZIP and TAR writers for packaging rendered outputs. createZip and
+// createTar build the archive in memory; the stream writers write each entry
+// to a writable stream as soon as it is added, so large batches never hold
+// more than one entry at a time.
+
+const zlib = require('zlib');
+
+const CRC_TABLE = (() => {
+  const table = new Uint32Array(256);
+  for (let n = 0; n < 256; n += 1) {
+    let c = n;
+    for (let k = 0; k < 8; k += 1) {
+      c = c & 1 ? 0xedb88320 ^ (c >>> 1) : c >>> 1;
+    }
+    table[n] = c >>> 0;
+  }
+  return table;
+})();
+
+/**
+ * CRC-32 as used by ZIP
+ * @param {Buffer} buffer - Data
+ * @returns {number} Unsigned CRC
+ */
+function crc32(buffer) {
+  let crc = 0xffffffff;
+  for (let i = 0; i < buffer.length; i += 1) {
+    crc = CRC_TABLE[(crc ^ buffer[i]) & 0xff] ^ (crc >>> 8);
+  }
+  return (crc ^ 0xffffffff) >>> 0;
+}
+
+/**
+ * Convert a date to MS-DOS time/date fields
+ * @param {Date} date - Date
+ * @returns {Object} { time, date }
+ */
+function toDosDateTime(date) {
+  return {
+    time: (date.getHours() << 11) | (date.getMinutes() << 5) | Math.floor(date.getSeconds() / 2),
+    date: ((date.getFullYear() - 1980) << 9) | ((date.getMonth() + 1) << 5) | date.getDate()
+  };
+}
+
+/**
+ * Build a ZIP archive. Already-compressed data (images) is stored; other
+ * entries are deflated.
+ * @param {Array<Object>} entries - { name, data: Buffer, compress: boolean }
+ * @param {Date} modified - Modification time for every entry
+ * @returns {Buffer} ZIP file
+ */
+function createZip(entries, modified = new Date()) {
+  const localParts = [];
+  const centralParts = [];
+  let offset = 0;
+
+  entries.forEach(entry => {
+    const { local, central } = zipEntry(entry, modified, offset);
+    localParts.push(...local);
+    centralParts.push(...central);
+    offset += local.reduce((sum, part) => sum + part.length, 0);
+  });
+
+  return Buffer.concat([...localParts, ...centralParts, zipEnd(centralParts, entries.length, offset)]);
+}
+
+/**
+ * Local and central directory records of one ZIP entry
+ * @param {Object} entry - { name, data: Buffer, compress: boolean }
+ * @param {Date} modified - Modification time
+ * @param {number} offset - Offset of the local header in the archive
+ * @returns {Object} { local: Array<Buffer>, central: Array<Buffer> }
+ * @private
+ */
+function zipEntry({ name, data, compress = false }, modified, offset) {
+  const { time, date } = toDosDateTime(modified);
+  const fileName = Buffer.from(name, 'utf8');
+  const body = compress ? zlib.deflateRawSync(data) : data;
+  const checksum = crc32(data);
+  const method = compress ? 8 : 0;
+
+  const local = Buffer.alloc(30);
+  local.writeUInt32LE(0x04034b50, 0);
+  local.writeUInt16LE(20, 4); // version needed
+  local.writeUInt16LE(0x0800, 6); // UTF-8 names
+  local.writeUInt16LE(method, 8);
+  local.writeUInt16LE(time, 10);
+  local.writeUInt16LE(date, 12);
+  local.writeUInt32LE(checksum, 14);
+  local.writeUInt32LE(body.length, 18);
+  local.writeUInt32LE(data.length, 22);
+  local.writeUInt16LE(fileName.length, 26);
+  local.writeUInt16LE(0, 28);
+
+  const central = Buffer.alloc(46);
+  central.writeUInt32LE(0x02014b50, 0);
+  central.writeUInt16LE(20, 4); // version made by
+  central.writeUInt16LE(20, 6);
+  central.writeUInt16LE(0x0800, 8);
+  central.writeUInt16LE(method, 10);
+  central.writeUInt16LE(time, 12);
+  central.writeUInt16LE(date, 14);
+  central.writeUInt32LE(checksum, 16);
+  central.writeUInt32LE(body.length, 20);
+  central.writeUInt32LE(data.length, 24);
+  central.writeUInt16LE(fileName.length, 28);
+  central.writeUInt32LE(offset, 42);
+
+  return { local: [local, fileName, body], central: [central, fileName] };
+}
+
+/**
+ * End of central directory record
+ * @param {Array<Buffer>} centralParts - Central directory records
+ * @param {number} count - Number of entries
+ * @param {number} offset - Offset of the central directory
+ * @returns {Buffer} Record
+ * @private
+ */
+function zipEnd(centralParts, count, offset) {
+  const end = Buffer.alloc(22);
+  end.writeUInt32LE(0x06054b50, 0);
+  end.writeUInt16LE(count, 8);
+  end.writeUInt16LE(count, 10);
+  end.writeUInt32LE(centralParts.reduce((sum, part) => sum + part.length, 0), 12);
+  end.writeUInt32LE(offset, 16);
+  return end;
+}
+
+/**
+ * Build a ustar TAR archive
+ * @param {Array<Object>} entries - { name, data: Buffer }
+ * @param {Date} modified - Modification time for every entry
+ * @returns {Buffer} TAR file
+ */
+function createTar(entries, modified = new Date()) {
+  const parts = entries.flatMap(entry => tarEntry(entry, modified));
+  // Two empty blocks mark the end of the archive
+  parts.push(Buffer.alloc(1024));
+  return Buffer.concat(parts);
+}
+
+/**
+ * Header, data and padding of one TAR entry
+ * @param {Object} entry - { name, data: Buffer }
+ * @param {Date} modified - Modification time
+ * @returns {Array<Buffer>} Parts
+ * @private
+ */
+function tarEntry({ name, data }, modified) {
+  const mtime = Math.floor(modified.getTime() / 1000);
+  const header = Buffer.alloc(512);
+  header.write(name.slice(0, 100), 0, 'utf8');
+  header.write('0000644\0', 100);
+  header.write('0000000\0', 108);
+  header.write('0000000\0', 116);
+  header.write(`${data.length.toString(8).padStart(11, '0')}\0`, 124);
+  header.write(`${mtime.toString(8).padStart(11, '0')}\0`, 136);
+  header.fill(' ', 148, 156); // checksum is computed with spaces here
+  header.write('0', 156);
+  header.write('ustar\0', 257);
+  header.write('00', 263);
+
+  let checksum = 0;
+  for (let i = 0; i < 512; i += 1) {
+    checksum += header[i];
+  }
+  header.write(`${checksum.toString(8).padStart(6, '0')}\0 `, 148);
+
+  const padding = (512 - (data.length % 512)) % 512;
+  return padding > 0 ? [header, data, Buffer.alloc(padding)] : [header, data];
+}
+
+/**
+ * Write buffers to a stream, waiting for it to drain when its buffer is full
+ * @param {stream.Writable} output - Stream
+ * @param {Array<Buffer>} parts - Data
+ * @returns {Promise<number>} Bytes written
+ * @private
+ */
+async function writeParts(output, parts) {
+  let bytes = 0;
+  for (const part of parts) {
+    bytes += part.length;
+    if (!output.write(part)) {
+      await new Promise((resolve, reject) => {
+        const onClose = () => reject(new Error('Output stream closed'));
+        output.once('drain', () => {
+          output.removeListener('close', onClose);
+          resolve();
+        });
+        output.once('close', onClose);
+      });
+    }
+  }
+  return bytes;
+}
+
+/**
+ * ZIP writer that streams entries as they are added. Only the central
+ * directory records are kept until finish().
+ * @param {stream.Writable} output - Destination, e.g. an HTTP response
+ * @param {Date} modified - Modification time for every entry
+ * @returns {Object} { add(entry): Promise, finish(): Promise<number> }; finish resolves with the archive size
+ */
+function createZipStream(output, modified = new Date()) {
+  const centralParts = [];
+  let offset = 0;
+  let count = 0;
+
+  return {
+    async add(entry) {
+      const { local, central } = zipEntry(entry, modified, offset);
+      centralParts.push(...central);
+      count += 1;
+      offset += await writeParts(output, local);
+    },
+    async finish() {
+      const size = await writeParts(output, [...centralParts, zipEnd(centralParts, count, offset)]);
+      return offset + size;
+    }
+  };
+}
+
+/**
+ * TAR writer that streams entries as they are added
+ * @param {stream.Writable} output - Destination, e.g. an HTTP response
+ * @param {Date} modified - Modification time for every entry
+ * @returns {Object} { add(entry): Promise, finish(): Promise<number> }; finish resolves with the archive size
+ */
+function createTarStream(output, modified = new Date()) {
+  let size = 0;
+
+  return {
+    async add(entry) {
+      size += await writeParts(output, tarEntry(entry, modified));
+    },
+    async finish() {
+      size += await writeParts(output, [Buffer.alloc(1024)]);
+      return size;
+    }
+  };
+}
+
+module.exports = {
+  createZip,
+  createTar,
+  createZipStream,
+  createTarStream,
+  crc32
+};
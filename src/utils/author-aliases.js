@@ -0,0 +1,49 @@
+// Mentions as they appear in raw logs: "@6281234567890" or "@agent.42";
+// the @ of an email address doesn't start a mention
+const MENTION_PATTERN = /(^|[^\w])@(\+?[\w.-]*\w)/g;
+
+/**
+ * Normalize an identifier for alias lookup: phone numbers compare by their
+ * digits, anything else case-insensitively
+ * @param {string} identifier - Phone number, system ID or name
+ * @returns {string} Lookup key
+ */
+function normalizeIdentifier(identifier) {
+  const value = String(identifier).trim();
+  return /^\+?[\d\s\-().]+$/.test(value) ? value.replace(/\D/g, '') : value.toLowerCase();
+}
+
+/**
+ * Rewrite sender identifiers to display names: the recipient (by
+ * recipient_name, else recipient_phone), group message authors and
+ * @mentions in message content.
+ * Runs before anonymization, so pseudonyms replace the display names.
+ * @param {Array<Object>} messages - Request messages
+ * @param {Object} [aliases] - options.authorAliases { identifier: displayName }
+ * @returns {Array<Object>} Messages with aliases applied
+ */
+function applyAuthorAliases(messages, aliases) {
+  if (!aliases || Object.keys(aliases).length === 0) {
+    return messages;
+  }
+  const lookup = new Map(Object.entries(aliases).map(([identifier, name]) => [normalizeIdentifier(identifier), name]));
+  const resolve = identifier => (identifier ? lookup.get(normalizeIdentifier(identifier)) : undefined);
+
+  return messages.map(msg => {
+    const recipientName = resolve(msg.recipient_name) || (!msg.recipient_name && resolve(msg.recipient_phone));
+    return {
+      ...msg,
+      content: msg.content && msg.content.replace(MENTION_PATTERN, (mention, before, identifier) => {
+        const name = resolve(identifier);
+        return name ? `${before}@${name}` : mention;
+      }),
+      ...(recipientName && { recipient_name: recipientName }),
+      ...(resolve(msg.author) && { author: resolve(msg.author) })
+    };
+  });
+}
+
+module.exports = {
+  applyAuthorAliases,
+  normalizeIdentifier
+};
@@ -0,0 +1,107 @@
+// Detection patterns, applied to escaped text outside of HTML tags
+const EMAIL_PATTERN = /(?<![\w.+-])([A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,})(?![\w-])/g;
+// Kept free of nested quantifiers so long digit runs can't backtrack catastrophically;
+// the minimum digit count is checked in linkPhones instead
+const PHONE_PATTERN = /(?<![\w+])\+?\d[\d -]{6,}\d(?!\w)/g;
+const MIN_PHONE_DIGITS = 8;
+
+// Elements whose text must never be linked
+const SKIPPED_TAGS = ['a', 'code'];
+
+/**
+ * Normalize the `autoLink` option
+ * @param {boolean|Object} option - Value of options.autoLink
+ * @returns {Object|null} Settings, or null when auto-linking is disabled
+ */
+function resolveAutoLinkSettings(option) {
+  if (!option) {
+    return null;
+  }
+
+  const settings = option === true ? {} : option;
+  return {
+    phones: settings.phones !== false,
+    emails: settings.emails !== false,
+    anchors: Boolean(settings.anchors)
+  };
+}
+
+/**
+ * Build the link markup for a detected value
+ * @param {string} text - Display text
+ * @param {string} href - Link target
+ * @param {boolean} anchors - Whether to emit a real anchor
+ * @returns {string} HTML
+ */
+function linkMarkup(text, href, anchors) {
+  return anchors
+    ? `<a class="message-link" href="${href}">${text}</a>`
+    : `<span class="message-link">${text}</span>`;
+}
+
+/**
+ * Link phone numbers in plain text
+ * @param {string} text - Escaped text without tags
+ * @param {boolean} anchors - Whether to emit real anchors
+ * @returns {string} HTML
+ */
+function linkPhones(text, anchors) {
+  return text.replace(PHONE_PATTERN, phone => {
+    const digits = phone.replace(/[^\d+]/g, '');
+    if (digits.replace('+', '').length < MIN_PHONE_DIGITS) {
+      return phone;
+    }
+    return linkMarkup(phone, `tel:${digits}`, anchors);
+  });
+}
+
+/**
+ * Style phone numbers and emails in formatted message HTML like WhatsApp links
+ * @param {string} html - Formatted message HTML
+ * @param {Object} settings - Resolved auto-link settings
+ * @returns {string} HTML with links
+ */
+function autoLinkHTML(html, settings) {
+  if (!html || !settings) {
+    return html;
+  }
+
+  const openTags = [];
+
+  return html.split(/(<[^>]+>)/).map(part => {
+    const tag = part.match(/^<(\/)?([a-zA-Z0-9]+)/);
+    if (tag) {
+      const name = tag[2].toLowerCase();
+      if (SKIPPED_TAGS.includes(name)) {
+        if (tag[1]) {
+          openTags.pop();
+        } else {
+          openTags.push(name);
+        }
+      }
+      return part;
+    }
+
+    if (openTags.length > 0) {
+      return part;
+    }
+
+    let text = part;
+    if (settings.emails) {
+      text = text.replace(EMAIL_PATTERN, email => linkMarkup(email, `mailto:${email}`, settings.anchors));
+    }
+    if (settings.phones) {
+      // Split around the email links created above so their text isn't re-linked
+      text = text.split(/(<[^>]+>[^<]*<\/[^>]+>)/).map(segment => (segment.startsWith('<')
+        ? segment
+        : linkPhones(segment, settings.anchors)
+      )).join('');
+    }
+    return text;
+  }).join('');
+}
+
+module.exports = {
+  autoLinkHTML,
+  resolveAutoLinkSettings
+};
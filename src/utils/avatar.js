@@ -0,0 +1,65 @@
+const { escapeHTML } = require('./html-escape');
+
+// Name colors of group members, as in WhatsApp group chats
+const AUTHOR_COLORS = ['#e542a3', '#1f7aec', '#fc9775', '#35cd96', '#6bcbef', '#d3a91d', '#ba33dc', '#a62c71', '#008069', '#dd6b21'];
+
+/**
+ * Stable color for a name
+ * @param {string} name - Author name
+ * @returns {string} CSS color
+ */
+function authorColor(name) {
+  let hash = 0;
+  for (const char of String(name)) {
+    hash = (hash * 31 + char.codePointAt(0)) >>> 0;
+  }
+  return AUTHOR_COLORS[hash % AUTHOR_COLORS.length];
+}
+
+/**
+ * Initials of a name: the first letters (or digits) of its first two words
+ * @param {string} name - Name
+ * @returns {string} One or two upper-cased characters
+ */
+function initialsOf(name) {
+  return String(name).trim().split(/\s+/).slice(0, 2)
+    .map(word => [...word.replace(/^[^\p{L}\p{N}]+/u, '')][0] || '')
+    .join('')
+    .toUpperCase();
+}
+
+/**
+ * Avatar of a group member: the photo, or their initials on a colored circle
+ * in their name color
+ * @param {Object} author - { name, label, pushName, src } from formatMessage
+ * @param {boolean} [blurred] - Blur the avatar (anonymize.avatar)
+ * @returns {string} HTML
+ */
+function renderAuthorAvatar(author, blurred = false) {
+  const className = `message-avatar${blurred ? ' blurred' : ''}`;
+  return author.src
+    ? `<img class="${className}" src="${escapeHTML(author.src)}" alt="">`
+    : `<span class="${className}" style="background-color: ${authorColor(author.name)}">${escapeHTML(initialsOf(author.pushName || author.label))}</span>`;
+}
+
+/**
+ * Attach the photo URL of each message's author from options.authorAvatars
+ * @param {Array<Object>} messages - Request messages
+ * @param {Object} [authorAvatars] - { author: url }
+ * @returns {Array<Object>} Messages; authors with a photo get authorAvatarUrl
+ */
+function attachAuthorAvatars(messages, authorAvatars) {
+  if (!authorAvatars) {
+    return messages;
+  }
+  return messages.map(msg => (msg.author && authorAvatars[msg.author]
+    ? { ...msg, authorAvatarUrl: authorAvatars[msg.author] }
+    : msg));
+}
+
+module.exports = {
+  authorColor,
+  initialsOf,
+  renderAuthorAvatar,
+  attachAuthorAvatars
+};
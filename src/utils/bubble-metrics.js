@@ -0,0 +1,178 @@
+// Estimated text layout of the bubbles at the requested width, for the
+// dry-run output (debugData): line counts, where lines wrap and where a
+// bubble stops fitting the screen. The estimate uses average glyph widths of
+// the template's system font, so it needs no browser.
+
+// Built-in template geometry, in CSS pixels
+const CHAT_PADDING = 10;
+const MESSAGE_PADDING = { start: 10, end: 20 };
+const BUBBLE_MAX_WIDTH = 0.7;
+const BUBBLE_PADDING = { inline: 21, block: 16, margin: 4 };
+const FONT_SIZE = 14;
+const LINE_HEIGHT = 19.6;
+const PARAGRAPH_MARGIN = 5;
+const HEADER_HEIGHT = 60;
+// Time (11px) with its margin, and the receipt ticks of sent messages
+const TIME_FONT_SIZE = 11;
+const TIME_MARGIN = 8;
+const TICKS_WIDTH = 18;
+
+// Glyph advance in em, by character class
+const NARROW = new Set([...' il.,:;\'|!`ijtfI']);
+const SEMI_NARROW = new Set([...'rJ()[]{}-"/']);
+const WIDE = new Set([...'mwMW@%']);
+
+/**
+ * Estimated advance of a character in em
+ * @param {string} char - One code point
+ * @returns {number} Width in em
+ */
+function charWidth(char) {
+  const code = char.codePointAt(0);
+  if (code > 0xffff || (code >= 0x2600 && code <= 0x27bf)) {
+    // Emoji
+    return 1.2;
+  }
+  if (code >= 0x2e80) {
+    // CJK and other full-width scripts
+    return 1;
+  }
+  if (NARROW.has(char)) {
+    return 0.27;
+  }
+  if (SEMI_NARROW.has(char)) {
+    return 0.35;
+  }
+  if (WIDE.has(char)) {
+    return 0.85;
+  }
+  if (/[0-9]/.test(char)) {
+    return 0.56;
+  }
+  if (/[A-Z]/.test(char)) {
+    return 0.66;
+  }
+  return 0.52;
+}
+
+/**
+ * Estimated width of a text
+ * @param {string} text - Text
+ * @param {number} fontSize - Font size in pixels
+ * @returns {number} Width in pixels
+ */
+const textWidth = (text, fontSize) => [...text].reduce((sum, char) => sum + charWidth(char), 0) * fontSize;
+
+/**
+ * Displayed text of a formatted message: markup removed, <br> as newlines
+ * @param {string} html - contentHTML of a processed message
+ * @returns {string} Text
+ */
+function displayedText(html = '') {
+  return html
+    .replace(/<br\s*\/?>/gi, '\n')
+    .replace(/<[^>]*>/g, '')
+    .replace(/&#(\d+);/g, (match, code) => String.fromCodePoint(Number(code)))
+    .replace(/&quot;/g, '"')
+    .replace(/&#39;|&apos;/g, '\'')
+    .replace(/&lt;/g, '<')
+    .replace(/&gt;/g, '>')
+    .replace(/&amp;/g, '&');
+}
+
+/**
+ * Wrap a text like the bubble does: at spaces, and inside words too long for
+ * a line (word-wrap: break-word)
+ * @param {string} text - Displayed text
+ * @param {number} maxWidth - Line width in pixels
+ * @returns {Array<Object>} Lines { start, width }; start is a code point offset
+ */
+function wrapText(text, maxWidth) {
+  const lines = [];
+  let offset = 0;
+  text.split('\n').forEach(paragraph => {
+    let line = { start: offset, width: 0 };
+    paragraph.split(/(\s+)/).filter(Boolean).forEach(token => {
+      const chars = [...token];
+      const width = textWidth(token, FONT_SIZE);
+      const isSpace = /^\s+$/.test(token);
+      if (line.width + width > maxWidth && line.width > 0 && !isSpace) {
+        lines.push(line);
+        line = { start: offset, width: 0 };
+      }
+      if (width > maxWidth && !isSpace) {
+        // Break the word itself wherever it overflows
+        chars.forEach((char, index) => {
+          const advance = charWidth(char) * FONT_SIZE;
+          if (line.width + advance > maxWidth && line.width > 0) {
+            lines.push(line);
+            line = { start: offset + index, width: 0 };
+          }
+          line.width += advance;
+        });
+      } else {
+        // Trailing spaces hang past the line end
+        line.width += isSpace ? Math.min(width, maxWidth - line.width) : width;
+      }
+      offset += chars.length;
+    });
+    lines.push(line);
+    // The newline itself
+    offset += 1;
+  });
+  return lines;
+}
+
+/**
+ * Layout metrics of every rendered message at the requested width
+ * @param {Array<Object>} messages - Messages from processChatData
+ * @param {Object} [settings] - { width, viewportHeight } in CSS pixels
+ * @returns {Object} { width, textWidth, viewportHeight, messages: [{ index, id, characters,
+ *   lineCount, lineBreaks, timeOnOwnLine, height, fitsViewport, visibleUntil }] }
+ */
+function measureBubbles(messages, { width = 400, viewportHeight = 800 } = {}) {
+  const pageWidth = parseInt(width, 10) || 400;
+  const rowWidth = pageWidth - 2 * CHAT_PADDING - MESSAGE_PADDING.start - MESSAGE_PADDING.end;
+  const maxTextWidth = Math.floor(rowWidth * BUBBLE_MAX_WIDTH - BUBBLE_PADDING.inline);
+  // Room for one bubble under the header
+  const screenHeight = viewportHeight - HEADER_HEIGHT - 2 * CHAT_PADDING;
+
+  return {
+    width: pageWidth,
+    textWidth: maxTextWidth,
+    viewportHeight,
+    messages: messages.map((msg, index) => {
+      const text = displayedText(msg.contentHTML);
+      const lines = text ? wrapText(text, maxTextWidth) : [];
+      // The time floats at the end of the last line, or wraps below it
+      const timeWidth = textWidth(msg.time || '', TIME_FONT_SIZE) + TIME_MARGIN + (msg.isSent ? TICKS_WIDTH : 0);
+      const lastLine = lines[lines.length - 1];
+      const timeOnOwnLine = Boolean(lastLine) && lastLine.width + timeWidth > maxTextWidth;
+      const renderedLines = lines.length + (timeOnOwnLine ? 1 : 0);
+      const height = Math.round(renderedLines * LINE_HEIGHT + (lines.length ? PARAGRAPH_MARGIN : 0)
+        + BUBBLE_PADDING.block + BUBBLE_PADDING.margin);
+      // Lines beyond one screen are cut off in a single-screen capture
+      const visibleLines = Math.floor((screenHeight - BUBBLE_PADDING.block - BUBBLE_PADDING.margin) / LINE_HEIGHT);
+      const fitsViewport = height <= screenHeight;
+
+      return {
+        index,
+        ...(msg.id !== undefined && { id: msg.id }),
+        characters: [...text].length,
+        lineCount: lines.length,
+        lineBreaks: lines.slice(1).map(line => line.start),
+        timeOnOwnLine,
+        height,
+        fitsViewport,
+        visibleUntil: fitsViewport || !lines[visibleLines] ? null : lines[visibleLines].start,
+        ...(msg.media && { media: true })
+      };
+    })
+  };
+}
+
+module.exports = {
+  measureBubbles,
+  wrapText,
+  displayedText
+};
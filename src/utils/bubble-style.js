@@ -0,0 +1,76 @@
+// Bubble shape and spacing options. The defaults reproduce the built-in
+// template, so requests without options.bubbles render unchanged.
+const BUBBLE_DEFAULTS = {
+  tail: 'all',
+  tailPlacement: 'bottom',
+  radius: 7.5,
+  groupSpacing: 2,
+  senderSpacing: 2
+};
+
+// Whether two messages come from the same person; in group chats received
+// messages are told apart by their author
+const sameSender = (a, b) => a.sender === b.sender && (a.author && a.author.name) === (b.author && b.author.name);
+
+/**
+ * Mark the first and last bubble of each run of consecutive messages from
+ * the same sender with group-first / group-last classes
+ * @param {Array<Object>} chatMessages - Formatted messages (modified)
+ */
+function markMessageGroups(chatMessages) {
+  chatMessages.forEach((msg, index) => {
+    const previous = chatMessages[index - 1];
+    const next = chatMessages[index + 1];
+    if (!previous || !sameSender(previous, msg)) {
+      msg.bubbleClass += ' group-first';
+    }
+    if (!next || !sameSender(next, msg)) {
+      msg.bubbleClass += ' group-last';
+    }
+  });
+}
+
+/**
+ * CSS overriding the template's bubble shape and spacing, appended to the
+ * template's styles as {{bubbleStyle}}
+ * @param {Object} [bubbles] - options.bubbles { tail, tailPlacement, radius, groupSpacing, senderSpacing }
+ * @returns {string} CSS rules, empty for the defaults
+ */
+function buildBubbleStyle(bubbles) {
+  const settings = { ...BUBBLE_DEFAULTS, ...bubbles };
+  if (Object.keys(BUBBLE_DEFAULTS).every(key => settings[key] === BUBBLE_DEFAULTS[key])) {
+    return '';
+  }
+
+  const radius = `${settings.radius}px`;
+  // Logical corners, so right-to-left chats square the mirrored corner
+  const corner = settings.tailPlacement === 'top' ? 'start' : 'end';
+  const rules = [
+    `.message { margin-bottom: ${settings.groupSpacing}px; }`,
+    `.message.group-last { margin-bottom: ${settings.senderSpacing}px; }`,
+    `.message.sent .message-content, .message.received .message-content { border-radius: ${radius}; }`,
+    // The corner the tail attaches to is square
+    `.message.sent .message-content { border-${corner}-end-radius: 0; }`,
+    `.message.received .message-content { border-${corner}-start-radius: 0; }`
+  ];
+  if (settings.tailPlacement === 'top') {
+    rules.push('.message.sent .message-content:after, .message.received .message-content:before { top: 0; bottom: auto; transform: scaleY(-1); }');
+    rules.push('[dir="rtl"] .message.sent .message-content:after, [dir="rtl"] .message.received .message-content:before { transform: scale(-1, -1); }');
+  }
+
+  // Bubbles without a tail get all corners rounded
+  const untailed = { all: null, first: ':not(.group-first)', last: ':not(.group-last)', none: '' }[settings.tail];
+  if (untailed !== null) {
+    rules.push(
+      `.message.sent${untailed} .message-content:after, .message.received${untailed} .message-content:before { content: none; }`,
+      `.message.sent${untailed} .message-content, .message.received${untailed} .message-content { border-radius: ${radius}; }`
+    );
+  }
+  return rules.join('\n    ');
+}
+
+module.exports = {
+  buildBubbleStyle,
+  markMessageGroups,
+  BUBBLE_DEFAULTS
+};
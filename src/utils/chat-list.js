@@ -0,0 +1,94 @@
+const { escapeHTML } = require('./html-escape');
+const { translate } = require('./i18n');
+
+const ICONS = {
+  // Clock for pending, single tick for sent, double tick for delivered and
+  // read, exclamation mark in a circle for failed
+  pending: color => `<svg class="chat-ticks" width="12" height="11" viewBox="0 0 12 11"><circle cx="6" cy="5.5" r="4.5" stroke="${color}" stroke-width="1.2" fill="none"/><path d="M6 3v2.7l1.8 1" stroke="${color}" stroke-width="1.2" stroke-linecap="round" fill="none"/></svg>`,
+  sent: color => `<svg class="chat-ticks" width="12" height="11" viewBox="0 0 12 11"><path d="M1.5 6l3 3 6-7.5" stroke="${color}" stroke-width="1.6" stroke-linecap="round" stroke-linejoin="round" fill="none"/></svg>`,
+  delivered: color => `<svg class="chat-ticks" width="16" height="11" viewBox="0 0 16 11"><path d="M1 6l3 3 6.5-7.5M6.5 8.5l1 1L14 1.5" stroke="${color}" stroke-width="1.6" stroke-linecap="round" stroke-linejoin="round" fill="none"/></svg>`,
+  failed: color => `<svg class="chat-ticks" width="14" height="14" viewBox="0 0 14 14"><circle cx="7" cy="7" r="6.5" fill="${color}"/><path d="M7 3.5v4.2" stroke="#fff" stroke-width="1.6" stroke-linecap="round"/><circle cx="7" cy="10.2" r="0.9" fill="#fff"/></svg>`,
+  muted: color => `<svg class="chat-flag" width="16" height="16" viewBox="0 0 24 24"><path d="M6 16V11a6 6 0 0 1 9.5-4.9M18 11v5l2 2H6M10 20a2 2 0 0 0 4 0M4 4l16 16" stroke="${color}" stroke-width="2" stroke-linecap="round" fill="none"/></svg>`,
+  pinned: color => `<svg class="chat-flag" width="16" height="16" viewBox="0 0 24 24"><path d="M9 3h6l-1 6 4 4H6l4-4zM12 13v8" stroke="${color}" stroke-width="2" stroke-linejoin="round" stroke-linecap="round" fill="${color}"/></svg>`,
+  archive: color => `<svg width="22" height="22" viewBox="0 0 24 24"><path d="M3 4h18v4H3zM5 8v12h14V8M10 12h4" stroke="${color}" stroke-width="1.8" stroke-linejoin="round" stroke-linecap="round" fill="none"/></svg>`
+};
+
+// Colors per style: secondary text, read ticks, failed messages and accents
+const COLORS = {
+  android: { muted: '#8696a0', read: '#53bdeb', failed: '#ea0038', accent: '#00a884' },
+  ios: { muted: '#8e8e93', read: '#34b7f1', failed: '#ff3b30', accent: '#007aff' }
+};
+
+/**
+ * Second line of a chat row: typing, or the last message with its ticks
+ * @param {Object} chat - Chat entry
+ * @param {Object} colors - Colors of the style
+ * @param {string} [locale] - Locale of the typing label
+ * @returns {string} HTML
+ */
+function previewLine(chat, colors, locale) {
+  if (chat.typing) {
+    return `<span class="chat-preview typing">${escapeHTML(translate(locale, 'typing'))}</span>`;
+  }
+  const status = chat.lastMessageStatus || 'read';
+  const ticks = chat.lastMessageFromMe
+    ? ICONS[status === 'read' ? 'delivered' : status](colors[status] || colors.muted)
+    : '';
+  return `${ticks}<span class="chat-preview ${chat.previewClass || ''}">${chat.previewHTML}</span>`;
+}
+
+/**
+ * One chat row
+ * @param {Object} chat - Chat entry
+ * @param {Object} colors - Colors of the style
+ * @param {string} [locale] - Locale of the labels
+ * @returns {string} HTML
+ */
+const renderChat = (chat, colors, locale) => `
+      <div class="chat-row${chat.pinned ? ' pinned' : ''}">
+        <div class="chat-avatar${chat.blurAvatar ? ' blurred' : ''}"><span>${escapeHTML(chat.name.charAt(0).toUpperCase())}</span></div>
+        <div class="chat-body">
+          <div class="chat-line"><span class="chat-name">${escapeHTML(chat.name)}</span><span class="chat-time${chat.unreadCount > 0 ? ' unread' : ''}">${escapeHTML(chat.time || '')}</span></div>
+          <div class="chat-line">
+            <span class="chat-last">${previewLine(chat, colors, locale)}</span>
+            <span class="chat-flags">${chat.muted ? ICONS.muted(colors.muted) : ''}${chat.pinned ? ICONS.pinned(colors.muted) : ''}${chat.unreadCount > 0 ? `<span class="unread-badge${chat.muted ? ' muted' : ''}">${chat.unreadCount}</span>` : ''}</span>
+          </div>
+        </div>
+      </div>`;
+
+/**
+ * Template data of the chat-list view: the home screen with the chat list.
+ * Pinned chats come first and archived chats are folded into the Archived
+ * row, otherwise the given order is kept.
+ * @param {Array<Object>} chats - Chat entries { name, previewHTML, time, muted, pinned, archived, typing, unreadCount, lastMessageFromMe, lastMessageStatus }
+ * @param {Object} [look] - { style, locale }
+ * @returns {Object} { chatListClass, chatList }
+ */
+function buildChatListData(chats, { style = 'android', locale } = {}) {
+  const colors = COLORS[style];
+  const visible = chats.filter(chat => !chat.archived);
+  const archivedCount = chats.length - visible.length;
+  const ordered = [...visible.filter(chat => chat.pinned), ...visible.filter(chat => !chat.pinned)];
+
+  const header = style === 'ios'
+    ? `<div class="list-header"><span class="list-edit">${escapeHTML(translate(locale, 'edit'))}</span><h1>${escapeHTML(translate(locale, 'chats'))}</h1></div>`
+    : '<div class="list-header"><h1>WhatsApp</h1></div>';
+  // Like WhatsApp, the Archived row counts the archived chats with unread messages
+  const archivedUnread = chats.filter(chat => chat.archived && chat.unreadCount > 0).length;
+  const archived = archivedCount > 0
+    ? `
+      <div class="archived-row">${ICONS.archive(colors.muted)}<span class="archived-label">${escapeHTML(translate(locale, 'archived'))}</span><span class="archived-count">${archivedUnread || ''}</span></div>`
+    : '';
+
+  return {
+    chatListClass: `chat-list-${style}`,
+    chatList: `
+    ${header}
+    <div class="chat-list">${archived}${ordered.map(chat => renderChat(chat, colors, locale)).join('')}
+    </div>`
+  };
+}
+
+module.exports = {
+  buildChatListData
+};
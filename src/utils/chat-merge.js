@@ -0,0 +1,104 @@
+const { ApiError } = require('../middleware/error.middleware');
+
+/**
+ * Key used to detect the same message appearing in several sources: the
+ * message ID when both have one, otherwise timestamp, sender and content
+ * @param {Object} msg - Message
+ * @returns {string} Duplicate key
+ */
+function duplicateKey(msg) {
+  return msg.id !== undefined
+    ? `id:${msg.id}`
+    : `msg:${new Date(msg.timestamp).getTime()}|${msg.sender}|${msg.content}`;
+}
+
+/**
+ * Merge several message arrays into one chronologically sorted conversation.
+ * Messages with the same timestamp keep the source order, then their order
+ * within the source.
+ * @param {Array<Object>} sources - { name, sender, messages }; sender overrides
+ *   the sender of every message in that source
+ * @param {Object} options - Merge options
+ * @param {string} options.duplicates - "drop" (keep the first occurrence),
+ *   "keep" or "error" (409)
+ * @returns {Object} { messages, report: { sources, total, duplicates: [...] } }
+ */
+function mergeConversations(sources, { duplicates = 'drop' } = {}) {
+  const entries = [];
+  sources.forEach((source, sourceIndex) => {
+    const sourceName = source.name || `source-${sourceIndex + 1}`;
+    source.messages.forEach((msg, index) => {
+      entries.push({
+        msg: source.sender ? { ...msg, sender: source.sender } : msg,
+        sourceName,
+        sourceIndex,
+        index,
+        time: new Date(msg.timestamp).getTime()
+      });
+    });
+  });
+
+  entries.sort((a, b) => a.time - b.time || a.sourceIndex - b.sourceIndex || a.index - b.index);
+
+  const seen = new Map();
+  const conflicts = [];
+  const messages = [];
+  entries.forEach(entry => {
+    const key = duplicateKey(entry.msg);
+    const first = seen.get(key);
+    // Only repeats across sources are conflicts; a source may legitimately
+    // contain the same text twice
+    if (first && first.sourceIndex !== entry.sourceIndex) {
+      conflicts.push({
+        timestamp: entry.msg.timestamp,
+        ...(entry.msg.id !== undefined && { id: entry.msg.id }),
+        kept: first.sourceName,
+        duplicate: entry.sourceName
+      });
+      if (duplicates !== 'keep') {
+        return;
+      }
+    } else if (!first) {
+      seen.set(key, entry);
+    }
+    messages.push(entry.msg);
+  });
+
+  if (duplicates === 'error' && conflicts.length > 0) {
+    const error = new ApiError(409, `${conflicts.length} message(s) appear in more than one source`)
+      .annotate({ stage: 'validate', code: 'merge_conflict' });
+    error.details = { duplicates: conflicts };
+    throw error;
+  }
+
+  return {
+    messages,
+    report: {
+      sources: sources.map((source, index) => ({
+        name: source.name || `source-${index + 1}`,
+        message_count: source.messages.length
+      })),
+      total: messages.length,
+      duplicates: conflicts
+    }
+  };
+}
+
+/**
+ * Get the messages of a render request: the request's messages, or the
+ * merged conversation when it provides sources instead
+ * @param {Object} body - Validated request body ({ messages } or { sources, merge })
+ * @returns {Object} { messages, mergeReport }
+ */
+function resolveRequestMessages({ messages, sources, merge = {} }) {
+  if (!sources) {
+    return { messages, mergeReport: null };
+  }
+  const { messages: merged, report } = mergeConversations(sources, merge);
+  return { messages: merged, mergeReport: report };
+}
+
+module.exports = {
+  mergeConversations,
+  resolveRequestMessages
+};
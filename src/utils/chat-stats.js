@@ -0,0 +1,84 @@
+// Conversation statistics computed from processed chat data
+
+const TAG_PATTERN = /<[^>]*>/g;
+const ENTITY_PATTERN = /&(?:amp|lt|gt|quot|#39);/g;
+const WORD_PATTERN = /[\p{L}\p{N}](?:[\p{L}\p{N}'’.-]*[\p{L}\p{N}])?/gu;
+const LINK_PATTERN = /<a\s/g;
+const CODE_BLOCK_PATTERN = /class="code-block"/g;
+const IMAGE_PATTERN = /<img\s/g;
+
+/**
+ * Count the occurrences of a pattern
+ * @param {string} text - Text to search
+ * @param {RegExp} pattern - Global pattern
+ * @returns {number} Match count
+ */
+function countMatches(text, pattern) {
+  return (text.match(pattern) || []).length;
+}
+
+/**
+ * Compute statistics for a processed conversation. Words are counted on the
+ * rendered text, so masked and anonymized content is counted as rendered;
+ * redacted messages contribute no words.
+ * @param {Object} chatData - Chat data from processChatData
+ * @returns {Object} Statistics
+ */
+function computeChatStats(chatData) {
+  const messages = chatData.messages || [];
+  const authors = {};
+  const hours = new Array(24).fill(0);
+  const media = { links: 0, codeBlocks: 0, images: 0 };
+  let words = 0;
+  let redacted = 0;
+  let first = null;
+  let last = null;
+
+  messages.forEach(msg => {
+    const author = authors[msg.sender] || (authors[msg.sender] = { messages: 0, words: 0 });
+    author.messages += 1;
+
+    const html = msg.contentHTML || '';
+    media.links += countMatches(html, LINK_PATTERN);
+    media.codeBlocks += countMatches(html, CODE_BLOCK_PATTERN);
+    media.images += countMatches(html, IMAGE_PATTERN);
+
+    if (msg.contentClass === 'redacted') {
+      redacted += 1;
+    } else {
+      const text = html.replace(TAG_PATTERN, ' ').replace(ENTITY_PATTERN, ' ');
+      const count = countMatches(text, WORD_PATTERN);
+      author.words += count;
+      words += count;
+    }
+
+    const date = new Date(msg.timestamp);
+    if (!Number.isNaN(date.getTime())) {
+      hours[date.getHours()] += 1;
+      first = first === null || date < first ? date : first;
+      last = last === null || date > last ? date : last;
+    }
+  });
+
+  const busiest = hours.reduce((best, count, hour) => (count > best.count ? { hour, count } : best), { hour: null, count: 0 });
+
+  return {
+    chat_name: chatData.chatName || null,
+    message_count: messages.length,
+    word_count: words,
+    redacted_count: redacted,
+    authors,
+    media,
+    time_span: {
+      first_message_at: first ? first.toISOString() : null,
+      last_message_at: last ? last.toISOString() : null,
+      duration_seconds: first ? Math.round((last - first) / 1000) : 0
+    },
+    busiest_hour: busiest.hour === null ? null : busiest,
+    messages_per_hour: hours
+  };
+}
+
+module.exports = {
+  computeChatStats
+};
@@ -0,0 +1,62 @@
+const { ApiError } = require('../middleware/error.middleware');
+
+/**
+ * Parse a window boundary
+ * @param {string} value - ISO timestamp
+ * @returns {number|null} Epoch milliseconds, or null when not set
+ */
+function toTime(value) {
+  return value ? new Date(value).getTime() : null;
+}
+
+/**
+ * Select the slice of a conversation to render. The timestamp range
+ * (from/to, inclusive) is applied first, then either the last N messages or
+ * the messages around a message ID with K messages of context on each side.
+ * @param {Array} messages - Messages in conversation order
+ * @param {Object} window - Window options
+ * @param {string} window.from - Keep messages at or after this timestamp
+ * @param {string} window.to - Keep messages at or before this timestamp
+ * @param {number} window.last - Keep only the last N messages
+ * @param {string} window.aroundId - Keep the message with this ID...
+ * @param {number} window.context - ...and this many messages before and after it
+ * @returns {Array} Selected messages
+ */
+function applyWindow(messages, window) {
+  if (!window) {
+    return messages;
+  }
+
+  const { from, to, last, aroundId, context = 5 } = window;
+  const fromTime = toTime(from);
+  const toTimeValue = toTime(to);
+
+  let selected = messages;
+  if (fromTime !== null || toTimeValue !== null) {
+    selected = selected.filter(msg => {
+      const time = new Date(msg.timestamp).getTime();
+      return (fromTime === null || time >= fromTime) && (toTimeValue === null || time <= toTimeValue);
+    });
+  }
+
+  if (aroundId !== undefined) {
+    const index = selected.findIndex(msg => msg.id === aroundId);
+    if (index === -1) {
+      throw new ApiError(400, `Message "${aroundId}" not found in the selected messages`)
+        .annotate({ stage: 'validate', code: 'window_message_not_found' });
+    }
+    selected = selected.slice(Math.max(0, index - context), index + context + 1);
+  } else if (last) {
+    selected = selected.slice(-last);
+  }
+
+  if (selected.length === 0) {
+    throw new ApiError(400, 'No messages left after applying the window')
+      .annotate({ stage: 'validate', code: 'window_empty' });
+  }
+  return selected;
+}
+
+module.exports = {
+  applyWindow
+};
@@ -0,0 +1,49 @@
+const crypto = require('crypto');
+
+// Where the render path takes "now" and new IDs from. The defaults are the
+// system clock and random UUIDs; tests and fixture replays pass fixed ones to
+// createApp({ clock, idGenerator }) so the same request renders the same
+// HTML, file names and metadata byte-for-byte.
+
+const systemClock = {
+  now: () => new Date()
+};
+
+/**
+ * Clock that is always at the same time
+ * @param {string|number|Date} time - The time, e.g. "2025-01-01T09:41:00Z"
+ * @returns {Object} { now() }
+ */
+function fixedClock(time) {
+  const timestamp = new Date(time).getTime();
+  if (Number.isNaN(timestamp)) {
+    throw new TypeError(`Invalid clock time: ${time}`);
+  }
+  return { now: () => new Date(timestamp) };
+}
+
+const randomIdGenerator = {
+  next: () => crypto.randomUUID()
+};
+
+/**
+ * ID generator counting up from 1
+ * @param {string} [prefix] - Prefix of every ID
+ * @returns {Object} { next() }; IDs are "<prefix>-1", "<prefix>-2", ...
+ */
+function sequentialIdGenerator(prefix = 'id') {
+  let count = 0;
+  return {
+    next: () => {
+      count += 1;
+      return `${prefix}-${count}`;
+    }
+  };
+}
+
+module.exports = {
+  systemClock,
+  fixedClock,
+  randomIdGenerator,
+  sequentialIdGenerator
+};
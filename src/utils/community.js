@@ -0,0 +1,43 @@
+// Community announcement groups (options.chatType "community"): the
+// megaphone group icon, @everyone mentions and the admin-only footer
+
+const { escapeHTML } = require('./html-escape');
+const { translate } = require('./i18n');
+
+// Announcement group icon: a white megaphone on the community green
+const ANNOUNCEMENT_ICON = `data:image/svg+xml,${encodeURIComponent(
+  "<svg xmlns='http://www.w3.org/2000/svg' viewBox='0 0 24 24'><rect width='24' height='24' fill='#00a884'/>" +
+  "<path transform='translate(3.6 3.6) scale(0.7)' fill='white' d='M18 11v2h4v-2h-4zm-2 6.61c.96.71 2.21 1.65 3.2 2.39.4-.53.8-1.07 1.2-1.6-.99-.74-2.24-1.68-3.2-2.4-.4.54-.8 1.08-1.2 1.61zM20.4 5.6c-.4-.53-.8-1.07-1.2-1.6-.99.74-2.24 1.68-3.2 2.4.4.53.8 1.07 1.2 1.6.96-.72 2.21-1.65 3.2-2.4zM4 9c-1.1 0-2 .9-2 2v2c0 1.1.9 2 2 2h1v4h2v-4h1l5 3V6L8 9H4zm11.5 3c0-1.33-.58-2.53-1.5-3.35v6.69c.92-.81 1.5-2.01 1.5-3.34z'/></svg>"
+)}`;
+
+/**
+ * Shown instead of the input bar: only admins post in announcement groups
+ * @param {string} [locale] - Locale of the note
+ * @returns {string} HTML
+ */
+const renderAdminOnlyNote = (locale) => `<div class="admin-only-note">${escapeHTML(translate(locale, 'adminOnly'))}</div>`;
+
+// "@everyone" as a word of its own; the @ of an address like
+// "team@everyone.example" doesn't start a mention
+const EVERYONE_PATTERN = /(^|[^\w])@everyone\b/g;
+
+/**
+ * Style @everyone mentions in formatted message HTML
+ * @param {string} html - Message content HTML
+ * @returns {string} HTML with each @everyone wrapped in a mention span
+ */
+function highlightEveryoneMentions(html) {
+  return html.replace(EVERYONE_PATTERN, '$1<span class="mention mention-everyone">@everyone</span>');
+}
+
+/**
+ * Header photo of an announcement group without options.avatarUrl
+ * @returns {string} HTML
+ */
+const renderAnnouncementIcon = () => `<img src="${ANNOUNCEMENT_ICON}" alt="">`;
+
+module.exports = {
+  highlightEveryoneMentions,
+  renderAnnouncementIcon,
+  renderAdminOnlyNote
+};
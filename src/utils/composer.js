@@ -0,0 +1,125 @@
+const { ApiError } = require('../middleware/error.middleware');
+const { escapeHTML } = require('./html-escape');
+const { formatMessage } = require('./message-formatter');
+const { systemClock } = require('./clock');
+
+// Attachment tray entries: label and icon color
+const TRAY_ITEMS = {
+  android: [
+    ['Document', '#7f66ff'], ['Camera', '#ff2e74'], ['Gallery', '#c861f9'],
+    ['Audio', '#ff7f36'], ['Location', '#1fa855'], ['Payment', '#009de2'],
+    ['Contact', '#009de2'], ['Poll', '#ffbc38'], ['Event', '#ff2e74']
+  ],
+  ios: [
+    ['Photos', '#007aff'], ['Camera', '#8e8e93'], ['Location', '#34c759'],
+    ['Contact', '#8e8e93'], ['Document', '#007aff'], ['Poll', '#ffcc00'], ['Event', '#ff3b30']
+  ]
+};
+
+/**
+ * Resolve options.composer.replyTo into the quoted message of the reply
+ * strip. Quotes go through the same content filter, redaction and blurring
+ * as the bubbles.
+ * @param {Object} [replyTo] - { messageId } of a request message, or { sender, content }
+ * @param {Array<Object>} messages - Request messages (after anonymization)
+ * @param {Object} settings - Formatting settings, see formatMessage
+ * @param {Object} [clock] - Clock ({ now() }) of quotes without a timestamp, the system clock by default
+ * @returns {Object|null} { label, own, contentHTML, contentClass }
+ */
+function resolveReply(replyTo, messages, settings, clock = systemClock) {
+  if (!replyTo) {
+    return null;
+  }
+  let quoted = replyTo;
+  if (replyTo.messageId !== undefined) {
+    quoted = messages.find(msg => msg.id === replyTo.messageId);
+    if (!quoted) {
+      throw new ApiError(400, `composer.replyTo: message "${replyTo.messageId}" not found`)
+        .annotate({ stage: 'validate', code: 'reply_message_not_found' });
+    }
+  }
+  const formatted = formatMessage({ timestamp: clock.now().toISOString(), ...quoted }, settings);
+  return {
+    label: formatted.isSent ? 'You' : quoted.sender,
+    own: formatted.isSent,
+    contentHTML: formatted.previewHTML || formatted.contentHTML,
+    contentClass: formatted.contentClass
+  };
+}
+
+/**
+ * Reply strip shown above the input field
+ * @param {Object} reply - From resolveReply
+ * @returns {string} HTML
+ */
+const renderReplyStrip = (reply) => `
+    <div class="composer-reply${reply.own ? ' own' : ''}">
+      <div class="composer-reply-quote">
+        <span class="composer-reply-sender">${escapeHTML(reply.label)}</span>
+        <span class="composer-reply-text ${reply.contentClass}">${reply.contentHTML}</span>
+      </div>
+      <span class="composer-reply-close">&#215;</span>
+    </div>`;
+
+/**
+ * Open attachment tray
+ * @param {string} style - "ios" or "android"
+ * @returns {string} HTML
+ */
+const renderAttachmentTray = (style) => `
+    <div class="composer-tray">
+      ${TRAY_ITEMS[style].map(([label, color]) => `<div class="composer-tray-item"><span class="composer-tray-icon" style="background-color: ${color}"></span><span>${label}</span></div>`).join('')}
+      ${style === 'ios' ? '<div class="composer-tray-cancel">Cancel</div>' : ''}
+    </div>`;
+
+const COMPOSER_STYLES = {
+  android: `
+    .composer-reply { display: flex; align-items: flex-start; margin: 6px 62px -6px 8px; padding: 6px 6px 12px; background-color: white; border-radius: 12px 12px 0 0; }
+    .composer-reply-quote { flex: 1; min-width: 0; padding: 4px 8px; background-color: #f0f2f5; border-inline-start: 4px solid #06cf9c; border-radius: 6px; }
+    .composer-reply.own .composer-reply-quote { border-inline-start-color: #53bdeb; }
+    .composer-reply-sender { display: block; color: #06cf9c; font-size: 13px; font-weight: 500; }
+    .composer-reply.own .composer-reply-sender { color: #53bdeb; }
+    .composer-reply-text { display: -webkit-box; -webkit-line-clamp: 2; -webkit-box-orient: vertical; overflow: hidden; color: #667781; font-size: 13px; }
+    .composer-reply-text.blurred { filter: blur(4px); }
+    .composer-reply-close { padding: 0 6px; color: #8696a0; font-size: 18px; }
+    .composer-tray { display: grid; grid-template-columns: repeat(3, 1fr); gap: 18px 8px; margin: 8px; padding: 22px 12px; background-color: white; border-radius: 16px; box-shadow: 0 2px 6px rgba(11, 20, 26, 0.16); }
+    .composer-tray-item { display: flex; flex-direction: column; align-items: center; gap: 6px; color: #667781; font-size: 13px; }
+    .composer-tray-icon { width: 52px; height: 52px; border-radius: 50%; }`,
+  ios: `
+    .composer-reply { display: flex; align-items: center; padding: 8px 12px 4px; background-color: #f6f6f6; border-top: 1px solid #d1d1d6; }
+    .composer-reply + .input-bar { border-top: none; }
+    .composer-reply-quote { flex: 1; min-width: 0; padding: 4px 8px; background-color: #e9e9eb; border-inline-start: 4px solid #34c759; border-radius: 6px; }
+    .composer-reply.own .composer-reply-quote { border-inline-start-color: #007aff; }
+    .composer-reply-sender { display: block; color: #34c759; font-size: 13px; font-weight: 600; }
+    .composer-reply.own .composer-reply-sender { color: #007aff; }
+    .composer-reply-text { display: -webkit-box; -webkit-line-clamp: 2; -webkit-box-orient: vertical; overflow: hidden; color: #8e8e93; font-size: 13px; }
+    .composer-reply-text.blurred { filter: blur(4px); }
+    .composer-reply-close { padding-inline-start: 10px; color: #8e8e93; font-size: 20px; }
+    .composer-tray { padding: 8px 8px 34px; background-color: #f6f6f6; }
+    .composer-tray-item { display: flex; align-items: center; gap: 14px; padding: 12px 14px; background-color: white; color: #000; font-size: 17px; border-bottom: 1px solid #e5e5ea; }
+    .composer-tray-item:first-child { border-radius: 13px 13px 0 0; }
+    .composer-tray-item:nth-last-child(2) { border-radius: 0 0 13px 13px; border-bottom: none; }
+    .composer-tray-icon { width: 28px; height: 28px; border-radius: 7px; }
+    .composer-tray-cancel { margin-top: 8px; padding: 14px; background-color: white; border-radius: 13px; color: #007aff; font-size: 17px; font-weight: 600; text-align: center; }`
+};
+
+/**
+ * Composer parts around the input bar
+ * @param {string} style - "ios" or "android"
+ * @param {Object} composer - { reply, attachmentTray }
+ * @returns {Object} { style, before, after } CSS and HTML for above and below the input bar
+ */
+function renderComposer(style, { reply, attachmentTray } = {}) {
+  const tray = attachmentTray ? renderAttachmentTray(style) : '';
+  return {
+    style: reply || attachmentTray ? COMPOSER_STYLES[style] : '',
+    // Android's tray opens above the input bar, iOS's sheet below it
+    before: `${style === 'android' ? tray : ''}${reply ? renderReplyStrip(reply) : ''}`,
+    after: style === 'ios' ? tray : ''
+  };
+}
+
+module.exports = {
+  resolveReply,
+  renderComposer
+};
@@ -0,0 +1,81 @@
+const fs = require('fs');
+const path = require('path');
+const dotenv = require('dotenv');
+const { CONFIG_VARIABLES } = require('./config-summary');
+const { logger } = require('./logger');
+
+// Variables read once at startup (server port, worker pools, timers, the
+// template set). The rest are read when used, so reloading them is enough.
+const RESTART_REQUIRED = new Set([
+  'PORT', 'NODE_ENV', 'FORMAT_WORKERS', 'PARALLEL_FORMAT_THRESHOLD', 'PUPPETEER_EXECUTABLE_PATH', 'RENDERER',
+  'TEMPLATE_DIR', 'TEMPLATE_FUNCTIONS_MODULE', 'OUTPUT_DIR', 'RETENTION_GC_INTERVAL_MS', 'BROWSER_HEALTH_CHECK_INTERVAL_MS'
+]);
+
+const ENV_FILE = path.resolve(process.cwd(), '.env');
+
+/**
+ * Parse the .env file
+ * @returns {Object} Variables, empty when there is no file
+ */
+function readEnvFile() {
+  try {
+    return dotenv.parse(fs.readFileSync(ENV_FILE));
+  } catch (error) {
+    if (error.code === 'ENOENT') {
+      return {};
+    }
+    throw error;
+  }
+}
+
+// Variables the .env file set at startup: dotenv never overrides the real
+// environment, so only these may be changed or removed by a reload
+const fromFile = new Set(Object.entries(readEnvFile())
+  .filter(([name, value]) => process.env[name] === value)
+  .map(([name]) => name));
+
+/**
+ * Re-read the .env file and apply the configuration variables that are safe
+ * to change at runtime (API keys, log level, quotas, policies, limits). The
+ * browser, its warm pages and queued renders are untouched.
+ * @returns {Object} { changed, restartRequired }: names of the variables
+ *   applied, and of changed variables that only take effect after a restart
+ */
+function reloadConfig() {
+  const file = readEnvFile();
+  const changed = [];
+  const restartRequired = [];
+
+  CONFIG_VARIABLES.forEach(name => {
+    // Variables from the real environment win over the file, as at startup
+    const managed = fromFile.has(name) || (name in file && process.env[name] === undefined);
+    if (!managed) {
+      return;
+    }
+    const next = file[name];
+    if (next === process.env[name]) {
+      return;
+    }
+    if (RESTART_REQUIRED.has(name)) {
+      restartRequired.push(name);
+      return;
+    }
+
+    if (next === undefined) {
+      delete process.env[name];
+      fromFile.delete(name);
+    } else {
+      process.env[name] = next;
+      fromFile.add(name);
+    }
+    changed.push(name);
+  });
+
+  logger.info('Configuration reloaded', { changed, restartRequired });
+  return { changed, restartRequired };
+}
+
+module.exports = {
+  reloadConfig,
+  RESTART_REQUIRED
+};
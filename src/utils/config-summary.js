@@ -0,0 +1,115 @@
+// Environment variables the service reads, reported by the startup banner
+// and GET /api/admin/config when set
+const CONFIG_VARIABLES = [
+  'ADMIN_API_KEYS', 'ANONYMIZE_RULES', 'API_KEYS', 'API_KEY_PROXIES', 'AWS_REGION',
+  'BROWSER_HEALTH_CHECK_INTERVAL_MS', 'BROWSER_MAX_MEMORY_MB', 'BROWSER_MAX_PAGE_AGE_MS', 'BROWSER_MAX_RENDERS', 'BROWSER_RESTART_QUEUE_LIMIT',
+  'CONTENT_FILTER_MANDATORY_PATTERNS', 'CONTENT_FILTER_MANDATORY_WORDS',
+  'DELIVERY_EMAIL_ALLOWED_DOMAINS', 'DELIVERY_EMAIL_FROM', 'DELIVERY_POLICIES', 'DELIVERY_S3_BUCKET', 'DELIVERY_S3_ENDPOINT',
+  'DELIVERY_S3_PREFIX', 'DELIVERY_S3_REGION', 'DELIVERY_SMTP_URL', 'DELIVERY_WEBHOOK_ALLOWLIST', 'DELIVERY_WEBHOOK_SECRET', 'DELIVERY_WEBHOOK_URL',
+  'ERROR_DEBUG', 'FFMPEG_PATH', 'FORMAT_WORKERS', 'HEALTH_HISTORY_SIZE', 'JOB_CONCURRENCY', 'JOB_PRIORITY_SLOTS', 'JOB_QUEUE_LIMIT', 'JOB_RERUN_MAX_JOBS', 'JOB_RERUN_TTL_MS', 'JOB_TTL_MS',
+  'LOCATION_MAP_URL', 'LOG_FORMAT', 'LOG_LEVEL', 'MEDIA_FETCH_TIMEOUT_MS', 'MEDIA_MAX_BYTES', 'MEDIA_URL_ALLOWLIST', 'NODE_ENV', 'OUTPUT_DIR',
+  'PARALLEL_FORMAT_THRESHOLD', 'PORT', 'PREVIEW_MAX_ENTRIES', 'PREVIEW_TTL_MS', 'PRIORITY_API_KEYS', 'PROVENANCE_SECRET', 'PROVENANCE_SERVER_ID', 'PUPPETEER_EXECUTABLE_PATH',
+  'RECORD_FIXTURES_DIR', 'RECORD_FIXTURES_REDACT', 'RECORD_FIXTURES_SAMPLE_RATE', 'RENDERER', 'RENDER_PROXY', 'RENDER_PROXY_ALLOWLIST', 'RENDER_PROXY_BYPASS',
+  'RETENTION_GC_INTERVAL_MS', 'RETENTION_MAX_AGE_HOURS', 'RETENTION_MAX_BYTES', 'RETENTION_TENANT_MAX_BYTES', 'RETENTION_TENANT_QUOTAS',
+  'SENTRY_DSN', 'SENTRY_ENVIRONMENT', 'SENTRY_RELEASE', 'SHUTDOWN_GRACE_MS',
+  'SLACK_ALLOWED_CHANNELS', 'SLACK_BOT_TOKEN', 'SLACK_DEFAULT_CHANNEL',
+  'SLOW_REQUEST_THRESHOLD_MS', 'SLOW_REQUEST_WEBHOOK_COOLDOWN_MS', 'SLOW_REQUEST_WEBHOOK_URL',
+  'TELEGRAM_ALLOWED_CHATS', 'TELEGRAM_BOT_TOKEN', 'TELEGRAM_DEFAULT_CHAT_ID',
+  'TEMPLATE_DIR', 'TEMPLATE_FUNCTIONS_MODULE', 'TEMPLATE_SANDBOX_FUNCTIONS', 'TEMPLATE_SANDBOX_MAX_OUTPUT_BYTES',
+  'TEMPLATE_SANDBOX_MAX_TEMPLATE_BYTES', 'TEMPLATE_SANDBOX_TIMEOUT_MS', 'TEMPLATE_UPLOAD_LIMIT', 'URL_RENDER_ALLOWLIST',
+  'WATERMARK_POLICIES'
+];
+
+// Variables that are credentials as a whole (webhook URLs embed tokens)
+const SECRET_PATTERN = /(KEYS?|SECRET|TOKEN|PASSWORD|DSN|WEBHOOK_URL)$/;
+
+// Per-tenant JSON maps keyed by API key
+const TENANT_MAPS = new Set(['API_KEY_PROXIES', 'DELIVERY_POLICIES', 'RETENTION_TENANT_QUOTAS', 'WATERMARK_POLICIES']);
+
+// Query parameters that carry credentials, e.g. key= of a static map URL
+// or the signature of a presigned URL
+const SECRET_PARAM_PATTERN = /key|token|secret|sig|pass|auth|credential/i;
+
+// Keys of tenant maps that aren't API keys
+const TENANT_KEYWORDS = new Set(['anonymous', '*']);
+
+/**
+ * Mask an API key, keeping its last four characters to tell keys apart
+ * @param {string} key - API key
+ * @returns {string} Masked key
+ */
+const maskKey = (key) => (TENANT_KEYWORDS.has(key) ? key : `***${key.slice(-4)}`);
+
+/**
+ * Remove the user and password, and the values of credential query
+ * parameters, from URLs inside a value
+ * @param {string} value - Value that may contain URLs
+ * @returns {string} Value without URL credentials
+ */
+const stripUrlCredentials = (value) => value
+  .replace(/(\w+:\/\/)[^/\s@"]+@/g, '$1[redacted]@')
+  .replace(/\w+:\/\/[^\s"?#]*\?[^\s"#]*/g, url => url.replace(
+    /([?&])([^=&]+)=([^&]*)/g,
+    (param, separator, name, paramValue) => (SECRET_PARAM_PATTERN.test(name) && paramValue ? `${separator}${name}=[redacted]` : param)
+  ));
+
+/**
+ * Redact the credentials nested in a tenant setting, e.g. a Slack token
+ * @param {*} setting - Parsed setting
+ * @returns {*} Setting with secret fields redacted
+ */
+function redactSetting(setting) {
+  if (typeof setting === 'string') {
+    return stripUrlCredentials(setting);
+  }
+  if (Array.isArray(setting)) {
+    return setting.map(redactSetting);
+  }
+  if (setting && typeof setting === 'object') {
+    return Object.fromEntries(Object.entries(setting).map(([field, entry]) => [
+      field,
+      SECRET_PATTERN.test(field.toUpperCase()) ? '[redacted]' : redactSetting(entry)
+    ]));
+  }
+  return setting;
+}
+
+/**
+ * Value of a variable as it may be shown to operators: credentials are
+ * redacted, API keys in tenant maps are masked and URL passwords and
+ * credential query parameters removed
+ * @param {string} name - Variable name
+ * @param {string} value - Raw value
+ * @returns {*} Safe value
+ */
+function redactValue(name, value) {
+  if (SECRET_PATTERN.test(name)) {
+    return '[redacted]';
+  }
+  if (TENANT_MAPS.has(name)) {
+    try {
+      const map = JSON.parse(value);
+      return Object.fromEntries(Object.entries(map).map(([key, setting]) => [maskKey(key), redactSetting(setting)]));
+    } catch (error) {
+      return '[unparseable]';
+    }
+  }
+  return stripUrlCredentials(value);
+}
+
+/**
+ * The configuration variables that are set, with secrets redacted
+ * @param {Object} [env] - Environment, process.env by default
+ * @returns {Object} Variable name -> safe value
+ */
+function describeEnvironment(env = process.env) {
+  return Object.fromEntries(CONFIG_VARIABLES
+    .filter(name => env[name] !== undefined && env[name] !== '')
+    .map(name => [name, redactValue(name, env[name])]));
+}
+
+module.exports = {
+  describeEnvironment,
+  redactValue,
+  CONFIG_VARIABLES
+};
@@ -0,0 +1,132 @@
+const { escapeHTML } = require('./html-escape');
+const { translate } = require('./i18n');
+
+// String keys of the disappearing message timers shown in the settings row
+const DISAPPEARING_LABELS = {
+  off: 'off',
+  '24h': 'disappearing24h',
+  '7d': 'disappearing7d',
+  '90d': 'disappearing90d'
+};
+
+// Date style without a locale, as before locales were supported
+const LEGACY_DATE_LOCALE = 'en-GB';
+const DATE_FORMAT = { day: 'numeric', month: 'long', year: 'numeric', timeZone: 'Asia/Jakarta' };
+
+/**
+ * Settings row with an icon, a label and an optional detail line
+ * @param {string} label - Row label (HTML)
+ * @param {string} [detail] - Detail line (HTML)
+ * @param {string} [className] - Extra row class
+ * @returns {string} HTML
+ */
+const row = (label, detail, className = '') => `
+      <div class="row${className ? ` ${className}` : ''}">
+        <span class="row-icon"></span>
+        <div class="row-body">${label}${detail ? `<span class="row-detail">${detail}</span>` : ''}</div>
+      </div>`;
+
+/**
+ * Format an ISO date in the locale's style, e.g. "12 March 2024" or "March 12, 2024"
+ * @param {string} date - ISO date
+ * @param {string} [locale] - BCP 47 locale
+ * @returns {string} Date text (HTML-escaped)
+ */
+const formatDate = (date, locale) => {
+  let text;
+  try {
+    text = new Date(date).toLocaleDateString(locale || LEGACY_DATE_LOCALE, DATE_FORMAT);
+  } catch (error) {
+    // Locales the runtime doesn't know fall back to the legacy style
+    text = new Date(date).toLocaleDateString(LEGACY_DATE_LOCALE, DATE_FORMAT);
+  }
+  return escapeHTML(text);
+};
+
+/**
+ * Template data of the contact-info view: the contact or group info screen
+ * @param {Object} contact - options.contact with name, phone, style, photo and blurAvatar resolved, see processChatData
+ * @param {Object} [look] - { locale } of the labels and dates
+ * @returns {Object} { contactClass, contactInfo }
+ */
+function buildContactInfoData(contact, { locale } = {}) {
+  const {
+    name, phone, about = translate(locale, 'aboutDefault'), aboutDate, group,
+    mediaCount = 0, muted = false, disappearingMessages = 'off', style = 'android', photo = null, blurAvatar = false
+  } = contact;
+  const label = (key, params) => escapeHTML(translate(locale, key, params));
+  const initial = escapeHTML(name.charAt(0).toUpperCase());
+  const participants = group ? group.participants || [] : [];
+  const single = participants.length === 1;
+  const subtitle = group
+    ? label(single ? 'groupMember' : 'groupMembers', { count: participants.length })
+    : escapeHTML(phone || '');
+
+  const profile = `
+    <div class="profile${style === 'android' ? ' card' : ''}">
+      <div class="avatar${blurAvatar ? ' blurred' : ''}">${photo ? `<img src="${escapeHTML(photo)}" alt="">` : `<span>${initial}</span>`}</div>
+      <div class="profile-name">${escapeHTML(name)}</div>
+      <div class="profile-detail">${subtitle}</div>
+      <div class="actions">
+        ${(group ? ['audioCall', 'videoCall', 'addMember', 'search'] : ['audioCall', 'videoCall', 'search']).map(action => `<span class="action">${label(action)}</span>`).join('')}
+      </div>
+    </div>`;
+
+  const aboutCard = group
+    ? (group.description || group.createdBy) && `
+    <div class="card">
+      ${group.description ? row(escapeHTML(group.description)) : ''}
+      ${group.createdBy ? row(label('createdBy', { name: group.createdBy }), group.createdAt && formatDate(group.createdAt, locale)) : ''}
+    </div>`
+    : `
+    <div class="card">
+      ${row(escapeHTML(about), aboutDate && formatDate(aboutDate, locale))}
+    </div>`;
+
+  const media = `
+    <div class="card">
+      <div class="section-title"><span>${label('mediaLinksDocs')}</span><span>${mediaCount}</span></div>
+      ${mediaCount > 0 ? `<div class="media-grid">${'<span></span>'.repeat(Math.min(mediaCount, 4))}</div>` : ''}
+    </div>`;
+
+  const settings = `
+    <div class="card">
+      ${row(label('muteNotifications'), label(muted ? 'muted' : 'off'))}
+      ${row(label('disappearingMessages'), label(DISAPPEARING_LABELS[disappearingMessages]))}
+      ${row(label('encryption'), label('encryptionDetail'))}
+    </div>`;
+
+  const members = group ? `
+    <div class="card">
+      <div class="section-title"><span>${label(single ? 'member' : 'members', { count: participants.length })}</span><span></span></div>
+      ${participants.map(participant => `
+      <div class="row">
+        <span class="participant-avatar">${escapeHTML(participant.name.charAt(0).toUpperCase())}</span>
+        <div class="row-body">${escapeHTML(participant.name)}${participant.phone ? `<span class="row-detail">${escapeHTML(participant.phone)}</span>` : ''}</div>
+        ${participant.admin ? `<span class="admin-badge">${label('groupAdmin')}</span>` : ''}
+      </div>`).join('')}
+    </div>` : '';
+
+  const danger = `
+    <div class="card">
+      ${group
+    ? `${row(label('exitGroup'), null, 'danger')}${row(label('reportGroup'), null, 'danger')}`
+    : `${row(label('blockContact', { name }), null, 'danger')}${row(label('reportContact', { name }), null, 'danger')}`}
+    </div>`;
+
+  return {
+    contactClass: `contact-${style}`,
+    contactInfo: `
+    <div class="topbar">${style === 'ios' ? `&#8249; ${label('back')}` : '&#8592;'}</div>
+    ${profile}
+    ${aboutCard || ''}
+    ${media}
+    ${settings}
+    ${members}
+    ${danger}`
+  };
+}
+
+module.exports = {
+  buildContactInfoData
+};
@@ -0,0 +1,169 @@
+const { parseJsonEnv, parseListEnv } = require('./env');
+const { logger } = require('./logger');
+const { convertToPlaceholderText } = require('./whatsapp-html');
+
+// Private-use characters mark masked ranges while the content goes through
+// WhatsApp formatting, so the markers can't collide with *bold* and friends.
+const BLUR_OPEN = '\uE000';
+const BLUR_CLOSE = '\uE001';
+const ASTERISK = '\uE002';
+
+const BLUR_MARKER_PATTERN = new RegExp(`${BLUR_OPEN}([^${BLUR_CLOSE}]*)${BLUR_CLOSE}`, 'g');
+const ASTERISK_MARKER_PATTERN = new RegExp(ASTERISK, 'g');
+const MASK_MARKER_TEST = new RegExp(`[${BLUR_OPEN}${ASTERISK}]`);
+
+// Named presets callers can enable per request
+const PRESET_PATTERNS = {
+  // One-time passwords: 4-8 digit codes following an OTP keyword
+  otp: /(?<=\b(?:OTP|otp|kode|code|Code|Kode|PIN|pin)\b\D{0,20})\d{4,8}\b/g,
+  // Payment card numbers: 13-19 digits, optionally grouped with spaces/dashes
+  card: /\b(?:\d[ -]?){12,18}\d\b/g
+};
+
+let cachedMandatoryRules;
+
+/**
+ * Escape a string for use inside a RegExp
+ * @param {string} value - Raw string
+ * @returns {string} Escaped string
+ */
+function escapeRegExp(value) {
+  return value.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+}
+
+/**
+ * Build a whole-word, case-insensitive pattern for a word list
+ * @param {Array<string>} words - Words to match
+ * @returns {RegExp|null} Pattern, or null for an empty list
+ */
+function wordsToPattern(words) {
+  const cleaned = words.map(word => word.trim()).filter(Boolean);
+  if (cleaned.length === 0) {
+    return null;
+  }
+  return new RegExp(`\\b(?:${cleaned.map(escapeRegExp).join('|')})\\b`, 'gi');
+}
+
+/**
+ * Compile the regex sources of CONTENT_FILTER_MANDATORY_PATTERNS. Requests
+ * can't supply regular expressions, so a public caller can't submit a
+ * pattern that backtracks catastrophically. Invalid sources are warned
+ * about and skipped.
+ * @param {Array<string>} patterns - Regex sources
+ * @returns {Array<RegExp>} Compiled patterns
+ */
+function compilePatterns(patterns) {
+  return patterns.flatMap(pattern => {
+    try {
+      return [new RegExp(pattern, 'g')];
+    } catch (error) {
+      logger.warn('Ignoring invalid CONTENT_FILTER_MANDATORY_PATTERNS entry', { reason: error.message });
+      return [];
+    }
+  });
+}
+
+/**
+ * Rules enforced by the server on every request, configured through
+ * CONTENT_FILTER_MANDATORY_WORDS (comma separated) and
+ * CONTENT_FILTER_MANDATORY_PATTERNS (JSON array of regex sources).
+ * Compiled once per value of the variables, so a config reload takes effect.
+ * @returns {Array<RegExp>} Mandatory patterns
+ */
+function getMandatoryRules() {
+  const raw = `${process.env.CONTENT_FILTER_MANDATORY_WORDS || ''}\n${process.env.CONTENT_FILTER_MANDATORY_PATTERNS || ''}`;
+  if (cachedMandatoryRules !== undefined && cachedMandatoryRules.raw === raw) {
+    return cachedMandatoryRules.rules;
+  }
+
+  const rules = [];
+  const words = parseListEnv('CONTENT_FILTER_MANDATORY_WORDS');
+  const wordPattern = wordsToPattern(words);
+  if (wordPattern) {
+    rules.push(wordPattern);
+  }
+
+  const patterns = parseJsonEnv('CONTENT_FILTER_MANDATORY_PATTERNS', []);
+  rules.push(...compilePatterns(Array.isArray(patterns) ? patterns : []));
+
+  cachedMandatoryRules = { raw, rules };
+  return rules;
+}
+
+/**
+ * Resolve the content filter for a request: the per-request words and
+ * presets, and the server-enforced mandatory rules.
+ * @param {Object} option - Value of options.contentFilter
+ * @returns {Object|null} { mandatory, patterns, style }, or null when nothing applies
+ */
+function resolveContentFilter(option = {}) {
+  const mandatory = getMandatoryRules();
+  const patterns = [];
+  const settings = option || {};
+
+  if (settings.enabled) {
+    const wordPattern = wordsToPattern(settings.words || []);
+    if (wordPattern) {
+      patterns.push(wordPattern);
+    }
+    (settings.presets || []).forEach(preset => patterns.push(PRESET_PATTERNS[preset]));
+  }
+
+  if (mandatory.length === 0 && patterns.length === 0) {
+    return null;
+  }
+
+  return {
+    mandatory,
+    patterns,
+    style: settings.style || 'asterisks'
+  };
+}
+
+/**
+ * Mask filtered content with private-use markers. The markers survive
+ * WhatsApp formatting and are turned into HTML by renderMaskedContent.
+ * Blurred matches hold placeholder text, since a blur would keep the raw
+ * text in the DOM and in HTML outputs. Mandatory matches are always
+ * replaced by asterisks.
+ * @param {string} content - Raw message content
+ * @param {Object} filter - Resolved content filter
+ * @returns {string} Content with masked ranges marked
+ */
+function maskContent(content, filter) {
+  if (!filter || !content) {
+    return content;
+  }
+
+  const asterisks = match => match.replace(/\S/g, ASTERISK);
+  const masked = filter.mandatory.reduce((text, pattern) => text.replace(pattern, asterisks), content);
+
+  return filter.patterns.reduce((text, pattern) => text.replace(pattern, match => {
+    if (filter.style === 'blur') {
+      return `${BLUR_OPEN}${convertToPlaceholderText(match)}${BLUR_CLOSE}`;
+    }
+    return asterisks(match);
+  }), masked);
+}
+
+/**
+ * Turn masking markers in formatted HTML into their final representation
+ * @param {string} html - Formatted message HTML
+ * @returns {string} HTML with masked ranges rendered
+ */
+function renderMaskedContent(html) {
+  if (!html || !MASK_MARKER_TEST.test(html)) {
+    return html;
+  }
+
+  return html
+    .replace(BLUR_MARKER_PATTERN, '<span class="masked-blur">$1</span>')
+    .replace(ASTERISK_MARKER_PATTERN, '*');
+}
+
+module.exports = {
+  resolveContentFilter,
+  maskContent,
+  renderMaskedContent,
+  PRESET_PATTERNS
+};
@@ -0,0 +1,52 @@
+const { convertWhatsAppToHTML } = require('./whatsapp-html');
+const { convertMarkdownToHTML } = require('./markdown-html');
+const { escapeHTML } = require('./html-escape');
+const { autoLinkHTML, resolveAutoLinkSettings } = require('./autolink');
+
+// Supported message content formats
+const CONTENT_FORMATS = ['whatsapp', 'markdown', 'plain'];
+
+/**
+ * Render content as-is: no marker interpretation, only escaping and line breaks
+ * @param {string} message - Raw message content
+ * @returns {string} HTML content
+ */
+function convertPlainToHTML(message) {
+  if (!message || typeof message !== 'string') {
+    return '';
+  }
+  return escapeHTML(message).replace(/\n/g, '<br>');
+}
+
+/**
+ * Format message content into bubble HTML according to the content format
+ * @param {string} content - Raw message content
+ * @param {Object} options - Formatting options
+ * @param {string} options.contentFormat - "whatsapp" (default), "markdown" or "plain"
+ * @param {string} options.spoilers - Spoiler state for WhatsApp formatting
+ * @param {boolean|Object} options.autoLink - Phone number/email link styling
+ * @returns {string} HTML content
+ */
+function formatContentHTML(content, options = {}) {
+  const { contentFormat = 'whatsapp', autoLink = false, ...formatOptions } = options;
+  let html;
+
+  switch (contentFormat) {
+    case 'markdown':
+      html = convertMarkdownToHTML(content);
+      break;
+    case 'plain':
+      html = convertPlainToHTML(content);
+      break;
+    case 'whatsapp':
+    default:
+      html = convertWhatsAppToHTML(content, formatOptions);
+  }
+
+  return autoLinkHTML(html, resolveAutoLinkSettings(autoLink));
+}
+
+module.exports = {
+  formatContentHTML,
+  CONTENT_FORMATS
+};
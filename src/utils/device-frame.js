@@ -0,0 +1,107 @@
+// Phone mockups around the chat (options.deviceFrame): the capture is what
+// the phone's screen shows, below a status bar, inside the device bezel
+
+const { escapeHTML } = require('./html-escape');
+
+// Device geometry in CSS pixels
+const DEVICE_FRAMES = {
+  iphone14: {
+    style: 'ios',
+    screen: { width: 390, height: 844 },
+    statusBarHeight: 47,
+    bezel: 14,
+    radius: 60,
+    screenRadius: 47,
+    cutout: 'notch'
+  },
+  pixel7: {
+    style: 'android',
+    screen: { width: 412, height: 915 },
+    statusBarHeight: 32,
+    bezel: 12,
+    radius: 44,
+    screenRadius: 32,
+    cutout: 'punch-hole'
+  }
+};
+
+const DEVICE_FRAME_NAMES = ['none', ...Object.keys(DEVICE_FRAMES)];
+
+// Status bar icons, drawn in the status bar's text color
+const ICONS = {
+  signal: '<svg width="18" height="12" viewBox="0 0 18 12"><rect x="0" y="8" width="3" height="4" rx="1"/><rect x="5" y="5.5" width="3" height="6.5" rx="1"/><rect x="10" y="3" width="3" height="9" rx="1"/><rect x="15" y="0" width="3" height="12" rx="1"/></svg>',
+  wifi: '<svg width="16" height="12" viewBox="0 0 16 12"><path d="M8 2.2c2.3 0 4.4.9 6 2.4l1.2-1.3A10.4 10.4 0 0 0 8 .4C5.2.4 2.7 1.5.8 3.3L2 4.6a8.6 8.6 0 0 1 6-2.4zm0 3.6c1.3 0 2.5.5 3.4 1.3l1.2-1.3A6.8 6.8 0 0 0 8 4c-1.8 0-3.4.7-4.6 1.8l1.2 1.3c.9-.8 2.1-1.3 3.4-1.3zM8 9.2l-2.1 2.2L8 11.6l2.1-2.2A3 3 0 0 0 8 9.2z"/></svg>'
+};
+
+/**
+ * Normalize options.deviceFrame
+ * @param {string|Object} [deviceFrame] - Device name, or { device, time, battery }
+ * @returns {Object|null} Device geometry with { name, time, battery }, or null for no frame
+ */
+function resolveDeviceFrame(deviceFrame) {
+  const { device = 'none', time = '9:41', battery = 100 } = typeof deviceFrame === 'string'
+    ? { device: deviceFrame }
+    : deviceFrame || {};
+  if (!DEVICE_FRAMES[device]) {
+    return null;
+  }
+  return { name: device, ...DEVICE_FRAMES[device], time, battery };
+}
+
+/**
+ * Readable text color on a status bar background: black on light colors,
+ * white on dark ones
+ * @param {string} background - CSS rgb()/rgba() or #rrggbb color
+ * @returns {string} "#000" or "#fff"
+ */
+function statusBarTextColor(background) {
+  const hex = /^#([0-9a-f]{2})([0-9a-f]{2})([0-9a-f]{2})$/i.exec(background || '');
+  const rgb = hex
+    ? hex.slice(1).map(part => parseInt(part, 16))
+    : ((background || '').match(/\d+(\.\d+)?/g) || [0, 0, 0]).slice(0, 3).map(Number);
+  const luminance = (0.299 * rgb[0] + 0.587 * rgb[1] + 0.114 * rgb[2]) / 255;
+  return luminance > 0.6 ? '#000' : '#fff';
+}
+
+/**
+ * Battery icon filled to the charge level
+ * @param {number} level - Charge in percent
+ * @returns {string} SVG
+ */
+const batteryIcon = (level) => {
+  const fill = Math.max(1, Math.round((Math.min(100, Math.max(0, level)) / 100) * 19));
+  return `<svg width="27" height="12" viewBox="0 0 27 12"><rect x="0.5" y="0.5" width="23" height="11" rx="3" fill="none" stroke="currentColor" opacity="0.4"/><rect x="2" y="2" width="${fill}" height="8" rx="1.5"/><rect x="25" y="4" width="1.5" height="4" rx="0.75" opacity="0.4"/></svg>`;
+};
+
+/**
+ * Template data of the device frame template
+ * @param {Object} frame - Resolved frame from resolveDeviceFrame
+ * @param {Object} screen - { src, background }: screen capture data URL and the status bar color
+ * @returns {Object} Template data
+ */
+function buildDeviceFrameData(frame, { src, background }) {
+  return {
+    deviceClass: `device device-${frame.name} device-${frame.style} cutout-${frame.cutout}`,
+    deviceWidth: frame.screen.width + 2 * frame.bezel,
+    deviceHeight: frame.screen.height + 2 * frame.bezel,
+    screenWidth: frame.screen.width,
+    screenHeight: frame.screen.height,
+    bezel: frame.bezel,
+    radius: frame.radius,
+    screenRadius: frame.screenRadius,
+    statusBarHeight: frame.statusBarHeight,
+    statusBarBackground: background,
+    statusBarColor: statusBarTextColor(background),
+    time: escapeHTML(frame.time),
+    statusIcons: `${ICONS.signal}${ICONS.wifi}${batteryIcon(frame.battery)}`,
+    screen: src
+  };
+}
+
+module.exports = {
+  resolveDeviceFrame,
+  buildDeviceFrameData,
+  statusBarTextColor,
+  DEVICE_FRAMES,
+  DEVICE_FRAME_NAMES
+};
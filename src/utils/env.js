@@ -0,0 +1,47 @@
+// Parsers of list and JSON environment variables. Variables are read at use
+// time, so a config reload takes effect; an invalid value is warned about
+// once and ignored.
+const { logger } = require('./logger');
+
+// Variable name -> last invalid value warned about
+const warned = new Map();
+
+/**
+ * Parse a JSON environment variable, e.g. a per-tenant map keyed by API key
+ * @param {string} name - Variable name
+ * @param {*} [fallback] - Value when the variable is unset or invalid
+ * @returns {*} Parsed value
+ */
+function parseJsonEnv(name, fallback = {}) {
+  const raw = process.env[name];
+  if (!raw) {
+    return fallback;
+  }
+  try {
+    return JSON.parse(raw);
+  } catch (error) {
+    if (warned.get(name) !== raw) {
+      warned.set(name, raw);
+      logger.warn(`Ignoring invalid ${name}`, { reason: error.message });
+    }
+    return fallback;
+  }
+}
+
+/**
+ * Entries of a comma separated environment variable, trimmed, without empty ones
+ * @param {string} name - Variable name
+ * @param {Object} [options] - { lowercase }; lowercase entries such as hostnames
+ * @returns {Array<string>} Entries
+ */
+function parseListEnv(name, { lowercase = false } = {}) {
+  return (process.env[name] || '')
+    .split(',')
+    .map(entry => (lowercase ? entry.trim().toLowerCase() : entry.trim()))
+    .filter(Boolean);
+}
+
+module.exports = {
+  parseJsonEnv,
+  parseListEnv
+};
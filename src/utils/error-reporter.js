@@ -0,0 +1,132 @@
+const crypto = require('crypto');
+const os = require('os');
+const { logger } = require('./logger');
+
+// Sentry-compatible error reporting over the store endpoint, so any Sentry
+// (or Sentry-protocol) server works without pulling in the SDK. Configured
+// through SENTRY_DSN, SENTRY_ENVIRONMENT and SENTRY_RELEASE; disabled when
+// SENTRY_DSN is unset.
+
+const REPORT_TIMEOUT_MS = 3000;
+
+let cachedDsn;
+
+/**
+ * Parse SENTRY_DSN (https://<key>@<host>/<projectId>)
+ * @returns {Object|null} { storeUrl, publicKey }, or null when disabled/invalid
+ */
+function getDsn() {
+  if (cachedDsn !== undefined && cachedDsn.raw === process.env.SENTRY_DSN) {
+    return cachedDsn.parsed;
+  }
+
+  let parsed = null;
+  if (process.env.SENTRY_DSN) {
+    try {
+      const url = new URL(process.env.SENTRY_DSN);
+      const projectId = url.pathname.replace(/^\/+|\/+$/g, '');
+      if (!url.username || !projectId) {
+        throw new Error('missing key or project');
+      }
+      parsed = {
+        storeUrl: `${url.protocol}//${url.host}/api/${projectId}/store/`,
+        publicKey: decodeURIComponent(url.username)
+      };
+    } catch (error) {
+      logger.warn('Ignoring invalid SENTRY_DSN', { reason: error.message });
+    }
+  }
+
+  cachedDsn = { raw: process.env.SENTRY_DSN, parsed };
+  return parsed;
+}
+
+/**
+ * Convert a V8 stack trace into Sentry frames (oldest call first)
+ * @param {string} stack - Error stack
+ * @returns {Array<Object>} Frames
+ */
+function parseStack(stack = '') {
+  return stack
+    .split('\n')
+    .slice(1)
+    .map(line => line.match(/^\s*at (?:(.+?) \()?(.+?):(\d+):(\d+)\)?$/))
+    .filter(Boolean)
+    .map(([, fn, file, line, column]) => ({
+      function: fn || '<anonymous>',
+      filename: file,
+      lineno: Number(line),
+      colno: Number(column),
+      in_app: !file.includes('node_modules') && !file.startsWith('node:')
+    }))
+    .reverse();
+}
+
+/**
+ * Report an error. Never throws; delivery failures are only logged.
+ * @param {Error} error - Error to report
+ * @param {Object} context - Extra context (requestId, route, payloadBytes, ...)
+ * @returns {Promise<boolean>} Whether the report was accepted
+ */
+async function reportError(error, context = {}) {
+  const dsn = getDsn();
+  if (!dsn) {
+    return false;
+  }
+
+  const { requestId, route, stage, code, ...extra } = context;
+  // Chained exceptions are listed innermost first, as Sentry expects
+  const chain = [];
+  for (let current = error; current instanceof Error && chain.length < 5; current = current.cause) {
+    chain.unshift(current);
+  }
+
+  const event = {
+    event_id: crypto.randomUUID().replace(/-/g, ''),
+    timestamp: new Date().toISOString(),
+    platform: 'node',
+    level: 'error',
+    server_name: os.hostname(),
+    environment: process.env.SENTRY_ENVIRONMENT || process.env.NODE_ENV || 'production',
+    ...(process.env.SENTRY_RELEASE && { release: process.env.SENTRY_RELEASE }),
+    ...(route && { transaction: route }),
+    tags: {
+      ...(requestId && { request_id: requestId }),
+      ...(stage && { stage }),
+      ...(code && { code })
+    },
+    extra,
+    exception: {
+      values: chain.map(item => ({
+        type: item.name || 'Error',
+        value: item.message,
+        stacktrace: { frames: parseStack(item.stack) }
+      }))
+    }
+  };
+
+  try {
+    const response = await fetch(dsn.storeUrl, {
+      method: 'POST',
+      headers: {
+        'Content-Type': 'application/json',
+        'X-Sentry-Auth': `Sentry sentry_version=7, sentry_client=whatsapp-chat-mockup-api/1.0, sentry_key=${dsn.publicKey}`
+      },
+      body: JSON.stringify(event),
+      signal: AbortSignal.timeout(REPORT_TIMEOUT_MS)
+    });
+    if (!response.ok) {
+      logger.warn('Error report rejected', { status: response.status });
+      return false;
+    }
+    return true;
+  } catch (reportFailure) {
+    logger.warn('Failed to send error report', { reason: reportFailure.message });
+    return false;
+  }
+}
+
+module.exports = {
+  reportError,
+  parseStack
+};
@@ -0,0 +1,107 @@
+const crypto = require('crypto');
+const { ApiError } = require('../middleware/error.middleware');
+
+const DEFAULT_FILE_NAME_TEMPLATE = '{chatName}-{date}-{hash}.{ext}';
+const MAX_FILE_NAME_LENGTH = 200;
+const MAX_SEGMENT_LENGTH = 64;
+
+// Characters that are unsafe in file names or object keys on common systems
+const UNSAFE_CHARS_PATTERN = /[\u0000-\u001f\u007f<>:"/\\|?*]/g;
+const PLACEHOLDER_PATTERN = /\{(\w+)\}/g;
+const WINDOWS_RESERVED_PATTERN = /^(con|prn|aux|nul|com\d|lpt\d)(\.|$)/i;
+
+/**
+ * Placeholders available in output file name templates
+ * @param {Object} input - Render input
+ * @param {string} input.chatName - Chat (recipient) name, already anonymized when requested
+ * @param {string} input.format - Image format, used as extension
+ * @param {Array} input.messages - Request messages
+ * @param {Object} input.options - Request options
+ * @param {string} input.requestId - Request ID
+ * @param {Date} input.now - Render time
+ * @returns {Object} Placeholder -> value
+ */
+function getFileNameValues({ chatName, format = 'png', messages = [], options = {}, requestId, now = new Date() }) {
+  const iso = now.toISOString();
+  return {
+    chatName: chatName || 'chat',
+    date: iso.slice(0, 10),
+    time: iso.slice(11, 19).replace(/:/g, ''),
+    timestamp: Math.floor(now.getTime() / 1000),
+    // Identical requests get identical hashes
+    hash: crypto.createHash('sha256').update(JSON.stringify({ messages, options })).digest('hex').slice(0, 10),
+    ext: format,
+    count: messages.length,
+    requestId: requestId || ''
+  };
+}
+
+/**
+ * Make a value safe to use in a file name: unsafe characters removed,
+ * whitespace collapsed to "-" and length capped
+ * @param {*} value - Raw value
+ * @param {number} maxLength - Maximum length
+ * @returns {string} Safe segment
+ */
+function sanitizeSegment(value, maxLength = MAX_SEGMENT_LENGTH) {
+  return String(value == null ? '' : value)
+    .normalize('NFKC')
+    .replace(UNSAFE_CHARS_PATTERN, '')
+    .replace(/\s+/g, '-')
+    .slice(0, maxLength);
+}
+
+/**
+ * Expand an output file name template such as "{chatName}-{date}-{hash}.{ext}".
+ * Every value and the literal text are sanitized, so the result is a single
+ * path segment; the extension is appended when the template omits it.
+ * @param {string} template - File name template
+ * @param {Object} values - Placeholder values from getFileNameValues
+ * @returns {string} File name
+ */
+function expandFileName(template = DEFAULT_FILE_NAME_TEMPLATE, values) {
+  const unknown = [...template.matchAll(PLACEHOLDER_PATTERN)]
+    .map(match => match[1])
+    .filter(name => !Object.prototype.hasOwnProperty.call(values, name));
+  if (unknown.length > 0) {
+    throw new ApiError(400, `Unknown outputFileName placeholder(s): ${[...new Set(unknown)].join(', ')}`)
+      .annotate({ stage: 'validate', code: 'invalid_file_name' });
+  }
+
+  let name = template
+    .split(PLACEHOLDER_PATTERN)
+    // split() with a capture group alternates literal text and placeholder names
+    .map((part, index) => sanitizeSegment(index % 2 === 1 ? values[part] : part, index % 2 === 1 ? MAX_SEGMENT_LENGTH : MAX_FILE_NAME_LENGTH))
+    .join('')
+    .replace(/^\.+/, '');
+
+  const extension = `.${values.ext}`;
+  if (!name.toLowerCase().endsWith(extension.toLowerCase())) {
+    name += extension;
+  }
+  if (name.length > MAX_FILE_NAME_LENGTH) {
+    name = name.slice(0, MAX_FILE_NAME_LENGTH - extension.length) + extension;
+  }
+  if (name === extension || WINDOWS_RESERVED_PATTERN.test(name)) {
+    name = `_${name}`;
+  }
+  return name;
+}
+
+/**
+ * Build the output file name for a render
+ * @param {string} template - Template from options.outputFileName (optional)
+ * @param {Object} input - Render input, see getFileNameValues
+ * @returns {string} File name
+ */
+function buildFileName(template, input) {
+  return expandFileName(template || DEFAULT_FILE_NAME_TEMPLATE, getFileNameValues(input));
+}
+
+module.exports = {
+  buildFileName,
+  expandFileName,
+  getFileNameValues,
+  sanitizeSegment,
+  DEFAULT_FILE_NAME_TEMPLATE
+};
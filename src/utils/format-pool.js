@@ -0,0 +1,133 @@
+const os = require('os');
+const path = require('path');
+const { Worker } = require('worker_threads');
+const { formatMessage } = require('./message-formatter');
+
+// Chats with at least this many messages are formatted in parallel
+const PARALLEL_THRESHOLD = parseInt(process.env.PARALLEL_FORMAT_THRESHOLD, 10) || 1000;
+// Number of formatting workers; defaults to the spare CPUs, capped at 4
+const WORKER_COUNT = parseInt(process.env.FORMAT_WORKERS, 10) || Math.max(1, Math.min(4, os.cpus().length - 1));
+
+class FormatPool {
+  constructor(size = WORKER_COUNT) {
+    this.size = size;
+    this.workers = [];
+    this.pending = new Map();
+    this.nextId = 0;
+    this.nextWorker = 0;
+  }
+
+  /**
+   * Spawn the workers on first use
+   */
+  ensureWorkers() {
+    if (this.workers.length > 0) {
+      return;
+    }
+
+    for (let i = 0; i < this.size; i += 1) {
+      const worker = new Worker(path.join(__dirname, 'format-worker.js'));
+      worker.on('message', ({ id, results, error }) => {
+        const task = this.pending.get(id);
+        if (!task) {
+          return;
+        }
+        this.pending.delete(id);
+        this.updateRefs();
+        if (error) {
+          task.reject(new Error(error));
+        } else {
+          task.resolve(results);
+        }
+      });
+      worker.on('error', error => this.handleWorkerFailure(worker, error));
+      this.workers.push(worker);
+    }
+    this.updateRefs();
+  }
+
+  /**
+   * Keep the process alive only while work is in flight
+   */
+  updateRefs() {
+    const busy = this.pending.size > 0;
+    this.workers.forEach(worker => (busy ? worker.ref() : worker.unref()));
+  }
+
+  /**
+   * Reject everything in flight and drop the pool so it is rebuilt on next use
+   * @param {Worker} worker - Failed worker
+   * @param {Error} error - Failure
+   */
+  handleWorkerFailure(worker, error) {
+    console.error('Format worker failed:', error);
+    this.pending.forEach(task => task.reject(error));
+    this.pending.clear();
+    this.destroy();
+  }
+
+  /**
+   * Send a chunk of messages to the next worker
+   * @param {Array} messages - Message chunk
+   * @param {Object} settings - Formatting settings
+   * @returns {Promise<Array>} Formatted messages
+   */
+  runChunk(messages, settings) {
+    return new Promise((resolve, reject) => {
+      const id = this.nextId++;
+      const worker = this.workers[this.nextWorker];
+      this.nextWorker = (this.nextWorker + 1) % this.workers.length;
+      this.pending.set(id, { resolve, reject });
+      this.updateRefs();
+      try {
+        worker.postMessage({ id, messages, settings });
+      } catch (error) {
+        // e.g. a setting that can't be cloned; the worker never sees the task
+        this.pending.delete(id);
+        this.updateRefs();
+        reject(error);
+      }
+    });
+  }
+
+  /**
+   * Format all messages, in parallel for large chats, preserving order
+   * @param {Array} messages - Messages to format
+   * @param {Object} settings - Formatting settings
+   * @returns {Promise<Array>} Formatted messages in input order
+   */
+  async formatAll(messages, settings) {
+    if (messages.length < PARALLEL_THRESHOLD || this.size < 2) {
+      return messages.map(msg => formatMessage(msg, settings));
+    }
+
+    try {
+      this.ensureWorkers();
+      const chunkSize = Math.ceil(messages.length / this.workers.length);
+      const chunks = [];
+      for (let start = 0; start < messages.length; start += chunkSize) {
+        chunks.push(messages.slice(start, start + chunkSize));
+      }
+
+      const results = await Promise.all(chunks.map(chunk => this.runChunk(chunk, settings)));
+      return results.flat();
+    } catch (error) {
+      console.error('Parallel formatting failed, falling back to sequential:', error);
+      return messages.map(msg => formatMessage(msg, settings));
+    }
+  }
+
+  /**
+   * Terminate all workers
+   */
+  destroy() {
+    this.workers.forEach(worker => worker.terminate());
+    this.workers = [];
+    this.nextWorker = 0;
+  }
+}
+
+const formatPoolInstance = new FormatPool();
+
+module.exports = formatPoolInstance;
+module.exports.PARALLEL_THRESHOLD = PARALLEL_THRESHOLD;
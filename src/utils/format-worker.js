@@ -0,0 +1,11 @@
+// Worker thread entry point for parallel message formatting
+const { parentPort } = require('worker_threads');
+const { formatMessage } = require('./message-formatter');
+
+parentPort.on('message', ({ id, messages, settings }) => {
+  try {
+    parentPort.postMessage({ id, results: messages.map(msg => formatMessage(msg, settings)) });
+  } catch (error) {
+    parentPort.postMessage({ id, error: error.message });
+  }
+});
@@ -0,0 +1,189 @@
+// Animated GIF encoder. Chat screenshots use few distinct colors, so a
+// single palette built from the most frequent colors of all frames (at 5 bits
+// per channel) looks close to the original without dithering.
+
+const MAX_CODE = 4095;
+
+/**
+ * 15-bit color key (5 bits per channel)
+ * @param {number} r - Red
+ * @param {number} g - Green
+ * @param {number} b - Blue
+ * @returns {number} Key
+ */
+function colorKey(r, g, b) {
+  return ((r >> 3) << 10) | ((g >> 3) << 5) | (b >> 3);
+}
+
+/**
+ * Build a 256-color palette from the most frequent colors in the frames
+ * @param {Array<Object>} frames - { data: RGBA Buffer }
+ * @returns {Array<Array<number>>} Palette of [r, g, b]
+ */
+function buildPalette(frames) {
+  const counts = new Uint32Array(32768);
+  frames.forEach(({ data }) => {
+    // Sampling every 4th pixel is plenty to find the dominant colors
+    for (let i = 0; i < data.length; i += 16) {
+      counts[colorKey(data[i], data[i + 1], data[i + 2])] += 1;
+    }
+  });
+
+  const keys = [];
+  counts.forEach((count, key) => {
+    if (count > 0) {
+      keys.push(key);
+    }
+  });
+  keys.sort((a, b) => counts[b] - counts[a]);
+
+  const palette = keys.slice(0, 256).map(key => [
+    ((key >> 10) & 31) << 3 | 4,
+    ((key >> 5) & 31) << 3 | 4,
+    (key & 31) << 3 | 4
+  ]);
+  while (palette.length < 256) {
+    palette.push([0, 0, 0]);
+  }
+  return palette;
+}
+
+/**
+ * Map RGBA pixels to palette indices, caching the nearest color per 15-bit key
+ * @param {Buffer} data - RGBA pixels
+ * @param {Array<Array<number>>} palette - Palette
+ * @param {Int16Array} cache - Nearest-color cache shared across frames
+ * @returns {Uint8Array} Indices
+ */
+function indexPixels(data, palette, cache) {
+  const indices = new Uint8Array(data.length / 4);
+  for (let i = 0, p = 0; i < data.length; i += 4, p += 1) {
+    const key = colorKey(data[i], data[i + 1], data[i + 2]);
+    let index = cache[key];
+    if (index < 0) {
+      const r = data[i];
+      const g = data[i + 1];
+      const b = data[i + 2];
+      let best = Infinity;
+      palette.forEach(([pr, pg, pb], candidate) => {
+        const distance = (r - pr) ** 2 + (g - pg) ** 2 + (b - pb) ** 2;
+        if (distance < best) {
+          best = distance;
+          index = candidate;
+        }
+      });
+      cache[key] = index;
+    }
+    indices[p] = index;
+  }
+  return indices;
+}
+
+/**
+ * LZW-compress palette indices into GIF image data sub-blocks
+ * @param {Uint8Array} indices - Palette indices
+ * @returns {Buffer} Minimum code size byte, sub-blocks and terminator
+ */
+function lzwEncode(indices) {
+  const minCodeSize = 8;
+  const clearCode = 1 << minCodeSize;
+  const endCode = clearCode + 1;
+  const bytes = [];
+  let codeSize = minCodeSize + 1;
+  let nextCode = endCode + 1;
+  let dictionary = new Map();
+  let bitBuffer = 0;
+  let bitCount = 0;
+
+  const write = (code) => {
+    bitBuffer |= code << bitCount;
+    bitCount += codeSize;
+    while (bitCount >= 8) {
+      bytes.push(bitBuffer & 0xff);
+      bitBuffer >>>= 8;
+      bitCount -= 8;
+    }
+  };
+
+  write(clearCode);
+  let prefix = indices[0];
+  for (let i = 1; i < indices.length; i += 1) {
+    const k = indices[i];
+    const key = (prefix << 8) | k;
+    const existing = dictionary.get(key);
+    if (existing !== undefined) {
+      prefix = existing;
+      continue;
+    }
+
+    write(prefix);
+    if (nextCode <= MAX_CODE) {
+      dictionary.set(key, nextCode);
+      if (nextCode === 1 << codeSize && codeSize < 12) {
+        codeSize += 1;
+      }
+      nextCode += 1;
+    } else {
+      write(clearCode);
+      dictionary = new Map();
+      codeSize = minCodeSize + 1;
+      nextCode = endCode + 1;
+    }
+    prefix = k;
+  }
+  write(prefix);
+  write(endCode);
+  if (bitCount > 0) {
+    bytes.push(bitBuffer & 0xff);
+  }
+
+  const blocks = [Buffer.from([minCodeSize])];
+  for (let i = 0; i < bytes.length; i += 255) {
+    const block = bytes.slice(i, i + 255);
+    blocks.push(Buffer.from([block.length, ...block]));
+  }
+  blocks.push(Buffer.from([0]));
+  return Buffer.concat(blocks);
+}
+
+/**
+ * Encode decoded frames into a looping animated GIF
+ * @param {Array<Object>} frames - { width, height, data: RGBA Buffer, delayMs }; all the same size
+ * @param {Object} options - Encoder options
+ * @param {number} options.loop - Loop count, 0 loops forever
+ * @returns {Buffer} GIF file
+ */
+function encodeGif(frames, { loop = 0 } = {}) {
+  const { width, height } = frames[0];
+  const palette = buildPalette(frames);
+  const cache = new Int16Array(32768).fill(-1);
+
+  const header = Buffer.alloc(13);
+  header.write('GIF89a', 0, 'ascii');
+  header.writeUInt16LE(width, 6);
+  header.writeUInt16LE(height, 8);
+  header[10] = 0xf7; // global color table, 8 bits per color, 256 entries
+  const colorTable = Buffer.from(palette.flat());
+
+  // NETSCAPE2.0 application extension for looping
+  const loopExtension = Buffer.from([0x21, 0xff, 0x0b, ...Buffer.from('NETSCAPE2.0'), 0x03, 0x01, loop & 0xff, loop >> 8, 0x00]);
+
+  const parts = [header, colorTable, loopExtension];
+  frames.forEach(frame => {
+    const delay = Math.max(2, Math.round((frame.delayMs || 100) / 10));
+    parts.push(Buffer.from([0x21, 0xf9, 0x04, 0x04, delay & 0xff, delay >> 8, 0x00, 0x00]));
+
+    const descriptor = Buffer.alloc(10);
+    descriptor[0] = 0x2c;
+    descriptor.writeUInt16LE(width, 5);
+    descriptor.writeUInt16LE(height, 7);
+    parts.push(descriptor, lzwEncode(indexPixels(frame.data, palette, cache)));
+  });
+  parts.push(Buffer.from([0x3b]));
+
+  return Buffer.concat(parts);
+}
+
+module.exports = {
+  encodeGif
+};
@@ -0,0 +1,39 @@
+// Results of the latest health probes (/health, /ready, /selftest), newest
+// last, for GET /healthz/history
+
+/**
+ * Number of probe results kept, from HEALTH_HISTORY_SIZE
+ * @returns {number} History size
+ */
+const getHistorySize = () => parseInt(process.env.HEALTH_HISTORY_SIZE, 10) || 50;
+
+const entries = [];
+
+/**
+ * Record a probe result, dropping the oldest beyond the history size
+ * @param {string} probe - "health", "ready" or "selftest"
+ * @param {Object} result - { ok, ...details }
+ */
+function recordProbe(probe, result) {
+  entries.push({ probe, timestamp: new Date().toISOString(), ...result });
+  const excess = entries.length - getHistorySize();
+  if (excess > 0) {
+    entries.splice(0, excess);
+  }
+}
+
+/**
+ * Latest probe results
+ * @param {Object} [filter] - { probe, limit }
+ * @returns {Array<Object>} Results, oldest first
+ */
+function getProbeHistory({ probe, limit } = {}) {
+  const matching = probe ? entries.filter(entry => entry.probe === probe) : entries;
+  return limit ? matching.slice(-limit) : matching.slice();
+}
+
+module.exports = {
+  recordProbe,
+  getProbeHistory,
+  getHistorySize
+};
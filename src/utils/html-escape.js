@@ -0,0 +1,27 @@
+// Escaping of text placed in HTML markup and attribute values
+
+const HTML_ESCAPES = {
+  '&': '&amp;',
+  '<': '&lt;',
+  '>': '&gt;',
+  '"': '&quot;',
+  "'": '&#39;'
+};
+const HTML_ESCAPE_PATTERN = /[&<>"']/g;
+const HTML_ESCAPE_TEST = /[&<>"']/;
+
+/**
+ * Escape HTML special characters in a single pass
+ * @param {string} text - Raw text
+ * @returns {string} Escaped text
+ */
+function escapeHTML(text) {
+  if (!HTML_ESCAPE_TEST.test(text)) {
+    return text;
+  }
+  return text.replace(HTML_ESCAPE_PATTERN, char => HTML_ESCAPES[char]);
+}
+
+module.exports = {
+  escapeHTML
+};
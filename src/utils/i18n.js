@@ -0,0 +1,357 @@
+// Localized system strings of the rendered app (header status, input bar,
+// receipts, media previews, chat list, notification labels and the contact
+// info screen), selected by options.locale. Message content is never
+// translated.
+
+const STRINGS = {
+  en: {
+    lastSeenToday: 'last seen today at {time}',
+    online: 'online',
+    typing: 'typing…',
+    messagePlaceholder: 'Message',
+    adminOnly: 'Only community admins can send messages',
+    statusPending: 'Pending',
+    statusSent: 'Sent',
+    statusDelivered: 'Delivered',
+    statusRead: 'Read',
+    statusFailed: 'Not sent',
+    photo: 'Photo',
+    video: 'Video',
+    document: 'Document',
+    sticker: 'Sticker',
+    voiceMessage: 'Voice message',
+    location: 'Location',
+    chats: 'Chats',
+    edit: 'Edit',
+    archived: 'Archived',
+    reply: 'Reply',
+    markAsRead: 'Mark as read',
+    moreNotification: '1 more notification',
+    moreNotifications: '{count} more notifications',
+    messageCount: '{count} messages',
+    aboutDefault: 'Hey there! I am using WhatsApp.',
+    groupMember: 'Group · 1 member',
+    groupMembers: 'Group · {count} members',
+    member: '1 member',
+    members: '{count} members',
+    audioCall: 'Audio',
+    videoCall: 'Video',
+    addMember: 'Add',
+    search: 'Search',
+    mediaLinksDocs: 'Media, links and docs',
+    muteNotifications: 'Mute notifications',
+    muted: 'Muted',
+    off: 'Off',
+    disappearingMessages: 'Disappearing messages',
+    disappearing24h: '24 hours',
+    disappearing7d: '7 days',
+    disappearing90d: '90 days',
+    encryption: 'Encryption',
+    encryptionDetail: 'Messages and calls are end-to-end encrypted. Tap to verify.',
+    createdBy: 'Created by {name}',
+    groupAdmin: 'Group admin',
+    exitGroup: 'Exit group',
+    reportGroup: 'Report group',
+    blockContact: 'Block {name}',
+    reportContact: 'Report {name}',
+    back: 'Back'
+  },
+  id: {
+    lastSeenToday: 'terakhir dilihat hari ini pukul {time}',
+    online: 'online',
+    typing: 'sedang mengetik…',
+    messagePlaceholder: 'Ketik pesan',
+    adminOnly: 'Hanya admin komunitas yang dapat mengirim pesan',
+    statusPending: 'Tertunda',
+    statusSent: 'Terkirim',
+    statusDelivered: 'Diterima',
+    statusRead: 'Dibaca',
+    statusFailed: 'Tidak terkirim',
+    photo: 'Foto',
+    video: 'Video',
+    document: 'Dokumen',
+    sticker: 'Stiker',
+    voiceMessage: 'Pesan suara',
+    location: 'Lokasi',
+    chats: 'Chat',
+    edit: 'Edit',
+    archived: 'Diarsipkan',
+    reply: 'Balas',
+    markAsRead: 'Tandai dibaca',
+    moreNotification: '1 notifikasi lainnya',
+    moreNotifications: '{count} notifikasi lainnya',
+    messageCount: '{count} pesan',
+    aboutDefault: 'Hai! Saya menggunakan WhatsApp.',
+    groupMember: 'Grup · 1 anggota',
+    groupMembers: 'Grup · {count} anggota',
+    member: '1 anggota',
+    members: '{count} anggota',
+    audioCall: 'Audio',
+    videoCall: 'Video',
+    addMember: 'Tambah',
+    search: 'Cari',
+    mediaLinksDocs: 'Media, tautan, dan dokumen',
+    muteNotifications: 'Bisukan notifikasi',
+    muted: 'Dibisukan',
+    off: 'Nonaktif',
+    disappearingMessages: 'Pesan sementara',
+    disappearing24h: '24 jam',
+    disappearing7d: '7 hari',
+    disappearing90d: '90 hari',
+    encryption: 'Enkripsi',
+    encryptionDetail: 'Pesan dan panggilan terenkripsi secara end-to-end. Ketuk untuk memverifikasi.',
+    createdBy: 'Dibuat oleh {name}',
+    groupAdmin: 'Admin grup',
+    exitGroup: 'Keluar dari grup',
+    reportGroup: 'Laporkan grup',
+    blockContact: 'Blokir {name}',
+    reportContact: 'Laporkan {name}',
+    back: 'Kembali'
+  },
+  es: {
+    lastSeenToday: 'últ. vez hoy a las {time}',
+    online: 'en línea',
+    typing: 'escribiendo…',
+    messagePlaceholder: 'Mensaje',
+    adminOnly: 'Solo los administradores de la comunidad pueden enviar mensajes',
+    statusPending: 'Pendiente',
+    statusSent: 'Enviado',
+    statusDelivered: 'Entregado',
+    statusRead: 'Leído',
+    statusFailed: 'No enviado',
+    photo: 'Foto',
+    video: 'Video',
+    document: 'Documento',
+    sticker: 'Sticker',
+    voiceMessage: 'Mensaje de voz',
+    location: 'Ubicación',
+    chats: 'Chats',
+    edit: 'Editar',
+    archived: 'Archivados',
+    reply: 'Responder',
+    markAsRead: 'Marcar como leído',
+    moreNotification: '1 notificación más',
+    moreNotifications: '{count} notificaciones más',
+    messageCount: '{count} mensajes',
+    aboutDefault: '¡Hola! Estoy usando WhatsApp.',
+    groupMember: 'Grupo · 1 miembro',
+    groupMembers: 'Grupo · {count} miembros',
+    member: '1 miembro',
+    members: '{count} miembros',
+    audioCall: 'Audio',
+    videoCall: 'Video',
+    addMember: 'Añadir',
+    search: 'Buscar',
+    mediaLinksDocs: 'Archivos, enlaces y documentos',
+    muteNotifications: 'Silenciar notificaciones',
+    muted: 'Silenciado',
+    off: 'Desactivado',
+    disappearingMessages: 'Mensajes temporales',
+    disappearing24h: '24 horas',
+    disappearing7d: '7 días',
+    disappearing90d: '90 días',
+    encryption: 'Cifrado',
+    encryptionDetail: 'Los mensajes y las llamadas están cifrados de extremo a extremo. Toca para verificar.',
+    createdBy: 'Creado por {name}',
+    groupAdmin: 'Admin. del grupo',
+    exitGroup: 'Salir del grupo',
+    reportGroup: 'Reportar grupo',
+    blockContact: 'Bloquear a {name}',
+    reportContact: 'Reportar a {name}',
+    back: 'Atrás'
+  },
+  'pt-BR': {
+    lastSeenToday: 'visto por último hoje às {time}',
+    online: 'online',
+    typing: 'digitando…',
+    messagePlaceholder: 'Mensagem',
+    adminOnly: 'Somente admins da comunidade podem enviar mensagens',
+    statusPending: 'Pendente',
+    statusSent: 'Enviada',
+    statusDelivered: 'Entregue',
+    statusRead: 'Lida',
+    statusFailed: 'Não enviada',
+    photo: 'Foto',
+    video: 'Vídeo',
+    document: 'Documento',
+    sticker: 'Figurinha',
+    voiceMessage: 'Mensagem de voz',
+    location: 'Localização',
+    chats: 'Conversas',
+    edit: 'Editar',
+    archived: 'Arquivadas',
+    reply: 'Responder',
+    markAsRead: 'Marcar como lida',
+    moreNotification: 'mais 1 notificação',
+    moreNotifications: 'mais {count} notificações',
+    messageCount: '{count} mensagens',
+    aboutDefault: 'Olá! Eu estou usando o WhatsApp.',
+    groupMember: 'Grupo · 1 membro',
+    groupMembers: 'Grupo · {count} membros',
+    member: '1 membro',
+    members: '{count} membros',
+    audioCall: 'Áudio',
+    videoCall: 'Vídeo',
+    addMember: 'Adicionar',
+    search: 'Pesquisar',
+    mediaLinksDocs: 'Mídia, links e docs',
+    muteNotifications: 'Silenciar notificações',
+    muted: 'Silenciado',
+    off: 'Desativado',
+    disappearingMessages: 'Mensagens temporárias',
+    disappearing24h: '24 horas',
+    disappearing7d: '7 dias',
+    disappearing90d: '90 dias',
+    encryption: 'Criptografia',
+    encryptionDetail: 'As mensagens e ligações são protegidas com a criptografia de ponta a ponta. Toque para verificar.',
+    createdBy: 'Criado por {name}',
+    groupAdmin: 'Admin do grupo',
+    exitGroup: 'Sair do grupo',
+    reportGroup: 'Denunciar grupo',
+    blockContact: 'Bloquear {name}',
+    reportContact: 'Denunciar {name}',
+    back: 'Voltar'
+  },
+  ar: {
+    lastSeenToday: 'آخر ظهور اليوم في {time}',
+    online: 'متصل الآن',
+    typing: 'يكتب…',
+    messagePlaceholder: 'مراسلة',
+    adminOnly: 'يمكن لمشرفي المجتمع فقط إرسال الرسائل',
+    statusPending: 'قيد الانتظار',
+    statusSent: 'تم الإرسال',
+    statusDelivered: 'تم التسليم',
+    statusRead: 'تمت القراءة',
+    statusFailed: 'لم يتم الإرسال',
+    photo: 'صورة',
+    video: 'فيديو',
+    document: 'مستند',
+    sticker: 'ملصق',
+    voiceMessage: 'رسالة صوتية',
+    location: 'الموقع',
+    chats: 'الدردشات',
+    edit: 'تعديل',
+    archived: 'المؤرشفة',
+    reply: 'رد',
+    markAsRead: 'تمييز كمقروءة',
+    moreNotification: 'إشعار آخر',
+    moreNotifications: '{count} إشعارات أخرى',
+    messageCount: '{count} رسائل',
+    aboutDefault: 'مرحبًا! أنا أستخدم واتساب.',
+    groupMember: 'مجموعة · عضو واحد',
+    groupMembers: 'مجموعة · {count} أعضاء',
+    member: 'عضو واحد',
+    members: '{count} أعضاء',
+    audioCall: 'صوت',
+    videoCall: 'فيديو',
+    addMember: 'إضافة',
+    search: 'بحث',
+    mediaLinksDocs: 'الوسائط والروابط والمستندات',
+    muteNotifications: 'كتم الإشعارات',
+    muted: 'مكتوم',
+    off: 'متوقف',
+    disappearingMessages: 'الرسائل المؤقتة',
+    disappearing24h: '24 ساعة',
+    disappearing7d: '7 أيام',
+    disappearing90d: '90 يومًا',
+    encryption: 'التشفير',
+    encryptionDetail: 'الرسائل والمكالمات مشفرة تمامًا بين الطرفين. انقر للتحقق.',
+    createdBy: 'أنشأها {name}',
+    groupAdmin: 'مشرف المجموعة',
+    exitGroup: 'الخروج من المجموعة',
+    reportGroup: 'الإبلاغ عن المجموعة',
+    blockContact: 'حظر {name}',
+    reportContact: 'الإبلاغ عن {name}',
+    back: 'رجوع'
+  }
+};
+
+// Languages written right to left
+const RTL_LANGUAGES = new Set(['ar', 'he', 'fa', 'ur']);
+
+// Bubble and header times without an explicit locale, as before locales were
+// supported
+const LEGACY_TIME_LOCALE = 'id-ID';
+
+// Locale of the times when only options.timeFormat is set, matching the
+// English system strings
+const FALLBACK_TIME_LOCALE = 'en-US';
+
+// Clock styles of options.timeFormat: "3:04 PM" or "15:04"
+const TIME_FORMATS = {
+  '12h': { hour: 'numeric', minute: '2-digit', hour12: true },
+  '24h': { hour: '2-digit', minute: '2-digit', hourCycle: 'h23' }
+};
+
+/**
+ * Bundle of a locale: the exact locale, then its language, then English
+ * @param {string} [locale] - BCP 47 locale, e.g. "pt-BR" or "es-MX"
+ * @returns {Object} Strings
+ */
+function getStrings(locale) {
+  if (!locale) {
+    return STRINGS.en;
+  }
+  const exact = Object.keys(STRINGS).find(key => key.toLowerCase() === locale.toLowerCase());
+  const language = locale.split('-')[0].toLowerCase();
+  return STRINGS[exact] || STRINGS[language] || STRINGS.en;
+}
+
+/**
+ * Localized system string
+ * @param {string} [locale] - BCP 47 locale
+ * @param {string} key - String key, e.g. "lastSeenToday"
+ * @param {Object} [params] - Values for {name} placeholders
+ * @returns {string} Text
+ */
+function translate(locale, key, params = {}) {
+  const text = getStrings(locale)[key] || STRINGS.en[key] || key;
+  return text.replace(/\{(\w+)\}/g, (match, name) => (params[name] !== undefined ? params[name] : match));
+}
+
+/**
+ * Whether a locale's language is written right to left
+ * @param {string} [locale] - BCP 47 locale
+ * @returns {boolean} True for Arabic, Hebrew, Persian and Urdu
+ */
+const isRtlLocale = (locale) => Boolean(locale) && RTL_LANGUAGES.has(locale.split('-')[0].toLowerCase());
+
+// Creating an Intl formatter is far more expensive than using one, so time
+// formatters are cached per locale and time zone
+const timeFormatters = new Map();
+
+/**
+ * Clock time formatter: hours and minutes in the locale's own style (12 or
+ * 24 hours, digits), or in the clock style of timeFormat. Without either, the
+ * legacy 12-hour id-ID format.
+ * @param {string} [locale] - BCP 47 locale
+ * @param {string} [timeZone] - IANA time zone, the process's by default
+ * @param {string} [timeFormat] - "12h" or "24h", the locale's own by default
+ * @returns {Intl.DateTimeFormat} Formatter
+ */
+function getTimeFormatter(locale, timeZone, timeFormat) {
+  const key = `${locale || ''}|${timeZone || ''}|${timeFormat || ''}`;
+  if (!timeFormatters.has(key)) {
+    const clock = TIME_FORMATS[timeFormat]
+      || { hour: '2-digit', minute: '2-digit', ...(!locale && { hour12: true }) };
+    const formatLocale = locale || (TIME_FORMATS[timeFormat] ? FALLBACK_TIME_LOCALE : LEGACY_TIME_LOCALE);
+    let formatter;
+    try {
+      formatter = new Intl.DateTimeFormat(formatLocale, { ...clock, ...(timeZone && { timeZone }) });
+    } catch (error) {
+      // Locales the runtime doesn't know fall back to the legacy format
+      formatter = new Intl.DateTimeFormat(LEGACY_TIME_LOCALE, { hour: '2-digit', minute: '2-digit', hour12: true, ...clock, ...(timeZone && { timeZone }) });
+    }
+    timeFormatters.set(key, formatter);
+  }
+  return timeFormatters.get(key);
+}
+
+module.exports = {
+  translate,
+  getStrings,
+  isRtlLocale,
+  getTimeFormatter,
+  TIME_FORMATS,
+  SUPPORTED_LOCALES: Object.keys(STRINGS)
+};
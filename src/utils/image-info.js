@@ -0,0 +1,55 @@
+// Read image dimensions straight from the encoded header, without decoding
+
+/**
+ * Get the pixel dimensions of a PNG, JPEG or WebP image
+ * @param {Buffer} buffer - Encoded image
+ * @returns {Object|null} { width, height }, or null if the format is unknown
+ */
+function getImageDimensions(buffer) {
+  if (!Buffer.isBuffer(buffer) || buffer.length < 24) {
+    return null;
+  }
+
+  // PNG: IHDR is always the first chunk
+  if (buffer.readUInt32BE(0) === 0x89504e47) {
+    return { width: buffer.readUInt32BE(16), height: buffer.readUInt32BE(20) };
+  }
+
+  // JPEG: walk the segments until a start-of-frame marker
+  if (buffer[0] === 0xff && buffer[1] === 0xd8) {
+    let offset = 2;
+    while (offset + 9 < buffer.length) {
+      if (buffer[offset] !== 0xff) {
+        return null;
+      }
+      const marker = buffer[offset + 1];
+      const isStartOfFrame = marker >= 0xc0 && marker <= 0xcf && ![0xc4, 0xc8, 0xcc].includes(marker);
+      if (isStartOfFrame) {
+        return { width: buffer.readUInt16BE(offset + 7), height: buffer.readUInt16BE(offset + 5) };
+      }
+      offset += 2 + buffer.readUInt16BE(offset + 2);
+    }
+    return null;
+  }
+
+  // WebP: lossy (VP8), lossless (VP8L) or extended (VP8X)
+  if (buffer.toString('ascii', 0, 4) === 'RIFF' && buffer.toString('ascii', 8, 12) === 'WEBP' && buffer.length >= 30) {
+    const chunk = buffer.toString('ascii', 12, 16);
+    if (chunk === 'VP8 ') {
+      return { width: buffer.readUInt16LE(26) & 0x3fff, height: buffer.readUInt16LE(28) & 0x3fff };
+    }
+    if (chunk === 'VP8L') {
+      const bits = buffer.readUInt32LE(21);
+      return { width: (bits & 0x3fff) + 1, height: ((bits >> 14) & 0x3fff) + 1 };
+    }
+    if (chunk === 'VP8X') {
+      return { width: buffer.readUIntLE(24, 3) + 1, height: buffer.readUIntLE(27, 3) + 1 };
+    }
+  }
+
+  return null;
+}
+
+module.exports = {
+  getImageDimensions
+};
@@ -0,0 +1,126 @@
+// Removal of metadata from rendered outputs for privacy-sensitive
+// distribution: text and EXIF/XMP chunks, timestamps, color profiles and
+// producer tags. Only what is needed to decode the image is kept.
+
+const PNG_KEEP_CHUNKS = new Set(['IHDR', 'PLTE', 'tRNS', 'IDAT', 'IEND']);
+const PDF_INFO_KEYS = ['Producer', 'Creator', 'CreationDate', 'ModDate', 'Author', 'Title', 'Subject', 'Keywords'];
+
+/**
+ * Keep only the PNG chunks needed to decode the image
+ * @param {Buffer} png - PNG file
+ * @returns {Buffer} PNG without ancillary metadata chunks
+ */
+function stripPng(png) {
+  const parts = [png.subarray(0, 8)];
+  for (let offset = 8; offset + 12 <= png.length;) {
+    const length = png.readUInt32BE(offset);
+    const type = png.toString('ascii', offset + 4, offset + 8);
+    const end = offset + 12 + length;
+    if (PNG_KEEP_CHUNKS.has(type)) {
+      parts.push(png.subarray(offset, end));
+    }
+    offset = end;
+    if (type === 'IEND') {
+      break;
+    }
+  }
+  return Buffer.concat(parts);
+}
+
+/**
+ * Remove APP1-APP15 (EXIF, XMP, ICC, ...) and comment segments from a JPEG.
+ * APP0 is kept only when it is a plain JFIF header.
+ * @param {Buffer} jpeg - JPEG file
+ * @returns {Buffer} JPEG without metadata segments
+ */
+function stripJpeg(jpeg) {
+  const parts = [jpeg.subarray(0, 2)];
+  let offset = 2;
+  while (offset + 4 <= jpeg.length && jpeg[offset] === 0xff) {
+    const marker = jpeg[offset + 1];
+    // Start of scan: the rest is entropy-coded image data
+    if (marker === 0xda) {
+      break;
+    }
+    const end = offset + 2 + jpeg.readUInt16BE(offset + 2);
+    const isApp = marker >= 0xe0 && marker <= 0xef;
+    const isJfif = marker === 0xe0 && jpeg.toString('ascii', offset + 4, offset + 9) === 'JFIF\0';
+    if ((!isApp || isJfif) && marker !== 0xfe) {
+      parts.push(jpeg.subarray(offset, end));
+    }
+    offset = end;
+  }
+  parts.push(jpeg.subarray(offset));
+  return Buffer.concat(parts);
+}
+
+/**
+ * Remove EXIF, XMP and ICC chunks from a WebP and clear their VP8X flags
+ * @param {Buffer} webp - WebP file
+ * @returns {Buffer} WebP without metadata chunks
+ */
+function stripWebp(webp) {
+  const chunks = [];
+  for (let offset = 12; offset + 8 <= webp.length;) {
+    const type = webp.toString('ascii', offset, offset + 4);
+    const size = webp.readUInt32LE(offset + 4);
+    const end = offset + 8 + size + (size % 2);
+    if (!['EXIF', 'XMP ', 'ICCP'].includes(type)) {
+      const chunk = Buffer.from(webp.subarray(offset, end));
+      if (type === 'VP8X') {
+        // Flags byte: ICC (0x20), EXIF (0x08), XMP (0x04)
+        chunk[8] &= ~(0x20 | 0x08 | 0x04);
+      }
+      chunks.push(chunk);
+    }
+    offset = end;
+  }
+
+  const body = Buffer.concat(chunks);
+  const header = Buffer.from(webp.subarray(0, 12));
+  header.writeUInt32LE(body.length + 4, 4);
+  return Buffer.concat([header, body]);
+}
+
+/**
+ * Blank the values of the PDF document information entries (producer,
+ * dates, ...). Values are overwritten in place with spaces of the same
+ * length, so the cross-reference offsets stay valid.
+ * @param {Buffer} pdf - PDF file
+ * @returns {Buffer} PDF with blank information values
+ */
+function stripPdf(pdf) {
+  const text = pdf.toString('latin1');
+  const pattern = new RegExp(`/(${PDF_INFO_KEYS.join('|')})\\s*(\\((?:\\\\.|[^\\\\)])*\\)|<[0-9A-Fa-f\\s]*>)`, 'g');
+  const output = text.replace(pattern, (match, key, value) => {
+    const blank = value.startsWith('(')
+      ? `(${' '.repeat(value.length - 2)})`
+      : `<${' '.repeat(value.length - 2)}>`;
+    return match.slice(0, match.length - value.length) + blank;
+  });
+  return Buffer.from(output, 'latin1');
+}
+
+/**
+ * Remove metadata from an output
+ * @param {Buffer} buffer - Encoded output
+ * @param {string} format - png, jpeg, webp or pdf; other formats are returned unchanged
+ * @returns {Buffer} Output without metadata
+ */
+function stripMetadata(buffer, format) {
+  switch (format) {
+    case 'png': return stripPng(buffer);
+    case 'jpeg': return stripJpeg(buffer);
+    case 'webp': return stripWebp(buffer);
+    case 'pdf': return stripPdf(buffer);
+    default: return buffer;
+  }
+}
+
+module.exports = {
+  stripMetadata,
+  stripPng,
+  stripJpeg,
+  stripWebp,
+  stripPdf
+};
@@ -0,0 +1,49 @@
+// Post-capture stitching of decoded images onto one canvas
+
+/**
+ * Parse a #rrggbb color
+ * @param {string} hex - Color
+ * @returns {Array<number>} [r, g, b, a]
+ */
+function parseColor(hex) {
+  const value = parseInt(hex.replace('#', ''), 16);
+  return [(value >> 16) & 0xff, (value >> 8) & 0xff, value & 0xff, 255];
+}
+
+/**
+ * Alpha-composite images onto a new canvas
+ * @param {Object} canvas - { width, height, background: "#rrggbb" }
+ * @param {Array<Object>} layers - { image: { width, height, data }, x, y }
+ * @returns {Object} { width, height, data: RGBA Buffer }
+ */
+function stitchImages({ width, height, background = '#ffffff' }, layers) {
+  const data = Buffer.alloc(width * height * 4);
+  const [r, g, b, a] = parseColor(background);
+  for (let i = 0; i < data.length; i += 4) {
+    data[i] = r;
+    data[i + 1] = g;
+    data[i + 2] = b;
+    data[i + 3] = a;
+  }
+
+  layers.forEach(({ image, x: left, y: top }) => {
+    const rows = Math.min(image.height, height - top);
+    const cols = Math.min(image.width, width - left);
+    for (let y = 0; y < rows; y += 1) {
+      for (let x = 0; x < cols; x += 1) {
+        const source = (y * image.width + x) * 4;
+        const target = ((top + y) * width + left + x) * 4;
+        const alpha = image.data[source + 3] / 255;
+        for (let c = 0; c < 3; c += 1) {
+          data[target + c] = Math.round(image.data[source + c] * alpha + data[target + c] * (1 - alpha));
+        }
+      }
+    }
+  });
+
+  return { width, height, data };
+}
+
+module.exports = {
+  stitchImages
+};
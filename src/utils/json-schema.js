@@ -0,0 +1,264 @@
+// JSON Schema and TypeScript generation from the Joi request schemas, so
+// clients can build payloads against the same rules the server validates
+
+const JSON_SCHEMA_DIALECT = 'https://json-schema.org/draft/2020-12/schema';
+
+/**
+ * Convert a Joi regex description ("/^a+$/i", or { regex }) to a JSON Schema pattern
+ * @param {string|Object} regex - Described regex
+ * @returns {string} Pattern
+ */
+function toPattern(regex) {
+  const source = regex && typeof regex === 'object' ? regex.regex || regex.source : regex;
+  const match = /^\/(.*)\/[a-z]*$/s.exec(String(source));
+  return match ? match[1] : String(source);
+}
+
+/**
+ * Unwrap a described literal; Joi describes object and array values as { value }
+ * @param {*} value - Described value
+ * @returns {*} Literal
+ */
+function unwrapValue(value) {
+  return value && typeof value === 'object' && !Array.isArray(value) && Object.keys(value).length === 1 && 'value' in value
+    ? value.value
+    : value;
+}
+
+/**
+ * Literal default values only; Joi describes generated defaults (e.g. an
+ * empty .default() on objects) as { special } objects
+ * @param {*} value - flags.default
+ * @returns {boolean} Whether the default can be published
+ */
+function isLiteralDefault(value) {
+  return value !== undefined && typeof value !== 'function' && !(value && typeof value === 'object' && value.special);
+}
+
+/**
+ * Apply Joi rules to a JSON Schema node
+ * @param {Object} node - JSON Schema node (modified)
+ * @param {string} type - Joi type
+ * @param {Array<Object>} rules - Described rules
+ */
+function applyRules(node, type, rules = []) {
+  const limits = {
+    string: { min: 'minLength', max: 'maxLength', length: ['minLength', 'maxLength'] },
+    number: { min: 'minimum', max: 'maximum', greater: 'exclusiveMinimum', less: 'exclusiveMaximum' },
+    array: { min: 'minItems', max: 'maxItems', length: ['minItems', 'maxItems'] },
+    object: { min: 'minProperties', max: 'maxProperties', length: ['minProperties', 'maxProperties'] }
+  }[type] || {};
+
+  rules.forEach(({ name, args = {} }) => {
+    if (limits[name] !== undefined && typeof args.limit === 'number') {
+      [].concat(limits[name]).forEach(keyword => {
+        node[keyword] = args.limit;
+      });
+    } else if (name === 'pattern' && args.regex) {
+      node.pattern = toPattern(args.regex);
+    } else if (name === 'isoDate') {
+      node.format = 'date-time';
+    } else if (name === 'uri') {
+      node.format = 'uri';
+      const scheme = args.options && args.options.scheme;
+      if (scheme) {
+        node.description = [node.description, `Schemes: ${[].concat(scheme).join(', ')}`].filter(Boolean).join('. ');
+      }
+    } else if (name === 'email') {
+      node.format = 'email';
+    } else if (name === 'integer') {
+      node.type = 'integer';
+    } else if (name === 'unique') {
+      node.uniqueItems = true;
+    }
+  });
+}
+
+/**
+ * Convert a Joi schema description (schema.describe()) to JSON Schema
+ * @param {Object} description - Joi description
+ * @returns {Object} JSON Schema
+ */
+function describeToJsonSchema(description) {
+  const { type, flags = {}, allow = [] } = description;
+  let node;
+
+  switch (type) {
+    case 'object': {
+      node = { type: 'object' };
+      const keys = Object.entries(description.keys || {})
+        .filter(([, child]) => !(child.flags && child.flags.presence === 'forbidden'));
+      if (description.keys) {
+        node.properties = Object.fromEntries(keys.map(([name, child]) => [name, describeToJsonSchema(child)]));
+        const required = keys.filter(([, child]) => child.flags && child.flags.presence === 'required').map(([name]) => name);
+        if (required.length > 0) {
+          node.required = required;
+        }
+      }
+      // object().pattern(key, value): free-form keys
+      const pattern = (description.patterns || []).find(entry => entry.rule);
+      if (pattern) {
+        node.additionalProperties = describeToJsonSchema(pattern.rule);
+      } else if (description.keys) {
+        node.additionalProperties = flags.unknown === true;
+      }
+      // Each rule is its own allOf entry, so several rules can combine
+      const rules = (description.dependencies || []).map(({ rel, peers = [] }) => {
+        const names = peers.map(peer => (typeof peer === 'string' ? peer : peer.key || (peer.path || []).join('.')));
+        if (rel === 'xor') {
+          return { oneOf: names.map(name => ({ required: [name] })) };
+        }
+        if (rel === 'nand') {
+          return { not: { required: names } };
+        }
+        if (rel === 'oxor') {
+          // At most one: no pair of them together
+          return {
+            not: {
+              anyOf: names.flatMap((name, index) => names.slice(index + 1).map(other => ({ required: [name, other] })))
+            }
+          };
+        }
+        if (rel === 'or') {
+          return { anyOf: names.map(name => ({ required: [name] })) };
+        }
+        return null;
+      }).filter(Boolean);
+      if (rules.length === 1) {
+        Object.assign(node, rules[0]);
+      } else if (rules.length > 1) {
+        node.allOf = rules;
+      }
+      break;
+    }
+    case 'array':
+      node = { type: 'array' };
+      if (description.items && description.items.length === 1) {
+        node.items = describeToJsonSchema(description.items[0]);
+      } else if (description.items && description.items.length > 1) {
+        node.items = { anyOf: description.items.map(describeToJsonSchema) };
+      }
+      break;
+    case 'alternatives':
+      node = {
+        anyOf: (description.matches || []).flatMap(match => [match.schema, match.then, match.otherwise])
+          .filter(Boolean)
+          .map(describeToJsonSchema)
+      };
+      break;
+    case 'string':
+    case 'number':
+    case 'boolean':
+      node = { type };
+      break;
+    case 'date':
+      node = { type: 'string', format: 'date-time' };
+      break;
+    default:
+      node = {};
+  }
+
+  applyRules(node, type, description.rules);
+
+  if (flags.only) {
+    node.enum = allow.map(unwrapValue);
+  } else if (allow.includes(null) && node.type) {
+    node.type = [node.type, 'null'];
+  }
+  if (flags.description) {
+    node.description = node.description ? `${flags.description}. ${node.description}` : flags.description;
+  }
+  if (isLiteralDefault(flags.default)) {
+    node.default = unwrapValue(flags.default);
+  }
+  return node;
+}
+
+/**
+ * Build a JSON Schema document with one definition per named Joi schema
+ * @param {Object} models - Model name -> Joi schema
+ * @returns {Object} JSON Schema document with $defs
+ */
+function buildSchemaDocument(models) {
+  return {
+    $schema: JSON_SCHEMA_DIALECT,
+    $defs: Object.fromEntries(Object.entries(models)
+      .map(([name, schema]) => [name, { title: name, ...describeToJsonSchema(schema.describe()) }]))
+  };
+}
+
+/**
+ * Render a JSON Schema node as a TypeScript type
+ * @param {Object} node - JSON Schema node
+ * @param {string} indent - Current indentation
+ * @returns {string} TypeScript type
+ */
+function toTypeScriptType(node, indent = '') {
+  if (node.enum) {
+    return node.enum.map(value => JSON.stringify(value)).join(' | ');
+  }
+  if (node.anyOf && !node.type) {
+    return node.anyOf.map(option => toTypeScriptType(option, indent)).join(' | ');
+  }
+
+  const types = [].concat(node.type || []);
+  if (types.length > 1) {
+    return types.map(type => toTypeScriptType({ ...node, type }, indent)).join(' | ');
+  }
+  switch (types[0]) {
+    case 'string':
+      return 'string';
+    case 'number':
+    case 'integer':
+      return 'number';
+    case 'boolean':
+      return 'boolean';
+    case 'null':
+      return 'null';
+    case 'array': {
+      const item = node.items ? toTypeScriptType(node.items, indent) : 'unknown';
+      return /[|&\s]/.test(item) && !item.startsWith('{') ? `Array<${item}>` : `${item}[]`;
+    }
+    case 'object': {
+      const inner = `${indent}  `;
+      const required = new Set(node.required || []);
+      const lines = Object.entries(node.properties || {}).map(([name, child]) => {
+        const comment = child.description || child.default !== undefined
+          ? `${inner}/** ${[child.description, child.default !== undefined && `Default: ${JSON.stringify(child.default)}`]
+            .filter(Boolean).join('. ').replace(/\*\//g, '*\\/')} */\n`
+          : '';
+        const key = /^[A-Za-z_$][\w$]*$/.test(name) ? name : JSON.stringify(name);
+        return `${comment}${inner}${key}${required.has(name) ? '' : '?'}: ${toTypeScriptType(child, inner)};`;
+      });
+      if (node.additionalProperties && typeof node.additionalProperties === 'object') {
+        lines.push(`${inner}[key: string]: ${toTypeScriptType(node.additionalProperties, inner)};`);
+      }
+      return lines.length > 0 ? `{\n${lines.join('\n')}\n${indent}}` : 'Record<string, unknown>';
+    }
+    default:
+      return 'unknown';
+  }
+}
+
+/**
+ * Render TypeScript declarations for a JSON Schema document from
+ * buildSchemaDocument
+ * @param {Object} document - JSON Schema document
+ * @returns {string} TypeScript source
+ */
+function toTypeScript(document) {
+  const declarations = Object.entries(document.$defs).map(([name, node]) => {
+    const type = toTypeScriptType(node);
+    return type.startsWith('{')
+      ? `export interface ${name} ${type}`
+      : `export type ${name} = ${type};`;
+  });
+  return `// Generated from the API request schemas. Do not edit.\n\n${declarations.join('\n\n')}\n`;
+}
+
+module.exports = {
+  describeToJsonSchema,
+  buildSchemaDocument,
+  toTypeScript,
+  JSON_SCHEMA_DIALECT
+};
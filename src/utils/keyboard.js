@@ -0,0 +1,85 @@
+const { escapeHTML } = require('./html-escape');
+
+// Letter rows shared by both keyboards
+const LETTER_ROWS = ['qwertyuiop', 'asdfghjkl', 'zxcvbnm'];
+
+// Colors per keyboard style and theme
+const KEYBOARD_THEMES = {
+  ios: {
+    light: { background: '#d1d3d9', key: '#ffffff', special: '#acb0ba', text: '#000000', shadow: '#898a8d', accent: '#007aff' },
+    dark: { background: '#2b2b2d', key: '#6b6b6d', special: '#464648', text: '#ffffff', shadow: '#1a1a1a', accent: '#0a84ff' }
+  },
+  android: {
+    light: { background: '#eef0f6', key: '#ffffff', special: '#d3d8e6', text: '#202124', shadow: '#c2c6d0', accent: '#00a884' },
+    dark: { background: '#202124', key: '#3c4043', special: '#2d2e31', text: '#e8eaed', shadow: '#151618', accent: '#00a884' }
+  }
+};
+
+/**
+ * One key
+ * @param {string} label - Key label (HTML)
+ * @param {string} [className] - Extra class, e.g. "special" or "space"
+ * @returns {string} HTML
+ */
+const key = (label, className = '') => `<span class="kb-key${className ? ` ${className}` : ''}">${label}</span>`;
+
+/**
+ * Word suggestions shown above the Android keyboard: the last word of the
+ * draft and two completions of it
+ * @param {string} [draft] - Typed text
+ * @returns {Array<string>} Three suggestions
+ */
+function suggestionsFor(draft) {
+  const lastWord = (draft || '').trim().split(/\s+/).pop() || '';
+  return lastWord ? [lastWord, `${lastWord}s`, `${lastWord}!`] : ['I', 'The', 'OK'];
+}
+
+/**
+ * Render a phone keyboard below the input bar
+ * @param {Object} [keyboard] - options.keyboard { style, theme }
+ * @param {Object} [state] - { platform, theme, draft }; style defaults to the platform, then android, and theme to the chat's theme
+ * @returns {Object} { keyboard, keyboardStyle } template data, empty without a keyboard
+ */
+function renderKeyboard(keyboard, { platform, theme, draft } = {}) {
+  if (!keyboard) {
+    return { keyboard: '', keyboardStyle: '' };
+  }
+  const style = keyboard.style || platform || 'android';
+  const colors = KEYBOARD_THEMES[style][keyboard.theme || theme || 'light'];
+  const ios = style === 'ios';
+
+  const rows = [
+    LETTER_ROWS[0].split('').map(letter => key(letter)).join(''),
+    `<span class="kb-gap"></span>${LETTER_ROWS[1].split('').map(letter => key(letter)).join('')}<span class="kb-gap"></span>`,
+    `${key('&#8679;', 'special wide')}${LETTER_ROWS[2].split('').map(letter => key(letter)).join('')}${key('&#9003;', 'special wide')}`,
+    ios
+      ? `${key('123', 'special wide')}${key('&#9786;', 'special')}${key('space', 'space')}${key('return', 'special return')}`
+      : `${key('?123', 'special wide')}${key(',', 'special')}${key('&#9786;', 'special')}${key('', 'space')}${key('.', 'special')}${key('&#8629;', 'accent wide')}`
+  ];
+  const suggestions = ios
+    ? ''
+    : `<div class="kb-suggestions">${suggestionsFor(draft).map(word => `<span>${escapeHTML(word)}</span>`).join('')}</div>`;
+
+  return {
+    keyboard: `<div class="keyboard keyboard-${style}">${suggestions}${rows.map(row => `<div class="kb-row">${row}</div>`).join('')}</div>`,
+    keyboardStyle: `
+    .keyboard { background-color: ${colors.background}; padding: ${ios ? '8px 3px 34px' : '4px 4px 16px'}; user-select: none; }
+    .kb-suggestions { display: flex; justify-content: space-around; padding: 6px 0 8px; color: ${colors.text}; font-size: 15px; }
+    .kb-row { display: flex; justify-content: center; gap: ${ios ? 6 : 5}px; margin-bottom: ${ios ? 11 : 8}px; }
+    .kb-row:last-child { margin-bottom: 0; }
+    .kb-key { flex: 1; max-width: ${ios ? 32 : 34}px; height: ${ios ? 42 : 44}px; display: flex; align-items: center; justify-content: center;
+      border-radius: ${ios ? 5 : 6}px; background-color: ${colors.key}; color: ${colors.text}; font-size: ${ios ? 22 : 20}px;
+      box-shadow: 0 1px 0 ${colors.shadow}; }
+    .kb-key.special { background-color: ${colors.special}; font-size: 15px; }
+    .kb-key.wide { max-width: ${ios ? 42 : 46}px; flex: 1.3; }
+    .kb-key.space { max-width: none; flex: 5; font-size: 15px; }
+    .kb-key.return { max-width: 88px; flex: 2.2; }
+    .kb-key.accent { background-color: ${colors.accent}; color: #fff; border-radius: ${ios ? 5 : 22}px; }
+    .kb-gap { flex: 0.5; max-width: 16px; }`.trim()
+  };
+}
+
+module.exports = {
+  renderKeyboard,
+  KEYBOARD_THEMES
+};
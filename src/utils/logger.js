@@ -0,0 +1,86 @@
+// Structured logger. Writes one JSON object per line (LOG_FORMAT=json, the
+// default) or a readable single line (LOG_FORMAT=text). Child loggers carry
+// bound fields such as the request ID into every entry.
+
+const LEVELS = { debug: 10, info: 20, warn: 30, error: 40 };
+
+/**
+ * Serialize values that JSON.stringify would drop (errors) or choke on
+ * @param {*} value - Field value
+ * @returns {*} Serializable value
+ */
+function serializeField(value) {
+  if (value instanceof Error) {
+    return { name: value.name, message: value.message, stack: value.stack };
+  }
+  return value;
+}
+
+class Logger {
+  /**
+   * @param {Object} fields - Fields bound to every entry
+   */
+  constructor(fields = {}) {
+    this.fields = fields;
+  }
+
+  /**
+   * Create a logger that adds extra bound fields
+   * @param {Object} fields - Additional fields, e.g. { requestId }
+   * @returns {Logger} Child logger
+   */
+  child(fields = {}) {
+    return new Logger({ ...this.fields, ...fields });
+  }
+
+  /**
+   * Write a log entry
+   * @param {string} level - debug, info, warn or error
+   * @param {string} message - Log message
+   * @param {Object} fields - Entry-specific fields
+   */
+  log(level, message, fields = {}) {
+    const minLevel = LEVELS[(process.env.LOG_LEVEL || 'info').toLowerCase()] || LEVELS.info;
+    if (LEVELS[level] < minLevel) {
+      return;
+    }
+
+    const entry = { time: new Date().toISOString(), level, msg: message, ...this.fields };
+    Object.entries(fields).forEach(([key, value]) => {
+      entry[key] = serializeField(value);
+    });
+
+    const stream = LEVELS[level] >= LEVELS.warn ? process.stderr : process.stdout;
+    if ((process.env.LOG_FORMAT || 'json').toLowerCase() === 'text') {
+      const { time, level: lvl, msg, ...rest } = entry;
+      const extra = Object.keys(rest).length > 0 ? ` ${JSON.stringify(rest)}` : '';
+      stream.write(`[${time}] ${lvl.toUpperCase()} ${msg}${extra}\n`);
+    } else {
+      stream.write(`${JSON.stringify(entry)}\n`);
+    }
+  }
+
+  debug(message, fields) {
+    this.log('debug', message, fields);
+  }
+
+  info(message, fields) {
+    this.log('info', message, fields);
+  }
+
+  warn(message, fields) {
+    this.log('warn', message, fields);
+  }
+
+  error(message, fields) {
+    this.log('error', message, fields);
+  }
+}
+
+const logger = new Logger();
+
+module.exports = {
+  logger,
+  Logger,
+  LEVELS
+};
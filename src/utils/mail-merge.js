@@ -0,0 +1,74 @@
+const { ApiError } = require('../middleware/error.middleware');
+
+// Mail-merge batches: one request with {{name}} placeholders, rendered once
+// per variable set. Placeholders are filled in every text of the messages
+// and options.
+
+const PLACEHOLDER = /\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}/g;
+
+/**
+ * Names of the placeholders used anywhere in a value
+ * @param {*} value - String, array or object
+ * @param {Set<string>} [names] - Names found so far
+ * @returns {Set<string>} Placeholder names
+ */
+function findPlaceholders(value, names = new Set()) {
+  if (typeof value === 'string') {
+    for (const match of value.matchAll(PLACEHOLDER)) {
+      names.add(match[1]);
+    }
+  } else if (Array.isArray(value)) {
+    value.forEach(item => findPlaceholders(item, names));
+  } else if (value && typeof value === 'object') {
+    Object.values(value).forEach(item => findPlaceholders(item, names));
+  }
+  return names;
+}
+
+/**
+ * Replace the placeholders of a value with the variables
+ * @param {*} value - String, array or object; other values are returned as is
+ * @param {Object} variables - Placeholder name -> value
+ * @returns {*} Copy of the value with the placeholders filled in
+ */
+function fillPlaceholders(value, variables) {
+  if (typeof value === 'string') {
+    return value.replace(PLACEHOLDER, (match, name) => String(variables[name]));
+  }
+  if (Array.isArray(value)) {
+    return value.map(item => fillPlaceholders(item, variables));
+  }
+  if (value && typeof value === 'object') {
+    return Object.fromEntries(Object.entries(value).map(([key, item]) => [key, fillPlaceholders(item, variables)]));
+  }
+  return value;
+}
+
+/**
+ * Expand a mail-merge request into one request per variable set. A set
+ * missing a placeholder the request uses gets an error instead of a request,
+ * so it fails on its own rather than failing the batch.
+ * @param {Object} request - Screenshot request body with placeholders
+ * @param {Array<Object>} variableSets - Placeholder name -> value, one object per output
+ * @returns {Array<Object>} { request, variables } or { error, variables }, in variable set order
+ */
+function expandMailMerge(request, variableSets) {
+  const placeholders = [...findPlaceholders(request)];
+  return variableSets.map((variables) => {
+    const missing = placeholders.filter(name => !Object.prototype.hasOwnProperty.call(variables, name));
+    if (missing.length > 0) {
+      return {
+        variables,
+        error: new ApiError(400, `Missing mail-merge variables: ${missing.join(', ')}`)
+          .annotate({ stage: 'validate', code: 'mail_merge_variable_missing' })
+      };
+    }
+    return { request: fillPlaceholders(request, variables), variables };
+  });
+}
+
+module.exports = {
+  expandMailMerge,
+  fillPlaceholders,
+  findPlaceholders
+};
@@ -0,0 +1,101 @@
+const { highlightCode } = require('./syntax-highlight');
+const { escapeHTML } = require('./html-escape');
+
+// Placeholders used to protect code spans/blocks from inline formatting
+const CODE_OPEN = '\uE020';
+const CODE_CLOSE = '\uE021';
+
+/**
+ * Apply inline Markdown formatting to an already escaped line
+ * @param {string} text - Escaped text
+ * @returns {string} HTML
+ */
+function formatInline(text) {
+  return text
+    // Links: [text](url) -> styled link text, only for safe schemes
+    .replace(/\[([^\]]+)\]\(([^)\s]+)(?:\s+&quot;[^&]*&quot;)?\)/g, (match, label, url) =>
+      /^(https?:|mailto:|tel:)/i.test(url)
+        ? `<a class="message-link" href="${url}">${label}</a>`
+        : `<span class="message-link">${label}</span>`)
+    // Bold: **text** or __text__
+    .replace(/\*\*([^*\n]+)\*\*/g, '<strong>$1</strong>')
+    .replace(/(?<!\w)__([^_\n]+)__(?!\w)/g, '<strong>$1</strong>')
+    // Italic: *text* or _text_
+    .replace(/(?<![*\w])\*([^*\s][^*\n]*?)\*(?![*\w])/g, '<em>$1</em>')
+    .replace(/(?<!\w)_([^_\s][^_\n]*?)_(?!\w)/g, '<em>$1</em>')
+    // Strikethrough: ~~text~~
+    .replace(/~~([^~\n]+)~~/g, '<del>$1</del>');
+}
+
+/**
+ * Convert CommonMark-style Markdown into the HTML used inside chat bubbles.
+ * Headings are reduced to bold lines, lists keep their markers, and block
+ * elements are flattened to line breaks so the output fits inside a bubble.
+ * @param {string} message - Raw Markdown content
+ * @returns {string} HTML content
+ */
+function convertMarkdownToHTML(message) {
+  if (!message || typeof message !== 'string') {
+    return '';
+  }
+
+  const codes = [];
+  const stash = (html) => {
+    codes.push(html);
+    return `${CODE_OPEN}${codes.length - 1}${CODE_CLOSE}`;
+  };
+
+  let text = message.replace(/\r\n/g, '\n');
+
+  // Fenced code blocks
+  text = text.replace(/^(```|~~~)([\w+#-]*)\n([\s\S]*?)\n?\1[ \t]*$/gm, (match, fence, lang, code) =>
+    stash(`<span class="code-block"><code>${highlightCode(code, lang)}</code></span>`));
+
+  // Inline code spans
+  text = text.replace(/`([^`\n]+)`/g, (match, code) => stash(`<code>${escapeHTML(code)}</code>`));
+
+  const lines = escapeHTML(text).split('\n').map(line => {
+    // Headings: strip the markers, keep the text emphasized
+    const heading = line.match(/^\s{0,3}#{1,6}\s+(.*?)\s*#*\s*$/);
+    if (heading) {
+      return `<strong>${formatInline(heading[1])}</strong>`;
+    }
+
+    // Horizontal rules are dropped
+    if (/^\s{0,3}([-*_])(\s*\1){2,}\s*$/.test(line)) {
+      return '';
+    }
+
+    // Block quotes
+    const quote = line.match(/^\s{0,3}&gt;\s?(.*)$/);
+    if (quote) {
+      return `<span class="message-quote">${formatInline(quote[1])}</span>`;
+    }
+
+    // Unordered lists: "- item" / "* item" / "+ item" -> bullet
+    const bullet = line.match(/^(\s*)[-*+]\s+(.*)$/);
+    if (bullet) {
+      return `${'&nbsp;'.repeat(bullet[1].length)}• ${formatInline(bullet[2])}`;
+    }
+
+    // Ordered lists keep their numbering
+    const ordered = line.match(/^(\s*)(\d+)[.)]\s+(.*)$/);
+    if (ordered) {
+      return `${'&nbsp;'.repeat(ordered[1].length)}${ordered[2]}. ${formatInline(ordered[3])}`;
+    }
+
+    return formatInline(line);
+  });
+
+  return lines
+    .join('<br>')
+    .replace(new RegExp(`${CODE_OPEN}(\\d+)${CODE_CLOSE}(<br>)?`, 'g'), (match, index, br) => {
+      const html = codes[index];
+      // Block-level code already breaks the line
+      return html.startsWith('<span class="code-block">') ? html : `${html}${br || ''}`;
+    });
+}
+
+module.exports = {
+  convertMarkdownToHTML
+};
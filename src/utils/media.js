@@ -0,0 +1,406 @@
+const http = require('http');
+const https = require('https');
+const { Readable } = require('stream');
+const { ApiError } = require('../middleware/error.middleware');
+const { assertUrlAllowed } = require('./url-guard');
+const { createProxyAgent } = require('./proxy');
+const { parseListEnv } = require('./env');
+const { escapeHTML } = require('./html-escape');
+const { convertToPlaceholderText } = require('./whatsapp-html');
+const { translate } = require('./i18n');
+
+// Message types with an attachment; everything else is a text message
+const MEDIA_TYPES = ['image', 'video', 'document', 'sticker', 'audio', 'location'];
+
+// MIME type prefixes each message type accepts for mediaUrl
+const ACCEPTED_MIME = {
+  image: ['image/'],
+  sticker: ['image/'],
+  video: ['video/'],
+  audio: ['audio/'],
+  document: ['application/', 'text/', 'image/'],
+  // The map thumbnail of a location
+  location: ['image/']
+};
+
+// Chat-list and notification text of a media message
+const MEDIA_LABELS = {
+  image: '&#128247; Photo',
+  video: '&#127909; Video',
+  document: '&#128196; Document',
+  sticker: 'Sticker',
+  audio: '&#127908; Voice message',
+  location: '&#128205; Location'
+};
+
+// Icon and i18n key of each media label
+const MEDIA_LABEL_PARTS = {
+  image: ['&#128247; ', 'photo'],
+  video: ['&#127909; ', 'video'],
+  document: ['&#128196; ', 'document'],
+  sticker: ['', 'sticker'],
+  audio: ['&#127908; ', 'voiceMessage'],
+  location: ['&#128205; ', 'location']
+};
+
+/**
+ * Preview label of a media type in a locale, e.g. "📷 Foto"
+ * @param {string} type - Media type
+ * @param {string} [locale] - BCP 47 locale; English by default
+ * @returns {string} HTML
+ */
+function getMediaLabel(type, locale) {
+  const [icon, key] = MEDIA_LABEL_PARTS[type];
+  return `${icon}${escapeHTML(translate(locale, key))}`;
+}
+
+// Download failures that render as WhatsApp's "tap to download" placeholder
+// (options.mediaErrors "placeholder") instead of failing the render
+const UNAVAILABLE_CODES = new Set(['media_fetch_failed', 'media_too_large']);
+
+// Map thumbnail of locations without a map image: streets, a park and the pin
+const PLACEHOLDER_MAP = `<svg class="media-location-map" viewBox="0 0 260 150" preserveAspectRatio="xMidYMid slice" xmlns="http://www.w3.org/2000/svg">
+  <rect width="260" height="150" fill="#e8e4dc"/>
+  <path d="M150 0h70v55h-70z" fill="#cfe5c3"/>
+  <path d="M0 95h260M0 40h260M60 0v150M190 0v150" stroke="white" stroke-width="9"/>
+  <path d="M0 130L260 10M110 0v150" stroke="#f7f5f0" stroke-width="5"/>
+  <path d="M130 47c-8 0-14 6-14 14 0 10 14 26 14 26s14-16 14-26c0-8-6-14-14-14z" fill="#e53935"/>
+  <circle cx="130" cy="61" r="5" fill="white"/>
+</svg>`;
+
+/**
+ * Media fetch settings: MEDIA_URL_ALLOWLIST (hosts mediaUrl may point to,
+ * comma separated; "*.example.com" matches subdomains), MEDIA_MAX_BYTES and
+ * MEDIA_FETCH_TIMEOUT_MS
+ * @param {Object} [proxy] - Proxy from resolveProxy that downloads go through
+ * @returns {Object} Settings
+ */
+const getMediaSettings = (proxy = null) => ({
+  proxy,
+  allowlist: parseListEnv('MEDIA_URL_ALLOWLIST', { lowercase: true }),
+  maxBytes: parseInt(process.env.MEDIA_MAX_BYTES, 10) || 5 * 1024 * 1024,
+  timeoutMs: parseInt(process.env.MEDIA_FETCH_TIMEOUT_MS, 10) || 10000
+});
+
+/**
+ * Check the MIME type of an attachment against its message type
+ * @param {string} type - Message type
+ * @param {string} mimeType - MIME type
+ * @param {string} messageRef - Message reference for errors
+ */
+function assertMimeType(type, mimeType, messageRef) {
+  if (!ACCEPTED_MIME[type].some(prefix => mimeType.startsWith(prefix))) {
+    throw new ApiError(400, `${messageRef}: ${mimeType || 'unknown'} media cannot be shown as ${type}`)
+      .annotate({ stage: 'validate', code: 'media_type_mismatch' });
+  }
+}
+
+/**
+ * Read a response body, giving up as soon as it exceeds maxBytes, so a body
+ * without Content-Length can't be buffered whole
+ * @param {Object} response - Fetch response
+ * @param {number} maxBytes - Size limit
+ * @returns {Promise<Buffer|null>} Body, or null when it is too large
+ */
+async function readLimitedBody(response, maxBytes) {
+  if (!response.body) {
+    return Buffer.alloc(0);
+  }
+  const reader = response.body.getReader();
+  const chunks = [];
+  let size = 0;
+  for (;;) {
+    const { done, value } = await reader.read();
+    if (done) {
+      return Buffer.concat(chunks, size);
+    }
+    size += value.length;
+    if (size > maxBytes) {
+      await reader.cancel().catch(() => {});
+      return null;
+    }
+    chunks.push(value);
+  }
+}
+
+/**
+ * GET a URL through a proxy agent. Redirects are not followed, like the
+ * direct download.
+ * @param {URL} url - Target URL
+ * @param {Object} agent - From createProxyAgent
+ * @param {number} timeoutMs - Timeout of the whole download
+ * @returns {Promise<Object>} Fetch-like response: { ok, status, headers.get, body }
+ */
+function requestThroughProxy(url, agent, timeoutMs) {
+  return new Promise((resolve, reject) => {
+    const client = url.protocol === 'https:' ? https : http;
+    const request = client.get(url, { agent, signal: AbortSignal.timeout(timeoutMs) }, response => {
+      const ok = response.statusCode >= 200 && response.statusCode < 300;
+      if (!ok) {
+        response.resume();
+      }
+      resolve({
+        ok,
+        status: response.statusCode,
+        headers: { get: name => (response.headers[name.toLowerCase()] === undefined ? null : String(response.headers[name.toLowerCase()])) },
+        body: ok ? Readable.toWeb(response) : null
+      });
+    });
+    request.on('error', reject);
+  });
+}
+
+/**
+ * Download an attachment into a data URL
+ * @param {string} rawUrl - http(s) URL
+ * @param {string} type - Message type
+ * @param {string} messageRef - Message reference for errors
+ * @param {Object} settings - From getMediaSettings
+ * @returns {Promise<string>} Data URL
+ */
+async function fetchMedia(rawUrl, type, messageRef, settings) {
+  const guard = { allowlist: settings.allowlist, allowlistName: 'MEDIA_URL_ALLOWLIST' };
+  // Like URL renders, proxied downloads leave name resolution to the proxy
+  const agent = createProxyAgent(settings.proxy, await assertUrlAllowed(rawUrl, { ...guard, resolve: false }));
+  const url = await assertUrlAllowed(rawUrl, { ...guard, resolve: !agent });
+
+  let response;
+  try {
+    // Redirects could lead to hosts that were never checked
+    response = agent
+      ? await requestThroughProxy(url, agent, settings.timeoutMs)
+      : await fetch(url, { redirect: 'error', signal: AbortSignal.timeout(settings.timeoutMs) });
+  } catch (error) {
+    throw new ApiError(502, `${messageRef}: media download failed: ${error.message}`)
+      .annotate({ stage: 'validate', code: 'media_fetch_failed', retryable: true })
+      .causedBy(error);
+  }
+  if (!response.ok) {
+    throw new ApiError(502, `${messageRef}: media download failed: HTTP ${response.status}`)
+      .annotate({ stage: 'validate', code: 'media_fetch_failed', retryable: response.status === 429 || response.status >= 500 });
+  }
+
+  const mimeType = (response.headers.get('content-type') || '').split(';')[0].trim().toLowerCase();
+  assertMimeType(type, mimeType, messageRef);
+  const tooLarge = () => new ApiError(400, `${messageRef}: media exceeds ${settings.maxBytes} bytes`)
+    .annotate({ stage: 'validate', code: 'media_too_large' });
+  if (parseInt(response.headers.get('content-length'), 10) > settings.maxBytes) {
+    throw tooLarge();
+  }
+  let buffer;
+  try {
+    buffer = await readLimitedBody(response, settings.maxBytes);
+  } catch (error) {
+    throw new ApiError(502, `${messageRef}: media download failed: ${error.message}`)
+      .annotate({ stage: 'validate', code: 'media_fetch_failed', retryable: true })
+      .causedBy(error);
+  }
+  if (!buffer) {
+    throw tooLarge();
+  }
+  return `data:${mimeType};base64,${buffer.toString('base64')}`;
+}
+
+/**
+ * Resolve a media URL into an inline data URL. Data URLs are checked and
+ * kept; http(s) URLs are downloaded once per downloads map.
+ * @param {string} rawUrl - http(s) or data: URL
+ * @param {string} type - Message type the media must fit
+ * @param {string} ref - Request field for errors, e.g. messages[3].mediaUrl
+ * @param {Object} [state] - { settings, downloads } shared across a request
+ * @returns {Promise<string>} Data URL
+ */
+async function resolveMediaUrl(rawUrl, type, ref, { settings = getMediaSettings(), downloads = new Map() } = {}) {
+  if (rawUrl.startsWith('data:')) {
+    const match = /^data:([^;,]+)[^,]*,/.exec(rawUrl);
+    if (!match) {
+      throw new ApiError(400, `${ref}: invalid data URL`).annotate({ stage: 'validate', code: 'invalid_url' });
+    }
+    assertMimeType(type, match[1].toLowerCase(), ref);
+    if (Buffer.byteLength(rawUrl) > settings.maxBytes * 1.4) {
+      throw new ApiError(400, `${ref}: media exceeds ${settings.maxBytes} bytes`)
+        .annotate({ stage: 'validate', code: 'media_too_large' });
+    }
+    return rawUrl;
+  }
+
+  const key = `${type} ${rawUrl}`;
+  if (!downloads.has(key)) {
+    downloads.set(key, fetchMedia(rawUrl, type, ref, settings));
+  }
+  return downloads.get(key);
+}
+
+/**
+ * Resolve a media URL like resolveMediaUrl. With onUnavailable, downloads
+ * that fail or are too large are reported to it and resolve to null, so the
+ * render shows a placeholder; other errors (such as a host that is not
+ * allowed) still fail the request.
+ * @param {string} rawUrl - http(s) or data: URL
+ * @param {string} type - Message type the media must fit
+ * @param {string} ref - Request field for errors, e.g. messages[3].mediaUrl
+ * @param {Object} [settings] - { state, proxy, onUnavailable }; state is shared with resolveMediaUrl,
+ *   proxy (from resolveProxy) carries the downloads when there is no state,
+ *   onUnavailable(error, ref) receives the failures
+ * @returns {Promise<string|null>} Data URL, or null when the media is unavailable
+ */
+async function resolveMediaOrPlaceholder(rawUrl, type, ref, { state, proxy, onUnavailable } = {}) {
+  try {
+    return await resolveMediaUrl(rawUrl, type, ref, state || { settings: getMediaSettings(proxy) });
+  } catch (error) {
+    if (!onUnavailable || !UNAVAILABLE_CODES.has(error.code)) {
+      throw error;
+    }
+    onUnavailable(error, ref);
+    return null;
+  }
+}
+
+/**
+ * Static map image URL of a location, from LOCATION_MAP_URL with {lat} and
+ * {lng} placeholders, e.g. https://maps.example.com/static?center={lat},{lng}&zoom=15
+ * @param {Object} location - { lat, lng }
+ * @returns {string|null} URL, or null when LOCATION_MAP_URL is not set
+ */
+function getLocationMapUrl(location) {
+  const template = process.env.LOCATION_MAP_URL;
+  if (!template || !location) {
+    return null;
+  }
+  return template
+    .replace(/\{lat\}/g, encodeURIComponent(location.lat))
+    .replace(/\{lng\}/g, encodeURIComponent(location.lng));
+}
+
+/**
+ * Resolve the mediaUrl of every media message (for locations, the map from
+ * LOCATION_MAP_URL when no mediaUrl is given), and the author photos, into
+ * inline data URLs, so the page never fetches remote content
+ * @param {Array<Object>} messages - Request messages
+ * @param {Object} [settings] - { proxy, onUnavailable }: see resolveMediaOrPlaceholder. Attachments
+ *   that could not be downloaded get mediaUnavailable; maps and author photos are left out
+ * @returns {Promise<Array<Object>>} Messages; media messages get mediaSrc and
+ *   messages with an author photo get authorAvatarSrc
+ */
+async function inlineMedia(messages, { proxy, onUnavailable } = {}) {
+  const mapUrls = messages.map(msg => (msg.type === 'location' && !msg.mediaUrl ? getLocationMapUrl(msg.location) : null));
+  if (!messages.some((msg, index) => msg.mediaUrl || msg.authorAvatarUrl || mapUrls[index])) {
+    return messages;
+  }
+  const state = { settings: getMediaSettings(proxy), downloads: new Map() };
+  const resolve = (url, type, ref) => resolveMediaOrPlaceholder(url, type, ref, { state, onUnavailable });
+
+  return Promise.all(messages.map(async (msg, index) => {
+    const inlined = { ...msg };
+    if (msg.mediaUrl && MEDIA_TYPES.includes(msg.type)) {
+      inlined.mediaSrc = await resolve(msg.mediaUrl, msg.type, `messages[${index}].mediaUrl`);
+      if (!inlined.mediaSrc) {
+        inlined.mediaUnavailable = true;
+      }
+    } else if (mapUrls[index]) {
+      // A missing map falls back to the placeholder map
+      inlined.mediaSrc = await resolve(mapUrls[index], 'location', `messages[${index}].location (LOCATION_MAP_URL)`);
+    }
+    if (msg.authorAvatarUrl) {
+      inlined.authorAvatarSrc = await resolve(msg.authorAvatarUrl, 'image', `options.authorAvatars["${msg.author}"]`);
+    }
+    return inlined;
+  }));
+}
+
+/**
+ * Human-readable file size, e.g. "1.2 MB"
+ * @param {number} bytes - Size in bytes
+ * @returns {string} Size
+ */
+function formatFileSize(bytes) {
+  if (bytes < 1024) {
+    return `${bytes} B`;
+  }
+  const units = ['kB', 'MB', 'GB'];
+  let value = bytes / 1024;
+  let unit = 0;
+  while (value >= 1024 && unit < units.length - 1) {
+    value /= 1024;
+    unit += 1;
+  }
+  return `${value >= 10 ? Math.round(value) : value.toFixed(1)} ${units[unit]}`;
+}
+
+/**
+ * Duration as m:ss
+ * @param {number} seconds - Duration
+ * @returns {string} Duration
+ */
+const formatDuration = (seconds) => `${Math.floor(seconds / 60)}:${String(Math.floor(seconds % 60)).padStart(2, '0')}`;
+
+/**
+ * Download button of media that is not on the phone yet: an arrow in a
+ * circle, with the file size when known
+ * @param {number} [fileSize] - Size in bytes
+ * @returns {string} HTML
+ */
+const renderDownloadButton = (fileSize) => `<span class="media-download"><span class="media-download-icon"></span>${
+  fileSize !== undefined ? `<span class="media-download-size">${formatFileSize(fileSize)}</span>` : ''}</span>`;
+
+/**
+ * Attachment markup above the caption of a media bubble. Attachments without
+ * a mediaUrl (and redacted ones) render as a placeholder; attachments whose
+ * download failed render as the app's download placeholder.
+ * @param {Object} media - { type, src, unavailable, fileName, fileSize, duration, location } from formatMessage
+ * @param {string} [contentClass] - "blurred" or "redacted"
+ * @returns {string} HTML
+ */
+function renderMediaHTML(media, contentClass = '') {
+  const src = contentClass === 'redacted' ? null : media.src;
+  const blurred = contentClass === 'blurred' ? ' blurred' : '';
+  const duration = media.duration !== undefined ? formatDuration(media.duration) : '';
+  const download = media.unavailable && contentClass !== 'redacted' ? renderDownloadButton(media.fileSize) : '';
+
+  switch (media.type) {
+    case 'image':
+    case 'sticker':
+      if (download) {
+        return `<div class="media-${media.type} media-placeholder media-unavailable">${download}</div>`;
+      }
+      return src
+        ? `<img class="media-${media.type}${blurred}" src="${escapeHTML(src)}" alt="">`
+        : `<div class="media-${media.type} media-placeholder"></div>`;
+    case 'video':
+      if (download) {
+        return `<div class="media-video media-unavailable"><div class="media-placeholder"></div>${download}${duration ? `<span class="media-duration">${duration}</span>` : ''}</div>`;
+      }
+      return `<div class="media-video${blurred}">${src ? `<video src="${escapeHTML(src)}" preload="auto" muted></video>` : '<div class="media-placeholder"></div>'}<span class="media-play"></span>${duration ? `<span class="media-duration">${duration}</span>` : ''}</div>`;
+    case 'audio':
+      return `<div class="media-audio${download ? ' media-unavailable' : ''}">${download ? '<span class="media-download-icon"></span>' : '<span class="media-play"></span>'}<span class="media-waveform">${'<i></i>'.repeat(28)}</span><span class="media-duration">${duration || '0:00'}</span></div>`;
+    case 'document': {
+      const extension = media.fileName && media.fileName.includes('.') ? media.fileName.split('.').pop().toUpperCase() : '';
+      const details = [media.fileSize !== undefined ? formatFileSize(media.fileSize) : '', extension].filter(Boolean).join(' &#183; ');
+      return `<div class="media-document${download ? ' media-unavailable' : ''}"><span class="media-document-icon">${escapeHTML(extension.slice(0, 4) || 'FILE')}</span><span class="media-document-name">${escapeHTML(media.fileName || 'Document')}</span>${details ? `<span class="media-document-details">${details}</span>` : ''}${download ? '<span class="media-download-icon"></span>' : ''}</div>`;
+    }
+    case 'location': {
+      const { lat, lng, label, address } = media.location || {};
+      const map = src ? `<img class="media-location-map" src="${escapeHTML(src)}" alt="">` : PLACEHOLDER_MAP;
+      // Redacted locations keep the card but not the place; blurred ones show
+      // placeholder text of the place
+      const text = value => (blurred ? convertToPlaceholderText(value) : value);
+      const details = contentClass === 'redacted'
+        ? ''
+        : `<div class="media-location-details${blurred}"><span class="media-location-label">${escapeHTML(text(label || `${lat}, ${lng}`))}</span>${address ? `<span class="media-location-address">${escapeHTML(text(address))}</span>` : ''}</div>`;
+      return `<div class="media-location"><div class="media-location-thumb${blurred}">${map}</div>${details}</div>`;
+    }
+    default:
+      return '';
+  }
+}
+
+module.exports = {
+  inlineMedia,
+  resolveMediaUrl,
+  resolveMediaOrPlaceholder,
+  renderMediaHTML,
+  formatFileSize,
+  MEDIA_TYPES,
+  MEDIA_LABELS,
+  getMediaLabel
+};
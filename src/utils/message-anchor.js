@@ -0,0 +1,15 @@
+/**
+ * DOM ID of the bubble of a message with an ID: "msg-" followed by the ID,
+ * with every character other than ASCII letters, digits and "-" written as
+ * "_<hex code point>_". The mapping is stable and unambiguous, so
+ * "#msg-<id>" links and selectors work for any request message ID.
+ * @param {string} messageId - Request message ID
+ * @returns {string} Element ID, e.g. msg-order_2e_42 for "order.42"
+ */
+function messageAnchorId(messageId) {
+  return `msg-${String(messageId).replace(/[^A-Za-z0-9-]/gu, char => `_${char.codePointAt(0).toString(16)}_`)}`;
+}
+
+module.exports = {
+  messageAnchorId
+};
@@ -0,0 +1,113 @@
+const { convertToRedactedHTML, convertToPlaceholderText } = require('./whatsapp-html');
+const { formatContentHTML } = require('./content-format');
+const { resolveMessageDirection } = require('./text-direction');
+const { maskContent, renderMaskedContent } = require('./content-filter');
+const { getMediaLabel } = require('./media');
+const { isPhoneNumber, formatPhoneNumber } = require('./phone-format');
+const { highlightEveryoneMentions } = require('./community');
+const { escapeHTML } = require('./html-escape');
+const { translate, getTimeFormatter } = require('./i18n');
+
+// Receipt states of sent messages, from the clock to the blue ticks, and the
+// red exclamation mark of a message that failed to send
+const MESSAGE_STATUSES = ['pending', 'sent', 'delivered', 'read', 'failed'];
+
+// Receipt tooltips (i18n keys)
+const STATUS_LABELS = {
+  pending: 'statusPending',
+  sent: 'statusSent',
+  delivered: 'statusDelivered',
+  read: 'statusRead',
+  failed: 'statusFailed'
+};
+
+/**
+ * Format a single message into its bubble data. This is a pure function so it
+ * can run either on the main thread or inside a formatting worker.
+ * @param {Object} msg - Message object
+ * @param {Object} settings - Formatting settings
+ * @param {Object} settings.contentFilter - Resolved content filter, or null
+ * @param {string} settings.contentFormat - Content format
+ * @param {string} settings.spoilers - Spoiler state
+ * @param {boolean|Object} settings.autoLink - Auto-link option
+ * @param {string} settings.direction - Chat-level direction
+ * @param {boolean} settings.autoDirection - Per-message direction detection
+ * @param {boolean} settings.formatAuthorPhones - Show phone-number authors in international style
+ * @param {string} settings.chatType - "standard" or "community"; communities style @everyone
+ * @param {string} [settings.locale] - Locale of the times, receipt tooltips and media labels
+ * @param {string} [settings.timeFormat] - "12h" or "24h" clock, the locale's own by default
+ * @returns {Object} Processed message
+ */
+function formatMessage(msg, settings = {}) {
+  const {
+    contentFilter = null,
+    contentFormat = 'whatsapp',
+    spoilers = 'hidden',
+    autoLink = false,
+    direction = 'ltr',
+    autoDirection = true,
+    formatAuthorPhones = true,
+    chatType = 'standard',
+    locale,
+    timeFormat
+  } = settings;
+  const isBot = msg.sender === 'Bot';
+  // Unsaved contacts appear by their number, with their push name beside it
+  const unsavedAuthor = Boolean(msg.author) && isPhoneNumber(msg.author);
+
+  // Format message content into html, masking filtered content.
+  // Redacted and blurred messages never include the original text.
+  const content = msg.blurred ? convertToPlaceholderText(msg.content) : msg.content;
+  const formattedHTML = msg.redacted
+    ? convertToRedactedHTML(msg.content)
+    : renderMaskedContent(formatContentHTML(maskContent(content, contentFilter), { contentFormat, spoilers, autoLink }));
+  const contentHTML = chatType === 'community' && !msg.redacted ? highlightEveryoneMentions(formattedHTML) : formattedHTML;
+  const type = msg.type || 'text';
+
+  return {
+    ...(msg.id !== undefined && { id: msg.id }),
+    sender: msg.sender,
+    timestamp: msg.timestamp,
+    // msg.timestamp is already in Asia/Jakarta
+    time: getTimeFormatter(locale, undefined, timeFormat).format(new Date(msg.timestamp)),
+    isSent: isBot,
+    ...(isBot && { status: msg.status || 'read', statusLabel: translate(locale, STATUS_LABELS[msg.status || 'read']) }),
+    bubbleClass: isBot ? 'sent' : 'received',
+    contentClass: msg.redacted ? 'redacted' : msg.blurred ? 'blurred' : '',
+    // Each bubble follows its own dominant script, falling back to the chat direction
+    dir: resolveMessageDirection(msg.content, { direction, autoDirection }, msg.direction),
+    contentHTML,
+    // Group member who wrote a received message, with their inlined photo
+    ...(msg.author && !isBot && {
+      author: {
+        name: msg.author,
+        label: unsavedAuthor && formatAuthorPhones ? formatPhoneNumber(msg.author) : msg.author,
+        pushName: unsavedAuthor && msg.pushName ? msg.pushName : null,
+        src: msg.authorAvatarSrc || null
+      }
+    }),
+    // Media messages: contentHTML is the caption, previewHTML the one-line
+    // text for chat lists, notifications and reply quotes
+    ...(type !== 'text' && {
+      type,
+      media: {
+        type,
+        src: msg.mediaSrc || null,
+        ...(msg.mediaUnavailable && { unavailable: true }),
+        fileName: msg.fileName,
+        fileSize: msg.fileSize,
+        duration: msg.duration,
+        ...(type === 'location' && { location: msg.location })
+      },
+      // Locations are previewed by their label, like WhatsApp
+      previewHTML: type === 'location' && msg.location && msg.location.label && !msg.redacted
+        ? `&#128205; ${escapeHTML(msg.blurred ? convertToPlaceholderText(msg.location.label) : msg.location.label)}`
+        : `${getMediaLabel(type, locale)}${contentHTML ? ` ${contentHTML}` : ''}`
+    })
+  };
+}
+
+module.exports = {
+  formatMessage,
+  MESSAGE_STATUSES
+};
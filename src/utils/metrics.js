@@ -0,0 +1,248 @@
+// Minimal in-process metrics registry with Prometheus text exposition
+
+const DEFAULT_DURATION_BUCKETS = [0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30];
+const DEFAULT_SIZE_BUCKETS = [1024, 10240, 102400, 512000, 1048576, 5242880, 10485760];
+
+/**
+ * Serialize a label set into a stable key / exposition string
+ * @param {Object} labels - Label name -> value
+ * @returns {string} Serialized labels, e.g. {stage="format"}
+ */
+function formatLabels(labels = {}) {
+  const entries = Object.entries(labels).sort(([a], [b]) => a.localeCompare(b));
+  if (entries.length === 0) {
+    return '';
+  }
+  const body = entries
+    .map(([name, value]) => `${name}="${String(value).replace(/\\/g, '\\\\').replace(/"/g, '\\"').replace(/\n/g, '\\n')}"`)
+    .join(',');
+  return `{${body}}`;
+}
+
+class Counter {
+  constructor(name, help) {
+    this.name = name;
+    this.help = help;
+    this.type = 'counter';
+    this.values = new Map();
+  }
+
+  inc(labels = {}, value = 1) {
+    const key = formatLabels(labels);
+    this.values.set(key, (this.values.get(key) || 0) + value);
+  }
+
+  render() {
+    const lines = [];
+    this.values.forEach((value, key) => lines.push(`${this.name}${key} ${value}`));
+    return lines;
+  }
+}
+
+class Gauge extends Counter {
+  constructor(name, help) {
+    super(name, help);
+    this.type = 'gauge';
+  }
+
+  set(labels = {}, value = 0) {
+    this.values.set(formatLabels(labels), value);
+  }
+
+  dec(labels = {}, value = 1) {
+    this.inc(labels, -value);
+  }
+}
+
+class Histogram {
+  constructor(name, help, buckets = DEFAULT_DURATION_BUCKETS) {
+    this.name = name;
+    this.help = help;
+    this.type = 'histogram';
+    this.buckets = [...buckets].sort((a, b) => a - b);
+    this.series = new Map();
+  }
+
+  observe(labels = {}, value) {
+    const key = formatLabels(labels);
+    if (!this.series.has(key)) {
+      this.series.set(key, { labels, counts: this.buckets.map(() => 0), sum: 0, count: 0 });
+    }
+    const series = this.series.get(key);
+    this.buckets.forEach((bound, index) => {
+      if (value <= bound) {
+        series.counts[index] += 1;
+      }
+    });
+    series.sum += value;
+    series.count += 1;
+  }
+
+  render() {
+    const lines = [];
+    this.series.forEach(({ labels, counts, sum, count }, key) => {
+      this.buckets.forEach((bound, index) => {
+        lines.push(`${this.name}_bucket${formatLabels({ ...labels, le: bound })} ${counts[index]}`);
+      });
+      lines.push(`${this.name}_bucket${formatLabels({ ...labels, le: '+Inf' })} ${count}`);
+      lines.push(`${this.name}_sum${key} ${sum}`);
+      lines.push(`${this.name}_count${key} ${count}`);
+    });
+    return lines;
+  }
+}
+
+class MetricsRegistry {
+  constructor() {
+    this.metrics = new Map();
+  }
+
+  register(metric) {
+    if (!this.metrics.has(metric.name)) {
+      this.metrics.set(metric.name, metric);
+    }
+    return this.metrics.get(metric.name);
+  }
+
+  counter(name, help) {
+    return this.register(new Counter(name, help));
+  }
+
+  gauge(name, help) {
+    return this.register(new Gauge(name, help));
+  }
+
+  histogram(name, help, buckets) {
+    return this.register(new Histogram(name, help, buckets));
+  }
+
+  /**
+   * Render all metrics in the Prometheus text exposition format
+   * @returns {string} Exposition text
+   */
+  render() {
+    const lines = [];
+    this.metrics.forEach(metric => {
+      lines.push(`# HELP ${metric.name} ${metric.help}`);
+      lines.push(`# TYPE ${metric.name} ${metric.type}`);
+      lines.push(...metric.render());
+    });
+    return `${lines.join('\n')}\n`;
+  }
+}
+
+const registry = new MetricsRegistry();
+
+/**
+ * Seconds elapsed since a process.hrtime.bigint() start mark
+ * @param {bigint} start - Start mark
+ * @returns {number} Elapsed seconds
+ */
+function secondsSince(start) {
+  return Number(process.hrtime.bigint() - start) / 1e9;
+}
+
+// Rendering pipeline metrics
+const pipelineMetrics = {
+  stageDuration: registry.histogram(
+    'chat_render_stage_duration_seconds',
+    'Duration of each rendering pipeline stage (process, format, html, capture)'
+  ),
+  messagesProcessed: registry.counter(
+    'chat_messages_processed_total',
+    'Number of messages processed into chat data'
+  ),
+  htmlBytes: registry.histogram(
+    'chat_html_bytes',
+    'Size of the generated chat HTML document in bytes',
+    DEFAULT_SIZE_BUCKETS
+  )
+};
+
+// Browser lifecycle metrics
+const browserMetrics = {
+  recycles: registry.counter(
+    'chat_browser_recycles_total',
+    'Number of browser restarts, by reason (renders, memory, disconnected)'
+  ),
+  memoryBytes: registry.gauge(
+    'chat_browser_memory_bytes',
+    'Resident memory of the browser process tree'
+  ),
+  openPages: registry.gauge(
+    'chat_browser_open_pages',
+    'Number of pages currently open for renders'
+  ),
+  zombiePagesClosed: registry.counter(
+    'chat_browser_zombie_pages_closed_total',
+    'Number of pages force-closed after exceeding BROWSER_MAX_PAGE_AGE_MS'
+  )
+};
+
+// Output persistence metrics
+const storageMetrics = {
+  writes: registry.counter(
+    'chat_storage_writes_total',
+    'Number of outputs stored, by result (stored, deduplicated)'
+  ),
+  bytes: registry.gauge(
+    'chat_storage_bytes',
+    'Total size of stored outputs as of the last garbage collection'
+  ),
+  files: registry.gauge(
+    'chat_storage_files',
+    'Number of stored outputs as of the last garbage collection'
+  ),
+  gcRuns: registry.counter(
+    'chat_storage_gc_runs_total',
+    'Number of storage garbage collection runs, by result (ok, failed)'
+  ),
+  gcDuration: registry.histogram(
+    'chat_storage_gc_duration_seconds',
+    'Duration of storage garbage collection runs'
+  ),
+  gcDeletedFiles: registry.counter(
+    'chat_storage_gc_deleted_files_total',
+    'Number of outputs deleted by garbage collection, by reason (age, tenant_quota, total_bytes)'
+  ),
+  gcFreedBytes: registry.counter(
+    'chat_storage_gc_freed_bytes_total',
+    'Bytes freed by garbage collection'
+  )
+};
+
+// Outbound delivery metrics
+const deliveryMetrics = {
+  deliveries: registry.counter(
+    'chat_deliveries_total',
+    'Number of output deliveries, by target and result (ok, failed)'
+  ),
+  duration: registry.histogram(
+    'chat_delivery_duration_seconds',
+    'Duration of output deliveries, by target'
+  )
+};
+
+// Async render job metrics
+const jobMetrics = {
+  jobs: registry.counter(
+    'chat_jobs_total',
+    'Number of finished async render jobs, by result (succeeded, failed)'
+  ),
+  queued: registry.gauge(
+    'chat_jobs_queued',
+    'Number of async render jobs waiting for a worker'
+  )
+};
+
+module.exports = {
+  registry,
+  pipelineMetrics,
+  browserMetrics,
+  storageMetrics,
+  deliveryMetrics,
+  jobMetrics,
+  secondsSince,
+  DEFAULT_DURATION_BUCKETS,
+  DEFAULT_SIZE_BUCKETS
+};
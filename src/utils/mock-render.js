@@ -0,0 +1,150 @@
+// Placeholder images for RENDERER=mock: a flat sketch of the chat (header,
+// bubbles) with the chat name stamped on it, drawn without a browser
+
+const { encodePng } = require('./png-encoder');
+
+// Same scale as the browser captures
+const DEVICE_SCALE = 2;
+
+const HEADER_HEIGHT = 60;
+const BUBBLE_PADDING = 8;
+const LINE_HEIGHT = 19;
+const CHAR_WIDTH = 7.5;
+
+const COLORS = {
+  background: [239, 234, 226],
+  header: [0, 128, 105],
+  sent: [217, 253, 211],
+  received: [255, 255, 255],
+  text: [255, 255, 255],
+  line: [200, 200, 200]
+};
+
+// 5x7 bitmap font, one 5-bit row per entry; other characters print as "?"
+const GLYPHS = {
+  A: [14, 17, 17, 31, 17, 17, 17], B: [30, 17, 17, 30, 17, 17, 30], C: [14, 17, 16, 16, 16, 17, 14],
+  D: [30, 17, 17, 17, 17, 17, 30], E: [31, 16, 16, 30, 16, 16, 31], F: [31, 16, 16, 30, 16, 16, 16],
+  G: [14, 17, 16, 23, 17, 17, 15], H: [17, 17, 17, 31, 17, 17, 17], I: [14, 4, 4, 4, 4, 4, 14],
+  J: [7, 2, 2, 2, 2, 18, 12], K: [17, 18, 20, 24, 20, 18, 17], L: [16, 16, 16, 16, 16, 16, 31],
+  M: [17, 27, 21, 21, 17, 17, 17], N: [17, 17, 25, 21, 19, 17, 17], O: [14, 17, 17, 17, 17, 17, 14],
+  P: [30, 17, 17, 30, 16, 16, 16], Q: [14, 17, 17, 17, 21, 18, 13], R: [30, 17, 17, 30, 20, 18, 17],
+  S: [15, 16, 16, 14, 1, 1, 30], T: [31, 4, 4, 4, 4, 4, 4], U: [17, 17, 17, 17, 17, 17, 14],
+  V: [17, 17, 17, 17, 17, 10, 4], W: [17, 17, 17, 21, 21, 21, 10], X: [17, 17, 10, 4, 10, 17, 17],
+  Y: [17, 17, 17, 10, 4, 4, 4], Z: [31, 1, 2, 4, 8, 16, 31],
+  0: [14, 17, 19, 21, 25, 17, 14], 1: [4, 12, 4, 4, 4, 4, 14], 2: [14, 17, 1, 2, 4, 8, 31],
+  3: [31, 2, 4, 2, 1, 17, 14], 4: [2, 6, 10, 18, 31, 2, 2], 5: [31, 16, 30, 1, 1, 17, 14],
+  6: [6, 8, 16, 30, 17, 17, 14], 7: [31, 1, 2, 4, 8, 8, 8], 8: [14, 17, 17, 14, 17, 17, 14],
+  9: [14, 17, 17, 15, 1, 2, 12],
+  ' ': [0, 0, 0, 0, 0, 0, 0], '-': [0, 0, 0, 31, 0, 0, 0], '.': [0, 0, 0, 0, 0, 12, 12],
+  '+': [0, 4, 4, 31, 4, 4, 0], ':': [0, 12, 12, 0, 12, 12, 0], '?': [14, 17, 1, 2, 4, 0, 4]
+};
+
+/**
+ * Fill a rectangle
+ * @param {Object} image - { width, height, data }
+ * @param {Object} rect - { x, y, width, height } in pixels
+ * @param {Array<number>} color - [r, g, b]
+ */
+function fillRect(image, { x, y, width, height }, color) {
+  const right = Math.min(image.width, x + width);
+  const bottom = Math.min(image.height, y + height);
+  for (let row = Math.max(0, y); row < bottom; row += 1) {
+    for (let col = Math.max(0, x); col < right; col += 1) {
+      const offset = (row * image.width + col) * 4;
+      image.data[offset] = color[0];
+      image.data[offset + 1] = color[1];
+      image.data[offset + 2] = color[2];
+      image.data[offset + 3] = 255;
+    }
+  }
+}
+
+/**
+ * Draw text with the bitmap font, clipped to maxWidth
+ * @param {Object} image - { width, height, data }
+ * @param {string} text - Text; letters are drawn upper case
+ * @param {Object} position - { x, y, scale, maxWidth } in pixels
+ * @param {Array<number>} color - [r, g, b]
+ */
+function drawText(image, text, { x, y, scale, maxWidth }, color) {
+  const advance = 6 * scale;
+  const fits = Math.max(0, Math.floor(maxWidth / advance));
+  [...text.toUpperCase()].slice(0, fits).forEach((char, index) => {
+    const glyph = GLYPHS[char] || GLYPHS['?'];
+    glyph.forEach((bits, row) => {
+      for (let col = 0; col < 5; col += 1) {
+        if (bits & (16 >> col)) {
+          fillRect(image, { x: x + index * advance + col * scale, y: y + row * scale, width: scale, height: scale }, color);
+        }
+      }
+    });
+  });
+}
+
+/**
+ * Estimated bubble size of a message, from its text length
+ * @param {Object} message - Formatted message
+ * @param {number} maxWidth - Widest bubble in CSS pixels
+ * @returns {Object} { width, height } in CSS pixels
+ */
+function estimateBubble(message, maxWidth) {
+  const text = (message.contentHTML || '').replace(/<[^>]*>/g, '').replace(/&[^;]+;/g, ' ');
+  const perLine = Math.max(1, Math.floor((maxWidth - 2 * BUBBLE_PADDING) / CHAR_WIDTH));
+  const lineLengths = text.split('\n').map(line => line.length);
+  const lines = lineLengths.reduce((sum, length) => sum + Math.max(1, Math.ceil(length / perLine)), 0);
+  const media = message.media ? 150 : 0;
+  // Short messages get narrow bubbles, with room for the time
+  const textWidth = Math.max(...lineLengths) * CHAR_WIDTH + 2 * BUBBLE_PADDING + 48;
+  return {
+    width: media ? maxWidth : Math.min(maxWidth, Math.round(textWidth)),
+    height: media + lines * LINE_HEIGHT + 2 * BUBBLE_PADDING + 12
+  };
+}
+
+/**
+ * Render the placeholder of a chat: the header with the chat name, and one
+ * block per message sized like its bubble. Output is deterministic for a
+ * given chat and width.
+ * @param {Object} chatData - Data from processChatData
+ * @param {Object} [options] - { width } in CSS pixels
+ * @returns {Buffer} PNG at DEVICE_SCALE
+ */
+function renderMockChat(chatData, { width = 400 } = {}) {
+  const cssWidth = parseInt(width, 10) || 400;
+  const maxBubbleWidth = Math.round(cssWidth * 0.75);
+  const bubbles = chatData.messages.map(message => ({ message, ...estimateBubble(message, maxBubbleWidth) }));
+  const cssHeight = HEADER_HEIGHT + 8 + bubbles.reduce((sum, { height }) => sum + height + 6, 0) + 8;
+
+  const image = {
+    width: cssWidth * DEVICE_SCALE,
+    height: cssHeight * DEVICE_SCALE,
+    data: Buffer.alloc(cssWidth * DEVICE_SCALE * cssHeight * DEVICE_SCALE * 4)
+  };
+  const scaled = rect => Object.fromEntries(Object.entries(rect).map(([key, value]) => [key, Math.round(value * DEVICE_SCALE)]));
+
+  fillRect(image, scaled({ x: 0, y: 0, width: cssWidth, height: cssHeight }), COLORS.background);
+  fillRect(image, scaled({ x: 0, y: 0, width: cssWidth, height: HEADER_HEIGHT }), COLORS.header);
+  drawText(image, chatData.chatName || 'Chat', { ...scaled({ x: 16, y: 12, maxWidth: cssWidth - 32 }), scale: 2 * DEVICE_SCALE }, COLORS.text);
+  drawText(image, 'Mock render', { ...scaled({ x: 16, y: 40, maxWidth: cssWidth - 32 }), scale: DEVICE_SCALE }, COLORS.text);
+
+  let top = HEADER_HEIGHT + 8;
+  bubbles.forEach(({ message, width: bubbleWidth, height }) => {
+    const left = message.isSent ? cssWidth - bubbleWidth - 12 : 12;
+    fillRect(image, scaled({ x: left, y: top, width: bubbleWidth, height }), message.isSent ? COLORS.sent : COLORS.received);
+    // A darker block for the attachment, then grey bars where the text lines are
+    const mediaHeight = message.media ? 150 : 0;
+    if (mediaHeight) {
+      fillRect(image, scaled({ x: left + 4, y: top + 4, width: bubbleWidth - 8, height: mediaHeight - 8 }), COLORS.line);
+    }
+    for (let line = top + mediaHeight + BUBBLE_PADDING + 6; line < top + height - BUBBLE_PADDING - 12; line += LINE_HEIGHT) {
+      fillRect(image, scaled({ x: left + BUBBLE_PADDING, y: line, width: bubbleWidth - 2 * BUBBLE_PADDING - 24, height: 6 }), COLORS.line);
+    }
+    top += height + 6;
+  });
+
+  return encodePng(image);
+}
+
+module.exports = {
+  renderMockChat
+};
@@ -0,0 +1,89 @@
+const { ApiError } = require('../middleware/error.middleware');
+const { escapeHTML } = require('./html-escape');
+const { translate } = require('./i18n');
+
+// WhatsApp glyph for the app icon
+const APP_GLYPH = '<svg width="70%" height="70%" viewBox="0 0 24 24"><path d="M12 3a9 9 0 0 0-7.8 13.5L3 21l4.6-1.2A9 9 0 1 0 12 3z" fill="none" stroke="white" stroke-width="2" stroke-linejoin="round"/></svg>';
+
+/**
+ * Preview text of a message as shown in notifications
+ * @param {Object} msg - Processed message
+ * @returns {string} HTML
+ */
+const notificationText = (msg) => `<span class="notification-text ${msg.contentClass}" dir="${msg.dir}">${msg.previewHTML || msg.contentHTML}</span>`;
+
+/**
+ * iOS banner: the newest message, with the older ones stacked behind it
+ * @param {string} title - Chat name
+ * @param {string} initial - Avatar initial
+ * @param {Array<Object>} shown - Messages, oldest first
+ * @param {string} [locale] - Locale of the labels
+ * @returns {string} HTML
+ */
+function renderIosBanner(title, initial, shown, locale) {
+  const latest = shown[shown.length - 1];
+  const stacked = Math.min(shown.length - 1, 2);
+  return `
+    <div class="banner">
+      <div class="avatar">${initial}<span class="app-icon">${APP_GLYPH}</span></div>
+      <div class="banner-body">
+        <div class="banner-title"><span>${title}</span><span class="banner-time">${latest.time}</span></div>
+        ${notificationText(latest)}
+      </div>
+    </div>
+    ${'<div class="stack"></div>'.repeat(stacked)}
+    ${shown.length > 1 ? `<div class="more">${escapeHTML(shown.length > 2 ? translate(locale, 'moreNotifications', { count: shown.length - 1 }) : translate(locale, 'moreNotification'))}</div>` : ''}`;
+}
+
+/**
+ * Android notification: one card listing the messages
+ * @param {string} title - Chat name
+ * @param {string} initial - Avatar initial
+ * @param {Array<Object>} shown - Messages, oldest first
+ * @param {string} [locale] - Locale of the labels
+ * @returns {string} HTML
+ */
+function renderAndroidBanner(title, initial, shown, locale) {
+  const latest = shown[shown.length - 1];
+  return `
+    <div class="banner">
+      <div class="banner-header"><span class="app-icon">${APP_GLYPH}</span><span>WhatsApp &#183; ${latest.time}</span></div>
+      <div class="banner-main">
+        <div class="banner-body">
+          <div class="banner-title">${title}${shown.length > 1 ? ` (${escapeHTML(translate(locale, 'messageCount', { count: shown.length }))})` : ''}</div>
+          ${shown.map(msg => `<div class="notification-line">${notificationText(msg)}${shown.length > 1 ? `<span class="notification-line-time">${msg.time}</span>` : ''}</div>`).join('')}
+        </div>
+        <div class="avatar">${initial}</div>
+      </div>
+      <div class="banner-actions"><span>${escapeHTML(translate(locale, 'reply'))}</span><span>${escapeHTML(translate(locale, 'markAsRead'))}</span></div>
+    </div>`;
+}
+
+/**
+ * Template data of the notification view: a push notification for the
+ * latest received messages instead of the chat
+ * @param {Object} chatData - Processed chat data
+ * @param {Object} [notification] - options.notification { style, count, background }
+ * @returns {Object} { notificationClass, notifications, background }
+ */
+function buildNotificationData(chatData, { style = 'ios', count = 1, background = 'transparent' } = {}) {
+  // Your own messages don't notify you
+  const received = chatData.messages.filter(msg => !msg.isSent);
+  if (received.length === 0) {
+    throw new ApiError(400, 'The notification view needs at least one message not sent by "Bot"')
+      .annotate({ stage: 'validate', code: 'notification_message_required' });
+  }
+  const shown = received.slice(-count);
+  const title = escapeHTML(chatData.chatName);
+  const render = style === 'android' ? renderAndroidBanner : renderIosBanner;
+
+  return {
+    notificationClass: `notification-${style}`,
+    notifications: render(title, escapeHTML(chatData.recipientName), shown, chatData.uiLocale),
+    background
+  };
+}
+
+module.exports = {
+  buildNotificationData
+};
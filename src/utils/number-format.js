@@ -0,0 +1,86 @@
+// Locale used when a request doesn't set options.locale, matching the
+// Indonesian time formatting of the bubbles
+const DEFAULT_LOCALE = 'id-ID';
+
+// Currencies written without minor units in chats, e.g. "Rp1.500.000"
+const WHOLE_UNIT_CURRENCIES = new Set(['IDR', 'JPY', 'KRW', 'VND']);
+
+/**
+ * Parse a number argument; template arguments may arrive as strings
+ * @param {*} value - Number or numeric string
+ * @returns {number|null} Number, or null when not numeric
+ */
+const toNumber = (value) => {
+  const number = typeof value === 'number' ? value : Number(String(value == null ? '' : value).trim());
+  return value === '' || value == null || Number.isNaN(number) ? null : number;
+};
+
+/**
+ * Build a number formatter, falling back to the default locale for locales
+ * Intl rejects
+ * @param {string} [locale] - BCP 47 locale
+ * @param {Object} options - Intl.NumberFormat options
+ * @returns {Intl.NumberFormat} Formatter
+ */
+const numberFormat = (locale, options) => {
+  try {
+    return new Intl.NumberFormat(locale || DEFAULT_LOCALE, options);
+  } catch (error) {
+    if (!(error instanceof RangeError) || !locale) {
+      throw error;
+    }
+    return new Intl.NumberFormat(DEFAULT_LOCALE, options);
+  }
+};
+
+/**
+ * Format a number with the locale's grouping and decimal separators
+ * @param {number|string} value - Number
+ * @param {string} [locale] - BCP 47 locale, defaults to id-ID
+ * @returns {string} Formatted number; non-numeric values are returned as given
+ */
+function formatNumber(value, locale = DEFAULT_LOCALE) {
+  const number = toNumber(value);
+  if (number === null) {
+    return value == null ? '' : String(value);
+  }
+  return numberFormat(locale, { maximumFractionDigits: 2 }).format(number);
+}
+
+/**
+ * Format an amount of money the way it is written in chats: "Rp1.500.000"
+ * (id-ID, IDR) or "$1,500.00" (en-US, USD). A currency symbol in front of
+ * the amount is not followed by a space.
+ * @param {number|string} amount - Amount
+ * @param {string} currency - ISO 4217 code, e.g. IDR
+ * @param {string} [locale] - BCP 47 locale, defaults to id-ID
+ * @returns {string} Formatted amount; non-numeric amounts are returned as given
+ */
+function formatCurrency(amount, currency, locale = DEFAULT_LOCALE) {
+  const number = toNumber(amount);
+  if (number === null) {
+    return amount == null ? '' : String(amount);
+  }
+  const code = String(currency || 'IDR').toUpperCase();
+  // Unknown currency codes are written in front of the plain number
+  if (!/^[A-Z]{3}$/.test(code)) {
+    return `${code} ${formatNumber(number, locale)}`;
+  }
+  const wholeUnits = WHOLE_UNIT_CURRENCIES.has(code);
+  const parts = numberFormat(locale, {
+    style: 'currency',
+    currency: code,
+    ...(wholeUnits && { minimumFractionDigits: 0, maximumFractionDigits: 0 })
+  }).formatToParts(number);
+
+  return parts
+    .filter((part, index) => !(part.type === 'literal' && /^\s+$/.test(part.value) && parts[index - 1] && parts[index - 1].type === 'currency'))
+    .map(part => part.value)
+    .join('');
+}
+
+module.exports = {
+  formatNumber,
+  formatCurrency,
+  DEFAULT_LOCALE
+};
@@ -0,0 +1,195 @@
+// Packaging layer for multi-output renders: bundles rendered files into a ZIP
+// or TAR archive together with a manifest.json describing every item, so that
+// clients get the same structure whichever endpoint produced the archive.
+// Streamed packages write manifest.json last, as the counts are only known
+// once every item has rendered.
+
+const { createZip, createTar, createZipStream, createTarStream } = require('./archive');
+const { getImageDimensions } = require('./image-info');
+
+const PACKAGE_TYPES = {
+  zip: { contentType: 'application/zip', extension: 'zip' },
+  tar: { contentType: 'application/x-tar', extension: 'tar' }
+};
+
+const MANIFEST_FILE = 'manifest.json';
+
+/**
+ * Decode a data: URL produced by the screenshot service
+ * @param {string} dataUrl - data:image/...;base64,... string
+ * @returns {Buffer|null} Decoded bytes
+ */
+function decodeDataUrl(dataUrl) {
+  const match = /^data:[^;,]+;base64,(.*)$/s.exec(dataUrl || '');
+  return match ? Buffer.from(match[1], 'base64') : null;
+}
+
+/**
+ * Make a file name unique within the archive by appending -2, -3, ...
+ * @param {string} name - Desired name
+ * @param {Set<string>} used - Names already taken
+ * @returns {string} Unique name
+ */
+function uniqueName(name, used) {
+  let candidate = name;
+  const dot = name.lastIndexOf('.');
+  const base = dot > 0 ? name.slice(0, dot) : name;
+  const ext = dot > 0 ? name.slice(dot) : '';
+  for (let n = 2; used.has(candidate) || candidate === MANIFEST_FILE; n += 1) {
+    candidate = `${base}-${n}${ext}`;
+  }
+  used.add(candidate);
+  return candidate;
+}
+
+/**
+ * Manifest entry and archive file of one rendered item
+ * @param {Object} item - { name, data: Buffer, format, warnings, error, meta }
+ * @param {number} index - Position of the item in the request
+ * @param {Set<string>} used - File names already taken
+ * @returns {Object} { manifestItem, entry }; entry is null for failed items
+ * @private
+ */
+function describeItem(item, index, used) {
+  const { name, data, format = null, warnings = [], error = null, meta = {} } = item;
+
+  if (error || !data) {
+    return {
+      manifestItem: {
+        index,
+        file: null,
+        status: 'error',
+        format,
+        warnings,
+        error: {
+          message: error ? error.message : 'No output produced',
+          code: (error && error.code) || null,
+          stage: (error && error.stage) || null
+        },
+        ...meta
+      },
+      entry: null
+    };
+  }
+
+  const file = uniqueName(name, used);
+  const dimensions = getImageDimensions(data) || { width: null, height: null };
+  return {
+    manifestItem: {
+      index,
+      file,
+      status: 'ok',
+      format,
+      width: dimensions.width,
+      height: dimensions.height,
+      bytes: data.length,
+      warnings,
+      ...meta
+    },
+    entry: { name: file, data }
+  };
+}
+
+/**
+ * Manifest of a package
+ * @param {Array<Object>} manifestItems - Entries from describeItem
+ * @param {Object} settings - { type, requestId, now }
+ * @returns {Object} Manifest
+ * @private
+ */
+const buildManifest = (manifestItems, { type, requestId, now }) => ({
+  generated_at: now.toISOString(),
+  request_id: requestId,
+  package: type,
+  total: manifestItems.length,
+  succeeded: manifestItems.filter(item => item.status === 'ok').length,
+  failed: manifestItems.filter(item => item.status === 'error').length,
+  items: manifestItems
+});
+
+/**
+ * Archive entry of a manifest
+ * @param {Object} manifest - Manifest
+ * @returns {Object} { name, data, compress }
+ * @private
+ */
+const manifestEntry = (manifest) => ({
+  name: MANIFEST_FILE,
+  data: Buffer.from(JSON.stringify(manifest, null, 2)),
+  compress: true
+});
+
+/**
+ * Build an archive with a manifest from rendered items. Failed items are
+ * listed in the manifest with their error but have no file in the archive.
+ * @param {Array<Object>} items - { name, data: Buffer, format, warnings, error, meta }
+ * @param {Object} options - Packaging options
+ * @param {string} options.type - "zip" (default) or "tar"
+ * @param {string} options.requestId - Request ID recorded in the manifest
+ * @param {Date} options.now - Generation time of the manifest and the files
+ * @returns {Object} { buffer, contentType, extension, manifest }
+ */
+function buildPackage(items, { type = 'zip', requestId = null, now = new Date() } = {}) {
+  const packageType = PACKAGE_TYPES[type] || PACKAGE_TYPES.zip;
+  const used = new Set();
+  const described = items.map((item, index) => describeItem(item, index, used));
+  const manifest = buildManifest(described.map(({ manifestItem }) => manifestItem), { type, requestId, now });
+
+  const files = [
+    manifestEntry(manifest),
+    ...described.map(({ entry }) => entry).filter(Boolean)
+  ];
+  const buffer = type === 'tar' ? createTar(files, now) : createZip(files, now);
+
+  return {
+    buffer,
+    contentType: packageType.contentType,
+    extension: packageType.extension,
+    manifest
+  };
+}
+
+/**
+ * Stream a package to a writable stream: each item's file is written as soon
+ * as it is added, and manifest.json closes the archive. Items are not kept
+ * in memory once written.
+ * @param {stream.Writable} output - Destination, e.g. an HTTP response
+ * @param {Object} options - Packaging options
+ * @param {string} options.type - "zip" (default) or "tar"
+ * @param {string} options.requestId - Request ID recorded in the manifest
+ * @param {Date} options.now - Generation time of the manifest and the files
+ * @returns {Object} { contentType, extension, add(item): Promise, finish(): Promise<Object> };
+ *   finish resolves with the manifest
+ */
+function createPackageStream(output, { type = 'zip', requestId = null, now = new Date() } = {}) {
+  const packageType = PACKAGE_TYPES[type] || PACKAGE_TYPES.zip;
+  const used = new Set();
+  const manifestItems = [];
+  const writer = type === 'tar' ? createTarStream(output, now) : createZipStream(output, now);
+
+  return {
+    contentType: packageType.contentType,
+    extension: packageType.extension,
+    async add(item) {
+      const { manifestItem, entry } = describeItem(item, manifestItems.length, used);
+      manifestItems.push(manifestItem);
+      if (entry) {
+        await writer.add(entry);
+      }
+    },
+    async finish() {
+      const manifest = buildManifest(manifestItems, { type, requestId, now });
+      await writer.add(manifestEntry(manifest));
+      await writer.finish();
+      return manifest;
+    }
+  };
+}
+
+module.exports = {
+  buildPackage,
+  createPackageStream,
+  decodeDataUrl,
+  PACKAGE_TYPES,
+  MANIFEST_FILE
+};
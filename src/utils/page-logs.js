@@ -0,0 +1,77 @@
+// Surfaces what happens inside a Chrome page (console output, uncaught
+// errors, failed requests) through the structured logger. Blank or partially
+// rendered screenshots are usually explained by one of these.
+
+const MAX_COLLECTED = 50;
+
+/**
+ * Subscribe to a page's console, error and failed-request events
+ * @param {Object} page - Puppeteer page
+ * @param {Object} log - Logger (usually bound to the request ID)
+ * @returns {Array<Object>} Live list of console errors, page errors and
+ *   failed requests, usable as response warnings
+ */
+function attachPageLogging(page, log) {
+  const problems = [];
+  const collect = (problem) => {
+    if (problems.length < MAX_COLLECTED) {
+      problems.push(problem);
+    }
+  };
+
+  page.on('console', message => {
+    const type = message.type();
+    const location = message.location() || {};
+    const fields = { source: 'page-console', type, url: location.url };
+    if (type === 'error') {
+      log.warn(message.text(), fields);
+      collect({ type: 'console', message: message.text() });
+    } else if (type === 'warning') {
+      log.info(message.text(), fields);
+    } else {
+      log.debug(message.text(), fields);
+    }
+  });
+
+  page.on('pageerror', error => {
+    log.warn('Uncaught page error', { source: 'page', error });
+    collect({ type: 'pageerror', message: error.message });
+  });
+
+  page.on('requestfailed', request => {
+    const failure = request.failure();
+    const reason = failure ? failure.errorText : 'unknown';
+    log.warn('Page request failed', { source: 'page', url: request.url(), reason });
+    collect({ type: 'requestfailed', message: `${request.url()}: ${reason}` });
+  });
+
+  return problems;
+}
+
+/**
+ * Forward the Chrome process stderr (crash reports, GPU/sandbox errors) to the logger
+ * @param {Object} browser - Puppeteer browser
+ * @param {Object} log - Logger
+ */
+function pipeBrowserOutput(browser, log) {
+  const chromeProcess = browser.process();
+  if (!chromeProcess || !chromeProcess.stderr) {
+    return;
+  }
+
+  let buffered = '';
+  chromeProcess.stderr.setEncoding('utf8');
+  chromeProcess.stderr.on('data', chunk => {
+    buffered += chunk;
+    const lines = buffered.split('\n');
+    buffered = lines.pop();
+    lines
+      .filter(line => line.trim())
+      .forEach(line => log.warn(line.trim(), { source: 'chrome-stderr', pid: chromeProcess.pid }));
+  });
+}
+
+module.exports = {
+  attachPageLogging,
+  pipeBrowserOutput
+};
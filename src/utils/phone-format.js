@@ -0,0 +1,107 @@
+// Country calling code assumed for numbers written without "+", matching the
+// +62 prefix the chat header adds to recipient phones
+const DEFAULT_COUNTRY_CODE = '62';
+
+// Digit groups of the national number per calling code, as WhatsApp shows
+// them; the last group takes the remaining digits. NANP numbers wrap the
+// area code in parentheses.
+const NATIONAL_GROUPS = {
+  1: { groups: [3, 3], separator: '-', parenthesized: true },
+  44: { groups: [4], separator: ' ' },
+  49: { groups: [4], separator: ' ' },
+  60: { groups: [2, 3], separator: ['-', ' '] },
+  61: { groups: [3, 3], separator: ' ' },
+  62: { groups: [3, 4], separator: '-' },
+  63: { groups: [3, 3], separator: ' ' },
+  65: { groups: [4], separator: ' ' },
+  66: { groups: [2, 3], separator: ' ' },
+  81: { groups: [2, 4], separator: '-' },
+  82: { groups: [2, 4], separator: '-' },
+  84: { groups: [2, 3], separator: ' ' },
+  86: { groups: [3, 4], separator: ' ' },
+  91: { groups: [5], separator: ' ' },
+  966: { groups: [2, 3], separator: ' ' },
+  971: { groups: [2, 3], separator: ' ' }
+};
+
+// Digits-only phone numbers, optionally with "+", spaces, dashes, dots
+// and parentheses
+const PHONE_PATTERN = /^\+?[\d\s\-().]{7,20}$/;
+
+/**
+ * Whether a sender identifier is a bare phone number (an unsaved contact)
+ * @param {string} value - Author name or identifier
+ * @returns {boolean} True for phone numbers
+ */
+function isPhoneNumber(value) {
+  const text = String(value || '').trim();
+  return PHONE_PATTERN.test(text) && text.replace(/\D/g, '').length >= 7;
+}
+
+/**
+ * Split a number into its calling code and national number. Numbers with "+"
+ * are international; others are national numbers of the default country
+ * (a leading 0 trunk prefix is dropped) unless they start with its code.
+ * @param {string} value - Phone number
+ * @returns {Object} { countryCode, nationalNumber }
+ */
+function splitPhoneNumber(value) {
+  const text = String(value).trim();
+  const digits = text.replace(/\D/g, '');
+  if (text.startsWith('+')) {
+    // Calling codes are prefix-free, so the first match is the code
+    for (let length = 1; length <= 3; length += 1) {
+      if (NATIONAL_GROUPS[digits.slice(0, length)]) {
+        return { countryCode: digits.slice(0, length), nationalNumber: digits.slice(length) };
+      }
+    }
+    // Unknown code: assume the common 2-digit length
+    return { countryCode: digits.slice(0, 2), nationalNumber: digits.slice(2) };
+  }
+  if (digits.startsWith(DEFAULT_COUNTRY_CODE)) {
+    return { countryCode: DEFAULT_COUNTRY_CODE, nationalNumber: digits.slice(DEFAULT_COUNTRY_CODE.length) };
+  }
+  return { countryCode: DEFAULT_COUNTRY_CODE, nationalNumber: digits.replace(/^0/, '') };
+}
+
+/**
+ * Format a phone number in international style, e.g. "+62 812-3456-7890"
+ * or "+1 (415) 555-2671"
+ * @param {string} value - Phone number in any common notation
+ * @returns {string} Formatted number; other values are returned unchanged
+ */
+function formatPhoneNumber(value) {
+  if (!isPhoneNumber(value)) {
+    return value;
+  }
+  const { countryCode, nationalNumber } = splitPhoneNumber(value);
+  const { groups, separator, parenthesized = false } = NATIONAL_GROUPS[countryCode] || { groups: [3, 3], separator: ' ' };
+
+  const parts = [];
+  let offset = 0;
+  groups.forEach(size => {
+    if (offset + size < nationalNumber.length) {
+      parts.push(nationalNumber.slice(offset, offset + size));
+      offset += size;
+    }
+  });
+  parts.push(nationalNumber.slice(offset));
+  if (parenthesized && parts.length > 1) {
+    parts[0] = `(${parts[0]})`;
+  }
+
+  const national = parts.reduce((text, part, index) => {
+    if (index === 0) {
+      return part;
+    }
+    const join = Array.isArray(separator) ? separator[Math.min(index - 1, separator.length - 1)] : separator;
+    return `${text}${parenthesized && index === 1 ? ' ' : join}${part}`;
+  }, '');
+  return `+${countryCode} ${national}`;
+}
+
+module.exports = {
+  isPhoneNumber,
+  formatPhoneNumber,
+  DEFAULT_COUNTRY_CODE
+};
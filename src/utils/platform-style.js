@@ -0,0 +1,133 @@
+const { escapeHTML } = require('./html-escape');
+const { renderComposer } = require('./composer');
+const { translate } = require('./i18n');
+
+// Looks of the official apps for options.platform. Without a platform the
+// built-in template renders unchanged.
+
+/**
+ * Read receipt (double tick) icon as a CSS url()
+ * @param {string} color - Stroke color, e.g. #53bdeb
+ * @returns {string} CSS url() value
+ */
+const doubleTick = (color) => `url("data:image/svg+xml,${encodeURIComponent(
+  `<svg xmlns='http://www.w3.org/2000/svg' viewBox='0 0 16 11'><path d='M1 6l3 3 6.5-7.5M6.5 8.5l1 1L14 1.5' stroke='${color}' stroke-width='1.6' stroke-linecap='round' stroke-linejoin='round' fill='none'/></svg>`
+)}")`;
+
+const ICONS = {
+  emoji: color => `<svg width="22" height="22" viewBox="0 0 24 24"><circle cx="12" cy="12" r="9" stroke="${color}" stroke-width="1.8" fill="none"/><circle cx="9" cy="10" r="1.2" fill="${color}"/><circle cx="15" cy="10" r="1.2" fill="${color}"/><path d="M8 14c2 2.5 6 2.5 8 0" stroke="${color}" stroke-width="1.8" fill="none" stroke-linecap="round"/></svg>`,
+  camera: color => `<svg width="22" height="22" viewBox="0 0 24 24"><path d="M4 8h3l2-3h6l2 3h3v11H4z" stroke="${color}" stroke-width="1.8" fill="none" stroke-linejoin="round"/><circle cx="12" cy="13" r="3.2" stroke="${color}" stroke-width="1.8" fill="none"/></svg>`,
+  mic: color => `<svg width="22" height="22" viewBox="0 0 24 24"><rect x="9" y="3" width="6" height="11" rx="3" stroke="${color}" stroke-width="1.8" fill="${color === 'white' ? 'white' : 'none'}"/><path d="M6 11a6 6 0 0 0 12 0M12 17v4" stroke="${color}" stroke-width="1.8" fill="none" stroke-linecap="round"/></svg>`,
+  send: color => `<svg width="20" height="20" viewBox="0 0 24 24"><path d="M3 20l18-8L3 4v6l12 2-12 2z" fill="${color}"/></svg>`
+};
+
+/**
+ * Input field contents: the draft with a caret, or the placeholder
+ * @param {string} [draft] - Typed text
+ * @param {string} [locale] - Locale of the placeholder
+ * @returns {string} HTML
+ */
+const inputText = (draft, locale) => (draft
+  ? `<span class="input-draft">${escapeHTML(draft)}<span class="input-caret"></span></span>`
+  : `<span class="input-placeholder">${escapeHTML(translate(locale, 'messagePlaceholder'))}</span>`);
+
+const PLATFORMS = {
+  android: {
+    // Tail on the first bubble of a group, in the top corner
+    bubbles: { tail: 'first', tailPlacement: 'top', radius: 7.5, groupSpacing: 2, senderSpacing: 8 },
+    style: `
+    * { font-family: Roboto, 'Noto Sans', 'Helvetica Neue', Arial, 'Noto Color Emoji', sans-serif; }
+    .chat-header { background-color: #008069; padding: 10px 12px; box-shadow: none; }
+    .back-button { margin-inline-end: 4px; font-size: 22px; }
+    .profile-pic { width: 38px; height: 38px; margin-inline-end: 10px; overflow: hidden; }
+    .profile-pic svg { width: 100%; height: 100%; }
+    .chat-info h2 { font-size: 17px; font-weight: 500; }
+    .chat-info p { font-size: 13px; }
+    .message.sent .message-content { background-color: #d9fdd3; }
+    .message.sent .message-time, .message.received .message-time { color: #667781; }
+    .message-status { width: 16px; height: 11px; background-image: ${doubleTick('#53bdeb')}; }`,
+    inputBarStyle: `
+    .input-bar { display: flex; align-items: flex-end; gap: 6px; padding: 6px 8px 10px; background-color: #e5ddd5; }
+    .input-field { flex: 1; display: flex; align-items: center; gap: 10px; min-height: 46px; padding: 0 14px;
+      background-color: white; border-radius: 23px; color: #8696a0; font-size: 17px; box-shadow: 0 1px 0.5px rgba(11, 20, 26, 0.13); }
+    .input-field > span { flex: 1; padding: 11px 0; }
+    .input-draft { color: #111b21; word-break: break-word; }
+    .input-caret { display: inline-block; width: 2px; height: 1.1em; margin-inline-start: 1px; vertical-align: text-bottom; background-color: #00a884; }
+    .input-action { flex: none; width: 46px; height: 46px; border-radius: 50%; background-color: #00a884;
+      display: flex; align-items: center; justify-content: center; }`,
+    inputBar: ({ draft }, locale) => `
+    <div class="input-bar">
+      <div class="input-field">
+        ${ICONS.emoji('#8696a0')}
+        ${inputText(draft, locale)}
+        ${draft ? '' : ICONS.camera('#8696a0')}
+      </div>
+      <div class="input-action">${draft ? ICONS.send('white') : ICONS.mic('white')}</div>
+    </div>`
+  },
+  ios: {
+    // Tail on the last bubble of a group, rounder corners
+    bubbles: { tail: 'last', tailPlacement: 'bottom', radius: 16, groupSpacing: 1, senderSpacing: 8 },
+    style: `
+    * { font-family: -apple-system, 'SF Pro Text', 'Helvetica Neue', Helvetica, Arial, 'Noto Color Emoji', sans-serif; }
+    .chat-header { background-color: #f6f6f6; color: #000; padding: 8px 10px; border-bottom: 1px solid #d1d1d6; box-shadow: none; }
+    .back-button { color: #007aff; font-size: 30px; line-height: 1; margin-inline-end: 6px; }
+    .profile-pic { order: 3; width: 36px; height: 36px; margin: 0 0 0 10px; overflow: hidden; }
+    .profile-pic svg { width: 100%; height: 100%; }
+    .chat-info { text-align: center; }
+    .chat-info h2 { font-size: 17px; font-weight: 600; }
+    .chat-info p { font-size: 12px; color: #8e8e93; opacity: 1; }
+    .message.sent .message-content { background-color: #dcf7c5; }
+    .message.sent .message-time, .message.received .message-time { color: #8e8e93; }
+    .message-status { width: 17px; height: 11px; background-image: ${doubleTick('#34b7f1')}; }`,
+    inputBarStyle: `
+    .input-bar { display: flex; align-items: flex-end; gap: 12px; padding: 8px 12px 10px; background-color: #f6f6f6; border-top: 1px solid #d1d1d6; }
+    .input-bar > svg, .input-plus { flex: none; margin-bottom: 5px; }
+    .input-field { flex: 1; min-height: 34px; padding: 6px 12px; display: flex; align-items: center; font-size: 16px;
+      background-color: white; border: 1px solid #d1d1d6; border-radius: 17px; }
+    .input-placeholder { visibility: hidden; }
+    .input-draft { color: #000; word-break: break-word; }
+    .input-caret { display: inline-block; width: 2px; height: 1.1em; margin-inline-start: 1px; vertical-align: text-bottom; background-color: #007aff; }
+    .input-plus { color: #007aff; font-size: 28px; line-height: 1; }
+    .input-send { flex: none; width: 30px; height: 30px; margin-bottom: 2px; border-radius: 50%; background-color: #007aff;
+      display: flex; align-items: center; justify-content: center; }`,
+    inputBar: ({ draft }, locale) => `
+    <div class="input-bar">
+      <span class="input-plus">+</span>
+      <div class="input-field">${inputText(draft, locale)}</div>
+      ${draft ? `<div class="input-send">${ICONS.send('white')}</div>` : `${ICONS.camera('#007aff')}
+      ${ICONS.mic('#007aff')}`}
+    </div>`
+  }
+};
+
+/**
+ * Template data for a platform look: the body class, the CSS appended to the
+ * template's styles, the input bar and the bubble preset. A keyboard or
+ * composer state needs an input bar, so without a platform they bring the
+ * input bar of the keyboard's style (android by default).
+ * @param {string} [platform] - "ios" or "android"
+ * @param {Object} [state] - { keyboard, composer, locale }; composer is { draft, reply, attachmentTray }
+ * @returns {Object} { platformClass, platformStyle, inputBar, bubbles }
+ */
+function resolvePlatform(platform, { keyboard, composer = {}, locale } = {}) {
+  const preset = PLATFORMS[platform];
+  const hasComposer = Boolean(composer.draft || composer.reply || composer.attachmentTray);
+  const inputStyle = platform || (keyboard && keyboard.style) || 'android';
+  if (!preset && !keyboard && !hasComposer) {
+    return { platformClass: '', platformStyle: '', inputBar: '', bubbles: undefined };
+  }
+  const inputPreset = PLATFORMS[inputStyle];
+  const parts = renderComposer(inputStyle, composer);
+  return {
+    platformClass: preset ? `platform-${platform}` : '',
+    platformStyle: `${preset ? preset.style : ''}${inputPreset.inputBarStyle}${parts.style}`.trim(),
+    inputBar: `${parts.before}${inputPreset.inputBar(composer, locale)}${parts.after}`.trim(),
+    bubbles: preset && preset.bubbles
+  };
+}
+
+module.exports = {
+  resolvePlatform,
+  PLATFORMS
+};
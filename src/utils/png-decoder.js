@@ -0,0 +1,101 @@
+// Decoder for the PNGs Chrome produces (8-bit RGB or RGBA, not interlaced),
+// used to turn captured frames into raw pixels for animation encoding
+
+const zlib = require('zlib');
+
+const PNG_SIGNATURE = Buffer.from([0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a]);
+const CHANNELS = { 2: 3, 6: 4 };
+
+/**
+ * Paeth predictor from the PNG specification
+ * @param {number} a - Left
+ * @param {number} b - Above
+ * @param {number} c - Upper left
+ * @returns {number} Predicted value
+ */
+function paeth(a, b, c) {
+  const p = a + b - c;
+  const pa = Math.abs(p - a);
+  const pb = Math.abs(p - b);
+  const pc = Math.abs(p - c);
+  if (pa <= pb && pa <= pc) {
+    return a;
+  }
+  return pb <= pc ? b : c;
+}
+
+/**
+ * Decode a PNG into RGBA pixels
+ * @param {Buffer} buffer - PNG file
+ * @returns {Object} { width, height, data: Buffer (RGBA) }
+ */
+function decodePng(buffer) {
+  if (!buffer.subarray(0, 8).equals(PNG_SIGNATURE)) {
+    throw new Error('Not a PNG image');
+  }
+
+  let width = 0;
+  let height = 0;
+  let channels = 0;
+  const idat = [];
+
+  for (let offset = 8; offset < buffer.length;) {
+    const length = buffer.readUInt32BE(offset);
+    const type = buffer.toString('ascii', offset + 4, offset + 8);
+    const chunk = buffer.subarray(offset + 8, offset + 8 + length);
+    if (type === 'IHDR') {
+      width = chunk.readUInt32BE(0);
+      height = chunk.readUInt32BE(4);
+      const bitDepth = chunk[8];
+      const colorType = chunk[9];
+      const interlace = chunk[12];
+      channels = CHANNELS[colorType];
+      if (bitDepth !== 8 || !channels || interlace !== 0) {
+        throw new Error(`Unsupported PNG (bit depth ${bitDepth}, color type ${colorType}, interlace ${interlace})`);
+      }
+    } else if (type === 'IDAT') {
+      idat.push(chunk);
+    } else if (type === 'IEND') {
+      break;
+    }
+    offset += 12 + length;
+  }
+
+  const raw = zlib.inflateSync(Buffer.concat(idat));
+  const stride = width * channels;
+  const pixels = Buffer.alloc(width * height * 4);
+  let previous = Buffer.alloc(stride);
+
+  for (let y = 0; y < height; y += 1) {
+    const filter = raw[y * (stride + 1)];
+    const line = Buffer.from(raw.subarray(y * (stride + 1) + 1, (y + 1) * (stride + 1)));
+
+    for (let x = 0; x < stride; x += 1) {
+      const left = x >= channels ? line[x - channels] : 0;
+      const up = previous[x];
+      const upLeft = x >= channels ? previous[x - channels] : 0;
+      switch (filter) {
+        case 1: line[x] = (line[x] + left) & 0xff; break;
+        case 2: line[x] = (line[x] + up) & 0xff; break;
+        case 3: line[x] = (line[x] + ((left + up) >> 1)) & 0xff; break;
+        case 4: line[x] = (line[x] + paeth(left, up, upLeft)) & 0xff; break;
+        default: break;
+      }
+    }
+
+    for (let x = 0; x < width; x += 1) {
+      const target = (y * width + x) * 4;
+      pixels[target] = line[x * channels];
+      pixels[target + 1] = line[x * channels + 1];
+      pixels[target + 2] = line[x * channels + 2];
+      pixels[target + 3] = channels === 4 ? line[x * channels + 3] : 255;
+    }
+    previous = line;
+  }
+
+  return { width, height, data: pixels };
+}
+
+module.exports = {
+  decodePng
+};
@@ -0,0 +1,55 @@
+// PNG encoder for images assembled in process (e.g. stitched compositions)
+
+const zlib = require('zlib');
+const { crc32 } = require('./archive');
+
+/**
+ * Build a PNG chunk
+ * @param {string} type - Chunk type
+ * @param {Buffer} data - Chunk data
+ * @returns {Buffer} Chunk with length and CRC
+ */
+function chunk(type, data) {
+  const length = Buffer.alloc(4);
+  length.writeUInt32BE(data.length, 0);
+  const body = Buffer.concat([Buffer.from(type, 'ascii'), data]);
+  const crc = Buffer.alloc(4);
+  crc.writeUInt32BE(crc32(body), 0);
+  return Buffer.concat([length, body, crc]);
+}
+
+/**
+ * Encode RGBA pixels as an 8-bit RGBA PNG. Rows use the "up" filter, which
+ * compresses flat UI backgrounds well.
+ * @param {Object} image - { width, height, data: RGBA Buffer }
+ * @returns {Buffer} PNG file
+ */
+function encodePng({ width, height, data }) {
+  const stride = width * 4;
+  const raw = Buffer.alloc((stride + 1) * height);
+  for (let y = 0; y < height; y += 1) {
+    const row = y * (stride + 1);
+    raw[row] = y === 0 ? 0 : 2;
+    for (let x = 0; x < stride; x += 1) {
+      const value = data[y * stride + x];
+      raw[row + 1 + x] = y === 0 ? value : (value - data[(y - 1) * stride + x]) & 0xff;
+    }
+  }
+
+  const header = Buffer.alloc(13);
+  header.writeUInt32BE(width, 0);
+  header.writeUInt32BE(height, 4);
+  header[8] = 8; // bit depth
+  header[9] = 6; // RGBA
+
+  return Buffer.concat([
+    Buffer.from([0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a]),
+    chunk('IHDR', header),
+    chunk('IDAT', zlib.deflateSync(raw)),
+    chunk('IEND', Buffer.alloc(0))
+  ]);
+}
+
+module.exports = {
+  encodePng
+};
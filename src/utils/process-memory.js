@@ -0,0 +1,78 @@
+const fs = require('fs');
+
+/**
+ * Read the resident set size of a single process from /proc
+ * @param {number} pid - Process ID
+ * @returns {number} RSS in bytes, 0 if unavailable
+ */
+function readRss(pid) {
+  try {
+    const status = fs.readFileSync(`/proc/${pid}/status`, 'utf8');
+    const match = status.match(/^VmRSS:\s+(\d+)\s+kB/m);
+    return match ? parseInt(match[1], 10) * 1024 : 0;
+  } catch (error) {
+    return 0;
+  }
+}
+
+/**
+ * Map every running process to its parent
+ * @returns {Map<number, number>} pid -> ppid
+ */
+function readParents() {
+  const parents = new Map();
+  let entries = [];
+  try {
+    entries = fs.readdirSync('/proc').filter(name => /^\d+$/.test(name));
+  } catch (error) {
+    return parents;
+  }
+
+  entries.forEach(name => {
+    try {
+      // The command name may contain spaces/parentheses; fields start after the last ")"
+      const stat = fs.readFileSync(`/proc/${name}/stat`, 'utf8');
+      const fields = stat.slice(stat.lastIndexOf(')') + 2).split(' ');
+      parents.set(parseInt(name, 10), parseInt(fields[1], 10));
+    } catch (error) {
+      // Process exited while scanning
+    }
+  });
+  return parents;
+}
+
+/**
+ * Total resident memory of a process and all of its descendants
+ * (Chrome spreads a browser over renderer, GPU and utility processes).
+ * Only supported where /proc is available (Linux); elsewhere returns null.
+ * @param {number} rootPid - Root process ID
+ * @returns {Object|null} { rss, processes } with rss in bytes
+ */
+function getProcessTreeMemory(rootPid) {
+  if (!rootPid || !fs.existsSync('/proc/self/status')) {
+    return null;
+  }
+
+  const parents = readParents();
+  const tree = new Set([rootPid]);
+  let grew = true;
+  while (grew) {
+    grew = false;
+    parents.forEach((ppid, pid) => {
+      if (!tree.has(pid) && tree.has(ppid)) {
+        tree.add(pid);
+        grew = true;
+      }
+    });
+  }
+
+  let rss = 0;
+  tree.forEach(pid => {
+    rss += readRss(pid);
+  });
+  return { rss, processes: tree.size };
+}
+
+module.exports = {
+  getProcessTreeMemory
+};
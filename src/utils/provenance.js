@@ -0,0 +1,183 @@
+// Provenance metadata embedded in PNG outputs: when and from which payload an
+// image was rendered, by which server, plus a hash of the pixel data. With
+// PROVENANCE_SECRET set the record is signed (HMAC-SHA256), so a consumer can
+// ask the verification endpoint whether an image was altered.
+
+const crypto = require('crypto');
+const os = require('os');
+const { crc32 } = require('./archive');
+
+const PNG_SIGNATURE = Buffer.from([0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a]);
+const PROVENANCE_KEYWORD = 'wa-mock-api:provenance';
+const PROVENANCE_VERSION = 1;
+
+/**
+ * SHA-256 of the request payload that produced an output
+ * @param {Array} messages - Request messages
+ * @param {Object} options - Request options
+ * @returns {string} Hex digest
+ */
+function hashPayload(messages, options) {
+  return crypto.createHash('sha256').update(JSON.stringify({ messages, options })).digest('hex');
+}
+
+/**
+ * Split a PNG into chunks
+ * @param {Buffer} png - PNG file
+ * @returns {Array<Object>} { type, data, start, end }
+ */
+function readPngChunks(png) {
+  if (!Buffer.isBuffer(png) || png.length < 8 || !png.subarray(0, 8).equals(PNG_SIGNATURE)) {
+    return null;
+  }
+  const chunks = [];
+  for (let offset = 8; offset + 12 <= png.length;) {
+    const length = png.readUInt32BE(offset);
+    const type = png.toString('ascii', offset + 4, offset + 8);
+    chunks.push({ type, data: png.subarray(offset + 8, offset + 8 + length), start: offset, end: offset + 12 + length });
+    offset += 12 + length;
+    if (type === 'IEND') {
+      break;
+    }
+  }
+  return chunks;
+}
+
+/**
+ * Hash the image data (all IDAT chunks); metadata chunks are not included
+ * @param {Array<Object>} chunks - From readPngChunks
+ * @returns {string} Hex digest
+ */
+function hashImageData(chunks) {
+  const hash = crypto.createHash('sha256');
+  chunks.filter(chunk => chunk.type === 'IDAT').forEach(chunk => hash.update(chunk.data));
+  return hash.digest('hex');
+}
+
+/**
+ * Sign a provenance record without its signature field
+ * @param {Object} record - Provenance record
+ * @param {string} secret - Signing secret
+ * @returns {string} Hex HMAC
+ */
+function signRecord(record, secret) {
+  const { signature, ...fields } = record;
+  const canonical = JSON.stringify(Object.keys(fields).sort().reduce((sorted, key) => ({ ...sorted, [key]: fields[key] }), {}));
+  return crypto.createHmac('sha256', secret).update(canonical).digest('hex');
+}
+
+/**
+ * Build a PNG tEXt chunk
+ * @param {string} keyword - Keyword
+ * @param {string} text - Latin-1 text
+ * @returns {Buffer} Chunk
+ */
+function textChunk(keyword, text) {
+  const body = Buffer.concat([Buffer.from('tEXt', 'ascii'), Buffer.from(keyword, 'latin1'), Buffer.from([0]), Buffer.from(text, 'latin1')]);
+  const length = Buffer.alloc(4);
+  length.writeUInt32BE(body.length - 4, 0);
+  const crc = Buffer.alloc(4);
+  crc.writeUInt32BE(crc32(body), 0);
+  return Buffer.concat([length, body, crc]);
+}
+
+/**
+ * Embed a provenance record into a PNG, right after IHDR
+ * @param {Buffer} png - PNG file
+ * @param {Object} fields - { payloadHash, requestId, renderedAt }
+ * @returns {Object} { png, record }
+ */
+function embedProvenance(png, { payloadHash, requestId = null, renderedAt = new Date().toISOString() }) {
+  const chunks = readPngChunks(png);
+  if (!chunks || chunks[0].type !== 'IHDR') {
+    throw new Error('Provenance can only be embedded into PNG images');
+  }
+
+  const record = {
+    version: PROVENANCE_VERSION,
+    renderedAt,
+    payloadHash,
+    requestId,
+    server: process.env.PROVENANCE_SERVER_ID || os.hostname(),
+    imageHash: hashImageData(chunks)
+  };
+  const secret = process.env.PROVENANCE_SECRET;
+  if (secret) {
+    record.signature = signRecord(record, secret);
+  }
+
+  // tEXt is Latin-1; the record holds only hashes, ISO dates and IDs
+  const chunk = textChunk(PROVENANCE_KEYWORD, JSON.stringify(record));
+  const afterHeader = chunks[0].end;
+  return {
+    png: Buffer.concat([png.subarray(0, afterHeader), chunk, png.subarray(afterHeader)]),
+    record
+  };
+}
+
+/**
+ * Read the provenance record from a PNG
+ * @param {Array<Object>} chunks - From readPngChunks
+ * @returns {Object|null} Record, or null when absent or unreadable
+ */
+function extractProvenance(chunks) {
+  const chunk = chunks.find(({ type, data }) => type === 'tEXt'
+    && data.toString('latin1', 0, PROVENANCE_KEYWORD.length + 1) === `${PROVENANCE_KEYWORD}\0`);
+  if (!chunk) {
+    return null;
+  }
+  try {
+    return JSON.parse(chunk.data.toString('latin1', PROVENANCE_KEYWORD.length + 1));
+  } catch (error) {
+    return null;
+  }
+}
+
+/**
+ * Verify a PNG against its embedded provenance record. Anyone can recompute
+ * imageHash after editing the pixels, so only a signature checked with
+ * PROVENANCE_SECRET makes a record valid; without the secret, verification
+ * reports a mismatching hash but never a valid record.
+ * @param {Buffer} png - PNG file
+ * @returns {Object} { valid, signed, problems, provenance }
+ */
+function verifyProvenance(png) {
+  const chunks = readPngChunks(png);
+  if (!chunks) {
+    return { valid: false, signed: false, problems: ['not a PNG image'], provenance: null };
+  }
+
+  const record = extractProvenance(chunks);
+  if (!record) {
+    return { valid: false, signed: false, problems: ['no provenance record'], provenance: null };
+  }
+
+  const problems = [];
+  if (record.imageHash !== hashImageData(chunks)) {
+    problems.push('image data does not match imageHash');
+  }
+
+  const secret = process.env.PROVENANCE_SECRET;
+  const signed = Boolean(record.signature);
+  if (!secret) {
+    problems.push('record cannot be authenticated: PROVENANCE_SECRET is not set');
+  } else if (!signed) {
+    problems.push('record is not signed');
+  } else {
+    const expected = Buffer.from(signRecord(record, secret), 'hex');
+    const actual = Buffer.from(String(record.signature), 'hex');
+    if (expected.length !== actual.length || !crypto.timingSafeEqual(expected, actual)) {
+      problems.push('signature does not match');
+    }
+  }
+
+  return { valid: problems.length === 0, signed, problems, provenance: record };
+}
+
+module.exports = {
+  hashPayload,
+  embedProvenance,
+  verifyProvenance,
+  readPngChunks,
+  PROVENANCE_KEYWORD
+};
@@ -0,0 +1,81 @@
+// Network resource tracking for a page. When a render comes out blank or
+// incomplete, the report shows which resources (avatars, media, fonts)
+// failed or never finished, with their status codes.
+
+const MAX_ENTRIES = 200;
+
+/**
+ * Track every request a page makes
+ * @param {Object} page - Puppeteer page
+ * @returns {Object} Tracker with a report() method
+ */
+function trackResources(page) {
+  const entries = new Map();
+
+  page.on('request', request => {
+    if (entries.size >= MAX_ENTRIES) {
+      return;
+    }
+    const url = request.url();
+    entries.set(request, {
+      // data: URLs can be huge; the prefix is enough to identify them
+      url: url.startsWith('data:') ? `${url.slice(0, 48)}...` : url,
+      method: request.method(),
+      resourceType: request.resourceType(),
+      status: null,
+      state: 'pending',
+      error: null,
+      startedAt: Date.now(),
+      durationMs: null
+    });
+  });
+
+  page.on('response', response => {
+    const entry = entries.get(response.request());
+    if (entry) {
+      entry.status = response.status();
+    }
+  });
+
+  page.on('requestfinished', request => {
+    const entry = entries.get(request);
+    if (entry) {
+      entry.state = entry.status >= 400 ? 'failed' : 'finished';
+      entry.durationMs = Date.now() - entry.startedAt;
+    }
+  });
+
+  page.on('requestfailed', request => {
+    const entry = entries.get(request);
+    if (entry) {
+      const failure = request.failure();
+      entry.state = 'failed';
+      entry.error = failure ? failure.errorText : 'unknown';
+      entry.durationMs = Date.now() - entry.startedAt;
+    }
+  });
+
+  return {
+    /**
+     * Summarize the tracked requests
+     * @returns {Object} { total, failed, pending, incomplete, resources }
+     */
+    report() {
+      const resources = [...entries.values()].map(({ startedAt, ...entry }) => entry);
+      const failed = resources.filter(entry => entry.state === 'failed');
+      const pending = resources.filter(entry => entry.state === 'pending');
+      return {
+        total: resources.length,
+        failed: failed.length,
+        pending: pending.length,
+        incomplete: failed.length > 0 || pending.length > 0,
+        truncated: entries.size >= MAX_ENTRIES,
+        resources
+      };
+    }
+  };
+}
+
+module.exports = {
+  trackResources
+};
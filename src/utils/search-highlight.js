@@ -0,0 +1,49 @@
+const { escapeHTML } = require('./html-escape');
+
+// Splits rendered HTML into tags and text runs; only text runs are searched
+const TAG_SPLIT_PATTERN = /(<[^>]*>)/;
+
+/**
+ * Compile a case-insensitive pattern for a search term. The term is matched
+ * against escaped HTML text, so it is escaped the same way first.
+ * @param {string} term - Search term
+ * @returns {RegExp|null} Pattern, or null for an empty term
+ */
+function compileSearchPattern(term) {
+  const trimmed = (term || '').trim();
+  if (!trimmed) {
+    return null;
+  }
+  const escaped = escapeHTML(trimmed).replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+  return new RegExp(escaped, 'gi');
+}
+
+/**
+ * Wrap every match of a search pattern in rendered message HTML with
+ * <mark class="search-match">, leaving tags and attributes untouched.
+ * Matches spanning formatting (e.g. half bold) are not found.
+ * @param {string} html - Message HTML
+ * @param {RegExp} pattern - Pattern from compileSearchPattern
+ * @returns {Object} { html, count }
+ */
+function highlightMatches(html, pattern) {
+  let count = 0;
+  const output = html
+    .split(TAG_SPLIT_PATTERN)
+    .map(part => {
+      if (part.startsWith('<')) {
+        return part;
+      }
+      return part.replace(pattern, match => {
+        count += 1;
+        return `<mark class="search-match">${match}</mark>`;
+      });
+    })
+    .join('');
+  return { html: output, count };
+}
+
+module.exports = {
+  compileSearchPattern,
+  highlightMatches
+};
@@ -0,0 +1,56 @@
+const { ApiError } = require('../middleware/error.middleware');
+const { systemClock } = require('./clock');
+
+// "Sender: message"; the sender is at most 64 characters without a colon
+const LINE_PATTERN = /^([^:\n]{1,64}):\s?(.*)$/;
+
+/**
+ * Build request messages from the flat /api/simple payload. Each line of
+ * text is "Sender: message"; lines without a sender continue the previous
+ * message. Messages from `me` (by default the first sender) are sent, the
+ * others received. Timestamps start at start_time and advance by
+ * interval_seconds; without start_time, the last message is at the clock's now.
+ * @param {Object} simple - { text, chat_name, chat_phone, me, start_time, interval_seconds }
+ * @param {Object} [clock] - Clock ({ now() }), the system clock by default
+ * @returns {Array<Object>} Messages for the screenshot pipeline
+ */
+function parseSimpleChat({ text, chat_name: chatName, chat_phone: chatPhone, me, start_time: startTime, interval_seconds: intervalSeconds = 60 }, clock = systemClock) {
+  // Low-code tools often send escaped newlines when the field is single-line
+  const normalized = (/\n/.test(text) ? text : text.replace(/\\n/g, '\n')).replace(/\r\n?/g, '\n');
+  const entries = [];
+
+  normalized.split('\n').forEach((line, index) => {
+    const match = LINE_PATTERN.exec(line);
+    if (match && match[1].trim()) {
+      entries.push({ sender: match[1].trim(), lines: [match[2]] });
+    } else if (entries.length > 0) {
+      entries[entries.length - 1].lines.push(line);
+    } else if (line.trim()) {
+      throw new ApiError(400, `text line ${index + 1} must start with "Sender:"`)
+        .annotate({ stage: 'validate', code: 'simple_line_invalid' });
+    }
+  });
+
+  const messages = entries
+    .map(entry => ({ sender: entry.sender, content: entry.lines.join('\n').trim() }))
+    .filter(entry => entry.content);
+  if (messages.length === 0) {
+    throw new ApiError(400, 'text contains no messages').annotate({ stage: 'validate', code: 'simple_text_empty' });
+  }
+
+  const outgoing = (me || messages[0].sender).trim().toLowerCase();
+  const intervalMs = intervalSeconds * 1000;
+  const start = startTime ? new Date(startTime).getTime() : clock.now().getTime() - (messages.length - 1) * intervalMs;
+
+  return messages.map((message, index) => ({
+    timestamp: new Date(start + index * intervalMs).toISOString(),
+    sender: message.sender.toLowerCase() === outgoing ? 'Bot' : 'Customer',
+    content: message.content,
+    ...(chatName && { recipient_name: chatName }),
+    ...(chatPhone && { recipient_phone: chatPhone })
+  }));
+}
+
+module.exports = {
+  parseSimpleChat
+};
@@ -0,0 +1,176 @@
+// Lightweight server-side syntax highlighter for fenced code blocks.
+// It only knows comments, strings, numbers, keywords, literals and function
+// calls, which is enough for chat-sized snippets and keeps the API free of a
+// heavyweight highlighting dependency.
+const { escapeHTML } = require('./html-escape');
+
+const C_STYLE_COMMENTS = ['//[^\\n]*', '/\\*[\\s\\S]*?\\*/'];
+const HASH_COMMENTS = ['#[^\\n]*'];
+const DOUBLE_QUOTED = '"(?:\\\\.|[^"\\\\\\n])*"';
+const SINGLE_QUOTED = '\'(?:\\\\.|[^\'\\\\\\n])*\'';
+const BACKTICK_QUOTED = '`(?:\\\\.|[^`\\\\])*`';
+
+const LANGUAGES = {
+  javascript: {
+    aliases: ['js', 'jsx', 'ts', 'tsx', 'typescript', 'node'],
+    comments: C_STYLE_COMMENTS,
+    strings: [DOUBLE_QUOTED, SINGLE_QUOTED, BACKTICK_QUOTED],
+    keywords: ['async', 'await', 'break', 'case', 'catch', 'class', 'const', 'continue', 'default', 'delete', 'do',
+      'else', 'export', 'extends', 'finally', 'for', 'from', 'function', 'if', 'import', 'in', 'instanceof', 'interface',
+      'let', 'new', 'of', 'return', 'static', 'switch', 'throw', 'try', 'type', 'typeof', 'var', 'void', 'while', 'yield'],
+    literals: ['true', 'false', 'null', 'undefined', 'this', 'NaN']
+  },
+  python: {
+    aliases: ['py', 'python3'],
+    comments: HASH_COMMENTS,
+    strings: ['"""[\\s\\S]*?"""', '\'\'\'[\\s\\S]*?\'\'\'', DOUBLE_QUOTED, SINGLE_QUOTED],
+    keywords: ['and', 'as', 'assert', 'async', 'await', 'break', 'class', 'continue', 'def', 'del', 'elif', 'else',
+      'except', 'finally', 'for', 'from', 'global', 'if', 'import', 'in', 'is', 'lambda', 'nonlocal', 'not', 'or',
+      'pass', 'raise', 'return', 'try', 'while', 'with', 'yield'],
+    literals: ['True', 'False', 'None', 'self']
+  },
+  go: {
+    aliases: ['golang'],
+    comments: C_STYLE_COMMENTS,
+    strings: [DOUBLE_QUOTED, BACKTICK_QUOTED, SINGLE_QUOTED],
+    keywords: ['break', 'case', 'chan', 'const', 'continue', 'default', 'defer', 'else', 'fallthrough', 'for', 'func',
+      'go', 'goto', 'if', 'import', 'interface', 'map', 'package', 'range', 'return', 'select', 'struct', 'switch',
+      'type', 'var'],
+    literals: ['true', 'false', 'nil', 'iota']
+  },
+  java: {
+    aliases: ['kotlin', 'kt', 'c', 'cpp', 'c++', 'csharp', 'cs'],
+    comments: C_STYLE_COMMENTS,
+    strings: [DOUBLE_QUOTED, SINGLE_QUOTED],
+    keywords: ['abstract', 'break', 'case', 'catch', 'class', 'const', 'continue', 'default', 'do', 'else', 'enum',
+      'extends', 'final', 'finally', 'for', 'fun', 'if', 'implements', 'import', 'interface', 'namespace', 'new',
+      'package', 'private', 'protected', 'public', 'return', 'static', 'struct', 'switch', 'throw', 'throws', 'try',
+      'using', 'val', 'var', 'void', 'while', 'int', 'long', 'double', 'float', 'bool', 'boolean', 'char', 'string'],
+    literals: ['true', 'false', 'null', 'this', 'NULL', 'nullptr']
+  },
+  bash: {
+    aliases: ['sh', 'shell', 'zsh', 'console'],
+    comments: HASH_COMMENTS,
+    strings: [DOUBLE_QUOTED, SINGLE_QUOTED],
+    keywords: ['if', 'then', 'else', 'elif', 'fi', 'for', 'while', 'do', 'done', 'case', 'esac', 'function', 'in',
+      'export', 'local', 'return', 'echo', 'cd', 'sudo', 'curl', 'npm', 'git'],
+    literals: ['true', 'false']
+  },
+  sql: {
+    aliases: ['mysql', 'postgres', 'postgresql', 'sqlite'],
+    comments: ['--[^\\n]*', '/\\*[\\s\\S]*?\\*/'],
+    strings: [SINGLE_QUOTED, DOUBLE_QUOTED],
+    keywords: ['select', 'from', 'where', 'and', 'or', 'not', 'insert', 'into', 'values', 'update', 'set', 'delete',
+      'create', 'table', 'drop', 'alter', 'join', 'left', 'right', 'inner', 'outer', 'on', 'group', 'by', 'order',
+      'having', 'limit', 'offset', 'as', 'distinct', 'count', 'in', 'is', 'like', 'between', 'union'],
+    literals: ['null', 'true', 'false'],
+    caseInsensitive: true
+  },
+  json: {
+    aliases: [],
+    comments: [],
+    strings: [DOUBLE_QUOTED],
+    keywords: [],
+    literals: ['true', 'false', 'null']
+  }
+};
+
+const compiledLanguages = new Map();
+
+/**
+ * Find the language definition for a fence hint
+ * @param {string} hint - Language hint after the opening fence
+ * @returns {Object|null} Compiled language, or null if unsupported
+ */
+function resolveLanguage(hint) {
+  const name = (hint || '').toLowerCase();
+  if (!name) {
+    return null;
+  }
+
+  if (compiledLanguages.has(name)) {
+    return compiledLanguages.get(name);
+  }
+
+  const key = Object.keys(LANGUAGES).find(lang => lang === name || LANGUAGES[lang].aliases.includes(name));
+  if (!key) {
+    return null;
+  }
+
+  const lang = LANGUAGES[key];
+  const alternatives = [];
+  if (lang.comments.length) {
+    alternatives.push(`(?<comment>${lang.comments.join('|')})`);
+  }
+  alternatives.push(`(?<string>${lang.strings.join('|')})`);
+  alternatives.push('(?<number>\\b\\d+(?:\\.\\d+)?\\b)');
+  alternatives.push('(?<word>[A-Za-z_$][\\w$]*)');
+
+  const compiled = {
+    name: key,
+    pattern: new RegExp(alternatives.join('|'), 'g'),
+    keywords: new Set(lang.keywords),
+    literals: new Set(lang.literals),
+    caseInsensitive: Boolean(lang.caseInsensitive)
+  };
+  compiledLanguages.set(name, compiled);
+  return compiled;
+}
+
+/**
+ * Wrap a token in a highlight span
+ * @param {string} cls - Token class suffix
+ * @param {string} text - Raw token text
+ * @returns {string} HTML span
+ */
+function token(cls, text) {
+  return `<span class="hl-${cls}">${escapeHTML(text)}</span>`;
+}
+
+/**
+ * Highlight a code snippet
+ * @param {string} code - Raw code
+ * @param {string} hint - Language hint
+ * @returns {string} Escaped HTML with highlight spans
+ */
+function highlightCode(code, hint) {
+  const lang = resolveLanguage(hint);
+  if (!lang) {
+    return escapeHTML(code);
+  }
+
+  let html = '';
+  let lastIndex = 0;
+
+  for (const match of code.matchAll(lang.pattern)) {
+    html += escapeHTML(code.slice(lastIndex, match.index));
+    lastIndex = match.index + match[0].length;
+
+    const { comment, string, number, word } = match.groups;
+    if (comment) {
+      html += token('c', comment);
+    } else if (string) {
+      html += token('s', string);
+    } else if (number) {
+      html += token('n', number);
+    } else {
+      const lookup = lang.caseInsensitive ? word.toLowerCase() : word;
+      if (lang.keywords.has(lookup)) {
+        html += token('k', word);
+      } else if (lang.literals.has(lookup)) {
+        html += token('l', word);
+      } else if (code[lastIndex] === '(') {
+        html += token('f', word);
+      } else {
+        html += escapeHTML(word);
+      }
+    }
+  }
+
+  html += escapeHTML(code.slice(lastIndex));
+  return html;
+}
+
+module.exports = {
+  highlightCode
+};
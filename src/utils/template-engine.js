@@ -0,0 +1,282 @@
+const path = require('path');
+const { ApiError } = require('../middleware/error.middleware');
+const { formatNumber, formatCurrency } = require('./number-format');
+const { getTimeFormatter } = require('./i18n');
+const { parseListEnv } = require('./env');
+
+/**
+ * Raised when a sandboxed template violates the sandbox policy
+ */
+class TemplateSandboxError extends ApiError {
+  constructor(message) {
+    super(422, `Template sandbox violation: ${message}`);
+    this.annotate({ stage: 'template', code: 'template_sandbox_violation' });
+  }
+}
+
+// Registered template helpers, callable from templates as {{name arg1 arg2}}
+const templateFunctions = new Map();
+
+/**
+ * Register a template helper
+ * @param {string} name - Helper name used in templates
+ * @param {Function} fn - Helper implementation, receives the resolved arguments
+ */
+function registerTemplateFunction(name, fn) {
+  if (!/^[A-Za-z_][\w]*$/.test(name)) {
+    throw new Error(`Invalid template function name: ${name}`);
+  }
+  if (typeof fn !== 'function') {
+    throw new Error(`Template function ${name} must be a function`);
+  }
+  templateFunctions.set(name, fn);
+}
+
+/**
+ * Register several template helpers at once
+ * @param {Object} functions - Map of name -> function
+ */
+function registerTemplateFunctions(functions = {}) {
+  Object.entries(functions).forEach(([name, fn]) => registerTemplateFunction(name, fn));
+}
+
+/**
+ * Get a snapshot of the registered helpers
+ * @returns {Object} Map of name -> function
+ */
+function getTemplateFunctions() {
+  return Object.fromEntries(templateFunctions);
+}
+
+/**
+ * Load helpers from the module configured in TEMPLATE_FUNCTIONS_MODULE.
+ * The module must export an object of name -> function.
+ */
+function loadTemplateFunctionsFromConfig() {
+  const modulePath = process.env.TEMPLATE_FUNCTIONS_MODULE;
+  if (!modulePath) {
+    return;
+  }
+
+  const resolved = path.resolve(process.cwd(), modulePath);
+  console.log('Loading template functions from:', resolved);
+  registerTemplateFunctions(require(resolved));
+}
+
+/**
+ * Resolve a dotted path against the template data
+ * @param {Object} data - Template data
+ * @param {string} key - Dotted path, e.g. "recipient.name"
+ * @returns {*} Value, or undefined
+ */
+function lookup(data, key) {
+  return key.split('.').reduce((value, part) => (value == null ? undefined : value[part]), data);
+}
+
+/**
+ * Resolve a single helper argument: quoted strings and numbers are literals,
+ * anything else is looked up in the template data
+ * @param {string} token - Raw argument token
+ * @param {Object} data - Template data
+ * @returns {*} Argument value
+ */
+function resolveArgument(token, data) {
+  if (/^(['"]).*\1$/.test(token)) {
+    return token.slice(1, -1);
+  }
+  if (/^-?\d+(\.\d+)?$/.test(token)) {
+    return Number(token);
+  }
+  if (token === 'true' || token === 'false') {
+    return token === 'true';
+  }
+  return lookup(data, token);
+}
+
+/**
+ * Split a placeholder expression into helper name and argument tokens
+ * @param {string} expression - Placeholder body
+ * @returns {Array<string>} Tokens
+ */
+function tokenize(expression) {
+  return expression.match(/'[^']*'|"[^"]*"|\S+/g) || [];
+}
+
+// Constructs uploaded templates may not contain: scripts, inline event
+// handlers, frames/embeds and anything that makes Chrome fetch remote content
+const FORBIDDEN_CONSTRUCTS = [
+  { pattern: /<\s*script\b/i, message: '<script> elements are not allowed' },
+  { pattern: /\son[a-z]+\s*=/i, message: 'inline event handlers (on*=) are not allowed' },
+  { pattern: /javascript\s*:/i, message: 'javascript: URLs are not allowed' },
+  { pattern: /<\s*(iframe|frame|object|embed|base|link|form|meta)\b/i, message: '<$1> elements are not allowed' },
+  { pattern: /@import\b/i, message: 'CSS @import is not allowed' },
+  { pattern: /(?:src|href|action)\s*=\s*["']?\s*(?:https?:)?\/\//i, message: 'remote resource URLs are not allowed' },
+  { pattern: /url\(\s*["']?\s*(?:https?:)?\/\//i, message: 'remote CSS url() references are not allowed' }
+];
+
+/**
+ * Restrictions applied to sandboxed (uploaded) templates. Configured through
+ * TEMPLATE_SANDBOX_FUNCTIONS (comma separated extra helpers),
+ * TEMPLATE_SANDBOX_TIMEOUT_MS, TEMPLATE_SANDBOX_MAX_OUTPUT_BYTES and
+ * TEMPLATE_SANDBOX_MAX_TEMPLATE_BYTES.
+ * @returns {Object} Sandbox policy
+ */
+function getSandboxPolicy() {
+  const extraFunctions = parseListEnv('TEMPLATE_SANDBOX_FUNCTIONS');
+
+  return {
+    functions: new Set([...BUILTIN_FUNCTION_NAMES, ...extraFunctions]),
+    timeoutMs: parseInt(process.env.TEMPLATE_SANDBOX_TIMEOUT_MS, 10) || 200,
+    maxOutputBytes: parseInt(process.env.TEMPLATE_SANDBOX_MAX_OUTPUT_BYTES, 10) || 5 * 1024 * 1024,
+    maxTemplateBytes: parseInt(process.env.TEMPLATE_SANDBOX_MAX_TEMPLATE_BYTES, 10) || 256 * 1024
+  };
+}
+
+/**
+ * Lint a template before it is accepted for sandboxed execution
+ * @param {string} source - Template source
+ * @returns {Array<string>} Problems found; empty when the template is acceptable
+ */
+function lintTemplate(source) {
+  const policy = getSandboxPolicy();
+  const problems = [];
+
+  if (typeof source !== 'string' || source.length === 0) {
+    return ['template source is empty'];
+  }
+  if (Buffer.byteLength(source) > policy.maxTemplateBytes) {
+    problems.push(`template exceeds ${policy.maxTemplateBytes} bytes`);
+  }
+
+  FORBIDDEN_CONSTRUCTS.forEach(({ pattern, message }) => {
+    const match = source.match(pattern);
+    if (match) {
+      problems.push(message.replace('$1', (match[1] || '').toLowerCase()));
+    }
+  });
+
+  for (const match of source.matchAll(/\{\{\s*([^{}]+?)\s*\}\}/g)) {
+    const [name, ...args] = tokenize(match[1]);
+    if (args.length > 0 && !policy.functions.has(name)) {
+      problems.push(`template function "${name}" is not allowed`);
+    }
+  }
+
+  return problems;
+}
+
+/**
+ * Parse a template into the data keys and helpers it references
+ * @param {string} source - Template source
+ * @returns {Object} { fields, functions } with unique, sorted names
+ */
+function describeTemplate(source) {
+  const fields = new Set();
+  const functions = new Set();
+
+  for (const match of source.matchAll(/\{\{\s*([^{}]+?)\s*\}\}/g)) {
+    const [name, ...args] = tokenize(match[1]);
+    if (templateFunctions.has(name) || args.length > 0) {
+      functions.add(name);
+      args
+        .filter(arg => !/^(['"]).*\1$/.test(arg) && !/^-?\d+(\.\d+)?$/.test(arg) && arg !== 'true' && arg !== 'false')
+        .forEach(arg => fields.add(arg));
+    } else {
+      fields.add(name);
+    }
+  }
+
+  return {
+    fields: [...fields].sort(),
+    functions: [...functions].sort()
+  };
+}
+
+/**
+ * Render a template by replacing {{key}} with data values and
+ * {{helper arg ...}} with the result of a registered helper.
+ * The template is scanned once, so inserted values are never re-evaluated.
+ * In sandbox mode only whitelisted helpers may run, and rendering is aborted
+ * when it exceeds the time or output size limits.
+ * @param {string} template - Template source
+ * @param {Object} data - Template data
+ * @param {Object} options - Render options
+ * @param {boolean} options.sandbox - Apply the sandbox policy
+ * @returns {string} Rendered output
+ */
+function renderTemplate(template, data = {}, { sandbox = false } = {}) {
+  const policy = sandbox ? getSandboxPolicy() : null;
+  const startedAt = Date.now();
+  let outputBytes = sandbox ? Buffer.byteLength(template) : 0;
+
+  const output = template.replace(/\{\{\s*([^{}]+?)\s*\}\}/g, (match, expression) => {
+    if (policy && Date.now() - startedAt > policy.timeoutMs) {
+      throw new TemplateSandboxError(`template execution exceeded ${policy.timeoutMs}ms`);
+    }
+
+    const [name, ...args] = tokenize(expression);
+    let result;
+
+    if (templateFunctions.has(name) && (!policy || policy.functions.has(name))) {
+      result = templateFunctions.get(name)(...args.map(arg => resolveArgument(arg, data)));
+    } else if (args.length > 0 && policy) {
+      throw new TemplateSandboxError(`template function "${name}" is not allowed`);
+    } else {
+      result = lookup(data, name);
+    }
+
+    const text = result == null ? '' : String(result);
+    if (policy) {
+      outputBytes += Buffer.byteLength(text);
+      if (outputBytes > policy.maxOutputBytes) {
+        throw new TemplateSandboxError(`template output exceeded ${policy.maxOutputBytes} bytes`);
+      }
+    }
+    return text;
+  });
+
+  if (policy && Date.now() - startedAt > policy.timeoutMs) {
+    throw new TemplateSandboxError(`template execution exceeded ${policy.timeoutMs}ms`);
+  }
+  return output;
+}
+
+/**
+ * Format a timestamp as a clock time, like the bubble times
+ * @param {string|number} value - ISO timestamp or epoch milliseconds
+ * @param {string} [locale] - BCP 47 locale
+ * @param {string} [timeFormat] - "12h" or "24h", the locale's own by default
+ * @returns {string} Time, or the value unchanged when it is not a date
+ */
+function formatTimestamp(value, locale, timeFormat) {
+  const date = new Date(value);
+  if (value == null || value === '' || Number.isNaN(date.getTime())) {
+    return value;
+  }
+  return getTimeFormatter(locale || undefined, undefined, timeFormat || undefined).format(date);
+}
+
+// Built-in helpers
+const BUILTIN_FUNCTIONS = {
+  upper: value => String(value == null ? '' : value).toUpperCase(),
+  lower: value => String(value == null ? '' : value).toLowerCase(),
+  initial: value => String(value == null ? '' : value).charAt(0).toUpperCase(),
+  default: (value, fallback) => (value == null || value === '' ? fallback : value),
+  formatNumber,
+  formatCurrency,
+  formatTimestamp
+};
+const BUILTIN_FUNCTION_NAMES = Object.keys(BUILTIN_FUNCTIONS);
+registerTemplateFunctions(BUILTIN_FUNCTIONS);
+
+module.exports = {
+  renderTemplate,
+  lintTemplate,
+  describeTemplate,
+  getSandboxPolicy,
+  TemplateSandboxError,
+  registerTemplateFunction,
+  registerTemplateFunctions,
+  getTemplateFunctions,
+  loadTemplateFunctionsFromConfig
+};
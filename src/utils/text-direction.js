@@ -0,0 +1,70 @@
+// Strong right-to-left scripts: Hebrew, Arabic, Syriac, Thaana, NKo and the
+// Arabic/Hebrew presentation forms
+const RTL_CHARS = /[\u0590-\u08FF\uFB1D-\uFDFF\uFE70-\uFEFF]/g;
+// Strong left-to-right characters: Latin, Greek, Cyrillic and most other
+// alphabetic scripts outside the RTL blocks
+const LTR_CHARS = /[A-Za-z\u00C0-\u024F\u0370-\u03FF\u0400-\u04FF\u0900-\u0DFF\u0E00-\u0E7F\u3040-\u30FF\u4E00-\u9FFF\uAC00-\uD7AF]/g;
+
+// Supported chat-level directions
+const DIRECTIONS = ['ltr', 'rtl'];
+
+/**
+ * Detect the dominant direction of a piece of text
+ * @param {string} text - Raw text
+ * @returns {string|null} "rtl", "ltr", or null when the text has no strong characters
+ */
+function detectDirection(text) {
+  if (!text || typeof text !== 'string') {
+    return null;
+  }
+
+  const rtl = (text.match(RTL_CHARS) || []).length;
+  const ltr = (text.match(LTR_CHARS) || []).length;
+
+  if (rtl === 0 && ltr === 0) {
+    return null;
+  }
+  return rtl > ltr ? 'rtl' : 'ltr';
+}
+
+/**
+ * Detect the direction of a whole chat: right-to-left when more messages
+ * are dominantly right-to-left than left-to-right
+ * @param {Array<Object>} messages - Request messages; an explicit message
+ *   direction counts as that message's direction
+ * @returns {string} "ltr" or "rtl"
+ */
+function detectChatDirection(messages) {
+  let balance = 0;
+  messages.forEach(msg => {
+    const dir = msg.direction || detectDirection(msg.content);
+    balance += dir === 'rtl' ? 1 : dir === 'ltr' ? -1 : 0;
+  });
+  return balance > 0 ? 'rtl' : 'ltr';
+}
+
+/**
+ * Resolve the direction of a single bubble
+ * @param {string} content - Raw message content
+ * @param {Object} options - Direction options
+ * @param {string} options.direction - Chat-level direction
+ * @param {boolean} options.autoDirection - Whether to detect per message
+ * @param {string} [explicit] - The message's own direction, which always wins
+ * @returns {string} "ltr" or "rtl"
+ */
+function resolveMessageDirection(content, { direction = 'ltr', autoDirection = true } = {}, explicit) {
+  if (explicit) {
+    return explicit;
+  }
+  if (!autoDirection) {
+    return direction;
+  }
+  return detectDirection(content) || direction;
+}
+
+module.exports = {
+  detectDirection,
+  detectChatDirection,
+  resolveMessageDirection,
+  DIRECTIONS
+};
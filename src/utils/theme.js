@@ -0,0 +1,84 @@
+// Color themes for options.theme. The light theme is the template's own
+// palette, so only the dark theme adds CSS.
+
+/**
+ * Bubble tail as a CSS url(), in the bubble's color
+ * @param {string} path - SVG path of the tail
+ * @param {string} color - Fill color, e.g. #005c4b
+ * @returns {string} CSS url() value
+ */
+const tail = (path, color) => `url("data:image/svg+xml;charset=utf-8,${encodeURIComponent(
+  `<svg xmlns='http://www.w3.org/2000/svg' viewBox='0 0 8 13'><path fill='${color}' d='${path}'/></svg>`
+)}")`;
+
+const SENT_TAIL = 'M5.188 13H0V1.807l6.467 8.625C7.526 11.844 6.958 13 5.188 13z';
+const RECEIVED_TAIL = 'M1.533 10.432L8 1.807V13H2.812C1.042 13 .474 11.844 1.533 10.432z';
+
+// WhatsApp's dark palette
+const DARK = {
+  background: '#0b141a',
+  header: '#1f2c34',
+  headerIos: '#1c1c1e',
+  border: '#2a3942',
+  sent: '#005c4b',
+  received: '#202c33',
+  text: '#e9edef',
+  secondary: '#8696a0',
+  link: '#53bdeb'
+};
+
+const THEME_STYLES = {
+  light: '',
+  dark: `
+    body, .chat-container, .chat-messages { background-color: ${DARK.background}; }
+    .chat-messages { background-image: none !important; }
+    .chat-header { background-color: ${DARK.header}; color: ${DARK.text}; }
+    .back-button { color: ${DARK.text}; }
+    .profile-pic { background-color: #6a7175; }
+    .message.sent .message-content { background-color: ${DARK.sent}; }
+    .message.received .message-content { background-color: ${DARK.received}; }
+    .message.sent .message-content:after { background-image: ${tail(SENT_TAIL, DARK.sent)}; }
+    .message.received .message-content:before { background-image: ${tail(RECEIVED_TAIL, DARK.received)}; }
+    .message p { color: ${DARK.text}; }
+    .message.sent .message-time, .message.received .message-time { color: rgba(233, 237, 239, 0.6); }
+    .message-link, .mention { color: ${DARK.link}; }
+    .message-quote { color: ${DARK.secondary}; }
+    .code-block { background-color: rgba(233, 237, 239, 0.06); }
+    .hl-k { color: #ff7b72; }
+    .hl-s { color: #a5d6ff; }
+    .hl-c { color: #8b949e; }
+    .hl-n, .hl-l { color: #79c0ff; }
+    .hl-f { color: #d2a8ff; }
+    .spoiler-hidden { background-color: rgba(233, 237, 239, 0.16); }
+    .spoiler-revealed { background-color: rgba(233, 237, 239, 0.08); }
+    .search-match { color: #111b21; }
+    .redacted-bar { background-color: ${DARK.text}; color: ${DARK.text}; }
+    .media-document { background-color: rgba(233, 237, 239, 0.06); }
+    .media-document-name, .media-location-label { color: ${DARK.text}; }
+    .media-location-address { color: ${DARK.secondary}; }
+    .media-document-details, .media-audio .media-duration { color: ${DARK.secondary}; }
+    .platform-ios .chat-header { background-color: ${DARK.headerIos}; border-bottom-color: #38383a; }
+    .platform-ios .back-button { color: #0a84ff; }
+    .input-bar { background-color: ${DARK.background}; border-top-color: ${DARK.border}; }
+    .input-field { background-color: ${DARK.received}; border-color: ${DARK.border}; color: ${DARK.secondary}; }
+    .input-draft { color: ${DARK.text}; }
+    .admin-only-note { background-color: ${DARK.header}; color: ${DARK.secondary}; }
+    .composer-reply { background-color: ${DARK.received}; border-top-color: ${DARK.border}; }
+    .composer-reply-quote { background-color: ${DARK.background}; }
+    .composer-reply-text { color: ${DARK.secondary}; }
+    .composer-tray, .composer-tray-item, .composer-tray-cancel { background-color: ${DARK.received}; color: ${DARK.text}; border-bottom-color: ${DARK.border}; }`
+};
+
+/**
+ * CSS for a theme, appended to the template's styles as {{themeStyle}}
+ * @param {string} [theme] - "light" or "dark"
+ * @returns {string} CSS rules, empty for the light theme
+ */
+function buildThemeStyle(theme = 'light') {
+  return THEME_STYLES[theme].trim();
+}
+
+module.exports = {
+  buildThemeStyle,
+  THEMES: Object.keys(THEME_STYLES)
+};
@@ -0,0 +1,48 @@
+// Typing bubble at the bottom of the chat (options.typingIndicator), for
+// conversations that are still in progress. The bubble is styled inline so
+// it works with any template; the dots pulse in animated output and in
+// previews, and screenshots catch them mid-pulse.
+
+const TYPING_STYLE = `
+  <style>
+    @keyframes typing-dot {
+      0%, 60%, 100% { opacity: 0.4; transform: translateY(0); }
+      30% { opacity: 1; transform: translateY(-3px); }
+    }
+    .typing-indicator .typing-dots { display: inline-flex; gap: 4px; padding: 12px 14px; }
+    .typing-indicator .typing-dots span {
+      width: 8px; height: 8px; border-radius: 50%;
+      background-color: #8696a0;
+      animation: typing-dot 1.2s infinite ease-in-out;
+    }
+    .typing-indicator .typing-dots span:nth-child(2) { animation-delay: 0.15s; }
+    .typing-indicator .typing-dots span:nth-child(3) { animation-delay: 0.3s; }
+  </style>`;
+
+/**
+ * Normalize options.typingIndicator
+ * @param {boolean|Object} [typingIndicator] - true, or { side }
+ * @returns {Object|null} { side }, or null when no indicator is shown
+ */
+function resolveTypingIndicator(typingIndicator) {
+  if (!typingIndicator) {
+    return null;
+  }
+  const { side = 'received' } = typingIndicator === true ? {} : typingIndicator;
+  return { side };
+}
+
+/**
+ * Typing bubble HTML: three pulsing dots on the side of whoever is typing
+ * @param {Object} indicator - { side } from resolveTypingIndicator
+ * @returns {string} HTML
+ */
+const renderTypingIndicator = ({ side }) => `${TYPING_STYLE}
+  <div class="message ${side} group-first group-last typing-indicator" aria-label="typing">
+    <div class="message-content typing-dots"><span></span><span></span><span></span></div>
+  </div>`;
+
+module.exports = {
+  resolveTypingIndicator,
+  renderTypingIndicator
+};
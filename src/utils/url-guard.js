@@ -0,0 +1,100 @@
+const dns = require('dns').promises;
+const net = require('net');
+const { ApiError } = require('../middleware/error.middleware');
+const { parseListEnv } = require('./env');
+
+/**
+ * Hosts that may be rendered or fetched, configured through
+ * URL_RENDER_ALLOWLIST (comma separated; "*.example.com" matches subdomains)
+ * @returns {Array<string>} Allowlist entries
+ */
+const getAllowlist = () => parseListEnv('URL_RENDER_ALLOWLIST', { lowercase: true });
+
+/**
+ * Whether a hostname matches the allowlist
+ * @param {string} hostname - Hostname to check
+ * @param {Array<string>} allowlist - Allowlist entries
+ * @returns {boolean} Whether the host is allowed
+ */
+function isHostAllowed(hostname, allowlist = getAllowlist()) {
+  const host = hostname.toLowerCase().replace(/^\[|\]$/g, '');
+  return allowlist.some(entry => {
+    if (entry.startsWith('*.')) {
+      return host.endsWith(entry.slice(1)) && host.length > entry.length - 1;
+    }
+    return host === entry;
+  });
+}
+
+/**
+ * Whether an IP address is private, loopback, link-local or otherwise internal
+ * @param {string} address - IPv4 or IPv6 address
+ * @returns {boolean} Whether the address is internal
+ */
+function isPrivateAddress(address) {
+  if (net.isIPv4(address)) {
+    const [a, b] = address.split('.').map(Number);
+    return a === 10 || a === 127 || a === 0 ||
+      (a === 169 && b === 254) ||
+      (a === 172 && b >= 16 && b <= 31) ||
+      (a === 192 && b === 168) ||
+      (a === 100 && b >= 64 && b <= 127) ||
+      a >= 224;
+  }
+
+  const lower = address.toLowerCase();
+  if (lower.startsWith('::ffff:')) {
+    return isPrivateAddress(lower.slice(7));
+  }
+  return lower === '::1' || lower === '::' || lower.startsWith('fc') || lower.startsWith('fd') || lower.startsWith('fe80');
+}
+
+/**
+ * Validate that a URL may be requested by the server: http(s) only, host on
+ * the allowlist, and not resolving to an internal address
+ * @param {string} rawUrl - URL to check
+ * @param {Object} options - Check options
+ * @param {boolean} options.resolve - Check the resolved addresses; skipped when
+ *   the request goes through a proxy, which does its own resolution
+ * @param {Array<string>} options.allowlist - Allowlist entries, URL_RENDER_ALLOWLIST by default
+ * @param {string} options.allowlistName - Name of the allowlist setting, for errors
+ * @returns {Promise<URL>} Parsed URL
+ */
+async function assertUrlAllowed(rawUrl, { resolve = true, allowlist = getAllowlist(), allowlistName = 'URL_RENDER_ALLOWLIST' } = {}) {
+  let url;
+  try {
+    url = new URL(rawUrl);
+  } catch (error) {
+    throw new ApiError(400, `Invalid URL: ${rawUrl}`).annotate({ stage: 'validate', code: 'invalid_url' });
+  }
+
+  if (url.protocol !== 'http:' && url.protocol !== 'https:') {
+    throw new ApiError(400, `URL scheme not allowed: ${url.protocol}`).annotate({ stage: 'validate', code: 'invalid_url' });
+  }
+  if (!isHostAllowed(url.hostname, allowlist)) {
+    throw new ApiError(403, `Host not in ${allowlistName}: ${url.hostname}`)
+      .annotate({ stage: 'validate', code: 'host_not_allowed' });
+  }
+  if (!resolve) {
+    return url;
+  }
+
+  const addresses = net.isIP(url.hostname)
+    ? [{ address: url.hostname }]
+    : await dns.lookup(url.hostname, { all: true }).catch(() => {
+      throw new ApiError(400, `Could not resolve host: ${url.hostname}`)
+        .annotate({ stage: 'validate', code: 'host_unresolvable' });
+    });
+  if (addresses.some(({ address }) => isPrivateAddress(address))) {
+    throw new ApiError(403, `Host resolves to an internal address: ${url.hostname}`)
+      .annotate({ stage: 'validate', code: 'host_not_allowed' });
+  }
+
+  return url;
+}
+
+module.exports = {
+  assertUrlAllowed,
+  isHostAllowed,
+  isPrivateAddress
+};
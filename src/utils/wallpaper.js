@@ -0,0 +1,105 @@
+const { ApiError } = require('../middleware/error.middleware');
+
+// Chat wallpaper (options.wallpaper): a stock doodle, a solid color or the
+// caller's own image behind the messages. Without it the template keeps its
+// own background.
+
+/**
+ * Doodle tile as a CSS url(): outlined chat icons scattered over 240px
+ * @param {string} ink - Stroke color, e.g. #d9d1c4
+ * @returns {string} CSS url() value
+ */
+const doodle = (ink) => `url("data:image/svg+xml,${encodeURIComponent(
+  `<svg xmlns='http://www.w3.org/2000/svg' width='240' height='240' viewBox='0 0 240 240' fill='none' stroke='${ink}' stroke-width='2' stroke-linecap='round' stroke-linejoin='round'>`
+  // Chat bubble
+  + '<path d=\'M18 20h40a8 8 0 0 1 8 8v18a8 8 0 0 1-8 8H34l-10 9v-9h-6a8 8 0 0 1-8-8V28a8 8 0 0 1 8-8z\'/>'
+  // Heart
+  + '<path d=\'M150 40c-6-10-22-8-22 4 0 10 22 22 22 22s22-12 22-22c0-12-16-14-22-4z\'/>'
+  // Phone handset
+  + '<path d=\'M206 112c-4 10-14 18-22 16-14-4-30-20-34-34-2-8 6-18 16-22l8 14-6 6c2 6 8 12 14 14l6-6z\'/>'
+  // Star
+  + '<path d=\'M60 112l7 14 15 2-11 11 3 15-14-7-14 7 3-15-11-11 15-2z\'/>'
+  // Camera
+  + '<rect x=\'100\' y=\'168\' width=\'44\' height=\'30\' rx=\'6\'/><circle cx=\'122\' cy=\'183\' r=\'8\'/><path d=\'M112 168l4-6h12l4 6\'/>'
+  // Music note
+  + '<path d=\'M28 218v-30l22-5v30\'/><circle cx=\'22\' cy=\'218\' r=\'6\'/><circle cx=\'44\' cy=\'213\' r=\'6\'/>'
+  // Clock
+  + '<circle cx=\'204\' cy=\'204\' r=\'16\'/><path d=\'M204 194v10l7 5\'/>'
+  // Scattered dots
+  + '<circle cx=\'110\' cy=\'110\' r=\'2\'/><circle cx=\'200\' cy=\'30\' r=\'2\'/><circle cx=\'80\' cy=\'70\' r=\'2\'/><circle cx=\'170\' cy=\'160\' r=\'2\'/>'
+  + '</svg>'
+)}")`;
+
+// Stock wallpapers: the doodle pattern of the light and dark app, and a plain
+// background without a pattern
+const STOCK_WALLPAPERS = {
+  doodle: { color: '#efeae2', image: doodle('#d9d1c4') },
+  'doodle-dark': { color: '#0b141a', image: doodle('#17232b') },
+  none: { color: '#efeae2', image: null }
+};
+
+const WALLPAPER_NAMES = ['default', ...Object.keys(STOCK_WALLPAPERS)];
+
+// Images end up inside CSS url(""), so only plain base64 data URLs are used
+const SAFE_IMAGE_URL = /^data:image\/[a-z0-9.+-]+;base64,[a-z0-9+/=]+$/i;
+
+/**
+ * Normalize options.wallpaper
+ * @param {string|Object} [wallpaper] - Stock name, #rrggbb color, image URL, or { image, size, dim }
+ * @returns {Object|null} { type: "stock", name } | { type: "color", color } | { type: "image", url, size, dim },
+ *   or null for the template's own background
+ */
+function resolveWallpaper(wallpaper) {
+  if (!wallpaper || wallpaper === 'default') {
+    return null;
+  }
+  if (typeof wallpaper === 'object') {
+    const { image, size = 'cover', dim = 0 } = wallpaper;
+    return { type: 'image', url: image, size, dim };
+  }
+  if (STOCK_WALLPAPERS[wallpaper]) {
+    return { type: 'stock', name: wallpaper };
+  }
+  if (wallpaper.startsWith('#')) {
+    return { type: 'color', color: wallpaper };
+  }
+  return { type: 'image', url: wallpaper, size: 'cover', dim: 0 };
+}
+
+/**
+ * CSS rules that put the wallpaper behind the messages. The rules are
+ * !important so they also replace the dark theme's plain background.
+ * @param {Object|null} resolved - Wallpaper from resolveWallpaper
+ * @param {string} [imageSrc] - Image wallpaper as a data URL (resolved by the caller)
+ * @returns {string} CSS, empty for the template's own background
+ */
+function buildWallpaperStyle(resolved, imageSrc) {
+  if (!resolved) {
+    return '';
+  }
+  if (resolved.type === 'color') {
+    return `.chat-messages { background-color: ${resolved.color} !important; background-image: none !important; }`;
+  }
+  if (resolved.type === 'stock') {
+    const { color, image } = STOCK_WALLPAPERS[resolved.name];
+    return `.chat-messages { background-color: ${color} !important; background-image: ${image || 'none'} !important;`
+      + ' background-size: 240px 240px !important; background-repeat: repeat !important; }';
+  }
+
+  if (!SAFE_IMAGE_URL.test(imageSrc || '')) {
+    throw new ApiError(400, 'options.wallpaper: the image must be a base64 image').annotate({ stage: 'validate', code: 'invalid_url' });
+  }
+  // A translucent black layer over the photo keeps bubbles readable
+  const dim = resolved.dim > 0 ? `linear-gradient(rgba(0, 0, 0, ${resolved.dim}), rgba(0, 0, 0, ${resolved.dim})), ` : '';
+  const sizing = resolved.size === 'tile'
+    ? 'background-size: auto !important; background-repeat: repeat !important;'
+    : 'background-size: cover !important; background-repeat: no-repeat !important; background-position: center !important;';
+  return `.chat-messages { background-color: #000 !important; background-image: ${dim}url("${imageSrc}") !important; ${sizing} }`;
+}
+
+module.exports = {
+  resolveWallpaper,
+  buildWallpaperStyle,
+  STOCK_WALLPAPERS,
+  WALLPAPER_NAMES
+};
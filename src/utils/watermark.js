@@ -0,0 +1,169 @@
+// Watermarks and branding footers drawn over rendered outputs
+const { escapeHTML } = require('./html-escape');
+const { parseJsonEnv } = require('./env');
+
+const WATERMARK_POSITIONS = ['top-left', 'top-right', 'bottom-left', 'bottom-right', 'center', 'footer'];
+
+// Defaults for watermarks from WATERMARK_POLICIES; request watermarks get
+// theirs from the validation schema
+const POLICY_DEFAULTS = { position: 'footer', opacity: 1 };
+
+/**
+ * Per-tenant watermark policies from WATERMARK_POLICIES, a JSON object mapping
+ * an API key to { text, position, opacity }. "anonymous" applies to requests
+ * without an API key and "*" to every tenant without its own entry.
+ * @returns {Object} API key -> policy
+ */
+const getWatermarkPolicies = () => parseJsonEnv('WATERMARK_POLICIES');
+
+/**
+ * Watermarks for one render: the tenant's enforced watermark, which requests
+ * cannot disable or change, followed by the one the request asked for
+ * @param {Object} [requested] - options.watermark { text, position, opacity }
+ * @param {string} [apiKey] - Caller's API key
+ * @returns {Array<Object>} Watermarks { text, position, opacity, enforced }
+ */
+function resolveWatermarks(requested, apiKey) {
+  const policies = getWatermarkPolicies();
+  const policy = (apiKey ? policies[apiKey] : policies.anonymous) || policies['*'];
+  const watermarks = [];
+
+  if (policy && policy.text) {
+    const position = WATERMARK_POSITIONS.includes(policy.position) ? policy.position : POLICY_DEFAULTS.position;
+    const opacity = Number(policy.opacity) > 0 ? Math.min(Number(policy.opacity), 1) : POLICY_DEFAULTS.opacity;
+    watermarks.push({ text: String(policy.text), position, opacity, enforced: true });
+  }
+  if (requested && requested.text && !watermarks.some(watermark => watermark.text === requested.text)) {
+    watermarks.push({ text: requested.text, position: requested.position, opacity: requested.opacity, enforced: false });
+  }
+  return watermarks;
+}
+
+/**
+ * Draw watermarks into the page (runs in the browser through page.evaluate).
+ * Styles are inline, so it works with sandboxed templates too. Without a
+ * region the watermarks are fixed to the viewport; a region pins them to
+ * "document" (the whole page), { selector } or an { x, y, width, height }
+ * clip. Footers reserve space at the end of the page, so they never cover
+ * the last message of a full-page capture; redrawing keeps that space, so
+ * measurements taken after the first draw stay valid.
+ * @param {Object} args - { watermarks, region }
+ */
+function drawWatermarks({ watermarks, region }) {
+  const lineHeight = 18;
+  const padding = 6;
+  document.querySelectorAll('[data-watermark="layer"]').forEach(node => node.remove());
+
+  const footerCount = watermarks.filter(watermark => watermark.position === 'footer').length;
+  if (footerCount > 0 && (!region || region === 'document') && !document.querySelector('[data-watermark="spacer"]')) {
+    const spacer = document.createElement('div');
+    spacer.setAttribute('data-watermark', 'spacer');
+    spacer.style.height = `${footerCount * lineHeight + 2 * padding}px`;
+    document.body.appendChild(spacer);
+  }
+
+  let rect = null;
+  if (region === 'document') {
+    const root = document.scrollingElement || document.documentElement;
+    rect = { x: 0, y: 0, width: root.scrollWidth, height: root.scrollHeight };
+  } else if (region && region.selector) {
+    const element = document.querySelector(region.selector);
+    const box = element && element.getBoundingClientRect();
+    rect = box && { x: box.left + window.scrollX, y: box.top + window.scrollY, width: box.width, height: box.height };
+  } else if (region) {
+    rect = region;
+  }
+
+  const layer = document.createElement('div');
+  layer.setAttribute('data-watermark', 'layer');
+  layer.setAttribute('aria-hidden', 'true');
+  layer.style.cssText = `${rect
+    ? `position:absolute;left:${rect.x}px;top:${rect.y}px;width:${rect.width}px;height:${rect.height}px;`
+    : 'position:fixed;left:0;top:0;right:0;bottom:0;'}z-index:2147483647;pointer-events:none;overflow:hidden;margin:0;padding:0;`;
+
+  const anchors = {
+    'top-left': 'top:8px;left:8px;align-items:flex-start;',
+    'top-right': 'top:8px;right:8px;align-items:flex-end;',
+    'bottom-left': 'bottom:8px;left:8px;align-items:flex-start;',
+    'bottom-right': 'bottom:8px;right:8px;align-items:flex-end;',
+    center: 'top:50%;left:50%;transform:translate(-50%,-50%);align-items:center;text-align:center;font-size:20px;',
+    footer: `left:0;right:0;bottom:0;align-items:center;padding:${padding}px 8px;background:rgba(17,27,33,0.85);`
+  };
+  const groups = {};
+  watermarks.forEach(watermark => {
+    groups[watermark.position] = groups[watermark.position] || [];
+    groups[watermark.position].push(watermark);
+  });
+
+  Object.keys(groups).forEach(position => {
+    const group = document.createElement('div');
+    group.style.cssText = 'position:absolute;display:flex;flex-direction:column;box-sizing:border-box;'
+      + `font:600 12px/${lineHeight}px -apple-system,"Segoe UI",Roboto,Helvetica,Arial,sans-serif;${anchors[position]}`;
+    groups[position].forEach(watermark => {
+      const line = document.createElement('div');
+      line.textContent = watermark.text;
+      line.style.cssText = position === 'footer'
+        ? `color:#ffffff;opacity:${watermark.opacity};white-space:nowrap;overflow:hidden;text-overflow:ellipsis;max-width:100%;`
+        : `color:#111b21;opacity:${watermark.opacity};text-shadow:0 0 3px #ffffff,0 0 3px #ffffff;`;
+      group.appendChild(line);
+    });
+    layer.appendChild(group);
+  });
+  document.documentElement.appendChild(layer);
+}
+
+/**
+ * Watermarks as static HTML, for pages served without a browser capture
+ * (previews, which run no scripts). The layer is fixed to the viewport like
+ * drawWatermarks without a region, and footers get the same spacer.
+ * @param {Array<Object>} watermarks - From resolveWatermarks
+ * @returns {string} HTML to append to the body
+ */
+function watermarksHTML(watermarks) {
+  if (watermarks.length === 0) {
+    return '';
+  }
+  const anchors = {
+    'top-left': 'top:8px;left:8px;align-items:flex-start;',
+    'top-right': 'top:8px;right:8px;align-items:flex-end;',
+    'bottom-left': 'bottom:8px;left:8px;align-items:flex-start;',
+    'bottom-right': 'bottom:8px;right:8px;align-items:flex-end;',
+    center: 'top:50%;left:50%;transform:translate(-50%,-50%);align-items:center;text-align:center;font-size:20px;',
+    footer: 'left:0;right:0;bottom:0;align-items:center;padding:6px 8px;background:rgba(17,27,33,0.85);'
+  };
+  const footerCount = watermarks.filter(watermark => watermark.position === 'footer').length;
+  const groups = Object.keys(anchors)
+    .map(position => [position, watermarks.filter(watermark => watermark.position === position)])
+    .filter(([, group]) => group.length > 0)
+    .map(([position, group]) => {
+      const lines = group.map(watermark => `<div style="${position === 'footer'
+        ? 'color:#ffffff;white-space:nowrap;overflow:hidden;text-overflow:ellipsis;max-width:100%;'
+        : 'color:#111b21;text-shadow:0 0 3px #ffffff,0 0 3px #ffffff;'}opacity:${Number(watermark.opacity)};">${escapeHTML(watermark.text)}</div>`);
+      return '<div style="position:absolute;display:flex;flex-direction:column;box-sizing:border-box;'
+        + `font:600 12px/18px -apple-system,'Segoe UI',Roboto,Helvetica,Arial,sans-serif;${anchors[position]}">${lines.join('')}</div>`;
+    });
+
+  return `${footerCount > 0 ? `<div data-watermark="spacer" style="height:${footerCount * 18 + 12}px"></div>` : ''}`
+    + '<div data-watermark="layer" aria-hidden="true" style="position:fixed;left:0;top:0;right:0;bottom:0;'
+    + `z-index:2147483647;pointer-events:none;overflow:hidden;margin:0;padding:0;">${groups.join('')}</div>`;
+}
+
+/**
+ * Draw watermarks into a Puppeteer page
+ * @param {Object} page - Puppeteer page
+ * @param {Array<Object>} watermarks - From resolveWatermarks
+ * @param {string|Object} [region] - See drawWatermarks
+ */
+async function applyWatermarks(page, watermarks, region = null) {
+  if (watermarks.length > 0) {
+    await page.evaluate(drawWatermarks, { watermarks, region });
+  }
+}
+
+module.exports = {
+  resolveWatermarks,
+  applyWatermarks,
+  drawWatermarks,
+  watermarksHTML,
+  WATERMARK_POSITIONS
+};
@@ -1,33 +1,84 @@
-function convertWhatsAppToHTML(message) {
+const { highlightCode } = require('./syntax-highlight');
+const { escapeHTML } = require('./html-escape');
+
+// Placeholders used to keep fenced code blocks out of the marker formatting
+const CODE_BLOCK_OPEN = '\uE010';
+const CODE_BLOCK_CLOSE = '\uE011';
+
+// Patterns are compiled once at load time; formatting runs for every message
+const FENCED_CODE_PATTERN = /```([\w+#-]*)\n([\s\S]*?)\n?```\n?/g;
+const CODE_BLOCK_PLACEHOLDER_PATTERN = new RegExp(`${CODE_BLOCK_OPEN}(\\d+)${CODE_BLOCK_CLOSE}`, 'g');
+const BOLD_PATTERN = /\*([^*\n]+)\*/g;
+const ITALIC_PATTERN = /_([^_\n]+)_/g;
+const MONOSPACE_PATTERN = /```([^`\n]+)```/g;
+const STRIKETHROUGH_PATTERN = /~([^~\n]+)~/g;
+const SPOILER_PATTERN = /\|\|([^|\n]+)\|\|/g;
+const NEWLINE_PATTERN = /\n/g;
+// Tags are kept and links dropped; each entity or other visible character
+// becomes one placeholder character
+const PLACEHOLDER_HTML_PATTERN = /(<\/?a\b[^>]*>)|(<[^>]*>)|&(?:#\d+|#x[\da-f]+|[a-z]+);|[^\s<&]/gi;
+// Quick check for any character that can start a marker
+const MARKER_CHARS_PATTERN = /[*_~`|]/;
+
+/**
+ * Convert WhatsApp formatting markers into HTML
+ * @param {string} message - Raw message content
+ * @param {Object} options - Formatting options
+ * @param {string} options.spoilers - Spoiler state: "hidden" (default) or "revealed"
+ * @returns {string} HTML content
+ */
+function convertWhatsAppToHTML(message, options = {}) {
     if (!message || typeof message !== 'string') {
       return '';
     }
+
+    // Fast path: nothing to format, only escaping and line breaks
+    if (!MARKER_CHARS_PATTERN.test(message)) {
+      return escapeHTML(message).replace(NEWLINE_PATTERN, '<br>');
+    }
+
+    const { spoilers = 'hidden' } = options;
   
     let html = message;
+
+    // Fenced code blocks: ```js\ncode\n``` -> highlighted block. A <span> is used
+    // instead of <pre> because the content ends up inside the bubble's <p>.
+    const codeBlocks = [];
+    if (html.includes('```')) {
+      html = html.replace(FENCED_CODE_PATTERN, (match, lang, code) => {
+        codeBlocks.push(`<span class="code-block"><code>${highlightCode(code, lang)}</code></span>`);
+        return `${CODE_BLOCK_OPEN}${codeBlocks.length - 1}${CODE_BLOCK_CLOSE}`;
+      });
+    }
     
     // Escape HTML characters first to prevent XSS
-    html = html
-      .replace(/&/g, '&amp;')
-      .replace(/</g, '&lt;')
-      .replace(/>/g, '&gt;')
-      .replace(/"/g, '&quot;')
-      .replace(/'/g, '&#39;');
+    html = escapeHTML(html);
     
     // Convert WhatsApp formatting to HTML
     // Bold: *text* -> <strong>text</strong>
-    html = html.replace(/\*([^*\n]+)\*/g, '<strong>$1</strong>');
+    html = html.replace(BOLD_PATTERN, '<strong>$1</strong>');
     
     // Italic: _text_ -> <em>text</em>
-    html = html.replace(/_([^_\n]+)_/g, '<em>$1</em>');
+    html = html.replace(ITALIC_PATTERN, '<em>$1</em>');
     
     // Monospace: ```text``` -> <code>text</code>
-    html = html.replace(/```([^`\n]+)```/g, '<code>$1</code>');
+    html = html.replace(MONOSPACE_PATTERN, '<code>$1</code>');
     
     // Strikethrough: ~text~ -> <del>text</del>
-    html = html.replace(/~([^~\n]+)~/g, '<del>$1</del>');
+    html = html.replace(STRIKETHROUGH_PATTERN, '<del>$1</del>');
+
+    // Spoiler: ||text|| -> <span class="spoiler">text</span>
+    // Hidden spoilers hold placeholder text, so outputs never contain the spoiler
+    html = html.replace(SPOILER_PATTERN, (match, text) =>
+      `<span class="spoiler spoiler-${spoilers}">${spoilers === 'hidden' ? convertToPlaceholderHTML(text) : text}</span>`);
     
     // Convert line breaks to <br> tags
-    html = html.replace(/\n/g, '<br>');
+    html = html.replace(NEWLINE_PATTERN, '<br>');
+
+    // Restore fenced code blocks
+    if (codeBlocks.length > 0) {
+      html = html.replace(CODE_BLOCK_PLACEHOLDER_PATTERN, (match, index) => codeBlocks[index]);
+    }
     
     return html;
   }
@@ -68,10 +119,41 @@ function convertWhatsAppToHTML(message) {
     return html;
   }
   
+  // Redacted version: every line becomes a black bar of roughly the same width,
+  // so the bubble keeps its shape without leaking any of the original text
+  function convertToRedactedHTML(message) {
+    if (!message || typeof message !== 'string') {
+      return '';
+    }
+
+    return message
+      .split('\n')
+      .map(line => {
+        const filler = line.replace(/\S/g, 'x').trim();
+        return filler ? `<span class="redacted-bar">${filler}</span>` : '';
+      })
+      .join('<br>');
+  }
+
+  // Placeholder text of the same shape: every visible character becomes an x,
+  // like the redaction filler. Blurred text is replaced by it, since a blur
+  // only hides text from the image, not from HTML outputs such as previews.
+  function convertToPlaceholderText(text) {
+    return typeof text === 'string' ? text.replace(/\S/g, 'x') : text;
+  }
+
+  // Placeholder version of formatted HTML, keeping the markup but not links
+  function convertToPlaceholderHTML(html) {
+    return html.replace(PLACEHOLDER_HTML_PATTERN, (match, link, tag) => (link ? '' : tag || 'x'));
+  }
+
 // Export the function
 module.exports = {
   convertWhatsAppToHTML,
-  convertWhatsAppToHTMLAdvanced
+  convertWhatsAppToHTMLAdvanced,
+  convertToRedactedHTML,
+  convertToPlaceholderText,
+  convertToPlaceholderHTML
 };
 
 // Usage examples:
@@ -0,0 +1,74 @@
+// Limits of the real WhatsApp apps and Business API, so mocks don't depict
+// conversations that can't happen (options.limits). Lengths are counted in
+// UTF-16 code units, like the apps count them.
+
+const WHATSAPP_LIMITS = {
+  // Text message body
+  message: 65536,
+  // Caption of an image, video or document
+  caption: 1024,
+  // Body of a Business API template message
+  templateBody: 1024,
+  // Profile (push) name
+  pushName: 25
+};
+
+// How options.limits treats a conversation beyond the limits
+const LIMIT_MODES = ['warn', 'error', 'off'];
+
+/**
+ * Which limit a message's content is held to
+ * @param {Object} msg - Message
+ * @returns {Object} { field, limit, label }
+ */
+function contentLimit(msg) {
+  if (msg.templateMessage) {
+    return { field: 'content', limit: WHATSAPP_LIMITS.templateBody, label: 'template body' };
+  }
+  if (msg.type && msg.type !== 'text') {
+    return { field: 'content', limit: WHATSAPP_LIMITS.caption, label: 'caption' };
+  }
+  return { field: 'content', limit: WHATSAPP_LIMITS.message, label: 'message' };
+}
+
+/**
+ * Find the messages that exceed WhatsApp's limits
+ * @param {Array<Object>} messages - Request messages
+ * @returns {Array<Object>} Problems { type, code, message, messageIndex, field, limit, length }, in message order
+ */
+function checkWhatsAppLimits(messages) {
+  const problems = [];
+  messages.forEach((msg, index) => {
+    const { field, limit, label } = contentLimit(msg);
+    const length = (msg.content || '').length;
+    if (length > limit) {
+      problems.push({
+        type: 'whatsapp_limit',
+        code: `${label.replace(' ', '_')}_too_long`,
+        message: `messages[${index}]: ${label} is ${length} characters, WhatsApp allows ${limit}`,
+        messageIndex: index,
+        field,
+        limit,
+        length
+      });
+    }
+    if (msg.pushName && msg.pushName.length > WHATSAPP_LIMITS.pushName) {
+      problems.push({
+        type: 'whatsapp_limit',
+        code: 'push_name_too_long',
+        message: `messages[${index}]: pushName is ${msg.pushName.length} characters, WhatsApp allows ${WHATSAPP_LIMITS.pushName}`,
+        messageIndex: index,
+        field: 'pushName',
+        limit: WHATSAPP_LIMITS.pushName,
+        length: msg.pushName.length
+      });
+    }
+  });
+  return problems;
+}
+
+module.exports = {
+  checkWhatsAppLimits,
+  WHATSAPP_LIMITS,
+  LIMIT_MODES
+};
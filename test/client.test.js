@@ -0,0 +1,100 @@
+const { test } = require('node:test');
+const assert = require('node:assert');
+const http = require('http');
+const { createClient, ClientError, decodeImage } = require('../client');
+const { createTestServer } = require('../src/testing/test-server');
+
+// Contract tests of the Node.js client against the in-process test server
+
+const MESSAGES = [
+  { timestamp: '2025-01-01T09:00:00Z', sender: 'Customer', recipient_name: 'Support', content: 'Hello' },
+  { timestamp: '2025-01-01T09:00:05Z', sender: 'Bot', content: 'Hi, how can I help?' }
+];
+
+/**
+ * Start a server that counts requests and never answers them
+ * @returns {Promise<Object>} { url, count, close }
+ */
+async function startHangingServer() {
+  const sockets = new Set();
+  const hanging = { count: 0 };
+  const server = http.createServer(() => {
+    hanging.count += 1;
+  });
+  server.on('connection', socket => {
+    sockets.add(socket);
+    socket.on('close', () => sockets.delete(socket));
+  });
+  await new Promise(resolve => server.listen(0, '127.0.0.1', resolve));
+  hanging.url = `http://127.0.0.1:${server.address().port}`;
+  hanging.close = () => {
+    sockets.forEach(socket => socket.destroy());
+    return new Promise(resolve => server.close(resolve));
+  };
+  return hanging;
+}
+
+test('renders a screenshot and downloads it by hash', async () => {
+  const api = await createTestServer();
+  try {
+    const client = createClient(api.url, api.apiKey);
+    const data = await client.screenshot({ messages: MESSAGES });
+    assert.match(data.metadata.sha256, /^[a-f0-9]{64}$/);
+
+    const file = await client.getFile(data.metadata.sha256);
+    assert.strictEqual(file.contentType, 'image/png');
+    assert.deepStrictEqual(file.buffer, decodeImage(data.image));
+  } finally {
+    await api.close();
+  }
+});
+
+test('queues a screenshot job and waits for its result', async () => {
+  const api = await createTestServer();
+  try {
+    const client = createClient(api.url, api.apiKey);
+    const job = await client.screenshotAsync({ messages: MESSAGES });
+    const result = await client.waitForJob(job.id, { intervalMs: 20 });
+    assert.match(result.image, /^data:image\/png;base64,/);
+  } finally {
+    await api.close();
+  }
+});
+
+test('rejects with the server error as a ClientError', async () => {
+  const api = await createTestServer();
+  try {
+    const client = createClient(api.url, api.apiKey);
+    await assert.rejects(client.screenshot({ messages: [] }), error => {
+      assert.ok(error instanceof ClientError);
+      assert.strictEqual(error.statusCode, 400);
+      assert.strictEqual(error.retryable, false);
+      assert.ok(error.requestId);
+      return true;
+    });
+  } finally {
+    await api.close();
+  }
+});
+
+test('does not retry a request with side effects after a timeout', async () => {
+  const hanging = await startHangingServer();
+  try {
+    const client = createClient(hanging.url, 'key', { retries: 2, backoffMs: 1, timeoutMs: 100 });
+    await assert.rejects(client.screenshotAsync({ messages: MESSAGES }), { code: 'client_timeout', retryable: false });
+    assert.strictEqual(hanging.count, 1);
+  } finally {
+    await hanging.close();
+  }
+});
+
+test('retries an idempotent request after a timeout', async () => {
+  const hanging = await startHangingServer();
+  try {
+    const client = createClient(hanging.url, 'key', { retries: 2, backoffMs: 1, timeoutMs: 100 });
+    await assert.rejects(client.getJob('job'), { code: 'client_timeout', retryable: true });
+    assert.strictEqual(hanging.count, 3);
+  } finally {
+    await hanging.close();
+  }
+});
@@ -0,0 +1,36 @@
+const { test } = require('node:test');
+const assert = require('node:assert');
+const crypto = require('crypto');
+const { createTestServer } = require('../src/testing/test-server');
+
+// Smoke test of the in-process test server: the mock renderer renders a
+// chat, and the stored output reads back by its content hash
+
+test('renders a screenshot and serves it by hash', async () => {
+  const api = await createTestServer();
+  try {
+    const response = await fetch(`${api.url}/api/whatsapp-screenshot`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json', 'X-API-Key': api.apiKey },
+      body: JSON.stringify({
+        messages: [
+          { timestamp: '2025-01-01T09:00:00Z', sender: 'Customer', recipient_name: 'Support', content: 'Hello' },
+          { timestamp: '2025-01-01T09:00:05Z', sender: 'Bot', content: 'Hi, how can I help?' }
+        ]
+      })
+    });
+    assert.strictEqual(response.status, 200);
+    const { data } = await response.json();
+    assert.match(data.image, /^data:image\/png;base64,/);
+    assert.match(data.metadata.sha256, /^[a-f0-9]{64}$/);
+    assert.strictEqual(data.metadata.file_url, `/api/files/by-hash/${data.metadata.sha256}`);
+
+    const file = await fetch(`${api.url}${data.metadata.file_url}`);
+    assert.strictEqual(file.status, 200);
+    assert.strictEqual(file.headers.get('content-type'), 'image/png');
+    const bytes = Buffer.from(await file.arrayBuffer());
+    assert.strictEqual(crypto.createHash('sha256').update(bytes).digest('hex'), data.metadata.sha256);
+  } finally {
+    await api.close();
+  }
+});